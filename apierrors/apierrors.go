@@ -0,0 +1,174 @@
+// Package apierrors is the error model of the API. Handlers return an *Error and the ErrorHandler
+// installed in main renders it as {"error": message, "code": code, "details": [...], "request_id": id}.
+package apierrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	CodeBadRequest         = "BAD_REQUEST"
+	CodeValidation         = "VALIDATION_FAILED"
+	CodeUnauthorized       = "UNAUTHORIZED"
+	CodeForbidden          = "FORBIDDEN"
+	CodeNotFound           = "NOT_FOUND"
+	CodeConflict           = "CONFLICT"
+	CodePreconditionFailed = "PRECONDITION_FAILED"
+	CodeTooManyRequests    = "TOO_MANY_REQUESTS"
+	CodeInternal           = "INTERNAL"
+	CodeTimeout            = "TIMEOUT"
+	CodeCanceled           = "CANCELED"
+)
+
+// StatusClientClosedRequest is sent for requests abandoned by the client. Nobody reads it, but it
+// keeps them apart from server errors in the access log and metrics.
+const StatusClientClosedRequest = 499
+
+// FieldError describes what is wrong with a single request field, named as in the JSON body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type Error struct {
+	Status  int          `json:"-"`
+	Code    string       `json:"code"`
+	Message string       `json:"error"`
+	Details []FieldError `json:"details,omitempty"`
+	// Request_id is filled in by the ErrorHandler so clients can quote it when reporting a problem.
+	Request_id string `json:"request_id,omitempty"`
+	// Cause is logged by the ErrorHandler but never sent to the client.
+	Cause error `json:"-"`
+	// RetryAfter, when set, is sent in the Retry-After header.
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Cause)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+func New(status int, code string, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, CodeConflict, message)
+}
+
+func PreconditionFailed(message string) *Error {
+	return New(http.StatusPreconditionFailed, CodePreconditionFailed, message)
+}
+
+// TooManyRequests tells the client to wait retryAfter before trying again.
+func TooManyRequests(message string, retryAfter time.Duration) *Error {
+	e := New(http.StatusTooManyRequests, CodeTooManyRequests, message)
+	e.RetryAfter = retryAfter
+	return e
+}
+
+// Internal reports a server side failure. The message is shown to the client, cause only ends up in the log.
+func Internal(message string, cause error) *Error {
+	e := New(http.StatusInternalServerError, CodeInternal, message)
+	e.Cause = cause
+	return e
+}
+
+// Timeout reports that the request's deadline passed before it was done.
+func Timeout(message string, cause error) *Error {
+	e := New(http.StatusGatewayTimeout, CodeTimeout, message)
+	e.Cause = cause
+	return e
+}
+
+// ErrorHandler renders every error returned by a handler. Errors that are not an *Error become a
+// 500 without leaking their text, apart from Fiber's own errors such as 404 for unknown routes.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var apiErr *Error
+	var fiberErr *fiber.Error
+
+	switch {
+	case errors.As(err, &apiErr):
+	case errors.As(err, &fiberErr):
+		apiErr = New(fiberErr.Code, codeForStatus(fiberErr.Code), fiberErr.Message)
+	default:
+		apiErr = Internal("internal server error", err)
+	}
+	// a failure caused by the request's own context ending is reported as such, not as a server error
+	if apiErr.Status >= http.StatusInternalServerError {
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			apiErr = Timeout("the request took too long", err)
+		case errors.Is(err, context.Canceled):
+			apiErr = New(StatusClientClosedRequest, CodeCanceled, "the request was canceled")
+		}
+	}
+
+	if apiErr.Status >= http.StatusInternalServerError {
+		logger.From(c).Error("request failed", "method", c.Method(), "path", c.Path(), "error", apiErr.Error())
+	}
+
+	// copied, so errors kept in package variables are not modified
+	response := *apiErr
+	response.Request_id = logger.RequestID(c)
+	if apiErr.RetryAfter > 0 {
+		// whole seconds, rounded up so clients don't come back too early
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(apiErr.RetryAfter.Seconds()))))
+	}
+	return c.Status(apiErr.Status).JSON(response)
+}
+
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusPreconditionFailed:
+		return CodePreconditionFailed
+	case http.StatusTooManyRequests:
+		return CodeTooManyRequests
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
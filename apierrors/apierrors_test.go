@@ -0,0 +1,76 @@
+package apierrors
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type pack struct {
+	Table_id *string `json:"table_id" validate:"required"`
+	Items    []item  `json:"order_items" validate:"required,min=1,dive"`
+}
+
+type item struct {
+	Quantity *string `json:"quantity" validate:"required,eq=S|eq=M|eq=L"`
+	Name     string  `json:"name" validate:"omitempty,min=3"`
+}
+
+func TestValidationNamesJSONFields(t *testing.T) {
+	xl, short := "XL", "ab"
+	err := NewValidator().Struct(pack{Items: []item{{Quantity: &short}, {Quantity: &xl, Name: short}}})
+
+	got := Validation(err)
+	if got.Status != http.StatusBadRequest || got.Code != CodeValidation {
+		t.Fatalf("Validation() = %d %s, want 400 %s", got.Status, got.Code, CodeValidation)
+	}
+
+	want := []FieldError{
+		{"table_id", "is required"},
+		{"order_items[0].quantity", "must be one of S, M, L"},
+		{"order_items[1].quantity", "must be one of S, M, L"},
+		{"order_items[1].name", "must be at least 3 characters long"},
+	}
+	if len(got.Details) != len(want) {
+		t.Fatalf("details = %+v, want %+v", got.Details, want)
+	}
+	for i := range want {
+		if got.Details[i] != want[i] {
+			t.Errorf("details[%d] = %+v, want %+v", i, got.Details[i], want[i])
+		}
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/missing", func(c *fiber.Ctx) error { return NotFound("food was not found") })
+	app.Get("/broken", func(c *fiber.Ctx) error { return errors.New("connection refused") })
+
+	tests := []struct {
+		path    string
+		status  int
+		code    string
+		message string
+	}{
+		{"/missing", http.StatusNotFound, CodeNotFound, "food was not found"},
+		{"/broken", http.StatusInternalServerError, CodeInternal, "internal server error"},
+		{"/unknown", http.StatusNotFound, CodeNotFound, "Cannot GET /unknown"},
+	}
+
+	for _, tt := range tests {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var body map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&body)
+		if resp.StatusCode != tt.status || body["code"] != tt.code || body["error"] != tt.message {
+			t.Errorf("GET %s = %d %v, want %d %s %q", tt.path, resp.StatusCode, body, tt.status, tt.code, tt.message)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package apierrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// NewValidator returns a validator that names fields after their json tag, so validation errors
+// refer to the fields the client actually sent.
+func NewValidator() *validator.Validate {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return strings.ToLower(field.Name)
+		}
+		return name
+	})
+	return validate
+}
+
+// Validation turns the error of validate.Struct into a 400 with one detail per invalid field.
+func Validation(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return BadRequest(err.Error())
+	}
+
+	e := New(http.StatusBadRequest, CodeValidation, "request validation failed")
+	for _, fieldErr := range validationErrs {
+		e.Details = append(e.Details, FieldError{
+			Field:   fieldPath(fieldErr),
+			Message: fieldMessage(fieldErr),
+		})
+	}
+	return e
+}
+
+// fieldPath drops the struct name from the namespace, e.g. OrderItemPack.order_items[1].quantity
+// becomes order_items[1].quantity.
+func fieldPath(fieldErr validator.FieldError) string {
+	namespace := fieldErr.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func fieldMessage(fieldErr validator.FieldError) string {
+	tag := fieldErr.Tag()
+	param := fieldErr.Param()
+
+	if strings.Contains(tag, "|") {
+		var allowed []string
+		for _, alternative := range strings.Split(tag, "|") {
+			value := strings.TrimPrefix(alternative, "eq=")
+			if value == "" {
+				value = `""`
+			}
+			allowed = append(allowed, value)
+		}
+		return "must be one of " + strings.Join(allowed, ", ")
+	}
+
+	switch tag {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "eq":
+		return "must be " + param
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(param), ", ")
+	case "datetime":
+		return "must be a time formatted as " + param
+	case "min", "gte":
+		if isCollection(fieldErr) {
+			return "must contain at least " + param + " items"
+		}
+		return "must be at least " + sizeOf(fieldErr, param)
+	case "max", "lte":
+		if isCollection(fieldErr) {
+			return "must contain at most " + param + " items"
+		}
+		return "must be at most " + sizeOf(fieldErr, param)
+	case "gt":
+		return "must be greater than " + sizeOf(fieldErr, param)
+	case "lt":
+		return "must be less than " + sizeOf(fieldErr, param)
+	case "len":
+		return "must be exactly " + sizeOf(fieldErr, param)
+	}
+	return fmt.Sprintf("failed the %s check", tag)
+}
+
+// sizeOf words a size parameter for the kind of field it limits.
+func sizeOf(fieldErr validator.FieldError, param string) string {
+	switch {
+	case fieldErr.Kind() == reflect.String:
+		return param + " characters long"
+	case isCollection(fieldErr):
+		return param + " items"
+	}
+	return param
+}
+
+func isCollection(fieldErr validator.FieldError) bool {
+	switch fieldErr.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+// Package app assembles the HTTP server: the middleware stack and every route, in the order the
+// authentication middleware depends on.
+package app
+
+import (
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/database"
+	"github.com/mayankr5/v1/restaurant-management/docs"
+	"github.com/mayankr5/v1/restaurant-management/mailer"
+	"github.com/mayankr5/v1/restaurant-management/metrics"
+	"github.com/mayankr5/v1/restaurant-management/middleware"
+	"github.com/mayankr5/v1/restaurant-management/repositories"
+	"github.com/mayankr5/v1/restaurant-management/routes"
+	"github.com/mayankr5/v1/restaurant-management/services"
+	"github.com/mayankr5/v1/restaurant-management/signing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Deps are what the app serves from.
+type Deps struct {
+	// Store keeps the documents of the app.
+	Store database.Store
+	// Mailer sends the emails of the app. When nil, emails are dropped with a warning.
+	Mailer mailer.Mailer
+	// Keys sign and verify the tokens. When nil, the app signs with a key of its own, and its
+	// tokens stop working when it is rebuilt.
+	Keys *signing.KeySet
+}
+
+// New builds the app. Building one connects to nothing and changes no package state besides the
+// process-wide metrics, so several apps with their own stores can run side by side.
+func New(cfg Config, deps Deps) *fiber.App {
+	defaults := DefaultConfig()
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = defaults.RequestTimeout
+	}
+	if cfg.IdempotencyTTL <= 0 {
+		cfg.IdempotencyTTL = defaults.IdempotencyTTL
+	}
+
+	if deps.Keys == nil {
+		keys, err := signing.Generate(signing.DefaultIssuer, signing.DefaultIssuer)
+		if err != nil {
+			// only fails when the system has no randomness to read
+			panic(err)
+		}
+		deps.Keys = keys
+	}
+
+	repos := repositories.New(deps.Store)
+	svc := services.New(services.Deps{Repos: repos, Mailer: deps.Mailer, Keys: deps.Keys, Login: cfg.Login, Location: cfg.Location})
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: apierrors.ErrorHandler,
+	})
+
+	app.Use(middleware.RequestID())
+	app.Use(middleware.RequestContext(cfg.RequestTimeout))
+	app.Use(metrics.Middleware())
+	app.Use(middleware.AccessLog())
+	app.Use(middleware.Recover())
+
+	// probes, docs, metrics and the public keys are served without a token
+	routes.HealthRoutes(app, deps.Store)
+	routes.KeyRoutes(app, deps.Keys)
+	docs.DocsRoutes(app)
+	routes.MetricsRoutes(app, svc.Orders)
+
+	app.Use(middleware.Authentication(deps.Keys, svc.Users, svc.Terminals))
+	app.Use(middleware.Idempotency(cfg.IdempotencyTTL, repos.Idempotency))
+
+	routes.Register(app, svc)
+
+	return app
+}
@@ -0,0 +1,834 @@
+package app_test
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/app"
+	"github.com/mayankr5/v1/restaurant-management/apptest"
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	"github.com/mayankr5/v1/restaurant-management/signing"
+	"github.com/mayankr5/v1/restaurant-management/totp"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// env is what a case runs against: an app seeded with the demo restaurant, a waiter, a manager,
+// and the ids of whatever the case's setup created.
+type env struct {
+	h       *apptest.Harness
+	staff   *apptest.User
+	manager *apptest.User
+	ids     map[string]string
+}
+
+func newEnv(t *testing.T) *env {
+	h := apptest.New(t)
+	h.SeedFile("../fixtures/demo.yaml")
+	return &env{h: h, staff: h.User("STAFF"), manager: h.User("MANAGER"), ids: map[string]string{}}
+}
+
+// expand replaces {name} in path with the id of that name; {staff} is the waiter's user id.
+func (e *env) expand(path string) string {
+	path = strings.ReplaceAll(path, "{staff}", e.staff.User_id)
+	for name, id := range e.ids {
+		path = strings.ReplaceAll(path, "{"+name+"}", id)
+	}
+	return path
+}
+
+// do sends a request as the waiter and fails the test unless it succeeds.
+func (e *env) do(t *testing.T, method string, path string, body interface{}) *apptest.Response {
+	t.Helper()
+	resp := e.h.Do(method, e.expand(path), body, e.staff)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("%s %s: status %d: %s", method, path, resp.Status, resp.Body)
+	}
+	return resp
+}
+
+// create sends a create request and remembers the inserted id under name.
+func (e *env) create(t *testing.T, name string, path string, body interface{}) {
+	t.Helper()
+	var result struct{ InsertedID string }
+	e.do(t, http.MethodPost, path, body).JSON(t, &result)
+	e.ids[name] = result.InsertedID
+}
+
+type handlerCase struct {
+	name   string
+	method string
+	// path may refer to ids with {name}, see env.expand
+	path  string
+	setup func(t *testing.T, e *env)
+	body  func(e *env) interface{}
+	// anonymous sends no token and manager sends the manager's; every other case is sent as the waiter
+	anonymous bool
+	manager   bool
+	status    int
+	check     func(t *testing.T, e *env, resp *apptest.Response)
+}
+
+func body(v interface{}) func(*env) interface{} {
+	return func(*env) interface{} { return v }
+}
+
+// managerApproval is a body signed off by the manager, for voids and refunds.
+func managerApproval(reason string, extra map[string]interface{}) func(*env) interface{} {
+	return func(e *env) interface{} {
+		b := map[string]interface{}{
+			"reason": reason, "manager_email": e.manager.Email, "manager_password": e.manager.Password,
+		}
+		for k, v := range extra {
+			b[k] = v
+		}
+		return b
+	}
+}
+
+// deleted has the manager delete the resource at path first, for the restore cases.
+func deleted(path string) func(*testing.T, *env) {
+	return func(t *testing.T, e *env) {
+		t.Helper()
+		if resp := e.h.Do(http.MethodDelete, e.expand(path), nil, e.manager); resp.Status != http.StatusOK {
+			t.Fatalf("DELETE %s: status %d: %s", path, resp.Status, resp.Body)
+		}
+	}
+}
+
+// field checks a field of the response.
+func field(key string, want interface{}) func(*testing.T, *env, *apptest.Response) {
+	return func(t *testing.T, _ *env, resp *apptest.Response) {
+		if got := resp.Map(t)[key]; got != want {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+}
+
+// stored checks a field of the resource at path, for the writes that don't return what they wrote.
+func stored(path string, key string, want interface{}) func(*testing.T, *env, *apptest.Response) {
+	return func(t *testing.T, e *env, _ *apptest.Response) {
+		if got := e.do(t, http.MethodGet, path, nil).Map(t)[key]; got != want {
+			t.Errorf("%s of %s = %v, want %v", key, path, got, want)
+		}
+	}
+}
+
+// archived checks that the resource at path is flagged as deleted. It can still be fetched, so
+// that orders and invoices referring to it keep resolving.
+func archived(path string) func(*testing.T, *env, *apptest.Response) {
+	return func(t *testing.T, e *env, _ *apptest.Response) {
+		if e.do(t, http.MethodGet, path, nil).Map(t)["deleted_at"] == nil {
+			t.Errorf("%s has no deleted_at after deleting it", path)
+		}
+	}
+}
+
+// staffLogin is the waiter's email and password.
+func staffLogin(e *env) interface{} {
+	return map[string]string{"email": e.staff.Email, "password": e.staff.Password}
+}
+
+// failedLogins tries to log in as the waiter n times with a wrong password.
+func failedLogins(n int) func(*testing.T, *env) {
+	return func(t *testing.T, e *env) {
+		for i := 0; i < n; i++ {
+			resp := e.h.Do(http.MethodPost, "/users/login", map[string]string{"email": e.staff.Email, "password": "nope"}, nil)
+			if resp.Status != http.StatusUnauthorized {
+				t.Fatalf("failed login %d: status %d, want 401: %s", i+1, resp.Status, resp.Body)
+			}
+		}
+	}
+}
+
+// mailedToken returns the token in the last email sent to address.
+func mailedToken(t *testing.T, e *env, address string) string {
+	t.Helper()
+	sent := e.h.Mail.To(address)
+	if len(sent) == 0 {
+		t.Fatalf("no email was sent to %s", address)
+	}
+	token := mailToken.FindString(sent[len(sent)-1].Body)
+	if token == "" {
+		t.Fatalf("no token in %q", sent[len(sent)-1].Body)
+	}
+	return token
+}
+
+var mailToken = regexp.MustCompile(`(?m)^[A-Za-z0-9_-]{43}$`)
+
+func verificationToken(t *testing.T, e *env) {
+	e.do(t, http.MethodPost, "/users/verification", nil)
+	e.ids["token"] = mailedToken(t, e, e.staff.Email)
+}
+
+func resetToken(t *testing.T, e *env) {
+	resp := e.h.Do(http.MethodPost, "/users/password-reset", map[string]string{"email": e.staff.Email}, nil)
+	if resp.Status != http.StatusAccepted {
+		t.Fatalf("requesting a reset: status %d: %s", resp.Status, resp.Body)
+	}
+	e.ids["token"] = mailedToken(t, e, e.staff.Email)
+}
+
+// tokenBody sends the token of the setup along with extra.
+func tokenBody(extra map[string]string) func(*env) interface{} {
+	return func(e *env) interface{} {
+		b := map[string]string{"token": e.ids["token"]}
+		for k, v := range extra {
+			b[k] = v
+		}
+		return b
+	}
+}
+
+// terminal registers a terminal as the manager, remembered as {terminal} with its key.
+func terminal(t *testing.T, e *env) {
+	resp := e.h.Do(http.MethodPost, "/terminals", map[string]string{"name": "Front till"}, e.manager)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("registering a terminal: status %d: %s", resp.Status, resp.Body)
+	}
+	var registered dto.TerminalRegistrationResponse
+	resp.JSON(t, &registered)
+	e.ids["terminal"] = registered.Terminal_id
+	e.ids["terminal_key"] = registered.Terminal_key
+}
+
+// terminalWithPins registers a terminal; the waiter's PIN is 4321, the manager's 8765.
+func terminalWithPins(t *testing.T, e *env) {
+	terminal(t, e)
+	for user, pin := range map[*apptest.User]string{e.staff: "4321", e.manager: "8765"} {
+		resp := e.h.Do(http.MethodPost, "/users/pin", map[string]string{"password": user.Password, "pin": pin}, user)
+		if resp.Status != http.StatusOK {
+			t.Fatalf("setting a PIN: status %d: %s", resp.Status, resp.Body)
+		}
+	}
+}
+
+// terminalSession signs the waiter in on a terminal. Their token is the terminal session's from then on.
+func terminalSession(t *testing.T, e *env) {
+	terminalWithPins(t, e)
+	resp := e.h.Do(http.MethodPost, "/terminals/login", pinLogin("4321")(e), nil)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("terminal login: status %d: %s", resp.Status, resp.Body)
+	}
+	var login dto.TerminalLoginResponse
+	resp.JSON(t, &login)
+	e.staff.Token = login.Token
+}
+
+// pinLogin signs the waiter in on the setup's terminal with pin.
+func pinLogin(pin string) func(*env) interface{} {
+	return func(e *env) interface{} {
+		return map[string]string{"terminal_id": e.ids["terminal"], "terminal_key": e.ids["terminal_key"], "user_id": e.staff.User_id, "pin": pin}
+	}
+}
+
+func managerPinLogin(e *env) interface{} {
+	return map[string]string{"terminal_id": e.ids["terminal"], "terminal_key": e.ids["terminal_key"], "user_id": e.manager.User_id, "pin": "8765"}
+}
+
+// mfaEnrolled starts the waiter's MFA enrollment, remembering the secret as {mfa_secret}.
+func mfaEnrolled(t *testing.T, e *env) {
+	var enrollment dto.MfaEnrollmentResponse
+	e.do(t, http.MethodPost, "/users/mfa", map[string]string{"password": e.staff.Password}).JSON(t, &enrollment)
+	e.ids["mfa_secret"] = enrollment.Secret
+}
+
+// mfaEnabled enables MFA for the waiter, remembering a recovery code as {recovery_code}.
+func mfaEnabled(t *testing.T, e *env) {
+	mfaEnrolled(t, e)
+	var codes dto.RecoveryCodesResponse
+	e.do(t, http.MethodPost, "/users/mfa/confirm", map[string]string{"code": mfaCode(e, 0)}).JSON(t, &codes)
+	e.ids["recovery_code"] = codes.Recovery_codes[0]
+}
+
+// mfaChallenge enables MFA for the waiter and logs in with their password, remembering the
+// challenge as {mfa_token}.
+func mfaChallenge(t *testing.T, e *env) {
+	mfaEnabled(t, e)
+	resp := e.h.Do(http.MethodPost, "/users/login", staffLogin(e), nil)
+	var login dto.LoginResponse
+	resp.JSON(t, &login)
+	if resp.Status != http.StatusOK || login.Mfa == nil {
+		t.Fatalf("login with MFA: status %d: %s", resp.Status, resp.Body)
+	}
+	e.ids["mfa_token"] = login.Mfa.Mfa_token
+}
+
+// mfaCode is the waiter's code for the current time step plus ahead. Each code is only accepted
+// once, so a case sending a code after its setup sent one sends the next step's.
+func mfaCode(e *env, ahead int64) string {
+	code, _ := totp.Code(e.ids["mfa_secret"], totp.Step(time.Now())+ahead)
+	return code
+}
+
+// mfaLogin answers the setup's challenge with code, or the next step's code when it is empty.
+func mfaLogin(code string) func(*env) interface{} {
+	return func(e *env) interface{} {
+		if code == "" {
+			code = mfaCode(e, 1)
+		}
+		return map[string]string{"mfa_token": e.ids["mfa_token"], "code": e.expand(code)}
+	}
+}
+
+func promotion(t *testing.T, e *env) {
+	e.create(t, "promotion", "/promotions",
+		map[string]interface{}{"name": "Pizza night", "type": "PERCENTAGE", "value": 10, "menu_id": "pizza"})
+}
+
+func coupon(t *testing.T, e *env) {
+	promotion(t, e)
+	e.create(t, "coupon", "/coupons", map[string]string{"code": "pizza10", "promotion_id": e.ids["promotion"]})
+}
+
+var handlerCases = []handlerCase{
+	// probes, docs and metrics
+	{name: "healthz", method: "GET", path: "/healthz", anonymous: true, status: 200},
+	{name: "readyz", method: "GET", path: "/readyz", anonymous: true, status: 200},
+	{name: "metrics", method: "GET", path: "/metrics", anonymous: true, status: 200},
+	{name: "openapi", method: "GET", path: "/openapi.json", anonymous: true, status: 200},
+	{name: "docs", method: "GET", path: "/docs", anonymous: true, status: 200},
+	{name: "jwks", method: "GET", path: "/.well-known/jwks.json", anonymous: true, status: 200, check: checkJWKS},
+	{name: "token required", method: "GET", path: "/foods", anonymous: true, status: 401},
+
+	// users
+	{name: "login", method: "POST", path: "/users/login", anonymous: true, status: 200, body: staffLogin, check: checkLoginTokens},
+	{name: "login with a wrong password", method: "POST", path: "/users/login", anonymous: true, status: 401,
+		body: func(e *env) interface{} { return map[string]string{"email": e.staff.Email, "password": "nope"} }},
+	{name: "login after repeated failures", method: "POST", path: "/users/login", anonymous: true, status: 429,
+		setup: failedLogins(3), body: staffLogin, check: checkRetryAfter},
+	{name: "unlock user", method: "POST", path: "/users/{staff}/unlock", manager: true, status: 200,
+		setup: failedLogins(3), check: checkCanLogIn},
+	{name: "unlock user as staff", method: "POST", path: "/users/{staff}/unlock", status: 403, setup: failedLogins(3)},
+	{name: "signup", method: "POST", path: "/users/signup", status: 200,
+		body: body(map[string]string{"first_name": "Nina", "last_name": "Greco", "password": "secret-pass",
+			"email": "nina@trattoria.test", "phone": "+15550100099"})},
+	{name: "signup with a role as manager", method: "POST", path: "/users/signup", manager: true, status: 403,
+		body: body(map[string]string{"first_name": "Nina", "last_name": "Greco", "password": "secret-pass",
+			"email": "nina@trattoria.test", "phone": "+15550100099", "role": "MANAGER"})},
+	{name: "signup invalid", method: "POST", path: "/users/signup", status: 400,
+		body: body(map[string]string{"first_name": "N", "email": "not-an-email"})},
+	{name: "list users", method: "GET", path: "/users", status: 200},
+	{name: "get user", method: "GET", path: "/users/{staff}", status: 200},
+	{name: "delete user as staff", method: "DELETE", path: "/users/{staff}", status: 403},
+	{name: "token of a deleted user", method: "GET", path: "/foods", status: 401, setup: deleted("/users/{staff}")},
+	{name: "delete user", method: "DELETE", path: "/users/{staff}", manager: true, status: 200},
+	{name: "restore user", method: "POST", path: "/users/{staff}/restore", manager: true, status: 200, setup: deleted("/users/{staff}")},
+	{name: "request verification", method: "POST", path: "/users/verification", status: 200, check: checkMailed("Verify your email address")},
+	{name: "verify email", method: "POST", path: "/users/verification/confirm", anonymous: true, status: 200,
+		setup: verificationToken, body: tokenBody(nil), check: checkVerified},
+	{name: "verify email with a used token", method: "POST", path: "/users/verification/confirm", anonymous: true, status: 400,
+		setup: func(t *testing.T, e *env) {
+			verificationToken(t, e)
+			e.h.Do(http.MethodPost, "/users/verification/confirm", map[string]string{"token": e.ids["token"]}, nil)
+		},
+		body: tokenBody(nil)},
+	{name: "request password reset", method: "POST", path: "/users/password-reset", anonymous: true, status: 202,
+		body:  func(e *env) interface{} { return map[string]string{"email": e.staff.Email} },
+		check: checkMailed("Reset your password")},
+	{name: "request password reset for an unknown email", method: "POST", path: "/users/password-reset", anonymous: true, status: 202,
+		body: body(map[string]string{"email": "nobody@trattoria.test"}), check: checkNothingMailed},
+	{name: "request password reset too often", method: "POST", path: "/users/password-reset", anonymous: true, status: 429,
+		setup: func(t *testing.T, e *env) {
+			for i := 0; i < 3; i++ {
+				e.h.Do(http.MethodPost, "/users/password-reset", map[string]string{"email": "nobody@trattoria.test"}, nil)
+			}
+		},
+		body: body(map[string]string{"email": "nobody@trattoria.test"}), check: checkRetryAfter},
+	{name: "reset password", method: "POST", path: "/users/password-reset/confirm", anonymous: true, status: 200,
+		setup: resetToken, body: tokenBody(map[string]string{"password": "brand-new-pass"}), check: checkPasswordChanged},
+	{name: "reset password with a wrong token", method: "POST", path: "/users/password-reset/confirm", anonymous: true, status: 400,
+		body: body(map[string]string{"token": "not-a-token", "password": "brand-new-pass"})},
+
+	{name: "set pin", method: "POST", path: "/users/pin", status: 200,
+		body:  func(e *env) interface{} { return map[string]string{"password": e.staff.Password, "pin": "4321"} },
+		check: stored("/users/{staff}", "has_pin", true)},
+	{name: "set pin with a wrong password", method: "POST", path: "/users/pin", status: 403,
+		body: body(map[string]string{"password": "nope", "pin": "4321"})},
+	{name: "enroll in mfa", method: "POST", path: "/users/mfa", status: 200,
+		body: func(e *env) interface{} { return map[string]string{"password": e.staff.Password} }, check: checkMfaSecret},
+	{name: "confirm mfa", method: "POST", path: "/users/mfa/confirm", status: 200,
+		setup: mfaEnrolled, body: func(e *env) interface{} { return map[string]string{"code": mfaCode(e, 0)} }, check: checkRecoveryCodes},
+	{name: "confirm mfa with a wrong code", method: "POST", path: "/users/mfa/confirm", status: 401,
+		setup: mfaEnrolled, body: body(map[string]string{"code": "000000"})},
+	{name: "login with mfa", method: "POST", path: "/users/login", anonymous: true, status: 200,
+		setup: mfaEnabled, body: staffLogin, check: checkMfaChallenge},
+	{name: "finish login with a code", method: "POST", path: "/users/login/mfa", anonymous: true, status: 200,
+		setup: mfaChallenge, body: mfaLogin(""), check: checkLoginToken},
+	{name: "finish login with a recovery code", method: "POST", path: "/users/login/mfa", anonymous: true, status: 200,
+		setup: mfaChallenge, body: mfaLogin("{recovery_code}"), check: checkLoginToken},
+	{name: "finish login with a wrong code", method: "POST", path: "/users/login/mfa", anonymous: true, status: 401,
+		setup: mfaChallenge, body: mfaLogin("000000")},
+	{name: "set up mfa at login without the mailed token", method: "POST", path: "/users/login/mfa/enroll", anonymous: true, status: 401,
+		body: body(map[string]string{"mfa_token": "not-a-token", "enrollment_token": "not-a-token"})},
+	{name: "disable mfa", method: "DELETE", path: "/users/mfa", status: 200, setup: mfaEnabled,
+		body: func(e *env) interface{} {
+			return map[string]string{"password": e.staff.Password, "code": mfaCode(e, 1)}
+		},
+		check: stored("/users/{staff}", "mfa_enabled_at", nil)},
+
+	// terminals
+	{name: "list terminals", method: "GET", path: "/terminals", status: 200, setup: terminal},
+	{name: "register terminal", method: "POST", path: "/terminals", manager: true, status: 200,
+		body: body(map[string]string{"name": "Bar till"}), check: checkTerminalKey},
+	{name: "register terminal as staff", method: "POST", path: "/terminals", status: 403,
+		body: body(map[string]string{"name": "Bar till"})},
+	{name: "revoke terminal", method: "DELETE", path: "/terminals/{terminal}", manager: true, status: 200,
+		setup: terminalSession, check: checkSessionEnded},
+	{name: "terminal login", method: "POST", path: "/terminals/login", anonymous: true, status: 200,
+		setup: terminalWithPins, body: pinLogin("4321"), check: checkTerminalToken},
+	{name: "terminal login with a wrong pin", method: "POST", path: "/terminals/login", anonymous: true, status: 401,
+		setup: terminalWithPins, body: pinLogin("1111")},
+	{name: "switch users on a terminal", method: "POST", path: "/terminals/login", anonymous: true, status: 200,
+		setup: terminalSession, body: managerPinLogin, check: checkSessionEnded},
+	{name: "terminal logout", method: "POST", path: "/terminals/logout", status: 200,
+		setup: terminalSession, check: checkSessionEnded},
+
+	// menus
+	{name: "list menus", method: "GET", path: "/menus", status: 200},
+	{name: "get menu", method: "GET", path: "/menus/pizza", status: 200, check: field("name", "Pizza")},
+	{name: "get unknown menu", method: "GET", path: "/menus/nope", status: 404},
+	{name: "create menu", method: "POST", path: "/menus", status: 200,
+		body: body(map[string]string{"name": "Specials", "category": "Dinner"})},
+	{name: "update menu", method: "PATCH", path: "/menus/pizza", status: 200,
+		body: body(map[string]string{"name": "Pizze"}), check: stored("/menus/pizza", "name", "Pizze")},
+	{name: "delete menu", method: "DELETE", path: "/menus/desserts", manager: true, status: 200, check: archived("/menus/desserts")},
+	{name: "restore menu", method: "POST", path: "/menus/desserts/restore", manager: true, status: 200, setup: deleted("/menus/desserts"),
+		check: stored("/menus/desserts", "name", "Desserts")},
+
+	// foods
+	{name: "list foods", method: "GET", path: "/foods", status: 200, check: field("total_count", 14.0)},
+	{name: "get food", method: "GET", path: "/foods/margherita", status: 200, check: field("price", 10.0)},
+	{name: "food prices", method: "GET", path: "/foods/margherita/prices", status: 200},
+	{name: "create food", method: "POST", path: "/foods", status: 200,
+		body: body(map[string]interface{}{"name": "Marinara", "price": 8.5, "food_image": "/images/marinara.jpg", "menu_id": "pizza"})},
+	{name: "create food for an unknown menu", method: "POST", path: "/foods", status: 400,
+		body: body(map[string]interface{}{"name": "Marinara", "price": 8.5, "food_image": "/images/marinara.jpg", "menu_id": "nope"})},
+	{name: "update food", method: "PATCH", path: "/foods/margherita", status: 200,
+		body: body(map[string]interface{}{"price": 11}), check: stored("/foods/margherita", "price", 11.0)},
+	{name: "delete food", method: "DELETE", path: "/foods/espresso", manager: true, status: 200, check: archived("/foods/espresso")},
+	{name: "restore food", method: "POST", path: "/foods/espresso/restore", manager: true, status: 200, setup: deleted("/foods/espresso"),
+		check: stored("/foods/espresso", "price", 2.5)},
+
+	// tables
+	{name: "list tables", method: "GET", path: "/tables", status: 200},
+	{name: "get table", method: "GET", path: "/tables/table-3", status: 200, check: field("table_number", 3.0)},
+	{name: "create table", method: "POST", path: "/tables", status: 200,
+		body: body(map[string]int{"number_of_guests": 4, "table_number": 7})},
+	{name: "create invalid table", method: "POST", path: "/tables", status: 400,
+		body: body(map[string]int{"table_number": 7})},
+	{name: "update table", method: "PATCH", path: "/tables/table-6", status: 200,
+		body: body(map[string]int{"number_of_guests": 10}), check: stored("/tables/table-6", "number_of_guests", 10.0)},
+	{name: "delete table as staff", method: "DELETE", path: "/tables/table-6", status: 403},
+	{name: "restore table as staff", method: "POST", path: "/tables/table-6/restore", status: 403, setup: deleted("/tables/table-6")},
+	{name: "delete table", method: "DELETE", path: "/tables/table-6", manager: true, status: 200, check: archived("/tables/table-6")},
+	{name: "restore table", method: "POST", path: "/tables/table-6/restore", manager: true, status: 200, setup: deleted("/tables/table-6"),
+		check: stored("/tables/table-6", "table_number", 6.0)},
+
+	// orders
+	{name: "list orders", method: "GET", path: "/orders", status: 200},
+	{name: "get order", method: "GET", path: "/orders/demo-order-1", status: 200, check: field("table_id", "table-1")},
+	{name: "create order", method: "POST", path: "/orders", status: 200,
+		body: body(map[string]string{"table_id": "table-2"})},
+	{name: "create order for an unknown table", method: "POST", path: "/orders", status: 400,
+		body: body(map[string]string{"table_id": "nope"})},
+	{name: "update order", method: "PATCH", path: "/orders/demo-order-5", status: 200,
+		body: body(map[string]string{"table_id": "table-6"}), check: stored("/orders/demo-order-5", "table_id", "table-6")},
+	{name: "delete order", method: "DELETE", path: "/orders/demo-order-5", manager: true, status: 200, check: archived("/orders/demo-order-5")},
+	{name: "restore order", method: "POST", path: "/orders/demo-order-5/restore", manager: true, status: 200, setup: deleted("/orders/demo-order-5"),
+		check: stored("/orders/demo-order-5", "table_id", "table-4")},
+
+	// order items
+	{name: "list order items", method: "GET", path: "/orderItems", status: 200},
+	{name: "get order item", method: "GET", path: "/orderItems/demo-order-1-2", status: 200, check: field("unit_price", 10.0)},
+	{name: "items by order", method: "GET", path: "/orderItems-order/demo-order-1", status: 200, check: checkItemsByOrder},
+	{name: "items by unknown order", method: "GET", path: "/orderItems-order/nope", status: 200},
+	{name: "create order with items", method: "POST", path: "/orderItems", status: 200, check: checkCreateOrderItem,
+		body: body(map[string]interface{}{"table_id": "table-2", "order_items": []map[string]string{
+			{"food_id": "diavola", "quantity": "M"}, {"food_id": "espresso", "quantity": "S"}, {"food_id": "espresso", "quantity": "S"},
+		}})},
+	{name: "create order with an invalid size", method: "POST", path: "/orderItems", status: 400,
+		body: body(map[string]interface{}{"table_id": "table-2", "order_items": []map[string]string{{"food_id": "diavola", "quantity": "XL"}}})},
+	{name: "create order with an unknown food", method: "POST", path: "/orderItems", status: 400,
+		body: body(map[string]interface{}{"table_id": "table-2", "order_items": []map[string]string{{"food_id": "nope", "quantity": "M"}}})},
+	{name: "update order item", method: "PATCH", path: "/orderItems/demo-order-5-1", status: 200,
+		body: body(map[string]string{"food_id": "burrata"}), check: stored("/orderItems/demo-order-5-1", "unit_price", 9.0)},
+	{name: "void order item", method: "POST", path: "/orderItems/demo-order-5-3/void", status: 200,
+		body: managerApproval("sent back", nil), check: checkVoid},
+	{name: "void without a manager", method: "POST", path: "/orderItems/demo-order-5-3/void", status: 403,
+		body: func(e *env) interface{} {
+			return map[string]string{"reason": "sent back", "manager_email": e.staff.Email, "manager_password": e.staff.Password}
+		}},
+
+	// invoices
+	{name: "list invoices", method: "GET", path: "/invoices", status: 200},
+	{name: "get invoice", method: "GET", path: "/invoices/demo-order-1", status: 200, check: checkGetInvoice},
+	{name: "get unknown invoice", method: "GET", path: "/invoices/nope", status: 404},
+	{name: "create invoice", method: "POST", path: "/invoices", status: 200,
+		body: body(map[string]string{"order_id": "demo-order-5"}), check: checkCreateInvoice},
+	{name: "update invoice", method: "PATCH", path: "/invoices/demo-order-4", status: 200,
+		body:  body(map[string]string{"payment_status": "PAID", "payment_method": "CASH"}),
+		check: stored("/invoices/demo-order-4", "Payment_status", "PAID")},
+	{name: "refund invoice", method: "POST", path: "/invoices/demo-order-1/refund", status: 200,
+		body:  managerApproval("corked wine", map[string]interface{}{"order_item_ids": []string{"demo-order-1-4"}}),
+		check: field("amount", 8.0)},
+	{name: "invoice refunds", method: "GET", path: "/invoices/demo-order-1/refunds", status: 200},
+
+	// promotions and coupons
+	{name: "list promotions", method: "GET", path: "/promotions", status: 200},
+	{name: "create promotion", method: "POST", path: "/promotions", status: 200,
+		body: body(map[string]interface{}{"name": "Pizza night", "type": "PERCENTAGE", "value": 10, "menu_id": "pizza"})},
+	{name: "create invalid promotion", method: "POST", path: "/promotions", status: 400,
+		body: body(map[string]interface{}{"name": "Pizza night", "type": "HALF_OFF"})},
+	{name: "get promotion", method: "GET", path: "/promotions/{promotion}", status: 200, setup: promotion,
+		check: field("name", "Pizza night")},
+	{name: "update promotion", method: "PATCH", path: "/promotions/{promotion}", status: 200, setup: promotion,
+		body: body(map[string]interface{}{"value": 15}), check: stored("/promotions/{promotion}", "value", 15.0)},
+	{name: "promotion report", method: "GET", path: "/promotions/report", status: 200},
+	{name: "list coupons", method: "GET", path: "/coupons", status: 200},
+	{name: "create coupon", method: "POST", path: "/coupons", status: 200, setup: promotion,
+		body: func(e *env) interface{} {
+			return map[string]string{"code": "pizza10", "promotion_id": e.ids["promotion"]}
+		}},
+	{name: "create duplicate coupon", method: "POST", path: "/coupons", status: 409, setup: coupon,
+		body: func(e *env) interface{} {
+			return map[string]string{"code": "PIZZA10", "promotion_id": e.ids["promotion"]}
+		}},
+	{name: "get coupon", method: "GET", path: "/coupons/{coupon}", status: 200, setup: coupon, check: field("code", "PIZZA10")},
+
+	// reports and audit
+	{name: "sales report", method: "GET", path: "/reports/sales", manager: true, status: 200},
+	{name: "sales report as staff", method: "GET", path: "/reports/sales", status: 403},
+	{name: "audit log", method: "GET", path: "/audit", manager: true, status: 200},
+	{name: "audit log as staff", method: "GET", path: "/audit", status: 403},
+}
+
+func TestHandlers(t *testing.T) {
+	for _, tc := range handlerCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			e := newEnv(t)
+			if tc.setup != nil {
+				tc.setup(t, e)
+			}
+
+			var b interface{}
+			if tc.body != nil {
+				b = tc.body(e)
+			}
+			as := e.staff
+			if tc.anonymous {
+				as = nil
+			}
+			if tc.manager {
+				as = e.manager
+			}
+
+			path := e.expand(tc.path)
+			resp := e.h.Do(tc.method, path, b, as)
+			if resp.Status != tc.status {
+				t.Fatalf("%s %s: status %d, want %d: %s", tc.method, path, resp.Status, tc.status, resp.Body)
+			}
+			if tc.check != nil {
+				tc.check(t, e, resp)
+			}
+		})
+	}
+}
+
+func checkMailed(subject string) func(*testing.T, *env, *apptest.Response) {
+	return func(t *testing.T, e *env, _ *apptest.Response) {
+		sent := e.h.Mail.To(e.staff.Email)
+		if len(sent) != 1 || sent[0].Subject != subject {
+			t.Fatalf("sent %+v, want one %q email", sent, subject)
+		}
+		mailedToken(t, e, e.staff.Email)
+	}
+}
+
+func checkNothingMailed(t *testing.T, e *env, _ *apptest.Response) {
+	if sent := e.h.Mail.Messages(); len(sent) != 0 {
+		t.Errorf("sent %+v, want nothing", sent)
+	}
+}
+
+func checkVerified(t *testing.T, e *env, resp *apptest.Response) {
+	if resp.Map(t)["email_verified_at"] == nil {
+		t.Errorf("email_verified_at is not set: %s", resp.Body)
+	}
+}
+
+func checkPasswordChanged(t *testing.T, e *env, _ *apptest.Response) {
+	if resp := e.h.Do(http.MethodGet, "/foods", nil, e.staff); resp.Status != http.StatusUnauthorized {
+		t.Errorf("token from before the reset: status %d, want 401", resp.Status)
+	}
+	for password, want := range map[string]int{"brand-new-pass": http.StatusOK, e.staff.Password: http.StatusUnauthorized} {
+		resp := e.h.Do(http.MethodPost, "/users/login", map[string]string{"email": e.staff.Email, "password": password}, nil)
+		if resp.Status != want {
+			t.Errorf("login with %q: status %d, want %d", password, resp.Status, want)
+		}
+	}
+}
+
+func checkRetryAfter(t *testing.T, _ *env, resp *apptest.Response) {
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("no Retry-After header")
+	}
+}
+
+func checkCanLogIn(t *testing.T, e *env, _ *apptest.Response) {
+	if resp := e.h.Do(http.MethodPost, "/users/login", staffLogin(e), nil); resp.Status != http.StatusOK {
+		t.Errorf("login after unlocking: status %d: %s", resp.Status, resp.Body)
+	}
+}
+
+func checkMfaSecret(t *testing.T, _ *env, resp *apptest.Response) {
+	var enrollment dto.MfaEnrollmentResponse
+	resp.JSON(t, &enrollment)
+	if enrollment.Secret == "" || !strings.HasPrefix(enrollment.Otpauth_uri, "otpauth://totp/") {
+		t.Errorf("enrollment = %s, want a secret and an otpauth URI", resp.Body)
+	}
+}
+
+func checkRecoveryCodes(t *testing.T, e *env, resp *apptest.Response) {
+	var codes dto.RecoveryCodesResponse
+	resp.JSON(t, &codes)
+	if len(codes.Recovery_codes) != 10 {
+		t.Errorf("recovery codes = %s, want 10", resp.Body)
+	}
+	if user := e.do(t, http.MethodGet, "/users/{staff}", nil).Map(t); user["mfa_enabled_at"] == nil {
+		t.Errorf("mfa_enabled_at is not set: %v", user)
+	}
+}
+
+// checkMfaChallenge checks that a login with only the password gets a challenge and no tokens.
+func checkMfaChallenge(t *testing.T, _ *env, resp *apptest.Response) {
+	var login dto.LoginResponse
+	resp.JSON(t, &login)
+	if login.Token != "" || login.Refresh_token != "" || login.Mfa == nil || login.Mfa.Mfa_token == "" {
+		t.Errorf("login = %s, want an MFA challenge and no tokens", resp.Body)
+	}
+}
+
+func checkLoginToken(t *testing.T, e *env, resp *apptest.Response) {
+	var login dto.LoginResponse
+	resp.JSON(t, &login)
+	if got := e.h.Do(http.MethodGet, "/foods", nil, nil, "token", login.Token); got.Status != http.StatusOK {
+		t.Errorf("request with the token: status %d: %s", got.Status, got.Body)
+	}
+}
+
+// checkLoginTokens checks that the token works and the refresh token can't stand in for it.
+func checkLoginTokens(t *testing.T, e *env, resp *apptest.Response) {
+	checkLoginToken(t, e, resp)
+	var login dto.LoginResponse
+	resp.JSON(t, &login)
+	if got := e.h.Do(http.MethodGet, "/foods", nil, nil, "token", login.Refresh_token); got.Status != http.StatusUnauthorized {
+		t.Errorf("request with the refresh token: status %d, want 401", got.Status)
+	}
+}
+
+func checkJWKS(t *testing.T, _ *env, resp *apptest.Response) {
+	var jwks signing.JSONWebKeySet
+	resp.JSON(t, &jwks)
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kty != "OKP" || jwks.Keys[0].Alg != signing.EdDSA || jwks.Keys[0].X == "" {
+		t.Errorf("jwks = %s, want the harness's Ed25519 key", resp.Body)
+	}
+}
+
+func checkTerminalKey(t *testing.T, _ *env, resp *apptest.Response) {
+	var registered dto.TerminalRegistrationResponse
+	resp.JSON(t, &registered)
+	if registered.Terminal_id == "" || len(registered.Terminal_key) < 32 {
+		t.Errorf("registration = %s, want an id and a key", resp.Body)
+	}
+}
+
+func checkTerminalToken(t *testing.T, e *env, resp *apptest.Response) {
+	var login dto.TerminalLoginResponse
+	resp.JSON(t, &login)
+	if got := e.h.Do(http.MethodGet, "/foods", nil, nil, "token", login.Token); got.Status != http.StatusOK {
+		t.Errorf("request with the terminal token: status %d: %s", got.Status, got.Body)
+	}
+}
+
+// checkSessionEnded checks that the waiter's terminal token stopped working.
+func checkSessionEnded(t *testing.T, e *env, _ *apptest.Response) {
+	if resp := e.h.Do(http.MethodGet, "/foods", nil, e.staff); resp.Status != http.StatusUnauthorized {
+		t.Errorf("request with the ended session's token: status %d, want 401", resp.Status)
+	}
+}
+
+func checkItemsByOrder(t *testing.T, _ *env, resp *apptest.Response) {
+	var orders []dto.OrderItemsOfOrder
+	resp.JSON(t, &orders)
+	if len(orders) != 1 {
+		t.Fatalf("got %d orders, want 1: %s", len(orders), resp.Body)
+	}
+	order := orders[0]
+	// bruschetta 6.5, margherita 10, carbonara 13.5 and two glasses of chianti at 8
+	if order.Payment_due != 46 || order.Table_number != 1 || order.Total_count != 5 || len(order.Order_items) != 5 {
+		t.Errorf("got %v due at table %d with %d items and %d lines, want 46 at table 1 with 5",
+			order.Payment_due, order.Table_number, order.Total_count, len(order.Order_items))
+	}
+}
+
+func checkCreateOrderItem(t *testing.T, e *env, resp *apptest.Response) {
+	var created dto.OrderViewFormat
+	resp.JSON(t, &created)
+	if created.Order.Order_id == "" || len(created.Order_items) != 3 {
+		t.Fatalf("got %s, want an order with 3 items", resp.Body)
+	}
+	for _, item := range created.Order_items {
+		if item.Order_id != created.Order.Order_id || item.Unit_price == nil {
+			t.Errorf("item %s isn't priced or isn't part of order %s", item.Order_item_id, created.Order.Order_id)
+		}
+	}
+
+	// prices are taken when the order is placed, so a later price change leaves it alone
+	e.do(t, http.MethodPatch, "/foods/diavola", map[string]float64{"price": 20})
+
+	var orders []dto.OrderItemsOfOrder
+	e.do(t, http.MethodGet, "/orderItems-order/"+created.Order.Order_id, nil).JSON(t, &orders)
+	if len(orders) != 1 || orders[0].Payment_due != 17.5 || orders[0].Table_number != 2 {
+		t.Errorf("got %+v, want 17.5 due at table 2", orders)
+	}
+}
+
+func checkVoid(t *testing.T, e *env, _ *apptest.Response) {
+	stored("/orderItems/demo-order-5-3", "status", "VOIDED")(t, e, nil)
+
+	// the voided glass of chianti is no longer due
+	var orders []dto.OrderItemsOfOrder
+	e.do(t, http.MethodGet, "/orderItems-order/demo-order-5", nil).JSON(t, &orders)
+	if len(orders) != 1 || orders[0].Payment_due != 20.5 {
+		t.Errorf("got %+v, want 20.5 due", orders)
+	}
+}
+
+func checkGetInvoice(t *testing.T, _ *env, resp *apptest.Response) {
+	var invoice dto.InvoiceViewFormat
+	resp.JSON(t, &invoice)
+	if invoice.Payment_due != 46 || invoice.Total != 46 || invoice.Table_number != 1 || len(invoice.Order_details) != 5 {
+		t.Errorf("got %v due, %v total at table %d with %d lines, want 46 at table 1 with 5",
+			invoice.Payment_due, invoice.Total, invoice.Table_number, len(invoice.Order_details))
+	}
+	if invoice.Payment_method != "CARD" || invoice.Payment_status == nil || *invoice.Payment_status != "PAID" {
+		t.Errorf("got %s %v, want a PAID CARD invoice", invoice.Payment_method, invoice.Payment_status)
+	}
+}
+
+func checkCreateInvoice(t *testing.T, e *env, resp *apptest.Response) {
+	var result struct{ InsertedID string }
+	resp.JSON(t, &result)
+
+	var invoice dto.InvoiceViewFormat
+	e.do(t, http.MethodGet, "/invoices/"+result.InsertedID, nil).JSON(t, &invoice)
+	// bruschetta 6.5, lasagne 14 and a glass of chianti at 8
+	if invoice.Order_id != "demo-order-5" || invoice.Total != 28.5 || invoice.Payment_due != 28.5 {
+		t.Errorf("got %v total and %v due for %s, want 28.5 for demo-order-5", invoice.Total, invoice.Payment_due, invoice.Order_id)
+	}
+}
+
+func TestAppsDoNotShareStores(t *testing.T) {
+	seeded, empty := newEnv(t), apptest.New(t)
+
+	if resp := seeded.do(t, http.MethodGet, "/menus/pizza", nil); resp.Map(t)["name"] != "Pizza" {
+		t.Fatalf("seeded app: %s", resp.Body)
+	}
+	if resp := empty.Do(http.MethodGet, "/menus/pizza", nil, empty.User("STAFF")); resp.Status != http.StatusNotFound {
+		t.Errorf("the other app found the menu: status %d", resp.Status)
+	}
+}
+
+func TestTerminalKeysAreNeverStoredForReplay(t *testing.T) {
+	e := newEnv(t)
+
+	var keys []string
+	for i := 0; i < 2; i++ {
+		resp := e.h.Do(http.MethodPost, "/terminals", map[string]string{"name": "Front till"}, e.manager, "Idempotency-Key", "register-till")
+		if resp.Status != http.StatusOK || resp.Header.Get("Idempotent-Replayed") != "" {
+			t.Fatalf("registration %d: status %d, replayed %q", i+1, resp.Status, resp.Header.Get("Idempotent-Replayed"))
+		}
+		var registered dto.TerminalRegistrationResponse
+		resp.JSON(t, &registered)
+		keys = append(keys, registered.Terminal_key)
+	}
+	if keys[0] == keys[1] {
+		t.Error("the second registration got the first one's key")
+	}
+	if count, _ := e.h.Repos.Idempotency.CountDocuments(context.Background(), bson.M{}); count != 0 {
+		t.Errorf("%d idempotency records stored, want none", count)
+	}
+}
+
+func TestLoginEnrollmentNeedsThePasswordAndTheMailbox(t *testing.T) {
+	cfg := app.DefaultConfig()
+	cfg.Login.MfaRoles = []string{"MANAGER"}
+	h := apptest.NewWithConfig(t, cfg)
+	e := &env{h: h, staff: h.User("STAFF"), manager: h.User("MANAGER"), ids: map[string]string{}}
+
+	resp := h.Do(http.MethodPost, "/users/login", map[string]string{"email": e.manager.Email, "password": e.manager.Password}, nil)
+	var login dto.LoginResponse
+	resp.JSON(t, &login)
+	if resp.Status != http.StatusOK || login.Mfa == nil || !login.Mfa.Enrollment_required || strings.Contains(string(resp.Body), "secret") {
+		t.Fatalf("login of a manager without MFA = %d %s, want a challenge without the secret", resp.Status, resp.Body)
+	}
+
+	enroll := map[string]string{"mfa_token": login.Mfa.Mfa_token, "enrollment_token": mailedToken(t, e, e.manager.Email)}
+	resp = h.Do(http.MethodPost, "/users/login/mfa/enroll", enroll, nil)
+	var enrollment dto.MfaEnrollmentResponse
+	resp.JSON(t, &enrollment)
+	if resp.Status != http.StatusOK || enrollment.Secret == "" {
+		t.Fatalf("enrolling with the mailed token: status %d: %s", resp.Status, resp.Body)
+	}
+	e.ids["mfa_secret"] = enrollment.Secret
+
+	resp = h.Do(http.MethodPost, "/users/login/mfa", map[string]string{"mfa_token": login.Mfa.Mfa_token, "code": mfaCode(e, 0)}, nil)
+	var finished dto.LoginResponse
+	resp.JSON(t, &finished)
+	if resp.Status != http.StatusOK || finished.Token == "" || len(finished.Recovery_codes) == 0 {
+		t.Errorf("finishing the login: status %d: %s", resp.Status, resp.Body)
+	}
+}
+
+// TestEveryRouteIsCovered fails when a route is added without a case above.
+func TestEveryRouteIsCovered(t *testing.T) {
+	h := apptest.New(t)
+	for _, route := range h.App.GetRoutes(true) {
+		if route.Method == http.MethodHead {
+			continue
+		}
+		covered := false
+		for _, tc := range handlerCases {
+			if tc.method == route.Method && matches(route.Path, tc.path) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			t.Errorf("no handler case for %s %s", route.Method, route.Path)
+		}
+	}
+}
+
+// matches reports whether path is served by the route pattern, whose parameters match any segment.
+func matches(pattern string, path string) bool {
+	patternSegments := strings.Split(pattern, "/")
+	pathSegments := strings.Split(path, "/")
+	if len(patternSegments) != len(pathSegments) {
+		return false
+	}
+	for i, segment := range patternSegments {
+		if !strings.HasPrefix(segment, ":") && segment != pathSegments[i] {
+			return false
+		}
+	}
+	return true
+}
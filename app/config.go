@@ -0,0 +1,89 @@
+package app
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/services"
+)
+
+// Config holds the settings of the HTTP server.
+type Config struct {
+	// RequestTimeout is the deadline of a request whose route doesn't set its own.
+	RequestTimeout time.Duration
+	// IdempotencyTTL is how long the response to an Idempotency-Key is kept for replays.
+	IdempotencyTTL time.Duration
+	// Login throttles failed logins and says who needs MFA. Settings left at zero keep their default.
+	Login services.LoginPolicy
+	// Location is the time zone happy hours are read in; nil is the server's local time zone.
+	Location *time.Location
+}
+
+// DefaultConfig is the configuration used for settings that aren't given.
+func DefaultConfig() Config {
+	return Config{
+		RequestTimeout: 15 * time.Second,
+		IdempotencyTTL: 24 * time.Hour,
+		Login:          services.DefaultLoginPolicy(),
+	}
+}
+
+// ConfigFromEnv reads REQUEST_TIMEOUT and IDEMPOTENCY_TTL, both Go durations, and the login
+// policy from LOGIN_BACKOFF_AFTER, LOGIN_BACKOFF, LOGIN_LOCKOUT_AFTER, LOGIN_LOCKOUT,
+// LOGIN_IP_LOCKOUT_AFTER and LOGIN_FAILURE_WINDOW, counts and durations. A variable that is
+// unset or isn't a positive number or duration keeps its default. MFA_REQUIRED_ROLES is a comma
+// separated list of the roles that need MFA, none by default. TIMEZONE is the IANA name of the
+// restaurant's time zone (e.g. Europe/Berlin), the server's by default.
+func ConfigFromEnv() Config {
+	cfg := DefaultConfig()
+	cfg.RequestTimeout = durationFromEnv("REQUEST_TIMEOUT", cfg.RequestTimeout)
+	cfg.IdempotencyTTL = durationFromEnv("IDEMPOTENCY_TTL", cfg.IdempotencyTTL)
+	cfg.Login.BackoffAfter = intFromEnv("LOGIN_BACKOFF_AFTER", cfg.Login.BackoffAfter)
+	cfg.Login.Backoff = durationFromEnv("LOGIN_BACKOFF", cfg.Login.Backoff)
+	cfg.Login.LockoutAfter = intFromEnv("LOGIN_LOCKOUT_AFTER", cfg.Login.LockoutAfter)
+	cfg.Login.Lockout = durationFromEnv("LOGIN_LOCKOUT", cfg.Login.Lockout)
+	cfg.Login.IPLockoutAfter = intFromEnv("LOGIN_IP_LOCKOUT_AFTER", cfg.Login.IPLockoutAfter)
+	cfg.Login.Window = durationFromEnv("LOGIN_FAILURE_WINDOW", cfg.Login.Window)
+	cfg.Login.MfaRoles = listFromEnv("MFA_REQUIRED_ROLES")
+	cfg.Location = locationFromEnv("TIMEZONE", cfg.Location)
+	return cfg
+}
+
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	if value := os.Getenv(name); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func intFromEnv(name string, fallback int) int {
+	if value := os.Getenv(name); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func locationFromEnv(name string, fallback *time.Location) *time.Location {
+	if value := os.Getenv(name); value != "" {
+		if loc, err := time.LoadLocation(value); err == nil {
+			return loc
+		}
+	}
+	return fallback
+}
+
+func listFromEnv(name string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
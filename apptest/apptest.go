@@ -0,0 +1,193 @@
+// Package apptest boots the whole app against a fresh in-memory store for tests, with helpers
+// to sign in as a user of a given role and to make JSON requests.
+package apptest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/app"
+	"github.com/mayankr5/v1/restaurant-management/database"
+	helper "github.com/mayankr5/v1/restaurant-management/helpers"
+	"github.com/mayankr5/v1/restaurant-management/mailer"
+	"github.com/mayankr5/v1/restaurant-management/models"
+	"github.com/mayankr5/v1/restaurant-management/repositories"
+	"github.com/mayankr5/v1/restaurant-management/seed"
+	"github.com/mayankr5/v1/restaurant-management/signing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Harness is an app serving from its own in-memory store.
+type Harness struct {
+	t     testing.TB
+	App   *fiber.App
+	Store *database.MemoryStore
+	// Repos are the collections of Store.
+	Repos *repositories.Repositories
+	// Mail holds the emails the app sent.
+	Mail *mailer.Outbox
+	// Keys sign the app's tokens.
+	Keys *signing.KeySet
+}
+
+// User is a user in the harness's store, with the password it signs in with and a valid token.
+type User struct {
+	models.User
+	Password string
+	Token    string
+}
+
+// Response is a recorded response.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// New boots the app on a new in-memory store. Harnesses share nothing, so tests using them can
+// run in parallel.
+func New(t testing.TB) *Harness {
+	t.Helper()
+	return NewWithConfig(t, app.DefaultConfig())
+}
+
+// NewWithConfig is New with the settings of cfg, e.g. roles that need MFA.
+func NewWithConfig(t testing.TB, cfg app.Config) *Harness {
+	t.Helper()
+
+	store := database.NewMemoryStore()
+	mail := mailer.NewOutbox()
+	keys, err := signing.Generate(signing.DefaultIssuer, signing.DefaultIssuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deps := app.Deps{Store: store, Mailer: mail, Keys: keys}
+	return &Harness{t: t, App: app.New(cfg, deps), Store: store, Repos: repositories.New(store), Mail: mail, Keys: keys}
+}
+
+// Seed loads a fixture into the harness's store.
+func (h *Harness) Seed(fixture *seed.Fixture) {
+	h.t.Helper()
+	if _, err := seed.Apply(context.Background(), h.Store, fixture); err != nil {
+		h.t.Fatalf("seeding: %v", err)
+	}
+}
+
+// SeedFile loads the fixture file at path, without its users, which are slow to hash; use User
+// to add the users a test needs.
+func (h *Harness) SeedFile(path string) *seed.Fixture {
+	h.t.Helper()
+	fixture, err := seed.Load(path)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	fixture.Users = nil
+	h.Seed(fixture)
+	return fixture
+}
+
+// User adds a user with the role (ADMIN, MANAGER or STAFF) and signs it in.
+func (h *Harness) User(role string) *User {
+	h.t.Helper()
+
+	id := primitive.NewObjectID()
+	password := "password-" + strings.ToLower(role)
+	// the lowest cost keeps tests fast; logins compare against any cost
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	user := &User{Password: password}
+	user.ID = id
+	user.User_id = id.Hex()
+	user.First_name = "Test"
+	user.Last_name = role
+	user.Email = strings.ToLower(role) + "-" + id.Hex() + "@example.com"
+	user.Phone = id.Hex()
+	user.User.Password = string(hash)
+	user.Role = role
+	user.Version = 1
+	user.Created_at = time.Now()
+	user.Updated_at = time.Now()
+
+	if _, err := h.Repos.Users.InsertOne(context.Background(), user.User); err != nil {
+		h.t.Fatal(err)
+	}
+
+	user.Token, _, err = helper.GenerateAllTokens(h.Keys, user.Email, user.First_name, user.Last_name, user.User_id, 0)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return user
+}
+
+// Do sends a request as the user, or without a token when as is nil. A body that isn't a string
+// or []byte is sent as JSON.
+func (h *Harness) Do(method string, path string, body interface{}, as *User, headers ...string) *Response {
+	h.t.Helper()
+
+	var reader io.Reader
+	switch body := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(body)
+	case []byte:
+		reader = bytes.NewReader(body)
+	default:
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if reader != nil {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	if as != nil {
+		req.Header.Set("token", as.Token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := h.App.Test(req, -1)
+	if err != nil {
+		h.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return &Response{Status: resp.StatusCode, Header: resp.Header, Body: data}
+}
+
+// JSON decodes the body into v and fails the test when it isn't valid JSON.
+func (r *Response) JSON(t testing.TB, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		t.Fatalf("decoding %s: %v", r.Body, err)
+	}
+}
+
+// Map decodes a JSON object body.
+func (r *Response) Map(t testing.TB) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	r.JSON(t, &m)
+	return m
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"github.com/mayankr5/v1/restaurant-management/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AuditController serves the audit log.
+type AuditController struct {
+	audits *services.AuditService
+}
+
+func NewAuditController(audits *services.AuditService) *AuditController {
+	return &AuditController{audits: audits}
+}
+
+func (h *AuditController) GetAudits(c *fiber.Ctx) error {
+	period, err := periodOf(c)
+	if err != nil {
+		return err
+	}
+
+	query := services.AuditQuery{
+		Entity:    c.Query("entity"),
+		Entity_id: c.Query("entity_id"),
+		User_id:   c.Query("user_id"),
+		Action:    c.Query("action"),
+		Period:    period,
+	}
+
+	allAudits, err := h.audits.List(c.UserContext(), currentUser(c), query, pageOf(c, 50))
+	if err != nil {
+		return serviceError(err, "error occurred while listing the audit log")
+	}
+	return c.JSON(allAudits)
+}
+
+func currentUser(c *fiber.Ctx) string {
+	uid, _ := c.Locals("uid").(string)
+	return uid
+}
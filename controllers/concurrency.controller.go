@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	helper "github.com/mayankr5/v1/restaurant-management/helpers"
+	"github.com/mayankr5/v1/restaurant-management/services"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// precondition reads the version the client expects from If-Match, or else the version it read
+// in the body. Without either the write is unconditional.
+func precondition(c *fiber.Ctx, bodyVersion int) (services.Precondition, error) {
+	version, conditional, err := helper.IfMatchVersion(c.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		return services.Precondition{}, apierrors.BadRequest(err.Error())
+	}
+	if conditional {
+		return services.Expect(version), nil
+	}
+	if bodyVersion > 0 {
+		return services.Expect(bodyVersion), nil
+	}
+	return services.Precondition{}, nil
+}
+
+// respondVersioned answers a versioned update with its result and the document's new ETag. A
+// client whose version is stale gets 412 with the current ETag; an unknown id is a 404.
+func respondVersioned(c *fiber.Ctx, result *mongo.UpdateResult, version int, err error, message string) error {
+	if version > 0 {
+		c.Set(fiber.HeaderETag, helper.ETag(version))
+	}
+	if err != nil {
+		return serviceError(err, message)
+	}
+	return c.JSON(result)
+}
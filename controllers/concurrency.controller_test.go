@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/database"
+	"github.com/mayankr5/v1/restaurant-management/repositories"
+	"github.com/mayankr5/v1/restaurant-management/services"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// newTestServices returns the services on a new in-memory store, and the store's collections.
+func newTestServices(t *testing.T) (*services.Services, *repositories.Repositories) {
+	t.Helper()
+
+	repos := repositories.New(database.NewMemoryStore())
+	return services.New(services.Deps{Repos: repos}), repos
+}
+
+func TestUpdateTableChecksVersion(t *testing.T) {
+	svc, repos := newTestServices(t)
+	tables := NewTableController(svc.Tables)
+
+	ctx := context.Background()
+	repos.Tables.InsertOne(ctx, bson.M{"table_id": "t1", "table_number": 4, "number_of_guests": 2, "version": 1})
+	repos.Tables.InsertOne(ctx, bson.M{"table_id": "legacy", "table_number": 5, "number_of_guests": 2})
+
+	app := fiber.New(fiber.Config{ErrorHandler: apierrors.ErrorHandler})
+	app.Get("/tables/:table_id", tables.GetTable)
+	app.Patch("/tables/:table_id", tables.UpdateTable)
+
+	patch := func(id string, ifMatch string) *http.Response {
+		req := httptest.NewRequest(http.MethodPatch, "/tables/"+id, bytes.NewBufferString(`{"number_of_guests": 6}`))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/tables/t1", nil))
+	if etag := resp.Header.Get("ETag"); etag != `"1"` {
+		t.Fatalf("GET ETag = %s, want \"1\"", etag)
+	}
+
+	if resp := patch("t1", `"1"`); resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != `"2"` {
+		t.Fatalf("PATCH with the current version = %d %s, want 200 \"2\"", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+
+	if resp := patch("t1", `"1"`); resp.StatusCode != http.StatusPreconditionFailed || resp.Header.Get("ETag") != `"2"` {
+		t.Errorf("PATCH with a stale version = %d %s, want 412 \"2\"", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+
+	if resp := patch("t1", ""); resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != `"3"` {
+		t.Errorf("unconditional PATCH = %d %s, want 200 \"3\"", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+
+	if resp := patch("legacy", `"0"`); resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != `"1"` {
+		t.Errorf("PATCH of a document without a version = %d %s, want 200 \"1\"", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+
+	if resp := patch("missing", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("PATCH of an unknown table = %d, want 404", resp.StatusCode)
+	}
+	if count, _ := repos.Tables.CountDocuments(ctx, bson.M{"table_id": "missing"}); count != 0 {
+		t.Errorf("PATCH of an unknown table created %d documents", count)
+	}
+}
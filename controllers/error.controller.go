@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/services"
+)
+
+// serviceError reports the business rule a service ran into with its status, and any other
+// failure as a 500 with message.
+func serviceError(err error, message string) error {
+	var domain *services.Error
+	if !errors.As(err, &domain) {
+		return apierrors.Internal(message, err)
+	}
+
+	switch domain.Kind {
+	case services.NotFound:
+		return apierrors.NotFound(domain.Message)
+	case services.Conflict:
+		return apierrors.Conflict(domain.Message)
+	case services.Unauthorized:
+		return apierrors.Unauthorized(domain.Message)
+	case services.Forbidden:
+		return apierrors.Forbidden(domain.Message)
+	case services.Stale:
+		return apierrors.PreconditionFailed(domain.Message)
+	case services.Throttled:
+		return apierrors.TooManyRequests(domain.Message, domain.RetryAfter)
+	}
+	return apierrors.BadRequest(domain.Message)
+}
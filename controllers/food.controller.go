@@ -1,188 +1,109 @@
 package controllers
 
 import (
-	"context"
-	"fmt"
-	"log"
-	"math"
-	"net/http"
-	"strconv"
-	"time"
-
-	"github.com/mayankr5/v1/restaurant-management/database"
-	"github.com/mayankr5/v1/restaurant-management/models"
-
-	"github.com/go-playground/validator/v10"
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	helper "github.com/mayankr5/v1/restaurant-management/helpers"
+	"github.com/mayankr5/v1/restaurant-management/services"
+
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var foodCollection = database.OpenCollection(database.Client, "food")
-var validate = validator.New()
-
-func GetFoods(c *fiber.Ctx) error {
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+var validate = apierrors.NewValidator()
 
-	recordPerPage, err := strconv.Atoi(c.Query("recordPerPage"))
-	if err != nil || recordPerPage < 1 {
-		recordPerPage = 10
-	}
+// FoodController serves the foods.
+type FoodController struct {
+	foods *services.FoodService
+}
 
-	page, err := strconv.Atoi(c.Query("page"))
-	if err != nil || page < 1 {
-		page = 1
-	}
+func NewFoodController(foods *services.FoodService) *FoodController {
+	return &FoodController{foods: foods}
+}
 
-	startIndex := (page - 1) * recordPerPage
-	startIndex, err = strconv.Atoi(c.Query("startIndex"))
-
-	matchStage := bson.D{{"$match", bson.D{{}}}}
-	groupStage := bson.D{{"$group", bson.D{{"_id", bson.D{{"_id", "null"}}}, {"total_count", bson.D{{"$sum", 1}}}, {"data", bson.D{{"$push", "$$ROOT"}}}}}}
-	projectStage := bson.D{
-		{
-			"$project", bson.D{
-				{"_id", 0},
-				{"total_count", 1},
-				{"food_items", bson.D{{"$slice", []interface{}{"$data", startIndex, recordPerPage}}}},
-			}}}
-
-	result, err := foodCollection.Aggregate(ctx, mongo.Pipeline{
-		matchStage, groupStage, projectStage})
+func (h *FoodController) GetFoods(c *fiber.Ctx) error {
+	total, foods, err := h.foods.List(c.UserContext(), pageOf(c, 10), c.QueryBool("include_deleted"))
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "error occurred while listing food items"})
-	}
-	defer result.Close(ctx)
-
-	var allFoods []bson.M
-	if err := result.All(ctx, &allFoods); err != nil {
-		log.Fatal(err)
+		return serviceError(err, "error occurred while listing food items")
 	}
-	return c.JSON(allFoods[0])
+	return c.JSON(dto.FoodPage{Total_count: total, Food_items: dto.NewFoodResponses(foods)})
 }
 
-func GetFood(c *fiber.Ctx) error {
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-
-	foodId := c.Params("food_id")
-	var food models.Food
-
-	err := foodCollection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food)
+func (h *FoodController) GetFood(c *fiber.Ctx) error {
+	food, err := h.foods.Get(c.UserContext(), c.Params("food_id"))
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "error occurred while fetching the food item"})
+		return serviceError(err, "error occurred while fetching the food item")
 	}
-	return c.JSON(food)
+	c.Set(fiber.HeaderETag, helper.ETag(food.Version))
+	return c.JSON(dto.NewFoodResponse(food))
 }
 
-func CreateFood(c *fiber.Ctx) error {
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+func (h *FoodController) CreateFood(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 
-	var menu models.Menu
-	var food models.Food
+	var request dto.CreateFoodRequest
 
-	if err := c.BodyParser(&food); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
 	}
 
-	validationErr := validate.Struct(food)
+	validationErr := validate.Struct(request)
 	if validationErr != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": validationErr.Error()})
+		return apierrors.Validation(validationErr)
 	}
 
-	err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.Menu_id}).Decode(&menu)
+	food, err := h.foods.Create(ctx, currentUser(c), request.Model())
 	if err != nil {
-		msg := fmt.Sprintf("menu was not found")
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
+		return serviceError(err, "Food item was not created")
 	}
-
-	food.Created_at = time.Now()
-	food.Updated_at = time.Now()
-	food.ID = primitive.NewObjectID()
-	food.Food_id = food.ID.Hex()
-	price := toFixed(*food.Price, 2)
-	food.Price = &price
-
-	result, insertErr := foodCollection.InsertOne(ctx, food)
-	if insertErr != nil {
-		msg := fmt.Sprintf("Food item was not created")
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
-	}
-	return c.JSON(result)
+	return c.JSON(&mongo.InsertOneResult{InsertedID: food.ID})
 }
 
-func UpdateFood(c *fiber.Ctx) error {
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+func (h *FoodController) UpdateFood(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 
-	var menu models.Menu
-	var food models.Food
+	var food dto.UpdateFoodRequest
 
 	foodId := c.Params("food_id")
 
 	if err := c.BodyParser(&food); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
-	}
-
-	var updateObj primitive.D
-
-	if food.Name != nil {
-		updateObj = append(updateObj, bson.E{"name", food.Name})
+		return apierrors.BadRequest(err.Error())
 	}
 
-	if food.Price != nil {
-		updateObj = append(updateObj, bson.E{"price", food.Price})
-	}
-
-	if food.Food_image != nil {
-		updateObj = append(updateObj, bson.E{"food_image", food.Food_image})
+	validationErr := validate.Struct(food)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
 	}
 
-	if food.Menu_id != nil {
-		err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.Menu_id}).Decode(&menu)
-		if err != nil {
-			msg := fmt.Sprintf("message:Menu was not found")
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
-		}
-		updateObj = append(updateObj, bson.E{"menu", food.Price})
+	expect, err := precondition(c, food.Version)
+	if err != nil {
+		return err
 	}
 
-	food.Updated_at = time.Now()
-	updateObj = append(updateObj, bson.E{"updated_at", food.Updated_at})
-
-	upsert := true
-	filter := bson.M{"food_id": foodId}
+	result, version, err := h.foods.Update(ctx, currentUser(c), foodId, expect, food)
+	return respondVersioned(c, result, version, err, "food update failed")
+}
 
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
+func (h *FoodController) GetFoodPrices(c *fiber.Ctx) error {
+	history, err := h.foods.Prices(c.UserContext(), c.Params("food_id"))
+	if err != nil {
+		return serviceError(err, "error occurred while fetching the food item")
 	}
+	return c.JSON(history)
+}
 
-	result, err := foodCollection.UpdateOne(
-		ctx,
-		filter,
-		bson.D{
-			{"$set", updateObj},
-		},
-		&opt,
-	)
-
+func (h *FoodController) DeleteFood(c *fiber.Ctx) error {
+	result, err := h.foods.Delete(c.UserContext(), currentUser(c), c.Params("food_id"))
 	if err != nil {
-		msg := fmt.Sprint("foot item update failed")
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
+		return serviceError(err, "food delete failed")
 	}
 	return c.JSON(result)
 }
 
-func round(num float64) int {
-	return int(num + math.Copysign(0.5, num))
-}
-
-func toFixed(num float64, precision int) float64 {
-	output := math.Pow(10, float64(precision))
-	res := float64(round(num*output)) / output
-	return res
+func (h *FoodController) RestoreFood(c *fiber.Ctx) error {
+	result, err := h.foods.Restore(c.UserContext(), currentUser(c), c.Params("food_id"))
+	if err != nil {
+		return serviceError(err, "food restore failed")
+	}
+	return c.JSON(result)
 }
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCreateFoodIgnoresServerOwnedFields(t *testing.T) {
+	svc, repos := newTestServices(t)
+	foods := NewFoodController(svc.Foods)
+
+	ctx := context.Background()
+	repos.Menus.InsertOne(ctx, bson.M{"menu_id": "m1", "name": "Lunch", "category": "Mains"})
+
+	app := fiber.New(fiber.Config{ErrorHandler: apierrors.ErrorHandler})
+	app.Post("/foods", foods.CreateFood)
+
+	req := httptest.NewRequest(http.MethodPost, "/foods", bytes.NewBufferString(`{
+		"name": "Soup", "price": 4.5, "food_image": "soup.png", "menu_id": "m1",
+		"food_id": "chosen", "ID": "000000000000000000000001", "version": 7, "created_at": "2000-01-01T00:00:00Z"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var food models.Food
+	if err := repos.Foods.FindOne(ctx, bson.M{"name": "Soup"}).Decode(&food); err != nil {
+		t.Fatal(err)
+	}
+	if food.Food_id == "chosen" || food.Food_id != food.ID.Hex() {
+		t.Errorf("food_id = %q, want the generated id %s", food.Food_id, food.ID.Hex())
+	}
+	if food.Version != 1 || food.Created_at.Year() == 2000 {
+		t.Errorf("client set version %d or created_at %s", food.Version, food.Created_at)
+	}
+}
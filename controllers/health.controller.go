@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/database"
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	"github.com/mayankr5/v1/restaurant-management/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HealthController serves the liveness and readiness probes.
+type HealthController struct {
+	store database.Store
+}
+
+func NewHealthController(store database.Store) *HealthController {
+	return &HealthController{store: store}
+}
+
+// Healthz reports that the process is up. It doesn't look at the database, so an outage there
+// doesn't get the service restarted.
+func (h *HealthController) Healthz(c *fiber.Ctx) error {
+	return c.JSON(dto.StatusResponse{Status: "ok"})
+}
+
+// Readyz reports whether the service can take traffic, which it can't while the database is unreachable.
+func (h *HealthController) Readyz(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), 2*time.Second)
+	defer cancel()
+
+	if err := h.store.Ping(ctx); err != nil {
+		logger.From(c).Warn("readiness check failed", "error", err)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(dto.ReadinessResponse{Status: "unavailable", Database: "down"})
+	}
+	return c.JSON(dto.ReadinessResponse{Status: "ready", Database: "up"})
+}
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/database"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type unreachableStore struct {
+	*database.MemoryStore
+}
+
+func (unreachableStore) Ping(ctx context.Context) error {
+	return errors.New("server selection timeout")
+}
+
+func TestReadyzReportsDatabase(t *testing.T) {
+	tests := []struct {
+		name    string
+		store   database.Store
+		healthz int
+		readyz  int
+	}{
+		{"reachable", database.NewMemoryStore(), http.StatusOK, http.StatusOK},
+		{"unreachable", unreachableStore{database.NewMemoryStore()}, http.StatusOK, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := NewHealthController(tt.store)
+			app := fiber.New(fiber.Config{ErrorHandler: apierrors.ErrorHandler})
+			app.Get("/healthz", health.Healthz)
+			app.Get("/readyz", health.Readyz)
+
+			for path, want := range map[string]int{"/healthz": tt.healthz, "/readyz": tt.readyz} {
+				resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.StatusCode != want {
+					t.Errorf("GET %s = %d, want %d", path, resp.StatusCode, want)
+				}
+			}
+		})
+	}
+}
@@ -1,173 +1,124 @@
 package controllers
 
 import (
-	"context"
-	"fmt"
-	"log"
-	"net/http"
-	"time"
-
-	"github.com/mayankr5/v1/restaurant-management/database"
-	"github.com/mayankr5/v1/restaurant-management/models"
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	helper "github.com/mayankr5/v1/restaurant-management/helpers"
+	"github.com/mayankr5/v1/restaurant-management/services"
 
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-type InvoiceViewFormat struct {
-	Invoice_id       string
-	Payment_method   string
-	Order_id         string
-	Payment_status   *string
-	Payment_due      interface{}
-	Table_number     interface{}
-	Payment_due_date time.Time
-	Order_details    interface{}
+// InvoiceController serves the invoices and their refunds.
+type InvoiceController struct {
+	invoices *services.InvoiceService
 }
 
-var invoiceCollection = database.OpenCollection(database.Client, "invoice")
-
-func GetInvoices(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+func NewInvoiceController(invoices *services.InvoiceService) *InvoiceController {
+	return &InvoiceController{invoices: invoices}
+}
 
-	result, err := invoiceCollection.Find(ctx, bson.M{})
+func (h *InvoiceController) GetInvoices(c *fiber.Ctx) error {
+	allInvoices, err := h.invoices.List(c.UserContext())
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "error occurred while listing invoice items"})
-	}
-	defer result.Close(ctx)
-
-	var allInvoices []bson.M
-	if err := result.All(ctx, &allInvoices); err != nil {
-		log.Fatal(err)
+		return serviceError(err, "error occurred while listing invoice items")
 	}
-	return c.JSON(allInvoices)
+	return c.JSON(dto.NewInvoiceResponses(allInvoices))
 }
 
-func GetInvoice(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+func (h *InvoiceController) GetInvoice(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 
 	invoiceId := c.Params("invoice_id")
-	var invoice models.Invoice
 
-	err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice)
+	invoice, err := h.invoices.Get(ctx, invoiceId)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "error occurred while listing invoice item"})
+		return serviceError(err, "error occurred while listing invoice item")
 	}
 
-	var invoiceView InvoiceViewFormat
+	c.Set(fiber.HeaderETag, helper.ETag(invoice.Version))
+	return c.JSON(invoice)
+}
+
+func (h *InvoiceController) CreateInvoice(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 
-	allOrderItems, err := ItemsByOrder(invoice.Order_id)
-	invoiceView.Order_id = invoice.Order_id
-	invoiceView.Payment_due_date = invoice.Payment_due_date
+	var request dto.CreateInvoiceRequest
 
-	invoiceView.Payment_method = "null"
-	if invoice.Payment_method != nil {
-		invoiceView.Payment_method = *invoice.Payment_method
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
 	}
 
-	invoiceView.Invoice_id = invoice.Invoice_id
-	invoiceView.Payment_status = *&invoice.Payment_status
-	invoiceView.Payment_due = allOrderItems[0]["payment_due"]
-	invoiceView.Table_number = allOrderItems[0]["table_number"]
-	invoiceView.Order_details = allOrderItems[0]["order_items"]
+	validationErr := validate.Struct(request)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
+	}
 
-	return c.JSON(invoiceView)
+	invoice, err := h.invoices.Create(ctx, currentUser(c), request.Model())
+	if err != nil {
+		return serviceError(err, "invoice item was not created")
+	}
+	return c.JSON(&mongo.InsertOneResult{InsertedID: invoice.ID})
 }
 
-func CreateInvoice(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+func (h *InvoiceController) UpdateInvoice(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 
-	var invoice models.Invoice
+	var invoice dto.UpdateInvoiceRequest
+	invoiceId := c.Params("invoice_id")
 
 	if err := c.BodyParser(&invoice); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return apierrors.BadRequest(err.Error())
 	}
 
-	var order models.Order
-
-	err := orderCollection.FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order)
-	if err != nil {
-		msg := fmt.Sprintf("message: Order was not found")
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
-	}
-	status := "PENDING"
-	if invoice.Payment_status == nil {
-		invoice.Payment_status = &status
+	if invoice.Payment_status != nil && *invoice.Payment_status != "PENDING" && *invoice.Payment_status != "PAID" {
+		return apierrors.BadRequest("invoices are refunded through the refund endpoint")
 	}
 
-	invoice.Payment_due_date = time.Now().AddDate(0, 0, 1)
-	invoice.Created_at = time.Now()
-	invoice.Updated_at = time.Now()
-	invoice.ID = primitive.NewObjectID()
-	invoice.Invoice_id = invoice.ID.Hex()
-
 	validationErr := validate.Struct(invoice)
 	if validationErr != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": validationErr.Error()})
+		return apierrors.Validation(validationErr)
 	}
 
-	result, insertErr := invoiceCollection.InsertOne(ctx, invoice)
-	if insertErr != nil {
-		msg := fmt.Sprintf("invoice item was not created")
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
+	expect, err := precondition(c, invoice.Version)
+	if err != nil {
+		return err
 	}
 
-	return c.JSON(result)
+	result, version, err := h.invoices.Update(ctx, currentUser(c), invoiceId, expect, invoice)
+	return respondVersioned(c, result, version, err, "invoice update failed")
 }
 
-func UpdateInvoice(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-
-	var invoice models.Invoice
-	invoiceId := c.Params("invoice_id")
-
-	if err := c.BodyParser(&invoice); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
-	}
+func (h *InvoiceController) RefundInvoice(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 
-	filter := bson.M{"invoice_id": invoiceId}
+	var refundRequest dto.RefundRequest
 
-	var updateObj primitive.D
+	invoiceId := c.Params("invoice_id")
 
-	if invoice.Payment_method != nil {
-		updateObj = append(updateObj, bson.E{"payment_method", invoice.Payment_method})
+	if err := c.BodyParser(&refundRequest); err != nil {
+		return apierrors.BadRequest(err.Error())
 	}
 
-	if invoice.Payment_status != nil {
-		updateObj = append(updateObj, bson.E{"payment_status", invoice.Payment_status})
+	validationErr := validate.Struct(refundRequest)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
 	}
 
-	invoice.Updated_at = time.Now()
-	updateObj = append(updateObj, bson.E{"updated_at", invoice.Updated_at})
-
-	upsert := true
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
-	}
+	approval := services.Approval{Email: *refundRequest.Manager_email, Password: *refundRequest.Manager_password, Code: refundRequest.Manager_code, IP: c.IP()}
 
-	status := "PENDING"
-	if invoice.Payment_status == nil {
-		invoice.Payment_status = &status
+	refund, err := h.invoices.Refund(ctx, currentUser(c), invoiceId, *refundRequest.Reason, refundRequest.Order_item_ids, approval)
+	if err != nil {
+		return serviceError(err, "invoice refund failed")
 	}
+	return c.JSON(dto.NewRefundResponse(refund))
+}
 
-	result, err := invoiceCollection.UpdateOne(
-		ctx,
-		filter,
-		bson.D{
-			{"$set", updateObj},
-		},
-		&opt,
-	)
+func (h *InvoiceController) GetInvoiceRefunds(c *fiber.Ctx) error {
+	allRefunds, err := h.invoices.Refunds(c.UserContext(), c.Params("invoice_id"))
 	if err != nil {
-		msg := fmt.Sprintf("invoice item update failed")
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
+		return serviceError(err, "error occurred while listing refunds")
 	}
-
-	return c.JSON(result)
+	return c.JSON(dto.NewRefundResponses(allRefunds))
 }
@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"github.com/mayankr5/v1/restaurant-management/signing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// KeyController publishes the keys tokens are verified with.
+type KeyController struct {
+	keys *signing.KeySet
+}
+
+func NewKeyController(keys *signing.KeySet) *KeyController {
+	return &KeyController{keys: keys}
+}
+
+// GetJWKS returns the public keys tokens are verified with. Keys being rotated in or out are
+// listed too, so verifiers can cache the set.
+func (h *KeyController) GetJWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(h.keys.JWKS())
+}
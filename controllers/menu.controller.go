@@ -1,141 +1,93 @@
 package controllers
 
 import (
-	"context"
-	"fmt"
-	"log"
-	"net/http"
-	"time"
-
-	"github.com/mayankr5/v1/restaurant-management/database"
-	"github.com/mayankr5/v1/restaurant-management/models"
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	helper "github.com/mayankr5/v1/restaurant-management/helpers"
+	"github.com/mayankr5/v1/restaurant-management/services"
 
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var menuCollection *mongo.Collection = database.OpenCollection(database.Client, "menu")
+// MenuController serves the menus.
+type MenuController struct {
+	menus *services.MenuService
+}
 
-func GetMenus(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+func NewMenuController(menus *services.MenuService) *MenuController {
+	return &MenuController{menus: menus}
+}
 
-	result, err := menuCollection.Find(context.TODO(), bson.M{})
+func (h *MenuController) GetMenus(c *fiber.Ctx) error {
+	allMenus, err := h.menus.List(c.UserContext(), c.QueryBool("include_deleted"))
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "error occurred while listing the menu items"})
+		return serviceError(err, "error occurred while listing the menu items")
 	}
-	defer result.Close(ctx)
-
-	var allMenus []bson.M
-	if err := result.All(ctx, &allMenus); err != nil {
-		log.Fatal(err)
-	}
-	return c.JSON(allMenus)
+	return c.JSON(dto.NewMenuResponses(allMenus))
 }
 
-func GetMenu(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-
-	menuId := c.Params("menu_id")
-	var menu models.Menu
-
-	err := menuCollection.FindOne(ctx, bson.M{"menu_id": menuId}).Decode(&menu)
+func (h *MenuController) GetMenu(c *fiber.Ctx) error {
+	menu, err := h.menus.Get(c.UserContext(), c.Params("menu_id"))
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "error occurred while fetching the menu"})
+		return serviceError(err, "error occurred while fetching the menu")
 	}
-	return c.JSON(menu)
+	c.Set(fiber.HeaderETag, helper.ETag(menu.Version))
+	return c.JSON(dto.NewMenuResponse(menu))
 }
 
-func CreateMenu(c *fiber.Ctx) error {
-	var menu models.Menu
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+func (h *MenuController) CreateMenu(c *fiber.Ctx) error {
+	var request dto.CreateMenuRequest
+	ctx := c.UserContext()
 
-	if err := c.BodyParser(&menu); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
 	}
 
-	validationErr := validate.Struct(menu)
+	validationErr := validate.Struct(request)
 	if validationErr != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": validationErr.Error()})
+		return apierrors.Validation(validationErr)
 	}
 
-	menu.Created_at = time.Now()
-	menu.Updated_at = time.Now()
-	menu.ID = primitive.NewObjectID()
-	menu.Menu_id = menu.ID.Hex()
-
-	result, insertErr := menuCollection.InsertOne(ctx, menu)
-	if insertErr != nil {
-		msg := fmt.Sprintf("Menu item was not created")
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
+	menu, err := h.menus.Create(ctx, currentUser(c), request.Model())
+	if err != nil {
+		return serviceError(err, "Menu item was not created")
 	}
-	return c.JSON(result)
+	return c.JSON(&mongo.InsertOneResult{InsertedID: menu.ID})
 }
 
-func UpdateMenu(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+func (h *MenuController) UpdateMenu(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 
-	var menu models.Menu
+	var menu dto.UpdateMenuRequest
 
 	if err := c.BodyParser(&menu); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return apierrors.BadRequest(err.Error())
 	}
 
 	menuId := c.Params("menu_id")
-	filter := bson.M{"menu_id": menuId}
-
-	var updateObj primitive.D
-
-	if menu.Start_Date != nil && menu.End_Date != nil {
-		if !inTimeSpan(*menu.Start_Date, *menu.End_Date, time.Now()) {
-			msg := "kindly retype the time"
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
-		}
-
-		updateObj = append(updateObj, bson.E{"start_date", menu.Start_Date})
-		updateObj = append(updateObj, bson.E{"end_date", menu.End_Date})
-
-		if menu.Name != "" {
-			updateObj = append(updateObj, bson.E{"name", menu.Name})
-		}
-		if menu.Category != "" {
-			updateObj = append(updateObj, bson.E{"name", menu.Category})
-		}
-
-		menu.Updated_at = time.Now()
-		updateObj = append(updateObj, bson.E{"updated_at", menu.Updated_at})
-
-		upsert := true
-
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
-		}
-
-		result, err := menuCollection.UpdateOne(
-			ctx,
-			filter,
-			bson.D{
-				{"$set", updateObj},
-			},
-			&opt,
-		)
-
-		if err != nil {
-			msg := "Menu update failed"
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
-		}
-
-		return c.JSON(result)
+
+	expect, err := precondition(c, menu.Version)
+	if err != nil {
+		return err
 	}
-	return nil
+
+	result, version, err := h.menus.Update(ctx, currentUser(c), menuId, expect, menu)
+	return respondVersioned(c, result, version, err, "menu update failed")
 }
 
-func inTimeSpan(start, end, check time.Time) bool {
-	return start.After(time.Now()) && end.After(start)
+func (h *MenuController) DeleteMenu(c *fiber.Ctx) error {
+	result, err := h.menus.Delete(c.UserContext(), currentUser(c), c.Params("menu_id"))
+	if err != nil {
+		return serviceError(err, "menu delete failed")
+	}
+	return c.JSON(result)
+}
+
+func (h *MenuController) RestoreMenu(c *fiber.Ctx) error {
+	result, err := h.menus.Restore(c.UserContext(), currentUser(c), c.Params("menu_id"))
+	if err != nil {
+		return serviceError(err, "menu restore failed")
+	}
+	return c.JSON(result)
 }
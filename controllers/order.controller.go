@@ -1,149 +1,94 @@
 package controllers
 
 import (
-	"context"
-	"fmt"
-	"log"
-	"net/http"
-	"time"
-
-	"github.com/mayankr5/v1/restaurant-management/database"
-	"github.com/mayankr5/v1/restaurant-management/models"
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	helper "github.com/mayankr5/v1/restaurant-management/helpers"
+	"github.com/mayankr5/v1/restaurant-management/services"
 
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-
-var orderCollection *mongo.Collection = database.OpenCollection(database.Client, "order")
+// OrderController serves the orders and their items.
+type OrderController struct {
+	orders *services.OrderService
+}
 
-func GetOrders(c *fiber.Ctx) error {
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+func NewOrderController(orders *services.OrderService) *OrderController {
+	return &OrderController{orders: orders}
+}
 
-	result, err := orderCollection.Find(context.TODO(), bson.M{})
+func (h *OrderController) GetOrders(c *fiber.Ctx) error {
+	allOrders, err := h.orders.List(c.UserContext(), c.QueryBool("include_deleted"))
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "error occurred while listing order items"})
+		return serviceError(err, "error occurred while listing order items")
 	}
-	defer result.Close(ctx)
-
-	var allOrders []bson.M
-	if err = result.All(ctx, &allOrders); err != nil {
-		log.Fatal(err)
-	}
-	return c.JSON(allOrders)
+	return c.JSON(dto.NewOrderResponses(allOrders))
 }
 
-func GetOrder(c *fiber.Ctx) error {
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-
-	orderId := c.Params("order_id")
-	var order models.Order
-
-	err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order)
+func (h *OrderController) GetOrder(c *fiber.Ctx) error {
+	order, err := h.orders.Get(c.UserContext(), c.Params("order_id"))
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "error occurred while fetching the orders"})
+		return serviceError(err, "error occurred while fetching the orders")
 	}
-	return c.JSON(order)
+	c.Set(fiber.HeaderETag, helper.ETag(order.Version))
+	return c.JSON(dto.NewOrderResponse(order))
 }
 
-func CreateOrder(c *fiber.Ctx) error {
-	var table models.Table
-	var order models.Order
+func (h *OrderController) CreateOrder(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 
-	if err := c.BodyParser(&order); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	var request dto.CreateOrderRequest
+
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
 	}
 
-	validationErr := validate.Struct(order)
+	validationErr := validate.Struct(request)
 
 	if validationErr != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": validationErr.Error()})
-	}
-
-	if order.Table_id != nil {
-		err := tableCollection.FindOne(ctx, bson.M{"table_id": order.Table_id}).Decode(&table)
-		if err != nil {
-			msg := fmt.Sprintf("message:Table was not found")
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
-		}
+		return apierrors.Validation(validationErr)
 	}
 
-	order.Created_at = time.Now()
-	order.Updated_at = time.Now()
-	order.ID = primitive.NewObjectID()
-	order.Order_id = order.ID.Hex()
-
-	result, insertErr := orderCollection.InsertOne(ctx, order)
-
-	if insertErr != nil {
-		msg := fmt.Sprintf("order item was not created")
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
+	order, err := h.orders.Create(ctx, currentUser(c), request.Model())
+	if err != nil {
+		return serviceError(err, "order item was not created")
 	}
-
-	return c.JSON(result)
+	return c.JSON(&mongo.InsertOneResult{InsertedID: order.ID})
 }
 
-func UpdateOrder(c *fiber.Ctx) error {
-	var table models.Table
-	var order models.Order
+func (h *OrderController) UpdateOrder(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 
-	var updateObj primitive.D
+	var order dto.UpdateOrderRequest
 
 	orderId := c.Params("order_id")
 	if err := c.BodyParser(&order); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
-	}
-
-	if order.Table_id != nil {
-		err := menuCollection.FindOne(ctx, bson.M{"tabled_id": order.Table_id}).Decode(&table)
-		if err != nil {
-			msg := fmt.Sprintf("message:Menu was not found")
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
-		}
-		updateObj = append(updateObj, bson.E{"menu", order.Table_id})
+		return apierrors.BadRequest(err.Error())
 	}
 
-	order.Updated_at = time.Now()
-	updateObj = append(updateObj, bson.E{"updated_at", order.Updated_at})
-
-	upsert := true
-
-	filter := bson.M{"order_id": orderId}
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
+	expect, err := precondition(c, order.Version)
+	if err != nil {
+		return err
 	}
 
-	result, err := orderCollection.UpdateOne(
-		ctx,
-		filter,
-		bson.D{
-			{"$st", updateObj},
-		},
-		&opt,
-	)
+	result, version, err := h.orders.Update(ctx, currentUser(c), orderId, expect, order)
+	return respondVersioned(c, result, version, err, "order update failed")
+}
 
+func (h *OrderController) DeleteOrder(c *fiber.Ctx) error {
+	result, err := h.orders.Delete(c.UserContext(), currentUser(c), c.Params("order_id"))
 	if err != nil {
-		msg := fmt.Sprintf("order item update failed")
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
+		return serviceError(err, "order delete failed")
 	}
-
 	return c.JSON(result)
 }
 
-func OrderItemOrderCreator(order models.Order) string {
-
-	order.Created_at = time.Now()
-	order.Updated_at = time.Now()
-	order.ID = primitive.NewObjectID()
-	order.Order_id = order.ID.Hex()
-
-	orderCollection.InsertOne(ctx, order)
-
-	return order.Order_id
+func (h *OrderController) RestoreOrder(c *fiber.Ctx) error {
+	result, err := h.orders.Restore(c.UserContext(), currentUser(c), c.Params("order_id"))
+	if err != nil {
+		return serviceError(err, "order restore failed")
+	}
+	return c.JSON(result)
 }
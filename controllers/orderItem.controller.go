@@ -1,222 +1,107 @@
 package controllers
 
 import (
-	"context"
-	"log"
-	"net/http"
-	"time"
-
-	"github.com/mayankr5/v1/restaurant-management/database"
-	"github.com/mayankr5/v1/restaurant-management/models"
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	helper "github.com/mayankr5/v1/restaurant-management/helpers"
+	"github.com/mayankr5/v1/restaurant-management/services"
 
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type OrderItemPack struct {
-	Table_id    *string
-	Order_items []models.OrderItem
-}
-
-var orderItemCollection *mongo.Collection = database.OpenCollection(database.Client, "orderItem")
-
-func GetOrderItems(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-
-	result, err := orderItemCollection.Find(context.TODO(), bson.M{})
+func (h *OrderController) GetOrderItems(c *fiber.Ctx) error {
+	allOrderItems, err := h.orders.Items(c.UserContext())
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "error occurred while listing ordered items"})
+		return serviceError(err, "error occurred while listing ordered items")
 	}
-	defer result.Close(ctx)
-
-	var allOrderItems []bson.M
-	if err := result.All(ctx, &allOrderItems); err != nil {
-		log.Fatal(err)
-	}
-	return c.JSON(allOrderItems)
+	return c.JSON(dto.NewOrderItemResponses(allOrderItems))
 }
 
-func GetOrderItemsByOrder(c *fiber.Ctx) error {
-	orderId := c.Params("order_id")
-
-	allOrderItems, err := ItemsByOrder(orderId)
-
+func (h *OrderController) GetOrderItemsByOrder(c *fiber.Ctx) error {
+	allOrderItems, err := h.orders.ItemsByOrder(c.UserContext(), c.Params("order_id"))
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "error occurred while listing order items by order ID"})
+		return serviceError(err, "error occurred while listing order items by order ID")
 	}
 	return c.JSON(allOrderItems)
 }
 
-func ItemsByOrder(id string) ([]primitive.M, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-
-	matchStage := bson.D{{"$match", bson.D{{"order_id", id}}}}
-	lookupStage := bson.D{{"$lookup", bson.D{{"from", "food"}, {"localField", "food_id"}, {"foreignField", "food_id"}, {"as", "food"}}}}
-	unwindStage := bson.D{{"$unwind", bson.D{{"path", "$food"}, {"preserveNullAndEmptyArrays", true}}}}
-
-	lookupOrderStage := bson.D{{"$lookup", bson.D{{"from", "order"}, {"localField", "order_id"}, {"foreignField", "order_id"}, {"as", "order"}}}}
-	unwindOrderStage := bson.D{{"$unwind", bson.D{{"path", "$order"}, {"preserveNullAndEmptyArrays", true}}}}
-
-	lookupTableStage := bson.D{{"$lookup", bson.D{{"from", "table"}, {"localField", "order.table_id"}, {"foreignField", "table_id"}, {"as", "table"}}}}
-	unwindTableStage := bson.D{{"$unwind", bson.D{{"path", "$table"}, {"preserveNullAndEmptyArrays", true}}}}
-
-	projectStage := bson.D{
-		{"$project", bson.D{
-			{"id", 0},
-			{"amount", "$food.price"},
-			{"total_count", 1},
-			{"food_name", "$food.name"},
-			{"food_image", "$food.food_image"},
-			{"table_number", "$table.table_number"},
-			{"table_id", "$table.table_id"},
-			{"order_id", "$order.order_id"},
-			{"price", "$food.price"},
-			{"quantity", 1},
-		}}}
-
-	groupStage := bson.D{{"$group", bson.D{{"_id", bson.D{{"order_id", "$order_id"}, {"table_id", "$table_id"}, {"table_number", "$table_number"}}}, {"payment_due", bson.D{{"$sum", "$amount"}}}, {"total_count", bson.D{{"$sum", 1}}}, {"order_items", bson.D{{"$push", "$$ROOT"}}}}}}
-
-	projectStage2 := bson.D{
-		{"$project", bson.D{
-
-			{"id", 0},
-			{"payment_due", 1},
-			{"total_count", 1},
-			{"table_number", "$_id.table_number"},
-			{"order_items", 1},
-		}}}
-
-	result, err := orderItemCollection.Aggregate(ctx, mongo.Pipeline{
-		matchStage,
-		lookupStage,
-		unwindStage,
-		lookupOrderStage,
-		unwindOrderStage,
-		lookupTableStage,
-		unwindTableStage,
-		projectStage,
-		groupStage,
-		projectStage2})
-
+func (h *OrderController) GetOrderItem(c *fiber.Ctx) error {
+	orderItem, err := h.orders.Item(c.UserContext(), c.Params("order_item_id"))
 	if err != nil {
-		return nil, err
-	}
-
-	var OrderItems []primitive.M
-	if err := result.All(ctx, &OrderItems); err != nil {
-		return nil, err
+		return serviceError(err, "error occurred while listing ordered item")
 	}
-
-	return OrderItems, nil
+	c.Set(fiber.HeaderETag, helper.ETag(orderItem.Version))
+	return c.JSON(dto.NewOrderItemResponse(orderItem))
 }
 
-func GetOrderItem(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-
-	orderItemId := c.Params("order_item_id")
-	var orderItem models.OrderItem
-
-	err := orderItemCollection.FindOne(ctx, bson.M{"orderItem_id": orderItemId}).Decode(&orderItem)
-	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "error occurred while listing ordered item"})
-	}
-	return c.JSON(orderItem)
-}
+func (h *OrderController) UpdateOrderItem(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 
-func UpdateOrderItem(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-
-	var orderItem models.OrderItem
+	var orderItem dto.UpdateOrderItemRequest
 
 	orderItemId := c.Params("order_item_id")
 
-	filter := bson.M{"order_item_id": orderItemId}
-
-	var updateObj primitive.D
-
-	if orderItem.Unit_price != nil {
-		updateObj = append(updateObj, bson.E{"unit_price", *&orderItem.Unit_price})
+	if err := c.BodyParser(&orderItem); err != nil {
+		return apierrors.BadRequest(err.Error())
 	}
 
-	if orderItem.Quantity != nil {
-		updateObj = append(updateObj, bson.E{"quantity", *orderItem.Quantity})
+	validationErr := validate.Struct(orderItem)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
 	}
 
-	if orderItem.Food_id != nil {
-		updateObj = append(updateObj, bson.E{"food_id", *orderItem.Food_id})
+	expect, err := precondition(c, orderItem.Version)
+	if err != nil {
+		return err
 	}
 
-	orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	updateObj = append(updateObj, bson.E{"updated_at", orderItem.Updated_at})
+	result, version, err := h.orders.UpdateItem(ctx, currentUser(c), orderItemId, expect, orderItem)
+	return respondVersioned(c, result, version, err, "orderItem update failed")
+}
+
+func (h *OrderController) CreateOrderItem(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	var orderItemPack dto.OrderItemPack
 
-	upsert := true
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
+	if err := c.BodyParser(&orderItemPack); err != nil {
+		return apierrors.BadRequest(err.Error())
 	}
 
-	result, err := orderItemCollection.UpdateOne(
-		ctx,
-		filter,
-		bson.D{
-			{"$set", updateObj},
-		},
-		&opt,
-	)
+	validationErr := validate.Struct(orderItemPack)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
+	}
 
+	order, orderItems, err := h.orders.Place(ctx, currentUser(c), *orderItemPack.Table_id, orderItemPack.Order_items)
 	if err != nil {
-		msg := "Order item update failed"
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
+		return serviceError(err, "order was not created")
 	}
 
-	return c.JSON(result)
+	return c.JSON(dto.OrderViewFormat{Order: dto.NewOrderResponse(order), Order_items: dto.NewOrderItemResponses(orderItems)})
 }
 
-func CreateOrderItem(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+func (h *OrderController) VoidOrderItem(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 
-	var orderItemPack OrderItemPack
-	var order models.Order
+	var voidRequest dto.VoidRequest
 
-	if err := c.BodyParser(&orderItemPack); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
-	}
-
-	order.Order_Date, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-
-	orderItemsToBeInserted := []interface{}{}
-	order.Table_id = orderItemPack.Table_id
-	order_id := OrderItemOrderCreator(order)
-
-	for _, orderItem := range orderItemPack.Order_items {
-		orderItem.Order_id = order_id
+	orderItemId := c.Params("order_item_id")
 
-		validationErr := validate.Struct(orderItem)
+	if err := c.BodyParser(&voidRequest); err != nil {
+		return apierrors.BadRequest(err.Error())
+	}
 
-		if validationErr != nil {
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": validationErr.Error()})
-		}
-		orderItem.ID = primitive.NewObjectID()
-		orderItem.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		orderItem.Order_item_id = orderItem.ID.Hex()
-		var num = toFixed(*orderItem.Unit_price, 2)
-		orderItem.Unit_price = &num
-		orderItemsToBeInserted = append(orderItemsToBeInserted, orderItem)
+	validationErr := validate.Struct(voidRequest)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
 	}
 
-	insertedOrderItems, err := orderItemCollection.InsertMany(ctx, orderItemsToBeInserted)
+	approval := services.Approval{Email: *voidRequest.Manager_email, Password: *voidRequest.Manager_password, Code: voidRequest.Manager_code, IP: c.IP()}
 
+	result, err := h.orders.VoidItem(ctx, currentUser(c), orderItemId, *voidRequest.Reason, approval)
 	if err != nil {
-		log.Fatal(err)
+		return serviceError(err, "order item void failed")
 	}
-
-	return c.JSON(insertedOrderItems)
+	return c.JSON(result)
 }
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	"github.com/mayankr5/v1/restaurant-management/repositories"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func setupOrderItemTest(t *testing.T) (*fiber.App, *repositories.Repositories) {
+	t.Helper()
+
+	svc, repos := newTestServices(t)
+
+	ctx := context.Background()
+	repos.Tables.InsertOne(ctx, bson.M{"table_id": "t1", "table_number": 4, "number_of_guests": 2})
+	repos.Foods.InsertOne(ctx, bson.M{"food_id": "f1", "name": "Soup", "price": 4.5, "menu_id": "m1"})
+	repos.Foods.InsertOne(ctx, bson.M{"food_id": "f2", "name": "Bread", "price": 2.0, "menu_id": "m1"})
+
+	app := fiber.New(fiber.Config{ErrorHandler: apierrors.ErrorHandler})
+	app.Post("/orderItems", NewOrderController(svc.Orders).CreateOrderItem)
+	return app, repos
+}
+
+func postOrderItems(t *testing.T, app *fiber.App, body string) *http.Response {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/orderItems", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestCreateOrderItemCreatesOrderAndItems(t *testing.T) {
+	app, repos := setupOrderItemTest(t)
+
+	resp := postOrderItems(t, app, `{"table_id": "t1", "order_items": [
+		{"food_id": "f1", "quantity": "M"},
+		{"food_id": "f2", "quantity": "S"}]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var view dto.OrderViewFormat
+	if err := json.NewDecoder(resp.Body).Decode(&view); err != nil {
+		t.Fatal(err)
+	}
+	if view.Order.Order_id == "" || len(view.Order_items) != 2 {
+		t.Fatalf("unexpected response %+v", view)
+	}
+	for _, orderItem := range view.Order_items {
+		if orderItem.Order_id != view.Order.Order_id {
+			t.Errorf("item %s belongs to order %s, want %s", orderItem.Order_item_id, orderItem.Order_id, view.Order.Order_id)
+		}
+	}
+	if *view.Order_items[0].Unit_price != 4.5 || *view.Order_items[0].Food_name != "Soup" {
+		t.Errorf("item did not snapshot the food: %+v", view.Order_items[0])
+	}
+
+	if count, _ := repos.OrderItems.CountDocuments(context.Background(), bson.M{"order_id": view.Order.Order_id}); count != 2 {
+		t.Errorf("stored %d order items, want 2", count)
+	}
+}
+
+func TestCreateOrderItemWritesNothingWhenInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"unknown food", `{"table_id": "t1", "order_items": [{"food_id": "f1", "quantity": "M"}, {"food_id": "nope", "quantity": "M"}]}`},
+		{"unknown table", `{"table_id": "nope", "order_items": [{"food_id": "f1", "quantity": "M"}]}`},
+		{"invalid item", `{"table_id": "t1", "order_items": [{"food_id": "f1", "quantity": "M"}, {"food_id": "f2", "quantity": "XL"}]}`},
+		{"no items", `{"table_id": "t1", "order_items": []}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, repos := setupOrderItemTest(t)
+
+			resp := postOrderItems(t, app, tt.body)
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", resp.StatusCode)
+			}
+
+			ctx := context.Background()
+			orders, _ := repos.Orders.CountDocuments(ctx, bson.M{})
+			orderItems, _ := repos.OrderItems.CountDocuments(ctx, bson.M{})
+			if orders != 0 || orderItems != 0 {
+				t.Errorf("wrote %d orders and %d order items, want none", orders, orderItems)
+			}
+		})
+	}
+}
+
+func TestCreateOrderItemRollsBackOrderWhenItemsFail(t *testing.T) {
+	app, repos := setupOrderItemTest(t)
+
+	// an order item that already exists makes InsertMany fail after the order was written
+	ctx := context.Background()
+	err := repos.WithTransaction(ctx, func(ctx context.Context) error {
+		if _, err := repos.Orders.InsertOne(ctx, bson.M{"order_id": "o1"}); err != nil {
+			return err
+		}
+		_, err := repos.OrderItems.InsertMany(ctx, []interface{}{bson.M{"_id": "dup"}, bson.M{"_id": "dup"}})
+		return err
+	})
+	if err == nil {
+		t.Fatal("expected the duplicate insert to fail")
+	}
+
+	if count, _ := repos.Orders.CountDocuments(ctx, bson.M{}); count != 0 {
+		t.Errorf("found %d orders after rollback, want 0", count)
+	}
+	if count, _ := repos.OrderItems.CountDocuments(ctx, bson.M{}); count != 0 {
+		t.Errorf("found %d order items after rollback, want 0", count)
+	}
+
+	resp := postOrderItems(t, app, `{"table_id": "t1", "order_items": [{"food_id": "f1", "quantity": "M"}]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+}
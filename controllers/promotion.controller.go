@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	helper "github.com/mayankr5/v1/restaurant-management/helpers"
+	"github.com/mayankr5/v1/restaurant-management/services"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PromotionController serves the promotions and coupons.
+type PromotionController struct {
+	promotions *services.PromotionService
+}
+
+func NewPromotionController(promotions *services.PromotionService) *PromotionController {
+	return &PromotionController{promotions: promotions}
+}
+
+func (h *PromotionController) GetPromotions(c *fiber.Ctx) error {
+	allPromotions, err := h.promotions.List(c.UserContext())
+	if err != nil {
+		return serviceError(err, "error occurred while listing promotions")
+	}
+	return c.JSON(dto.NewPromotionResponses(allPromotions))
+}
+
+func (h *PromotionController) GetPromotion(c *fiber.Ctx) error {
+	promotion, err := h.promotions.Get(c.UserContext(), c.Params("promotion_id"))
+	if err != nil {
+		return serviceError(err, "error occurred while fetching the promotion")
+	}
+	c.Set(fiber.HeaderETag, helper.ETag(promotion.Version))
+	return c.JSON(dto.NewPromotionResponse(promotion))
+}
+
+func (h *PromotionController) CreatePromotion(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	var request dto.CreatePromotionRequest
+
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
+	}
+
+	validationErr := validate.Struct(request)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
+	}
+
+	promotion, err := h.promotions.Create(ctx, currentUser(c), request.Model())
+	if err != nil {
+		return serviceError(err, "promotion was not created")
+	}
+	return c.JSON(&mongo.InsertOneResult{InsertedID: promotion.ID})
+}
+
+func (h *PromotionController) UpdatePromotion(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	var promotion dto.UpdatePromotionRequest
+
+	promotionId := c.Params("promotion_id")
+
+	if err := c.BodyParser(&promotion); err != nil {
+		return apierrors.BadRequest(err.Error())
+	}
+
+	validationErr := validate.Struct(promotion)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
+	}
+
+	expect, err := precondition(c, promotion.Version)
+	if err != nil {
+		return err
+	}
+
+	result, version, err := h.promotions.Update(ctx, currentUser(c), promotionId, expect, promotion)
+	return respondVersioned(c, result, version, err, "promotion update failed")
+}
+
+func (h *PromotionController) GetCoupons(c *fiber.Ctx) error {
+	allCoupons, err := h.promotions.Coupons(c.UserContext())
+	if err != nil {
+		return serviceError(err, "error occurred while listing coupons")
+	}
+	return c.JSON(dto.NewCouponResponses(allCoupons))
+}
+
+func (h *PromotionController) GetCoupon(c *fiber.Ctx) error {
+	coupon, err := h.promotions.Coupon(c.UserContext(), c.Params("coupon_id"))
+	if err != nil {
+		return serviceError(err, "error occurred while fetching the coupon")
+	}
+	c.Set(fiber.HeaderETag, helper.ETag(coupon.Version))
+	return c.JSON(dto.NewCouponResponse(coupon))
+}
+
+func (h *PromotionController) CreateCoupon(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	var request dto.CreateCouponRequest
+
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
+	}
+
+	validationErr := validate.Struct(request)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
+	}
+
+	coupon, err := h.promotions.CreateCoupon(ctx, currentUser(c), request.Model())
+	if err != nil {
+		return serviceError(err, "coupon was not created")
+	}
+	return c.JSON(&mongo.InsertOneResult{InsertedID: coupon.ID})
+}
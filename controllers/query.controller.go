@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// pageOf reads the page of a list from ?page and ?recordPerPage, size items by default. An
+// explicit ?startIndex takes precedence over the page.
+func pageOf(c *fiber.Ctx, size int) services.Page {
+	recordPerPage, err := strconv.Atoi(c.Query("recordPerPage"))
+	if err != nil || recordPerPage < 1 {
+		recordPerPage = size
+	}
+
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	startIndex, err := strconv.Atoi(c.Query("startIndex"))
+	if err != nil || startIndex < 0 {
+		startIndex = (page - 1) * recordPerPage
+	}
+	return services.Page{Offset: startIndex, Limit: recordPerPage}
+}
+
+// periodOf reads a period from ?from and ?to, either of which may be left out.
+func periodOf(c *fiber.Ctx) (services.Period, error) {
+	from, err := timeQuery(c, "from")
+	if err != nil {
+		return services.Period{}, err
+	}
+	to, err := timeQuery(c, "to")
+	if err != nil {
+		return services.Period{}, err
+	}
+	return services.Period{From: from, To: to}, nil
+}
+
+func timeQuery(c *fiber.Ctx, key string) (*time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, apierrors.BadRequest(key + " must be an RFC3339 timestamp")
+	}
+	return &parsed, nil
+}
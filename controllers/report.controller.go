@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"github.com/mayankr5/v1/restaurant-management/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReportController serves the sales and promotion reports.
+type ReportController struct {
+	reports *services.ReportService
+}
+
+func NewReportController(reports *services.ReportService) *ReportController {
+	return &ReportController{reports: reports}
+}
+
+func (h *ReportController) GetSalesReport(c *fiber.Ctx) error {
+	period, err := periodOf(c)
+	if err != nil {
+		return err
+	}
+
+	report, err := h.reports.Sales(c.UserContext(), currentUser(c), period)
+	if err != nil {
+		return serviceError(err, "error occurred while building the sales report")
+	}
+	return c.JSON(report)
+}
+
+func (h *ReportController) GetPromotionReport(c *fiber.Ctx) error {
+	period, err := periodOf(c)
+	if err != nil {
+		return err
+	}
+
+	report, err := h.reports.Promotions(c.UserContext(), period)
+	if err != nil {
+		return serviceError(err, "error occurred while building the promotion report")
+	}
+	return c.JSON(report)
+}
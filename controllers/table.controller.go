@@ -1,129 +1,94 @@
 package controllers
 
 import (
-	"context"
-	"fmt"
-	"log"
-	"net/http"
-	"time"
-
-	"github.com/mayankr5/v1/restaurant-management/database"
-	"github.com/mayankr5/v1/restaurant-management/models"
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	helper "github.com/mayankr5/v1/restaurant-management/helpers"
+	"github.com/mayankr5/v1/restaurant-management/services"
 
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var tableCollection *mongo.Collection = database.OpenCollection(database.Client, "table")
+// TableController serves the tables.
+type TableController struct {
+	tables *services.TableService
+}
 
-func GetTables(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+func NewTableController(tables *services.TableService) *TableController {
+	return &TableController{tables: tables}
+}
 
-	result, err := orderCollection.Find(context.TODO(), bson.M{})
+func (h *TableController) GetTables(c *fiber.Ctx) error {
+	allTables, err := h.tables.List(c.UserContext(), c.QueryBool("include_deleted"))
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "error occurred while listing table items"})
+		return serviceError(err, "error occurred while listing table items")
 	}
-	defer result.Close(ctx)
-
-	var allTables []bson.M
-	if err = result.All(ctx, &allTables); err != nil {
-		log.Fatal(err)
-	}
-	return c.JSON(allTables)
+	return c.JSON(dto.NewTableResponses(allTables))
 }
 
-func GetTable(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-
-	tableId := c.Params("table_id")
-	var table models.Table
-
-	err := tableCollection.FindOne(ctx, bson.M{"table_id": tableId}).Decode(&table)
+func (h *TableController) GetTable(c *fiber.Ctx) error {
+	table, err := h.tables.Get(c.UserContext(), c.Params("table_id"))
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "error occurred while fetching the tables"})
+		return serviceError(err, "error occurred while fetching the tables")
 	}
-	return c.JSON(table)
+	c.Set(fiber.HeaderETag, helper.ETag(table.Version))
+	return c.JSON(dto.NewTableResponse(table))
 }
 
-func CreateTable(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+func (h *TableController) CreateTable(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 
-	var table models.Table
+	var request dto.CreateTableRequest
 
-	if err := c.BodyParser(&table); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
 	}
 
-	validationErr := validate.Struct(table)
+	validationErr := validate.Struct(request)
 	if validationErr != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": validationErr.Error()})
+		return apierrors.Validation(validationErr)
 	}
 
-	table.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	table.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-
-	table.ID = primitive.NewObjectID()
-	table.Table_id = table.ID.Hex()
-
-	result, insertErr := tableCollection.InsertOne(ctx, table)
-	if insertErr != nil {
-		msg := fmt.Sprintf("Table item was not created")
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
+	table, err := h.tables.Create(ctx, currentUser(c), request.Model())
+	if err != nil {
+		return serviceError(err, "Table item was not created")
 	}
-
-	return c.JSON(result)
+	return c.JSON(&mongo.InsertOneResult{InsertedID: table.ID})
 }
 
-func UpdateTable(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+func (h *TableController) UpdateTable(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 
-	var table models.Table
+	var table dto.UpdateTableRequest
 
 	tableId := c.Params("table_id")
 
 	if err := c.BodyParser(&table); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
-	}
-
-	var updateObj primitive.D
-
-	if table.Number_of_guests != nil {
-		updateObj = append(updateObj, bson.E{"number_of_guests", table.Number_of_guests})
+		return apierrors.BadRequest(err.Error())
 	}
 
-	if table.Table_number != nil {
-		updateObj = append(updateObj, bson.E{"table_number", table.Table_number})
+	expect, err := precondition(c, table.Version)
+	if err != nil {
+		return err
 	}
 
-	table.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	result, version, err := h.tables.Update(ctx, currentUser(c), tableId, expect, table)
+	return respondVersioned(c, result, version, err, "table update failed")
+}
 
-	upsert := true
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
+func (h *TableController) DeleteTable(c *fiber.Ctx) error {
+	result, err := h.tables.Delete(c.UserContext(), currentUser(c), c.Params("table_id"))
+	if err != nil {
+		return serviceError(err, "table delete failed")
 	}
+	return c.JSON(result)
+}
 
-	filter := bson.M{"table_id": tableId}
-
-	result, err := tableCollection.UpdateOne(
-		ctx,
-		filter,
-		bson.D{
-			{"$set", updateObj},
-		},
-		&opt,
-	)
-
+func (h *TableController) RestoreTable(c *fiber.Ctx) error {
+	result, err := h.tables.Restore(c.UserContext(), currentUser(c), c.Params("table_id"))
 	if err != nil {
-		msg := fmt.Sprintf("table item update failed")
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
+		return serviceError(err, "table restore failed")
 	}
-
 	return c.JSON(result)
 }
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	"github.com/mayankr5/v1/restaurant-management/logger"
+	"github.com/mayankr5/v1/restaurant-management/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TerminalController serves the POS terminals and their PIN logins.
+type TerminalController struct {
+	terminals *services.TerminalService
+}
+
+func NewTerminalController(terminals *services.TerminalService) *TerminalController {
+	return &TerminalController{terminals: terminals}
+}
+
+func (h *TerminalController) GetTerminals(c *fiber.Ctx) error {
+	allTerminals, err := h.terminals.List(c.UserContext())
+	if err != nil {
+		return serviceError(err, "error occurred while listing terminals")
+	}
+	return c.JSON(dto.NewTerminalResponses(allTerminals))
+}
+
+// RegisterTerminal answers with the terminal's key, the only time it is shown.
+func (h *TerminalController) RegisterTerminal(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	var request dto.RegisterTerminalRequest
+
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
+	}
+
+	validationErr := validate.Struct(request)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
+	}
+
+	terminal, key, err := h.terminals.Register(ctx, currentUser(c), request.Model())
+	if err != nil {
+		return serviceError(err, "terminal was not registered")
+	}
+	return c.JSON(dto.NewTerminalRegistrationResponse(terminal, key))
+}
+
+func (h *TerminalController) RevokeTerminal(c *fiber.Ctx) error {
+	if err := h.terminals.Revoke(c.UserContext(), currentUser(c), c.Params("terminal_id")); err != nil {
+		return serviceError(err, "error occurred while revoking the terminal")
+	}
+	return c.JSON(dto.StatusResponse{Status: "revoked"})
+}
+
+// TerminalLogin signs a user in on a terminal with their PIN, taking over from whoever used it before.
+func (h *TerminalController) TerminalLogin(c *fiber.Ctx) error {
+	ctx := logger.WithContext(c.UserContext(), logger.From(c).With("ip", c.IP()))
+
+	var request dto.TerminalLoginRequest
+
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
+	}
+
+	validationErr := validate.Struct(request)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
+	}
+
+	login, err := h.terminals.Login(ctx, request.Terminal_id, request.Terminal_key, request.User_id, request.Pin)
+	if err != nil {
+		return serviceError(err, "error occurred while signing in on the terminal")
+	}
+	return c.JSON(dto.NewTerminalLoginResponse(login.User, request.Terminal_id, login.Token, login.Expires_at))
+}
+
+// TerminalLogout ends the session of the token it is called with, which must come from a PIN login.
+func (h *TerminalController) TerminalLogout(c *fiber.Ctx) error {
+	terminalId, _ := c.Locals("terminal_id").(string)
+	sessionId, _ := c.Locals("session_id").(string)
+
+	if err := h.terminals.Logout(c.UserContext(), currentUser(c), terminalId, sessionId); err != nil {
+		return serviceError(err, "error occurred while signing out of the terminal")
+	}
+	return c.JSON(dto.StatusResponse{Status: "signed out"})
+}
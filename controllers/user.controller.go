@@ -1,175 +1,300 @@
 package controllers
 
 import (
-	"context"
-	"fmt"
-	"log"
-	"net/http"
-	"strconv"
-	"time"
-
-	"github.com/mayankr5/v1/restaurant-management/database"
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/dto"
 	helper "github.com/mayankr5/v1/restaurant-management/helpers"
-	"github.com/mayankr5/v1/restaurant-management/models"
+	"github.com/mayankr5/v1/restaurant-management/logger"
+	"github.com/mayankr5/v1/restaurant-management/services"
 
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"golang.org/x/crypto/bcrypt"
 )
 
-var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
+// UserController serves the users, their logins and second factors.
+type UserController struct {
+	users *services.UserService
+}
 
-func GetUsers(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+func NewUserController(users *services.UserService) *UserController {
+	return &UserController{users: users}
+}
 
-	recordPerPage, err := strconv.Atoi(c.Query("recordPerPage"))
-	if err != nil || recordPerPage < 1 {
-		recordPerPage = 10
+func (h *UserController) GetUsers(c *fiber.Ctx) error {
+	total, users, err := h.users.List(c.UserContext(), pageOf(c, 10), c.QueryBool("include_deleted"))
+	if err != nil {
+		return serviceError(err, "error occurred while listing user items")
 	}
+	return c.JSON(dto.UserPage{Total_count: total, User_items: dto.NewUserResponses(users)})
+}
 
-	page, err1 := strconv.Atoi(c.Query("page"))
-	if err1 != nil || page < 1 {
-		page = 1
+func (h *UserController) GetUser(c *fiber.Ctx) error {
+	user, err := h.users.Get(c.UserContext(), c.Params("user_id"))
+	if err != nil {
+		return serviceError(err, "error occurred while listing user items")
 	}
+	c.Set(fiber.HeaderETag, helper.ETag(user.Version))
+	return c.JSON(dto.NewUserResponse(user))
+}
 
-	startIndex := (page - 1) * recordPerPage
-	startIndex, err = strconv.Atoi(c.Query("startIndex"))
+func (h *UserController) SignUp(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 
-	matchStage := bson.D{{"$match", bson.D{{}}}}
-	projectStage := bson.D{
-		{"$project", bson.D{
-			{"_id", 0},
-			{"total_count", 1},
-			{"user_items", bson.D{{"$slice", []interface{}{"$data", startIndex, recordPerPage}}}},
-		}}}
+	var request dto.SignUpRequest
 
-	result, err := userCollection.Aggregate(ctx, mongo.Pipeline{
-		matchStage, projectStage})
-	defer cancel()
-	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "error occurred while listing user items"})
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
 	}
 
-	var allUsers []bson.M
-	if err = result.All(ctx, &allUsers); err != nil {
-		log.Fatal(err)
+	validationErr := validate.Struct(request)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
 	}
-	return c.JSON(allUsers[0])
-}
-
-func GetUser(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-
-	userId := c.Params("user_id")
-	var user models.User
 
-	err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+	user, err := h.users.SignUp(ctx, currentUser(c), request.Model())
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "error occurred while listing user items"})
+		return serviceError(err, "User item was not created")
 	}
-	return c.JSON(user)
+	return c.JSON(&mongo.InsertOneResult{InsertedID: user.ID})
 }
 
-func SignUp(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+func (h *UserController) Login(c *fiber.Ctx) error {
+	// failed logins are logged with the address they came from
+	ctx := logger.WithContext(c.UserContext(), logger.From(c).With("ip", c.IP()))
 
-	var user models.User
+	var user dto.LoginRequest
 
 	if err := c.BodyParser(&user); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return apierrors.BadRequest(err.Error())
 	}
 
 	validationErr := validate.Struct(user)
 	if validationErr != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": validationErr.Error()})
+		return apierrors.Validation(validationErr)
 	}
 
-	count, err := userCollection.CountDocuments(ctx, bson.M{"email": user.Email})
+	result, err := h.users.Login(ctx, user.Email, user.Password, c.IP())
 	if err != nil {
-		log.Panic(err)
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "error occurred while checking for the email"})
+		return serviceError(err, "error occurred while signing in")
+	}
+	return c.JSON(loginResponse(result))
+}
+
+// LoginMfa finishes a login with the second factor.
+func (h *UserController) LoginMfa(c *fiber.Ctx) error {
+	ctx := logger.WithContext(c.UserContext(), logger.From(c).With("ip", c.IP()))
+
+	var request dto.MfaLoginRequest
+
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
 	}
 
-	password := HashPassword(user.Password)
-	user.Password = password
+	validationErr := validate.Struct(request)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
+	}
 
-	count, err = userCollection.CountDocuments(ctx, bson.M{"phone": user.Phone})
+	result, err := h.users.LoginMfa(ctx, request.Mfa_token, request.Code, c.IP())
 	if err != nil {
-		log.Panic(err)
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "error occurred while checking for the phone number"})
+		return serviceError(err, "error occurred while signing in")
 	}
+	return c.JSON(loginResponse(result))
+}
 
-	if count > 0 {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "this email or phone number already exists"})
+// EnrollAtLogin returns the MFA secret of a login that has to set MFA up, given the token mailed for it.
+func (h *UserController) EnrollAtLogin(c *fiber.Ctx) error {
+	var request dto.MfaLoginEnrollRequest
+
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
 	}
 
-	user.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	user.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	user.ID = primitive.NewObjectID()
-	user.User_id = user.ID.Hex()
+	validationErr := validate.Struct(request)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
+	}
 
-	token, refreshToken, _ := helper.GenerateAllTokens(user.Email, user.First_name, user.Last_name, user.User_id)
-	user.Token = token
-	user.Refresh_Token = refreshToken
+	enrollment, err := h.users.EnrollAtLogin(c.UserContext(), request.Mfa_token, request.Enrollment_token)
+	if err != nil {
+		return serviceError(err, "error occurred while enrolling in MFA")
+	}
+	return c.JSON(dto.NewMfaEnrollmentResponse(enrollment.Secret, enrollment.Uri))
+}
 
-	resultInsertionNumber, insertErr := userCollection.InsertOne(ctx, user)
-	if insertErr != nil {
-		msg := fmt.Sprintf("User item was not created")
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
+// loginResponse has the tokens of a signed in user, or the challenge of a login that needs a
+// second factor.
+func loginResponse(result services.LoginResult) dto.LoginResponse {
+	response := dto.NewLoginResponse(result.User, result.User.Token, result.User.Refresh_Token)
+	response.Recovery_codes = result.Recovery_codes
+	if challenge := result.Challenge; challenge != nil {
+		response.Mfa = &dto.MfaChallengeResponse{Mfa_token: challenge.Token, Mfa_expires_at: challenge.Expires_at, Enrollment_required: challenge.Enrollment_required}
 	}
+	return response
+}
 
-	return c.JSON(resultInsertionNumber)
+// RequestVerification mails the signed in user a new email verification token.
+func (h *UserController) RequestVerification(c *fiber.Ctx) error {
+	if err := h.users.RequestVerification(c.UserContext(), currentUser(c)); err != nil {
+		return serviceError(err, "error occurred while sending the verification email")
+	}
+	return c.JSON(dto.StatusResponse{Status: "sent"})
 }
 
-func Login(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
+func (h *UserController) VerifyEmail(c *fiber.Ctx) error {
+	var request dto.VerifyEmailRequest
 
-	var user models.User
-	var foundUser models.User
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
+	}
 
-	if err := c.BodyParser(&user); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	validationErr := validate.Struct(request)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
 	}
 
-	err := userCollection.FindOne(ctx, bson.M{"email": user.Email}).Decode(&foundUser)
+	user, err := h.users.VerifyEmail(c.UserContext(), request.Token)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "user not found, login seems to be incorrect"})
+		return serviceError(err, "error occurred while verifying the email")
 	}
+	return c.JSON(dto.NewUserResponse(user))
+}
+
+// RequestPasswordReset answers the same whether or not the email has an account.
+func (h *UserController) RequestPasswordReset(c *fiber.Ctx) error {
+	var request dto.PasswordResetRequest
 
-	passwordIsValid, msg := VerifyPassword(user.Password, foundUser.Password)
-	if passwordIsValid != true {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": msg})
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
 	}
 
-	token, refreshToken, _ := helper.GenerateAllTokens(foundUser.Email, foundUser.First_name, foundUser.Last_name, foundUser.User_id)
-	helper.UpdateAllTokens(token, refreshToken, foundUser.User_id)
+	validationErr := validate.Struct(request)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
+	}
 
-	return c.JSON(foundUser)
+	if err := h.users.RequestPasswordReset(c.UserContext(), request.Email, c.IP()); err != nil {
+		return serviceError(err, "error occurred while sending the password reset email")
+	}
+	return c.Status(fiber.StatusAccepted).JSON(dto.StatusResponse{Status: "sent"})
 }
 
-func HashPassword(password string) string {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+func (h *UserController) ResetPassword(c *fiber.Ctx) error {
+	var request dto.PasswordResetConfirmRequest
+
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
+	}
+
+	validationErr := validate.Struct(request)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
+	}
+
+	if err := h.users.ResetPassword(c.UserContext(), request.Token, request.Password); err != nil {
+		return serviceError(err, "error occurred while resetting the password")
+	}
+	return c.JSON(dto.StatusResponse{Status: "password changed"})
+}
+
+// SetPin sets the signed in user's PIN for terminals.
+func (h *UserController) SetPin(c *fiber.Ctx) error {
+	var request dto.SetPinRequest
+
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
+	}
+
+	validationErr := validate.Struct(request)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
+	}
+
+	if err := h.users.SetPin(c.UserContext(), currentUser(c), request.Password, request.Pin); err != nil {
+		return serviceError(err, "error occurred while setting the PIN")
+	}
+	return c.JSON(dto.StatusResponse{Status: "PIN set"})
+}
+
+// EnrollMfa starts setting up MFA for the signed in user and returns the new secret.
+func (h *UserController) EnrollMfa(c *fiber.Ctx) error {
+	var request dto.MfaEnrollRequest
+
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
+	}
+
+	validationErr := validate.Struct(request)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
+	}
+
+	enrollment, err := h.users.EnrollMfa(c.UserContext(), currentUser(c), request.Password)
 	if err != nil {
-		log.Panic(err)
+		return serviceError(err, "error occurred while enrolling in MFA")
+	}
+	return c.JSON(dto.NewMfaEnrollmentResponse(enrollment.Secret, enrollment.Uri))
+}
+
+// ConfirmMfa enables MFA for the signed in user and returns their recovery codes.
+func (h *UserController) ConfirmMfa(c *fiber.Ctx) error {
+	var request dto.MfaConfirmRequest
+
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
 	}
 
-	return string(bytes)
+	validationErr := validate.Struct(request)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
+	}
+
+	codes, err := h.users.ConfirmMfa(c.UserContext(), currentUser(c), request.Code)
+	if err != nil {
+		return serviceError(err, "error occurred while enabling MFA")
+	}
+	return c.JSON(dto.RecoveryCodesResponse{Recovery_codes: codes})
+}
+
+// DisableMfa turns MFA off for the signed in user.
+func (h *UserController) DisableMfa(c *fiber.Ctx) error {
+	var request dto.MfaDisableRequest
+
+	if err := c.BodyParser(&request); err != nil {
+		return apierrors.BadRequest(err.Error())
+	}
+
+	validationErr := validate.Struct(request)
+	if validationErr != nil {
+		return apierrors.Validation(validationErr)
+	}
+
+	if err := h.users.DisableMfa(c.UserContext(), currentUser(c), request.Password, request.Code); err != nil {
+		return serviceError(err, "error occurred while disabling MFA")
+	}
+	return c.JSON(dto.StatusResponse{Status: "MFA disabled"})
+}
+
+// UnlockUser lets a manager lift the lockout of an account after too many failed logins.
+func (h *UserController) UnlockUser(c *fiber.Ctx) error {
+	if err := h.users.Unlock(c.UserContext(), currentUser(c), c.Params("user_id")); err != nil {
+		return serviceError(err, "error occurred while unlocking the user")
+	}
+	return c.JSON(dto.StatusResponse{Status: "unlocked"})
 }
 
-func VerifyPassword(userPassword string, providedPassword string) (bool, string) {
-	err := bcrypt.CompareHashAndPassword([]byte(providedPassword), []byte(userPassword))
-	check := true
-	msg := ""
+func (h *UserController) DeleteUser(c *fiber.Ctx) error {
+	result, err := h.users.Delete(c.UserContext(), currentUser(c), c.Params("user_id"))
+	if err != nil {
+		return serviceError(err, "user delete failed")
+	}
+	return c.JSON(result)
+}
 
+func (h *UserController) RestoreUser(c *fiber.Ctx) error {
+	result, err := h.users.Restore(c.UserContext(), currentUser(c), c.Params("user_id"))
 	if err != nil {
-		msg = fmt.Sprintf("login or password is incorrect")
-		check = false
+		return serviceError(err, "user restore failed")
 	}
-	return check, msg
+	return c.JSON(result)
 }
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestUserResponsesHideSecrets(t *testing.T) {
+	svc, repos := newTestServices(t)
+	users := NewUserController(svc.Users)
+
+	repos.Users.InsertOne(context.Background(), bson.M{
+		"user_id": "u1", "first_name": "Ada", "last_name": "Lovelace", "email": "ada@example.com",
+		"password": "$2a$14$hash", "token": "secret-token", "refresh_token": "secret-refresh", "version": 1,
+	})
+
+	app := fiber.New(fiber.Config{ErrorHandler: apierrors.ErrorHandler})
+	app.Get("/users", users.GetUsers)
+	app.Get("/users/:user_id", users.GetUser)
+
+	for _, path := range []string{"/users/u1", "/users"} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s = %d: %s", path, resp.StatusCode, body)
+		}
+		if !strings.Contains(string(body), "ada@example.com") {
+			t.Errorf("GET %s did not return the user: %s", path, body)
+		}
+		for _, secret := range []string{"$2a$14$hash", "secret-token", "secret-refresh"} {
+			if strings.Contains(string(body), secret) {
+				t.Errorf("GET %s leaked %q: %s", path, secret, body)
+			}
+		}
+	}
+}
@@ -2,39 +2,195 @@ package database
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"os"
 	"time"
 
+	"github.com/mayankr5/v1/restaurant-management/logger"
+	"github.com/mayankr5/v1/restaurant-management/metrics"
+
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
-func DBinstance() *mongo.Client {
-	MongoDb := "mongodb://localhost:27017"
-	fmt.Print(MongoDb)
+// Collection is the part of *mongo.Collection the application uses, so that the
+// in-memory store can stand in for Mongo.
+type Collection interface {
+	InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error)
+	InsertMany(ctx context.Context, documents []interface{}, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error)
+	FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult
+	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error)
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+	CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error)
+	Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (*mongo.Cursor, error)
+}
 
-	client, err := mongo.NewClient(options.Client().ApplyURI(MongoDb))
-	if err != nil {
-		log.Fatal(err)
+// Store hands out collections and runs multi-document transactions.
+type Store interface {
+	Collection(name string) Collection
+	// WithTransaction runs fn in a transaction that is committed when fn returns nil and
+	// rolled back otherwise. Operations must use the ctx passed to fn to take part in it.
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	// Ping checks that the store can be reached.
+	Ping(ctx context.Context) error
+	// Close releases the store's connections.
+	Close(ctx context.Context) error
+}
+
+// DefaultURI is used when MONGODB_URI is not set.
+const DefaultURI = "mongodb://localhost:27017"
+
+// Name is the database the collections live in.
+const Name = "restaurant"
+
+// Connect creates a Mongo client for uri. The driver dials in the background, so an unreachable
+// server is not an error here; operations wait for it, and Ping tells whether it can be reached.
+func Connect(uri string) (*mongo.Client, error) {
+	return mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+}
+
+// URI returns MONGODB_URI, or DefaultURI when it is not set.
+func URI() string {
+	if uri := os.Getenv("MONGODB_URI"); uri != "" {
+		return uri
+	}
+	return DefaultURI
+}
+
+// WaitUntilReachable pings store until it answers or ctx is done, waiting longer after every
+// failed attempt.
+func WaitUntilReachable(ctx context.Context, store Store) error {
+	delay := 500 * time.Millisecond
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		err := store.Ping(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		logger.Get().Warn("database is not reachable yet", "error", err, "retry_in", delay.String())
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		if delay < 30*time.Second {
+			delay *= 2
+		}
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+}
 
-	defer cancel()
+// OpenCollection returns the named collection of store, with every operation timed for the
+// metrics. Opening one connects to nothing.
+func OpenCollection(store Store, collectionName string) Collection {
+	return collection{store: store, name: collectionName}
+}
+
+type collection struct {
+	store Store
+	name  string
+}
 
-	err = client.Connect(ctx)
+func (c collection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	start := time.Now()
+	result, err := c.store.Collection(c.name).InsertOne(ctx, document, opts...)
+	metrics.ObserveMongo(c.name, "InsertOne", start, err)
+	return result, err
+}
+
+func (c collection) InsertMany(ctx context.Context, documents []interface{}, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error) {
+	start := time.Now()
+	result, err := c.store.Collection(c.name).InsertMany(ctx, documents, opts...)
+	metrics.ObserveMongo(c.name, "InsertMany", start, err)
+	return result, err
+}
+
+func (c collection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	start := time.Now()
+	result := c.store.Collection(c.name).FindOne(ctx, filter, opts...)
+	metrics.ObserveMongo(c.name, "FindOne", start, result.Err())
+	return result
+}
 
+func (c collection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	start := time.Now()
+	result, err := c.store.Collection(c.name).Find(ctx, filter, opts...)
+	metrics.ObserveMongo(c.name, "Find", start, err)
+	return result, err
+}
+
+func (c collection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	start := time.Now()
+	result, err := c.store.Collection(c.name).UpdateOne(ctx, filter, update, opts...)
+	metrics.ObserveMongo(c.name, "UpdateOne", start, err)
+	return result, err
+}
+
+func (c collection) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	start := time.Now()
+	result, err := c.store.Collection(c.name).UpdateMany(ctx, filter, update, opts...)
+	metrics.ObserveMongo(c.name, "UpdateMany", start, err)
+	return result, err
+}
+
+func (c collection) DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	start := time.Now()
+	result, err := c.store.Collection(c.name).DeleteOne(ctx, filter, opts...)
+	metrics.ObserveMongo(c.name, "DeleteOne", start, err)
+	return result, err
+}
+
+func (c collection) CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	start := time.Now()
+	result, err := c.store.Collection(c.name).CountDocuments(ctx, filter, opts...)
+	metrics.ObserveMongo(c.name, "CountDocuments", start, err)
+	return result, err
+}
+
+func (c collection) Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (*mongo.Cursor, error) {
+	start := time.Now()
+	result, err := c.store.Collection(c.name).Aggregate(ctx, pipeline, opts...)
+	metrics.ObserveMongo(c.name, "Aggregate", start, err)
+	return result, err
+}
+
+// MongoStore serves collections from a Mongo database. Transactions need a replica set or sharded cluster.
+type MongoStore struct {
+	Client   *mongo.Client
+	Database string
+}
+
+func NewMongoStore(client *mongo.Client, database string) *MongoStore {
+	return &MongoStore{Client: client, Database: database}
+}
+
+func (s *MongoStore) Collection(name string) Collection {
+	return s.Client.Database(s.Database).Collection(name)
+}
+
+func (s *MongoStore) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := s.Client.StartSession()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	fmt.Println("connected to mongodb")
-	return client
-}
+	defer session.EndSession(ctx)
 
-var Client *mongo.Client = DBinstance()
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	})
+	return err
+}
 
-func OpenCollection(client *mongo.Client, collectionName string) *mongo.Collection {
-	var collection *mongo.Collection = client.Database("restaurant").Collection(collectionName)
+func (s *MongoStore) Ping(ctx context.Context) error {
+	return s.Client.Ping(ctx, readpref.Primary())
+}
 
-	return collection
+func (s *MongoStore) Close(ctx context.Context) error {
+	return s.Client.Disconnect(ctx)
 }
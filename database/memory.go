@@ -0,0 +1,471 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MemoryStore is an in-process Store for tests and local development. It understands the
+// filters, updates and aggregation stages the application uses, and rolls transactions back
+// by undoing their writes.
+type MemoryStore struct {
+	mu          sync.Mutex
+	txMu        sync.Mutex
+	collections map[string]*memoryCollection
+}
+
+type memoryCollection struct {
+	store *MemoryStore
+	name  string
+	docs  []primitive.D
+}
+
+type memoryTxKey struct{}
+
+type memoryTx struct {
+	undo []func()
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{collections: map[string]*memoryCollection{}}
+}
+
+func (s *MemoryStore) Collection(name string) Collection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.collection(name)
+}
+
+func (s *MemoryStore) collection(name string) *memoryCollection {
+	c, ok := s.collections[name]
+	if !ok {
+		c = &memoryCollection{store: s, name: name}
+		s.collections[name] = c
+	}
+	return c
+}
+
+// WithTransaction serializes transactions and undoes every write made through ctx when fn fails.
+func (s *MemoryStore) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(memoryTxKey{}).(*memoryTx); ok {
+		return fn(ctx)
+	}
+
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	tx := &memoryTx{}
+	err := fn(context.WithValue(ctx, memoryTxKey{}, tx))
+	if err != nil {
+		s.mu.Lock()
+		for i := len(tx.undo) - 1; i >= 0; i-- {
+			tx.undo[i]()
+		}
+		s.mu.Unlock()
+	}
+	return err
+}
+
+func (c *memoryCollection) track(ctx context.Context, undo func()) {
+	if tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx); ok {
+		tx.undo = append(tx.undo, undo)
+	}
+}
+
+func (c *memoryCollection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	id, err := c.insert(ctx, document)
+	if err != nil {
+		return nil, err
+	}
+	return &mongo.InsertOneResult{InsertedID: id}, nil
+}
+
+func (c *memoryCollection) InsertMany(ctx context.Context, documents []interface{}, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error) {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	result := &mongo.InsertManyResult{}
+	for _, document := range documents {
+		id, err := c.insert(ctx, document)
+		if err != nil {
+			return result, err
+		}
+		result.InsertedIDs = append(result.InsertedIDs, id)
+	}
+	return result, nil
+}
+
+func (c *memoryCollection) insert(ctx context.Context, document interface{}) (interface{}, error) {
+	doc, err := toDocument(document)
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := docGet(doc, "_id")
+	if !ok {
+		id = primitive.NewObjectID()
+		doc = append(primitive.D{{Key: "_id", Value: id}}, doc...)
+	}
+	if c.indexOf(id) >= 0 {
+		return nil, duplicateKeyError(c.name, id)
+	}
+
+	c.docs = append(c.docs, doc)
+	c.track(ctx, func() { c.remove(id) })
+	return id, nil
+}
+
+func (c *memoryCollection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	findOpts := options.Find().SetLimit(1)
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if opt.Sort != nil {
+			findOpts.SetSort(opt.Sort)
+		}
+		if opt.Skip != nil {
+			findOpts.SetSkip(*opt.Skip)
+		}
+		if opt.Projection != nil {
+			findOpts.SetProjection(opt.Projection)
+		}
+	}
+
+	docs, err := c.find(filter, findOpts)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(bson.D{}, err, nil)
+	}
+	if len(docs) == 0 {
+		return mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil)
+	}
+	return mongo.NewSingleResultFromDocument(docs[0], nil, nil)
+}
+
+func (c *memoryCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	docs, err := c.find(filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return newMemoryCursor(docs)
+}
+
+func (c *memoryCollection) find(filter interface{}, opts ...*options.FindOptions) ([]primitive.D, error) {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	query, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := c.matching(query)
+	if err != nil {
+		return nil, err
+	}
+
+	findOpts := options.MergeFindOptions(opts...)
+	if findOpts.Sort != nil {
+		sortSpec, err := toDocument(findOpts.Sort)
+		if err != nil {
+			return nil, err
+		}
+		sortDocuments(docs, sortSpec)
+	}
+	if findOpts.Skip != nil {
+		docs = docs[min(int(*findOpts.Skip), len(docs)):]
+	}
+	if findOpts.Limit != nil && *findOpts.Limit > 0 && int(*findOpts.Limit) < len(docs) {
+		docs = docs[:*findOpts.Limit]
+	}
+	if findOpts.Projection != nil {
+		spec, err := toDocument(findOpts.Projection)
+		if err != nil {
+			return nil, err
+		}
+		for i, doc := range docs {
+			if docs[i], err = project(doc, spec); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return docs, nil
+}
+
+func (c *memoryCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return c.update(ctx, filter, update, false, opts...)
+}
+
+func (c *memoryCollection) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return c.update(ctx, filter, update, true, opts...)
+}
+
+func (c *memoryCollection) update(ctx context.Context, filter interface{}, update interface{}, many bool, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	query, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := toDocument(update)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &mongo.UpdateResult{}
+	for i, doc := range c.docs {
+		ok, err := matches(doc, query)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		updated, err := applyUpdate(copyDocument(doc), changes, false)
+		if err != nil {
+			return nil, err
+		}
+
+		result.MatchedCount++
+		if !documentsEqual(doc, updated) {
+			result.ModifiedCount++
+			previous := doc
+			c.docs[i] = updated
+			id, _ := docGet(doc, "_id")
+			c.track(ctx, func() { c.replace(id, previous) })
+		}
+		if !many {
+			break
+		}
+	}
+
+	upsert := options.MergeUpdateOptions(opts...).Upsert
+	if result.MatchedCount == 0 && upsert != nil && *upsert {
+		doc := equalityFields(query)
+		doc, err := applyUpdate(doc, changes, true)
+		if err != nil {
+			return nil, err
+		}
+		id, err := c.insert(ctx, doc)
+		if err != nil {
+			return nil, err
+		}
+		result.UpsertedCount = 1
+		result.UpsertedID = id
+	}
+	return result, nil
+}
+
+func (c *memoryCollection) DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	query, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, doc := range c.docs {
+		ok, err := matches(doc, query)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			c.docs = append(c.docs[:i:i], c.docs[i+1:]...)
+			c.track(ctx, func() { c.docs = append(c.docs, doc) })
+			return &mongo.DeleteResult{DeletedCount: 1}, nil
+		}
+	}
+	return &mongo.DeleteResult{}, nil
+}
+
+func (c *memoryCollection) CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	query, err := toDocument(filter)
+	if err != nil {
+		return 0, err
+	}
+	docs, err := c.matching(query)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(docs)), nil
+}
+
+func (c *memoryCollection) Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (*mongo.Cursor, error) {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	stages, err := toValue(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	stageList, ok := stages.(primitive.A)
+	if !ok {
+		return nil, fmt.Errorf("memory store: pipeline must be an array of stages")
+	}
+
+	docs := make([]primitive.D, 0, len(c.docs))
+	for _, doc := range c.docs {
+		docs = append(docs, copyDocument(doc))
+	}
+
+	docs, err = c.store.aggregate(docs, stageList)
+	if err != nil {
+		return nil, err
+	}
+	return newMemoryCursor(docs)
+}
+
+func (c *memoryCollection) matching(query primitive.D) ([]primitive.D, error) {
+	docs := []primitive.D{}
+	for _, doc := range c.docs {
+		ok, err := matches(doc, query)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			docs = append(docs, copyDocument(doc))
+		}
+	}
+	return docs, nil
+}
+
+func (c *memoryCollection) indexOf(id interface{}) int {
+	for i, doc := range c.docs {
+		if docId, ok := docGet(doc, "_id"); ok && valuesEqual(docId, id) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *memoryCollection) remove(id interface{}) {
+	if i := c.indexOf(id); i >= 0 {
+		c.docs = append(c.docs[:i:i], c.docs[i+1:]...)
+	}
+}
+
+func (c *memoryCollection) replace(id interface{}, doc primitive.D) {
+	if i := c.indexOf(id); i >= 0 {
+		c.docs[i] = doc
+	}
+}
+
+func newMemoryCursor(docs []primitive.D) (*mongo.Cursor, error) {
+	documents := make([]interface{}, 0, len(docs))
+	for _, doc := range docs {
+		documents = append(documents, doc)
+	}
+	return mongo.NewCursorFromDocuments(documents, nil, nil)
+}
+
+func duplicateKeyError(collection string, id interface{}) error {
+	return mongo.WriteException{
+		WriteErrors: []mongo.WriteError{{
+			Code:    11000,
+			Message: fmt.Sprintf("E11000 duplicate key error collection: %s index: _id_ dup key: { _id: %v }", collection, id),
+		}},
+	}
+}
+
+// toDocument round-trips a filter, update or document through BSON so that pointers, structs,
+// maps and Go times all become the primitive types stored documents are made of.
+func toDocument(value interface{}) (primitive.D, error) {
+	if value == nil {
+		return primitive.D{}, nil
+	}
+	normalized, err := toValue(value)
+	if err != nil {
+		return nil, err
+	}
+	doc, ok := normalized.(primitive.D)
+	if !ok {
+		return nil, errors.New("memory store: expected a document")
+	}
+	return doc, nil
+}
+
+func toValue(value interface{}) (interface{}, error) {
+	data, err := bson.Marshal(primitive.D{{Key: "v", Value: value}})
+	if err != nil {
+		return nil, err
+	}
+	var wrapper primitive.D
+	if err := bson.Unmarshal(data, &wrapper); err != nil {
+		return nil, err
+	}
+	return wrapper[0].Value, nil
+}
+
+func copyDocument(doc primitive.D) primitive.D {
+	return copyValue(doc).(primitive.D)
+}
+
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case primitive.D:
+		out := make(primitive.D, len(v))
+		for i, e := range v {
+			out[i] = primitive.E{Key: e.Key, Value: copyValue(e.Value)}
+		}
+		return out
+	case primitive.A:
+		out := make(primitive.A, len(v))
+		for i, e := range v {
+			out[i] = copyValue(e)
+		}
+		return out
+	}
+	return value
+}
+
+func docGet(doc primitive.D, key string) (interface{}, bool) {
+	for _, e := range doc {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+func sortDocuments(docs []primitive.D, spec primitive.D) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, key := range spec {
+			a, _ := fieldValue(docs[i], key.Key)
+			b, _ := fieldValue(docs[j], key.Key)
+			cmp := compareOrder(a, b)
+			if cmp == 0 {
+				continue
+			}
+			if asFloat(key.Value) < 0 {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+}
+
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *MemoryStore) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,622 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// aggregate runs an aggregation pipeline over docs. The caller holds the store lock.
+func (s *MemoryStore) aggregate(docs []primitive.D, stages primitive.A) ([]primitive.D, error) {
+	for _, rawStage := range stages {
+		stage, ok := rawStage.(primitive.D)
+		if !ok || len(stage) != 1 {
+			return nil, fmt.Errorf("memory store: a pipeline stage must be a document with one field")
+		}
+
+		var err error
+		name, spec := stage[0].Key, stage[0].Value
+
+		switch name {
+		case "$match":
+			query, _ := spec.(primitive.D)
+			docs, err = filterDocuments(docs, query)
+		case "$lookup":
+			docs, err = s.lookup(docs, spec)
+		case "$unwind":
+			docs, err = unwind(docs, spec)
+		case "$project":
+			projection, _ := spec.(primitive.D)
+			for i := range docs {
+				if docs[i], err = project(docs[i], projection); err != nil {
+					break
+				}
+			}
+		case "$addFields", "$set":
+			fields, _ := spec.(primitive.D)
+			for i := range docs {
+				if docs[i], err = addFields(docs[i], fields); err != nil {
+					break
+				}
+			}
+		case "$group":
+			group, _ := spec.(primitive.D)
+			docs, err = groupDocuments(docs, group)
+		case "$sort":
+			sortSpec, _ := spec.(primitive.D)
+			sortDocuments(docs, sortSpec)
+		case "$skip":
+			docs = docs[min(int(asFloat(spec)), len(docs)):]
+		case "$limit":
+			docs = docs[:min(int(asFloat(spec)), len(docs))]
+		case "$count":
+			field, _ := spec.(string)
+			if len(docs) == 0 {
+				docs = []primitive.D{}
+			} else {
+				docs = []primitive.D{{{Key: field, Value: int32(len(docs))}}}
+			}
+		default:
+			err = fmt.Errorf("memory store: unsupported pipeline stage %s", name)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+func filterDocuments(docs []primitive.D, query primitive.D) ([]primitive.D, error) {
+	kept := []primitive.D{}
+	for _, doc := range docs {
+		ok, err := matches(doc, query)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			kept = append(kept, doc)
+		}
+	}
+	return kept, nil
+}
+
+func (s *MemoryStore) lookup(docs []primitive.D, spec interface{}) ([]primitive.D, error) {
+	options, _ := spec.(primitive.D)
+	from, _ := docGet(options, "from")
+	localField, _ := docGet(options, "localField")
+	foreignField, _ := docGet(options, "foreignField")
+	as, _ := docGet(options, "as")
+
+	fromName, ok1 := from.(string)
+	local, ok2 := localField.(string)
+	foreign, ok3 := foreignField.(string)
+	asName, ok4 := as.(string)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return nil, fmt.Errorf("memory store: $lookup needs from, localField, foreignField and as")
+	}
+
+	foreignDocs := s.collection(fromName).docs
+	for i, doc := range docs {
+		localValues, found := lookupValues(doc, strings.Split(local, "."))
+		if !found {
+			localValues = []interface{}{nil}
+		}
+		localValues = expandArrays(localValues)
+
+		joined := primitive.A{}
+		for _, foreignDoc := range foreignDocs {
+			values, found := lookupValues(foreignDoc, strings.Split(foreign, "."))
+			for _, localValue := range localValues {
+				if matchEquals(values, found, localValue) {
+					joined = append(joined, copyDocument(foreignDoc))
+					break
+				}
+			}
+		}
+		docs[i] = setPath(doc, asName, joined)
+	}
+	return docs, nil
+}
+
+func unwind(docs []primitive.D, spec interface{}) ([]primitive.D, error) {
+	path, _ := spec.(string)
+	preserve := false
+	if options, ok := spec.(primitive.D); ok {
+		value, _ := docGet(options, "path")
+		path, _ = value.(string)
+		preserveValue, _ := docGet(options, "preserveNullAndEmptyArrays")
+		preserve = truthy(preserveValue)
+	}
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("memory store: $unwind path must start with $")
+	}
+	field := path[1:]
+
+	unwound := []primitive.D{}
+	for _, doc := range docs {
+		value, found := fieldValue(doc, field)
+		array, isArray := value.(primitive.A)
+
+		switch {
+		case !found || value == nil:
+			if preserve {
+				unwound = append(unwound, doc)
+			}
+		case !isArray:
+			unwound = append(unwound, doc)
+		case len(array) == 0:
+			if preserve {
+				unwound = append(unwound, unsetPath(copyDocument(doc), field))
+			}
+		default:
+			for _, element := range array {
+				unwound = append(unwound, setPath(copyDocument(doc), field, copyValue(element)))
+			}
+		}
+	}
+	return unwound, nil
+}
+
+// project applies a $project specification. Like Mongo, it refuses to mix exclusions other than _id with inclusions.
+func project(doc primitive.D, spec primitive.D) (primitive.D, error) {
+	inclusion := false
+	excludeId := false
+	var excluded []string
+
+	for _, field := range spec {
+		isFlag := isNumber(field.Value) || isBool(field.Value)
+		switch {
+		case isFlag && !truthy(field.Value) && field.Key == "_id":
+			excludeId = true
+		case isFlag && !truthy(field.Value):
+			excluded = append(excluded, field.Key)
+		default:
+			inclusion = true
+		}
+	}
+
+	if inclusion && len(excluded) > 0 {
+		return nil, fmt.Errorf("memory store: Invalid $project :: caused by :: Cannot do exclusion on field %s in inclusion projection", excluded[0])
+	}
+
+	if !inclusion {
+		out := copyDocument(doc)
+		for _, field := range excluded {
+			out = unsetPath(out, field)
+		}
+		if excludeId {
+			out = unsetPath(out, "_id")
+		}
+		return out, nil
+	}
+
+	out := primitive.D{}
+	if id, ok := docGet(doc, "_id"); ok && !excludeId {
+		if _, specified := docGet(spec, "_id"); !specified {
+			out = append(out, primitive.E{Key: "_id", Value: id})
+		}
+	}
+
+	for _, field := range spec {
+		if field.Key == "_id" && excludeId {
+			continue
+		}
+
+		var value interface{}
+		var found bool
+
+		if (isNumber(field.Value) || isBool(field.Value)) && truthy(field.Value) {
+			value, found = fieldValue(doc, field.Key)
+		} else {
+			result, err := evalExpr(doc, field.Value)
+			if err != nil {
+				return nil, err
+			}
+			_, isMissing := result.(missing)
+			value, found = result, !isMissing
+		}
+
+		if found {
+			out = setPath(out, field.Key, copyValue(value))
+		}
+	}
+	return out, nil
+}
+
+func addFields(doc primitive.D, fields primitive.D) (primitive.D, error) {
+	for _, field := range fields {
+		value, err := evalExpr(doc, field.Value)
+		if err != nil {
+			return nil, err
+		}
+		if _, isMissing := value.(missing); isMissing {
+			continue
+		}
+		doc = setPath(doc, field.Key, value)
+	}
+	return doc, nil
+}
+
+type group struct {
+	id     interface{}
+	fields primitive.D
+	counts map[string]int
+}
+
+func groupDocuments(docs []primitive.D, spec primitive.D) ([]primitive.D, error) {
+	idExpr, ok := docGet(spec, "_id")
+	if !ok {
+		return nil, fmt.Errorf("memory store: $group needs an _id")
+	}
+
+	var order []string
+	groups := map[string]*group{}
+
+	for _, doc := range docs {
+		id, err := evalExpr(doc, idExpr)
+		if err != nil {
+			return nil, err
+		}
+		if _, isMissing := id.(missing); isMissing {
+			id = nil
+		}
+
+		key, err := groupKey(id)
+		if err != nil {
+			return nil, err
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &group{id: id, counts: map[string]int{}}
+			groups[key] = g
+			order = append(order, key)
+		}
+
+		for _, field := range spec {
+			if field.Key == "_id" {
+				continue
+			}
+			if err := accumulate(g, doc, field); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	out := make([]primitive.D, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		doc := primitive.D{{Key: "_id", Value: g.id}}
+		for _, field := range spec {
+			if field.Key == "_id" {
+				continue
+			}
+			value, _ := docGet(g.fields, field.Key)
+			if accumulator, ok := field.Value.(primitive.D); ok && len(accumulator) == 1 && accumulator[0].Key == "$avg" {
+				if g.counts[field.Key] == 0 {
+					value = nil
+				} else {
+					value = asFloat(value) / float64(g.counts[field.Key])
+				}
+			}
+			doc = append(doc, primitive.E{Key: field.Key, Value: value})
+		}
+		out = append(out, doc)
+	}
+	return out, nil
+}
+
+func accumulate(g *group, doc primitive.D, field primitive.E) error {
+	accumulator, ok := field.Value.(primitive.D)
+	if !ok || len(accumulator) != 1 {
+		return fmt.Errorf("memory store: $group field %s needs an accumulator", field.Key)
+	}
+
+	operator := accumulator[0].Key
+	value, err := evalExpr(doc, accumulator[0].Value)
+	if err != nil {
+		return err
+	}
+	current, seen := docGet(g.fields, field.Key)
+	_, isMissing := value.(missing)
+
+	switch operator {
+	case "$sum", "$avg":
+		if !seen {
+			current = int32(0)
+		}
+		if isNumber(value) {
+			if current, err = addNumbers(current, value); err != nil {
+				return err
+			}
+			g.counts[field.Key]++
+		}
+	case "$first":
+		if !seen {
+			current = nilIfMissing(value)
+		}
+	case "$last":
+		current = nilIfMissing(value)
+	case "$min", "$max":
+		if isMissing || value == nil {
+			if !seen {
+				current = nil
+			}
+			break
+		}
+		cmp := compareOrder(value, current)
+		if !seen || current == nil || operator == "$min" && cmp < 0 || operator == "$max" && cmp > 0 {
+			current = value
+		}
+	case "$push", "$addToSet":
+		array, _ := current.(primitive.A)
+		if array == nil {
+			array = primitive.A{}
+		}
+		if !isMissing && !(operator == "$addToSet" && matchEquals(array, true, value)) {
+			array = append(array, copyValue(value))
+		}
+		current = array
+	default:
+		return fmt.Errorf("memory store: unsupported accumulator %s", operator)
+	}
+
+	if seen {
+		for i := range g.fields {
+			if g.fields[i].Key == field.Key {
+				g.fields[i].Value = current
+			}
+		}
+	} else {
+		g.fields = append(g.fields, primitive.E{Key: field.Key, Value: current})
+	}
+	return nil
+}
+
+func groupKey(id interface{}) (string, error) {
+	data, err := bson.Marshal(primitive.D{{Key: "k", Value: id}})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// evalExpr evaluates an aggregation expression against doc. Paths that don't resolve evaluate to missing{}.
+func evalExpr(doc primitive.D, expr interface{}) (interface{}, error) {
+	switch e := expr.(type) {
+	case string:
+		switch {
+		case e == "$$ROOT" || e == "$$CURRENT":
+			return doc, nil
+		case strings.HasPrefix(e, "$$"):
+			return nil, fmt.Errorf("memory store: unsupported variable %s", e)
+		case strings.HasPrefix(e, "$"):
+			value, found := fieldValue(doc, e[1:])
+			if !found {
+				return missing{}, nil
+			}
+			return value, nil
+		}
+		return e, nil
+	case primitive.A:
+		out := primitive.A{}
+		for _, element := range e {
+			value, err := evalExpr(doc, element)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, nilIfMissing(value))
+		}
+		return out, nil
+	case primitive.D:
+		if isOperatorDocument(e) {
+			return evalOperator(doc, e[0].Key, e[0].Value)
+		}
+		out := primitive.D{}
+		for _, field := range e {
+			value, err := evalExpr(doc, field.Value)
+			if err != nil {
+				return nil, err
+			}
+			if _, isMissing := value.(missing); !isMissing {
+				out = append(out, primitive.E{Key: field.Key, Value: value})
+			}
+		}
+		return out, nil
+	}
+	return expr, nil
+}
+
+func evalOperator(doc primitive.D, operator string, argument interface{}) (interface{}, error) {
+	if operator == "$literal" {
+		return argument, nil
+	}
+
+	var args primitive.A
+	if operator == "$cond" {
+		if named, ok := argument.(primitive.D); ok {
+			ifExpr, _ := docGet(named, "if")
+			thenExpr, _ := docGet(named, "then")
+			elseExpr, _ := docGet(named, "else")
+			argument = primitive.A{ifExpr, thenExpr, elseExpr}
+		}
+	}
+	if array, ok := argument.(primitive.A); ok {
+		args = array
+	} else {
+		args = primitive.A{argument}
+	}
+
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		value, err := evalExpr(doc, arg)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+
+	switch operator {
+	case "$ifNull":
+		for _, value := range values {
+			if _, isMissing := value.(missing); !isMissing && value != nil {
+				return value, nil
+			}
+		}
+		return nil, nil
+	case "$cond":
+		if len(values) != 3 {
+			return nil, fmt.Errorf("memory store: $cond needs if, then and else")
+		}
+		if truthy(values[0]) {
+			return values[1], nil
+		}
+		return values[2], nil
+	case "$add", "$multiply", "$sum":
+		if operator == "$sum" && len(values) == 1 {
+			if array, ok := values[0].(primitive.A); ok {
+				values = array
+			}
+		}
+		var result interface{} = int32(0)
+		if operator == "$multiply" {
+			result = int32(1)
+		}
+		for _, value := range values {
+			if !isNumber(value) {
+				if operator == "$sum" {
+					continue
+				}
+				return nil, nil
+			}
+			if operator == "$multiply" {
+				result = numberResult(asFloat(result)*asFloat(value), result, value)
+			} else {
+				result = numberResult(asFloat(result)+asFloat(value), result, value)
+			}
+		}
+		return result, nil
+	case "$subtract", "$divide":
+		if len(values) != 2 {
+			return nil, fmt.Errorf("memory store: %s needs two arguments", operator)
+		}
+		a, aDate := values[0].(primitive.DateTime)
+		b, bDate := values[1].(primitive.DateTime)
+		if operator == "$subtract" && aDate && bDate {
+			return int64(a - b), nil
+		}
+		if !isNumber(values[0]) || !isNumber(values[1]) {
+			return nil, nil
+		}
+		if operator == "$divide" {
+			if asFloat(values[1]) == 0 {
+				return nil, fmt.Errorf("memory store: can't $divide by zero")
+			}
+			return asFloat(values[0]) / asFloat(values[1]), nil
+		}
+		return numberResult(asFloat(values[0])-asFloat(values[1]), values[0], values[1]), nil
+	case "$eq", "$ne", "$gt", "$gte", "$lt", "$lte":
+		if len(values) != 2 {
+			return nil, fmt.Errorf("memory store: %s needs two arguments", operator)
+		}
+		cmp := compareOrder(nilIfMissing(values[0]), nilIfMissing(values[1]))
+		switch operator {
+		case "$eq":
+			return cmp == 0, nil
+		case "$ne":
+			return cmp != 0, nil
+		case "$gt":
+			return cmp > 0, nil
+		case "$gte":
+			return cmp >= 0, nil
+		case "$lt":
+			return cmp < 0, nil
+		}
+		return cmp <= 0, nil
+	case "$and":
+		for _, value := range values {
+			if !truthy(value) {
+				return false, nil
+			}
+		}
+		return true, nil
+	case "$or":
+		for _, value := range values {
+			if truthy(value) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$not":
+		return !truthy(values[0]), nil
+	case "$size":
+		array, ok := values[0].(primitive.A)
+		if !ok {
+			return nil, fmt.Errorf("memory store: $size needs an array")
+		}
+		return int32(len(array)), nil
+	case "$slice":
+		array, ok := values[0].(primitive.A)
+		if !ok {
+			return nil, nil
+		}
+		start, count := 0, 0
+		if len(values) == 2 {
+			count = int(asFloat(values[1]))
+			if count < 0 {
+				start, count = max(len(array)+count, 0), -count
+			}
+		} else if len(values) == 3 {
+			start, count = int(asFloat(values[1])), int(asFloat(values[2]))
+			if start < 0 {
+				start = max(len(array)+start, 0)
+			}
+		}
+		start = min(start, len(array))
+		return append(primitive.A{}, array[start:min(start+count, len(array))]...), nil
+	case "$arrayElemAt":
+		array, ok := values[0].(primitive.A)
+		if !ok || len(values) != 2 {
+			return nil, nil
+		}
+		index := int(asFloat(values[1]))
+		if index < 0 {
+			index += len(array)
+		}
+		if index < 0 || index >= len(array) {
+			return missing{}, nil
+		}
+		return array[index], nil
+	case "$concat":
+		var builder strings.Builder
+		for _, value := range values {
+			s, ok := value.(string)
+			if !ok {
+				return nil, nil
+			}
+			builder.WriteString(s)
+		}
+		return builder.String(), nil
+	case "$in":
+		array, ok := values[1].(primitive.A)
+		if !ok {
+			return nil, fmt.Errorf("memory store: $in needs an array")
+		}
+		return matchEquals(array, true, values[0]), nil
+	}
+	return nil, fmt.Errorf("memory store: unsupported expression operator %s", operator)
+}
+
+func nilIfMissing(value interface{}) interface{} {
+	if _, isMissing := value.(missing); isMissing {
+		return nil
+	}
+	return value
+}
+
+func isBool(value interface{}) bool {
+	_, ok := value.(bool)
+	return ok
+}
@@ -0,0 +1,604 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// missing marks a field path that resolves to nothing, as opposed to an explicit null.
+type missing struct{}
+
+// matches reports whether doc satisfies a Mongo query document.
+func matches(doc primitive.D, query primitive.D) (bool, error) {
+	for _, e := range query {
+		var ok bool
+		var err error
+
+		switch e.Key {
+		case "$and", "$or", "$nor":
+			ok, err = matchLogical(doc, e.Key, e.Value)
+		case "$expr":
+			var value interface{}
+			value, err = evalExpr(doc, e.Value)
+			ok = truthy(value)
+		default:
+			ok, err = matchField(doc, e.Key, e.Value)
+		}
+
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchLogical(doc primitive.D, operator string, value interface{}) (bool, error) {
+	clauses, ok := value.(primitive.A)
+	if !ok {
+		return false, fmt.Errorf("memory store: %s needs an array", operator)
+	}
+
+	for _, clause := range clauses {
+		query, ok := clause.(primitive.D)
+		if !ok {
+			return false, fmt.Errorf("memory store: %s clauses must be documents", operator)
+		}
+		ok, err := matches(doc, query)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case operator == "$and" && !ok:
+			return false, nil
+		case operator == "$or" && ok:
+			return true, nil
+		case operator == "$nor" && ok:
+			return false, nil
+		}
+	}
+	return operator != "$or", nil
+}
+
+func matchField(doc primitive.D, path string, condition interface{}) (bool, error) {
+	values, found := lookupValues(doc, strings.Split(path, "."))
+
+	operators, ok := condition.(primitive.D)
+	if !ok || !isOperatorDocument(operators) {
+		return matchEquals(values, found, condition), nil
+	}
+
+	for _, operator := range operators {
+		ok, err := matchOperator(values, found, operator.Key, operator.Value, operators)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchOperator(values []interface{}, found bool, operator string, argument interface{}, operators primitive.D) (bool, error) {
+	switch operator {
+	case "$eq":
+		return matchEquals(values, found, argument), nil
+	case "$ne":
+		return !matchEquals(values, found, argument), nil
+	case "$gt", "$gte", "$lt", "$lte":
+		for _, value := range expandArrays(values) {
+			cmp, ok := compareValues(value, argument)
+			if !ok {
+				continue
+			}
+			if operator == "$gt" && cmp > 0 || operator == "$gte" && cmp >= 0 || operator == "$lt" && cmp < 0 || operator == "$lte" && cmp <= 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$in", "$nin":
+		candidates, ok := argument.(primitive.A)
+		if !ok {
+			return false, fmt.Errorf("memory store: %s needs an array", operator)
+		}
+		in := false
+		for _, candidate := range candidates {
+			if matchEquals(values, found, candidate) {
+				in = true
+				break
+			}
+		}
+		return in == (operator == "$in"), nil
+	case "$exists":
+		return found == truthy(argument), nil
+	case "$not":
+		inner, ok := argument.(primitive.D)
+		if !ok {
+			return false, fmt.Errorf("memory store: $not needs an operator document")
+		}
+		for _, op := range inner {
+			ok, err := matchOperator(values, found, op.Key, op.Value, inner)
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$size":
+		for _, value := range values {
+			if array, ok := value.(primitive.A); ok && float64(len(array)) == asFloat(argument) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$regex":
+		pattern, _ := argument.(string)
+		if options, ok := docGet(operators, "$options"); ok {
+			pattern = "(?" + options.(string) + ")" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, err
+		}
+		for _, value := range expandArrays(values) {
+			if s, ok := value.(string); ok && re.MatchString(s) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$options":
+		return true, nil
+	}
+	return false, fmt.Errorf("memory store: unsupported query operator %s", operator)
+}
+
+// matchEquals implements Mongo equality: null matches missing fields and arrays match any of their elements.
+func matchEquals(values []interface{}, found bool, condition interface{}) bool {
+	if condition == nil {
+		if !found {
+			return true
+		}
+		for _, value := range values {
+			if value == nil {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, value := range values {
+		if valuesEqual(value, condition) {
+			return true
+		}
+		if array, ok := value.(primitive.A); ok {
+			for _, element := range array {
+				if valuesEqual(element, condition) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func expandArrays(values []interface{}) []interface{} {
+	var expanded []interface{}
+	for _, value := range values {
+		if array, ok := value.(primitive.A); ok {
+			expanded = append(expanded, array...)
+			continue
+		}
+		expanded = append(expanded, value)
+	}
+	return expanded
+}
+
+func isOperatorDocument(doc primitive.D) bool {
+	return len(doc) > 0 && strings.HasPrefix(doc[0].Key, "$")
+}
+
+// lookupValues resolves a dotted path the way queries do, descending into every element of arrays on the way.
+func lookupValues(value interface{}, parts []string) ([]interface{}, bool) {
+	if len(parts) == 0 {
+		return []interface{}{value}, true
+	}
+
+	switch v := value.(type) {
+	case primitive.D:
+		child, ok := docGet(v, parts[0])
+		if !ok {
+			return nil, false
+		}
+		return lookupValues(child, parts[1:])
+	case primitive.A:
+		if index, err := strconv.Atoi(parts[0]); err == nil {
+			if index < len(v) {
+				return lookupValues(v[index], parts[1:])
+			}
+			return nil, false
+		}
+		var values []interface{}
+		found := false
+		for _, element := range v {
+			if _, ok := element.(primitive.D); !ok {
+				continue
+			}
+			elementValues, ok := lookupValues(element, parts)
+			if ok {
+				values = append(values, elementValues...)
+				found = true
+			}
+		}
+		return values, found
+	}
+	return nil, false
+}
+
+// fieldValue resolves a dotted path the way aggregation expressions do: paths through arrays yield arrays.
+func fieldValue(value interface{}, path string) (interface{}, bool) {
+	current := value
+	for _, part := range strings.Split(path, ".") {
+		switch v := current.(type) {
+		case primitive.D:
+			child, ok := docGet(v, part)
+			if !ok {
+				return nil, false
+			}
+			current = child
+		case primitive.A:
+			out := primitive.A{}
+			for _, element := range v {
+				if child, ok := fieldValue(element, part); ok {
+					out = append(out, child)
+				}
+			}
+			current = out
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func setPath(doc primitive.D, path string, value interface{}) primitive.D {
+	parts := strings.SplitN(path, ".", 2)
+	for i, e := range doc {
+		if e.Key != parts[0] {
+			continue
+		}
+		if len(parts) == 1 {
+			doc[i].Value = value
+			return doc
+		}
+		switch child := e.Value.(type) {
+		case primitive.D:
+			doc[i].Value = setPath(child, parts[1], value)
+		case primitive.A:
+			doc[i].Value = setArrayPath(child, parts[1], value)
+		default:
+			doc[i].Value = setPath(primitive.D{}, parts[1], value)
+		}
+		return doc
+	}
+
+	if len(parts) == 1 {
+		return append(doc, primitive.E{Key: path, Value: value})
+	}
+	return append(doc, primitive.E{Key: parts[0], Value: setPath(primitive.D{}, parts[1], value)})
+}
+
+func setArrayPath(array primitive.A, path string, value interface{}) primitive.A {
+	parts := strings.SplitN(path, ".", 2)
+	index, err := strconv.Atoi(parts[0])
+	if err != nil || index >= len(array) {
+		return array
+	}
+	if len(parts) == 1 {
+		array[index] = value
+		return array
+	}
+	if child, ok := array[index].(primitive.D); ok {
+		array[index] = setPath(child, parts[1], value)
+	}
+	return array
+}
+
+func unsetPath(doc primitive.D, path string) primitive.D {
+	parts := strings.SplitN(path, ".", 2)
+	for i, e := range doc {
+		if e.Key != parts[0] {
+			continue
+		}
+		if len(parts) == 1 {
+			return append(doc[:i:i], doc[i+1:]...)
+		}
+		if child, ok := e.Value.(primitive.D); ok {
+			doc[i].Value = unsetPath(child, parts[1])
+		}
+		return doc
+	}
+	return doc
+}
+
+// applyUpdate applies update operators to doc. inserting is true when the document is being created by an upsert.
+func applyUpdate(doc primitive.D, update primitive.D, inserting bool) (primitive.D, error) {
+	if !isOperatorDocument(update) {
+		return nil, fmt.Errorf("memory store: updates must use update operators")
+	}
+
+	for _, operator := range update {
+		fields, ok := operator.Value.(primitive.D)
+		if !ok {
+			return nil, fmt.Errorf("memory store: %s needs a document", operator.Key)
+		}
+
+		for _, field := range fields {
+			current, found := fieldValue(doc, field.Key)
+
+			switch operator.Key {
+			case "$set":
+				doc = setPath(doc, field.Key, field.Value)
+			case "$setOnInsert":
+				if inserting {
+					doc = setPath(doc, field.Key, field.Value)
+				}
+			case "$unset":
+				doc = unsetPath(doc, field.Key)
+			case "$inc":
+				if !found || current == nil {
+					current = int32(0)
+				}
+				sum, err := addNumbers(current, field.Value)
+				if err != nil {
+					return nil, err
+				}
+				doc = setPath(doc, field.Key, sum)
+			case "$min", "$max":
+				cmp, comparable := compareValues(field.Value, current)
+				if !found || comparable && (operator.Key == "$min" && cmp < 0 || operator.Key == "$max" && cmp > 0) {
+					doc = setPath(doc, field.Key, field.Value)
+				}
+			case "$push", "$addToSet":
+				array, _ := current.(primitive.A)
+				if found && current != nil && array == nil {
+					return nil, fmt.Errorf("memory store: %s on non-array field %s", operator.Key, field.Key)
+				}
+				values := primitive.A{field.Value}
+				if each, ok := field.Value.(primitive.D); ok && len(each) > 0 && each[0].Key == "$each" {
+					values, _ = each[0].Value.(primitive.A)
+				}
+				array = append(primitive.A{}, array...)
+				for _, value := range values {
+					if operator.Key == "$addToSet" && matchEquals(array, true, value) {
+						continue
+					}
+					array = append(array, value)
+				}
+				doc = setPath(doc, field.Key, array)
+			case "$pull":
+				array, _ := current.(primitive.A)
+				kept := primitive.A{}
+				for _, element := range array {
+					if !valuesEqual(element, field.Value) {
+						kept = append(kept, element)
+					}
+				}
+				if array != nil {
+					doc = setPath(doc, field.Key, kept)
+				}
+			default:
+				return nil, fmt.Errorf("memory store: unsupported update operator %s", operator.Key)
+			}
+		}
+	}
+	return doc, nil
+}
+
+// equalityFields seeds an upserted document with the plain equality conditions of its filter.
+func equalityFields(query primitive.D) primitive.D {
+	doc := primitive.D{}
+	for _, e := range query {
+		if strings.HasPrefix(e.Key, "$") {
+			continue
+		}
+		if condition, ok := e.Value.(primitive.D); ok && isOperatorDocument(condition) {
+			if value, ok := docGet(condition, "$eq"); ok {
+				doc = setPath(doc, e.Key, value)
+			}
+			continue
+		}
+		doc = setPath(doc, e.Key, e.Value)
+	}
+	return doc
+}
+
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case int32, int64, float64:
+		return true
+	}
+	return false
+}
+
+func asFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+	}
+	return 0
+}
+
+func addNumbers(a interface{}, b interface{}) (interface{}, error) {
+	if !isNumber(a) || !isNumber(b) {
+		return nil, fmt.Errorf("memory store: cannot add %T and %T", a, b)
+	}
+	return numberResult(asFloat(a)+asFloat(b), a, b), nil
+}
+
+// numberResult keeps integer results integral, widening to int64 or float64 the way Mongo does.
+func numberResult(result float64, operands ...interface{}) interface{} {
+	kind := 0
+	for _, operand := range operands {
+		switch operand.(type) {
+		case int64:
+			kind = max(kind, 1)
+		case float64:
+			kind = 2
+		}
+	}
+	switch {
+	case kind == 2 || result != math.Trunc(result):
+		return result
+	case kind == 0 && result >= math.MinInt32 && result <= math.MaxInt32:
+		return int32(result)
+	}
+	return int64(result)
+}
+
+func valuesEqual(a interface{}, b interface{}) bool {
+	if isNumber(a) && isNumber(b) {
+		return asFloat(a) == asFloat(b)
+	}
+
+	switch av := a.(type) {
+	case primitive.D:
+		bv, ok := b.(primitive.D)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if av[i].Key != bv[i].Key || !valuesEqual(av[i].Value, bv[i].Value) {
+				return false
+			}
+		}
+		return true
+	case primitive.A:
+		bv, ok := b.(primitive.A)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !valuesEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case primitive.ObjectID:
+		bv, ok := b.(primitive.ObjectID)
+		return ok && av == bv
+	case primitive.DateTime:
+		bv, ok := b.(primitive.DateTime)
+		return ok && av == bv
+	case nil:
+		return b == nil
+	case missing:
+		_, ok := b.(missing)
+		return ok
+	}
+
+	switch b.(type) {
+	case primitive.D, primitive.A, nil:
+		return false
+	}
+	return a == b
+}
+
+func documentsEqual(a primitive.D, b primitive.D) bool {
+	return valuesEqual(a, b)
+}
+
+// compareValues compares values of the same kind; ok is false when they can't be compared.
+func compareValues(a interface{}, b interface{}) (int, bool) {
+	if isNumber(a) && isNumber(b) {
+		return compareFloats(asFloat(a), asFloat(b)), true
+	}
+
+	switch av := a.(type) {
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv), true
+		}
+	case primitive.DateTime:
+		if bv, ok := b.(primitive.DateTime); ok {
+			return compareFloats(float64(av), float64(bv)), true
+		}
+	case primitive.ObjectID:
+		if bv, ok := b.(primitive.ObjectID); ok {
+			return bytes.Compare(av[:], bv[:]), true
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			return compareFloats(asFloat(av), asFloat(bv)), true
+		}
+	}
+	return 0, false
+}
+
+// compareOrder orders any two values using Mongo's sort order across types.
+func compareOrder(a interface{}, b interface{}) int {
+	rankA, rankB := typeRank(a), typeRank(b)
+	if rankA != rankB {
+		return compareFloats(float64(rankA), float64(rankB))
+	}
+	if cmp, ok := compareValues(a, b); ok {
+		return cmp
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func typeRank(value interface{}) int {
+	switch value.(type) {
+	case nil, missing:
+		return 1
+	case int32, int64, float64:
+		return 2
+	case string:
+		return 3
+	case primitive.D:
+		return 4
+	case primitive.A:
+		return 5
+	case primitive.ObjectID:
+		return 7
+	case bool:
+		return 8
+	case primitive.DateTime:
+		return 9
+	}
+	return 6
+}
+
+func compareFloats(a float64, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil, missing:
+		return false
+	case bool:
+		return v
+	case int32, int64, float64:
+		return asFloat(v) != 0
+	}
+	return true
+}
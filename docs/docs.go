@@ -0,0 +1,38 @@
+package docs
+
+import (
+	"embed"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+//go:embed ui/index.html
+var ui embed.FS
+
+var ownRoutes = map[string]bool{
+	"/openapi.json": true,
+	"/docs":         true,
+}
+
+// DocsRoutes serves the OpenAPI document and the docs UI. Register them before the authentication
+// middleware so the docs can be read without a token. The document is built on first request, once
+// every route has been registered.
+func DocsRoutes(app *fiber.App) {
+	var once sync.Once
+	var spec *Document
+
+	app.Get("/openapi.json", func(c *fiber.Ctx) error {
+		once.Do(func() { spec = Generate(c.App()) })
+		return c.JSON(spec)
+	})
+
+	app.Get("/docs", func(c *fiber.Ctx) error {
+		page, err := ui.ReadFile("ui/index.html")
+		if err != nil {
+			return err
+		}
+		c.Type("html")
+		return c.Send(page)
+	})
+}
@@ -0,0 +1,119 @@
+package docs_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mayankr5/v1/restaurant-management/app"
+	"github.com/mayankr5/v1/restaurant-management/database"
+	"github.com/mayankr5/v1/restaurant-management/docs"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// newApp builds the app the way main does, so the probes, metrics and keys are documented too.
+func newApp() *fiber.App {
+	return app.New(app.DefaultConfig(), app.Deps{Store: database.NewMemoryStore()})
+}
+
+func TestSpecMatchesRoutes(t *testing.T) {
+	app := newApp()
+
+	if missing := docs.Undocumented(app); len(missing) > 0 {
+		t.Errorf("routes missing from the operations table: %v", missing)
+	}
+	if stale := docs.Stale(app); len(stale) > 0 {
+		t.Errorf("operations without a route: %v", stale)
+	}
+
+	doc := docs.Generate(app)
+	documented := map[string]bool{}
+	for path, item := range doc.Paths {
+		for method := range *item {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+	registered := map[string]bool{}
+	for _, route := range docs.DocumentedRoutes(app) {
+		registered[route.Method+" "+docs.RouteParam.ReplaceAllString(route.Path, "{$1}")] = true
+	}
+	if !reflect.DeepEqual(documented, registered) {
+		t.Errorf("spec paths %v, registered routes %v", documented, registered)
+	}
+}
+
+func TestSchemasCarryValidateTags(t *testing.T) {
+	doc := docs.Generate(newApp())
+
+	pack := doc.Components.Schemas["OrderItemPack"]
+	if pack == nil {
+		t.Fatal("OrderItemPack schema missing")
+	}
+	if !reflect.DeepEqual(pack.Required, []string{"table_id", "order_items"}) {
+		t.Errorf("OrderItemPack required = %v", pack.Required)
+	}
+	if items := pack.Properties["order_items"]; items.MinItems == nil || *items.MinItems != 1 {
+		t.Errorf("order_items minItems = %v", items.MinItems)
+	}
+
+	quantity := doc.Components.Schemas["OrderItemRequest"].Properties["quantity"]
+	if !reflect.DeepEqual(quantity.Enum, []interface{}{"S", "M", "L"}) {
+		t.Errorf("quantity enum = %v", quantity.Enum)
+	}
+	if quantity.Nullable {
+		t.Error("required quantity should not be nullable")
+	}
+
+	email := doc.Components.Schemas["SignUpRequest"].Properties["email"]
+	if email.Format != "email" {
+		t.Errorf("email format = %q", email.Format)
+	}
+}
+
+func TestServesDocument(t *testing.T) {
+	app := newApp()
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /openapi.json = %d", resp.StatusCode)
+	}
+	var doc docs.Document
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.0.3" || doc.Paths["/orderItems/{order_item_id}"] == nil {
+		t.Errorf("unexpected document: openapi %q, %d paths", doc.OpenAPI, len(doc.Paths))
+	}
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("GET /docs = %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestPublicOperationsNeedNoToken(t *testing.T) {
+	app := newApp()
+
+	for route := range docs.PublicRoutes() {
+		method, path, _ := strings.Cut(route, " ")
+		resp, err := app.Test(httptest.NewRequest(method, path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusUnauthorized && strings.Contains(string(body), "No Authorization header") {
+			t.Errorf("%s asked for a token", route)
+		}
+	}
+}
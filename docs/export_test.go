@@ -0,0 +1,7 @@
+package docs
+
+// the route listing, for the tests of the app as a whole
+var (
+	DocumentedRoutes = documentedRoutes
+	RouteParam       = routeParam
+)
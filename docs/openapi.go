@@ -0,0 +1,242 @@
+// Package docs describes the API as an OpenAPI 3 document built from the routes registered on the
+// app, the operations table in operations.go and the request and response structs, and serves it
+// together with a docs UI.
+package docs
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]*PathItem  `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type string `json:"type"`
+	In   string `json:"in"`
+	Name string `json:"name"`
+}
+
+// PathItem maps lower case HTTP methods to their operation.
+type PathItem map[string]*OperationObject
+
+type OperationObject struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []*Parameter               `json:"parameters,omitempty"`
+	RequestBody *RequestBody               `json:"requestBody,omitempty"`
+	Responses   map[string]*ResponseObject `json:"responses"`
+	// Security overrides the document's; an empty list means no token is needed.
+	Security *[]map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type ResponseObject struct {
+	Description string                `json:"description"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+var routeParam = regexp.MustCompile(`:(\w+)`)
+
+// Generate describes every route registered on app, apart from the docs routes themselves.
+// Routes without an entry in the operations table are still listed, just without bodies.
+func Generate(app *fiber.App) *Document {
+	schemas := newSchemaBuilder()
+
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "Restaurant Management API",
+			Description: "Errors are returned as an Error object with a stable code.",
+			Version:     "1.0.0",
+		},
+		Paths: map[string]*PathItem{},
+		Components: Components{
+			Schemas: schemas.components,
+			SecuritySchemes: map[string]*SecurityScheme{
+				"token": {Type: "apiKey", In: "header", Name: "token"},
+			},
+		},
+		Security: []map[string][]string{{"token": {}}},
+	}
+
+	errorSchema := schemas.schemaOf(apierrors.Error{})
+
+	for _, route := range documentedRoutes(app) {
+		op := operations[route.Method+" "+route.Path]
+		path := routeParam.ReplaceAllString(route.Path, "{$1}")
+
+		code := op.Status
+		if code == 0 {
+			code = http.StatusOK
+		}
+		status := strconv.Itoa(code)
+
+		operation := &OperationObject{
+			OperationID: operationID(route),
+			Summary:     op.Summary,
+			Responses: map[string]*ResponseObject{
+				status:    {Description: http.StatusText(code)},
+				"default": {Description: "Error", Content: jsonContent(errorSchema)},
+			},
+		}
+		if op.Public {
+			operation.Security = &[]map[string][]string{}
+		}
+		if op.Tag != "" {
+			operation.Tags = []string{op.Tag}
+		}
+
+		for _, name := range route.Params {
+			operation.Parameters = append(operation.Parameters, &Parameter{
+				Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"},
+			})
+		}
+		for _, query := range op.Query {
+			param := query
+			operation.Parameters = append(operation.Parameters, &param)
+		}
+		if route.Method == fiber.MethodPatch {
+			operation.Parameters = append(operation.Parameters, &Parameter{
+				Name: "If-Match", In: "header", Schema: &Schema{Type: "string"},
+				Description: "ETag of the version being edited; the update fails with 412 if it changed since",
+			})
+		}
+		if route.Method != fiber.MethodGet {
+			operation.Parameters = append(operation.Parameters, &Parameter{
+				Name: "Idempotency-Key", In: "header", Schema: &Schema{Type: "string", MaxLength: intPtr(255)},
+				Description: "retries with the same key and body replay the first response",
+			})
+		}
+
+		if op.Request != nil {
+			operation.RequestBody = &RequestBody{Required: true, Content: jsonContent(schemas.schemaOf(op.Request))}
+		}
+		if op.Response != nil {
+			operation.Responses[status].Content = jsonContent(schemas.schemaOf(op.Response))
+		}
+		if op.ETag || route.Method == fiber.MethodPatch {
+			operation.Responses[status].Headers = map[string]*Header{
+				"ETag": {Description: "version of the returned resource", Schema: &Schema{Type: "string"}},
+			}
+		}
+
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+		(*item)[strings.ToLower(route.Method)] = operation
+	}
+
+	return doc
+}
+
+// Undocumented lists the routes registered on app that are missing from the operations table.
+func Undocumented(app *fiber.App) []string {
+	var missing []string
+	for _, route := range documentedRoutes(app) {
+		if _, ok := operations[route.Method+" "+route.Path]; !ok {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	return missing
+}
+
+// Stale lists the entries of the operations table that no longer match a registered route.
+func Stale(app *fiber.App) []string {
+	registered := map[string]bool{}
+	for _, route := range documentedRoutes(app) {
+		registered[route.Method+" "+route.Path] = true
+	}
+
+	var stale []string
+	for key := range operations {
+		if !registered[key] {
+			stale = append(stale, key)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// documentedRoutes returns the app's routes without middleware, the HEAD routes Fiber adds for
+// every GET, and the docs routes.
+func documentedRoutes(app *fiber.App) []fiber.Route {
+	var routes []fiber.Route
+	seen := map[string]bool{}
+	for _, route := range app.GetRoutes(true) {
+		key := route.Method + " " + route.Path
+		if route.Method == fiber.MethodHead || ownRoutes[route.Path] || seen[key] {
+			continue
+		}
+		seen[key] = true
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+func operationID(route fiber.Route) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(route.Method))
+	for _, part := range strings.FieldsFunc(route.Path, func(r rune) bool { return r == '/' || r == '-' || r == ':' || r == '_' }) {
+		id.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return id.String()
+}
+
+func jsonContent(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{fiber.MIMEApplicationJSON: {Schema: schema}}
+}
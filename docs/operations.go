@@ -0,0 +1,167 @@
+package docs
+
+import (
+	"net/http"
+
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	"github.com/mayankr5/v1/restaurant-management/models"
+	"github.com/mayankr5/v1/restaurant-management/signing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Operation is what the route registrations don't tell: the request and response bodies, the
+// query parameters and a summary. Request and Response hold a value of the body's type.
+type Operation struct {
+	Summary  string
+	Tag      string
+	Query    []Parameter
+	Request  interface{}
+	Response interface{}
+	// ETag is set on single resource reads, which return the version in an ETag header.
+	ETag bool
+	// Status is the status of a successful response, 200 when zero.
+	Status int
+	// Public operations are served without a token.
+	Public bool
+	// Credentials operations answer with tokens, keys, secrets or codes, which must never be
+	// stored for an idempotent replay.
+	Credentials bool
+}
+
+var (
+	includeDeleted = Parameter{Name: "include_deleted", In: "query", Description: "also list soft-deleted documents", Schema: &Schema{Type: "boolean"}}
+	recordPerPage  = Parameter{Name: "recordPerPage", In: "query", Description: "page size, 10 by default", Schema: &Schema{Type: "integer", Minimum: floatPtr(1)}}
+	page           = Parameter{Name: "page", In: "query", Description: "1 based page number", Schema: &Schema{Type: "integer", Minimum: floatPtr(1)}}
+	startIndex     = Parameter{Name: "startIndex", In: "query", Description: "offset of the first item", Schema: &Schema{Type: "integer", Minimum: floatPtr(0)}}
+	from           = Parameter{Name: "from", In: "query", Description: "start of the period, RFC3339", Schema: &Schema{Type: "string", Format: "date-time"}}
+	to             = Parameter{Name: "to", In: "query", Description: "end of the period, RFC3339", Schema: &Schema{Type: "string", Format: "date-time"}}
+)
+
+func queryString(name string, description string) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: &Schema{Type: "string"}}
+}
+
+// operations documents every route, keyed by method and path as registered. The docs test fails
+// when a route is missing here or an entry no longer matches a route.
+var operations = map[string]Operation{
+	"GET /healthz":               {Summary: "Tell that the process is up", Tag: "operations", Response: dto.StatusResponse{}, Public: true},
+	"GET /readyz":                {Summary: "Tell whether the service can take traffic; 503 while the database is unreachable", Tag: "operations", Response: dto.ReadinessResponse{}, Public: true},
+	"GET /metrics":               {Summary: "Prometheus metrics, in the text exposition format", Tag: "operations", Public: true},
+	"GET /.well-known/jwks.json": {Summary: "The public keys that verify the tokens", Tag: "operations", Response: signing.JSONWebKeySet{}, Public: true},
+
+	"GET /audit": {Summary: "List the audit trail (managers only)", Tag: "audit", Response: []models.Audit{},
+		Query: []Parameter{
+			queryString("entity", "entity type, e.g. invoice"),
+			queryString("entity_id", "id of the entity"),
+			queryString("user_id", "user who made the change"),
+			queryString("action", "CREATE, UPDATE, DELETE, RESTORE, VOID, REFUND or LOGIN"),
+			from, to, recordPerPage, page,
+		}},
+
+	"GET /coupons":            {Summary: "List coupons", Tag: "promotions", Response: []dto.CouponResponse{}},
+	"GET /coupons/:coupon_id": {Summary: "Get a coupon", Tag: "promotions", Response: dto.CouponResponse{}, ETag: true},
+	"POST /coupons":           {Summary: "Create a coupon code for a promotion", Tag: "promotions", Request: dto.CreateCouponRequest{}, Response: mongo.InsertOneResult{}},
+
+	"GET /foods":                        {Summary: "List foods a page at a time", Tag: "foods", Query: []Parameter{recordPerPage, page, startIndex, includeDeleted}, Response: dto.FoodPage{}},
+	"GET /foods/:food_id":               {Summary: "Get a food", Tag: "foods", Response: dto.FoodResponse{}, ETag: true},
+	"GET /foods/:food_id/prices":        {Summary: "Get the price history of a food", Tag: "foods", Response: []models.PriceChange{}},
+	"POST /foods":                       {Summary: "Create a food", Tag: "foods", Request: dto.CreateFoodRequest{}, Response: mongo.InsertOneResult{}},
+	"PATCH /foods/:food_id":             {Summary: "Update a food", Tag: "foods", Request: dto.UpdateFoodRequest{}, Response: mongo.UpdateResult{}},
+	"DELETE /foods/:food_id":            {Summary: "Soft delete a food (managers only)", Tag: "foods", Response: mongo.UpdateResult{}},
+	"POST /foods/:food_id/restore":      {Summary: "Restore a deleted food (managers only)", Tag: "foods", Response: mongo.UpdateResult{}},
+	"GET /invoices":                     {Summary: "List invoices", Tag: "invoices", Response: []dto.InvoiceResponse{}},
+	"GET /invoices/:invoice_id":         {Summary: "Get an invoice with its order lines", Tag: "invoices", Response: dto.InvoiceViewFormat{}, ETag: true},
+	"POST /invoices":                    {Summary: "Invoice an order, applying promotions and an optional coupon", Tag: "invoices", Request: dto.CreateInvoiceRequest{}, Response: mongo.InsertOneResult{}},
+	"PATCH /invoices/:invoice_id":       {Summary: "Update the payment of an invoice; its method is fixed once it is paid", Tag: "invoices", Request: dto.UpdateInvoiceRequest{}, Response: mongo.UpdateResult{}},
+	"POST /invoices/:invoice_id/refund": {Summary: "Refund a paid invoice in full or by line, with manager approval", Tag: "invoices", Request: dto.RefundRequest{}, Response: dto.RefundResponse{}},
+	"GET /invoices/:invoice_id/refunds": {Summary: "List the refunds of an invoice", Tag: "invoices", Response: []dto.RefundResponse{}},
+
+	"GET /menus":                   {Summary: "List menus", Tag: "menus", Query: []Parameter{includeDeleted}, Response: []dto.MenuResponse{}},
+	"GET /menus/:menu_id":          {Summary: "Get a menu", Tag: "menus", Response: dto.MenuResponse{}, ETag: true},
+	"POST /menus":                  {Summary: "Create a menu", Tag: "menus", Request: dto.CreateMenuRequest{}, Response: mongo.InsertOneResult{}},
+	"PATCH /menus/:menu_id":        {Summary: "Update a menu", Tag: "menus", Request: dto.UpdateMenuRequest{}, Response: mongo.UpdateResult{}},
+	"DELETE /menus/:menu_id":       {Summary: "Soft delete a menu (managers only)", Tag: "menus", Response: mongo.UpdateResult{}},
+	"POST /menus/:menu_id/restore": {Summary: "Restore a deleted menu (managers only)", Tag: "menus", Response: mongo.UpdateResult{}},
+
+	"GET /orders":                    {Summary: "List orders", Tag: "orders", Query: []Parameter{includeDeleted}, Response: []dto.OrderResponse{}},
+	"GET /orders/:order_id":          {Summary: "Get an order", Tag: "orders", Response: dto.OrderResponse{}, ETag: true},
+	"POST /orders":                   {Summary: "Create an empty order", Tag: "orders", Request: dto.CreateOrderRequest{}, Response: mongo.InsertOneResult{}},
+	"PATCH /orders/:order_id":        {Summary: "Update an order", Tag: "orders", Request: dto.UpdateOrderRequest{}, Response: mongo.UpdateResult{}},
+	"DELETE /orders/:order_id":       {Summary: "Soft delete an order (managers only)", Tag: "orders", Response: mongo.UpdateResult{}},
+	"POST /orders/:order_id/restore": {Summary: "Restore a deleted order (managers only)", Tag: "orders", Response: mongo.UpdateResult{}},
+
+	"GET /orderItems":                      {Summary: "List order items", Tag: "orders", Response: []dto.OrderItemResponse{}},
+	"GET /orderItems/:order_item_id":       {Summary: "Get an order item", Tag: "orders", Response: dto.OrderItemResponse{}, ETag: true},
+	"GET /orderItems-order/:order_id":      {Summary: "List the items of an order with the amount due", Tag: "orders", Response: []dto.OrderItemsOfOrder{}},
+	"POST /orderItems":                     {Summary: "Place an order together with its items", Tag: "orders", Request: dto.OrderItemPack{}, Response: dto.OrderViewFormat{}},
+	"PATCH /orderItems/:order_item_id":     {Summary: "Update an active item of an unpaid order, repricing its pending invoices", Tag: "orders", Request: dto.UpdateOrderItemRequest{}, Response: mongo.UpdateResult{}},
+	"POST /orderItems/:order_item_id/void": {Summary: "Void an order item, with manager approval", Tag: "orders", Request: dto.VoidRequest{}, Response: mongo.UpdateResult{}},
+
+	"GET /promotions":                 {Summary: "List promotions", Tag: "promotions", Response: []dto.PromotionResponse{}},
+	"GET /promotions/report":          {Summary: "Report how often each promotion applied and what it cost", Tag: "promotions", Query: []Parameter{from, to}, Response: []dto.PromotionUsage{}},
+	"GET /promotions/:promotion_id":   {Summary: "Get a promotion", Tag: "promotions", Response: dto.PromotionResponse{}, ETag: true},
+	"POST /promotions":                {Summary: "Create a promotion", Tag: "promotions", Request: dto.CreatePromotionRequest{}, Response: mongo.InsertOneResult{}},
+	"PATCH /promotions/:promotion_id": {Summary: "Update a promotion", Tag: "promotions", Request: dto.UpdatePromotionRequest{}, Response: mongo.UpdateResult{}},
+
+	"GET /reports/sales": {Summary: "Sales paid in the period by payment method, net of discounts and of the refunds made in it (managers only)", Tag: "reports", Query: []Parameter{from, to}, Response: dto.SalesReport{}},
+
+	"GET /tables":                    {Summary: "List tables", Tag: "tables", Query: []Parameter{includeDeleted}, Response: []dto.TableResponse{}},
+	"GET /tables/:table_id":          {Summary: "Get a table", Tag: "tables", Response: dto.TableResponse{}, ETag: true},
+	"POST /tables":                   {Summary: "Create a table", Tag: "tables", Request: dto.CreateTableRequest{}, Response: mongo.InsertOneResult{}},
+	"PATCH /tables/:table_id":        {Summary: "Update a table", Tag: "tables", Request: dto.UpdateTableRequest{}, Response: mongo.UpdateResult{}},
+	"DELETE /tables/:table_id":       {Summary: "Soft delete a table (managers only)", Tag: "tables", Response: mongo.UpdateResult{}},
+	"POST /tables/:table_id/restore": {Summary: "Restore a deleted table (managers only)", Tag: "tables", Response: mongo.UpdateResult{}},
+
+	"GET /terminals":                 {Summary: "List POS terminals and who is signed in on them", Tag: "terminals", Response: []dto.TerminalResponse{}},
+	"POST /terminals":                {Summary: "Register a POS terminal and get its key (managers only)", Tag: "terminals", Request: dto.RegisterTerminalRequest{}, Response: dto.TerminalRegistrationResponse{}, Credentials: true},
+	"POST /terminals/login":          {Summary: "Sign in on a terminal with a PIN, signing out the previous user; not for users with MFA", Tag: "terminals", Request: dto.TerminalLoginRequest{}, Response: dto.TerminalLoginResponse{}, Public: true, Credentials: true},
+	"POST /terminals/logout":         {Summary: "End the terminal session of the token", Tag: "terminals", Response: dto.StatusResponse{}},
+	"DELETE /terminals/:terminal_id": {Summary: "Revoke a terminal's key and end its session (managers only)", Tag: "terminals", Response: dto.StatusResponse{}},
+
+	"GET /users":                         {Summary: "List users a page at a time", Tag: "users", Query: []Parameter{recordPerPage, page, startIndex, includeDeleted}, Response: dto.UserPage{}},
+	"GET /users/:user_id":                {Summary: "Get a user", Tag: "users", Response: dto.UserResponse{}, ETag: true},
+	"POST /users/signup":                 {Summary: "Sign up a user", Tag: "users", Request: dto.SignUpRequest{}, Response: mongo.InsertOneResult{}},
+	"POST /users/login":                  {Summary: "Log in with email and password; users with MFA get a challenge instead of tokens", Tag: "users", Request: dto.LoginRequest{}, Response: dto.LoginResponse{}, Public: true, Credentials: true},
+	"POST /users/verification":           {Summary: "Mail the signed in user a new email verification token", Tag: "users", Response: dto.StatusResponse{}},
+	"POST /users/verification/confirm":   {Summary: "Verify an email with the mailed token", Tag: "users", Request: dto.VerifyEmailRequest{}, Response: dto.UserResponse{}, Public: true},
+	"POST /users/password-reset":         {Summary: "Mail a password reset token, if the email has an account", Tag: "users", Request: dto.PasswordResetRequest{}, Response: dto.StatusResponse{}, Status: http.StatusAccepted, Public: true},
+	"POST /users/password-reset/confirm": {Summary: "Set a new password with the mailed token", Tag: "users", Request: dto.PasswordResetConfirmRequest{}, Response: dto.StatusResponse{}, Public: true},
+	"POST /users/login/mfa":              {Summary: "Finish a login with a code from the authenticator app or a recovery code", Tag: "users", Request: dto.MfaLoginRequest{}, Response: dto.LoginResponse{}, Public: true, Credentials: true},
+	"POST /users/login/mfa/enroll":       {Summary: "Get the MFA secret of a login that has to set MFA up, with the token mailed for it", Tag: "users", Request: dto.MfaLoginEnrollRequest{}, Response: dto.MfaEnrollmentResponse{}, Public: true, Credentials: true},
+	"POST /users/mfa":                    {Summary: "Start setting up MFA for the signed in user, confirmed with their password", Tag: "users", Request: dto.MfaEnrollRequest{}, Response: dto.MfaEnrollmentResponse{}, Credentials: true},
+	"POST /users/mfa/confirm":            {Summary: "Enable MFA with the first code and get the recovery codes", Tag: "users", Request: dto.MfaConfirmRequest{}, Response: dto.RecoveryCodesResponse{}, Credentials: true},
+	"DELETE /users/mfa":                  {Summary: "Turn MFA off, confirmed with the password and a code", Tag: "users", Request: dto.MfaDisableRequest{}, Response: dto.StatusResponse{}},
+	"POST /users/pin":                    {Summary: "Set the signed in user's PIN for terminals, confirmed with their password", Tag: "users", Request: dto.SetPinRequest{}, Response: dto.StatusResponse{}},
+	"POST /users/:user_id/unlock":        {Summary: "Lift the lockout of an account after failed logins (managers only)", Tag: "users", Response: dto.StatusResponse{}},
+	"DELETE /users/:user_id":             {Summary: "Soft delete a user (managers only)", Tag: "users", Response: mongo.UpdateResult{}},
+	"POST /users/:user_id/restore":       {Summary: "Restore a deleted user (managers only)", Tag: "users", Response: mongo.UpdateResult{}},
+}
+
+// PublicRoutes returns the method and path of every Public operation, for the authentication
+// middleware to let through without a token.
+func PublicRoutes() map[string]bool {
+	public := map[string]bool{}
+	for key, op := range operations {
+		if op.Public {
+			public[key] = true
+		}
+	}
+	return public
+}
+
+// CredentialRoutes returns the method and path of every Credentials operation, for the
+// idempotency middleware to leave alone.
+func CredentialRoutes() map[string]bool {
+	credentials := map[string]bool{}
+	for key, op := range operations {
+		if op.Credentials {
+			credentials[key] = true
+		}
+	}
+	return credentials
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
@@ -0,0 +1,229 @@
+package docs
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+	bytesType    = reflect.TypeOf([]byte(nil))
+)
+
+// schemaBuilder turns Go types into schemas the way encoding/json serializes them. Named structs
+// end up in components and are referenced, so each one is described once.
+type schemaBuilder struct {
+	components map[string]*Schema
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{components: map[string]*Schema{}}
+}
+
+func (b *schemaBuilder) schemaOf(value interface{}) *Schema {
+	return b.schema(reflect.TypeOf(value))
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case objectIDType:
+		return &Schema{Type: "string", Pattern: "^[0-9a-f]{24}$"}
+	case bytesType:
+		return &Schema{Type: "string", Format: "byte"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: true}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+		if _, ok := b.components[name]; !ok {
+			// reserve the name first so that self references terminate
+			b.components[name] = &Schema{}
+			*b.components[name] = *b.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+func (b *schemaBuilder) object(t reflect.Type) *Schema {
+	object := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			embedded := b.object(indirect(field.Type))
+			for key, property := range embedded.Properties {
+				object.Properties[key] = property
+			}
+			object.Required = append(object.Required, embedded.Required...)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		property := b.schema(field.Type)
+		if field.Type.Kind() == reflect.Ptr && property.Ref == "" {
+			property.Nullable = true
+		}
+		if constrain(property, indirect(field.Type), field.Tag.Get("validate")) {
+			object.Required = append(object.Required, name)
+		}
+		object.Properties[name] = property
+	}
+
+	return object
+}
+
+// constrain copies the validate tag onto the schema as far as OpenAPI can express it and reports
+// whether the field is required. Rules after "dive" apply to the elements and are left out.
+func constrain(schema *Schema, t reflect.Type, tag string) bool {
+	required := false
+
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == "dive" {
+			break
+		}
+
+		if strings.Contains(rule, "|") {
+			for _, alternative := range strings.Split(rule, "|") {
+				if value, ok := strings.CutPrefix(alternative, "eq="); ok {
+					schema.Enum = append(schema.Enum, value)
+				}
+			}
+			continue
+		}
+
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+			schema.Nullable = false
+		case "email":
+			schema.Format = "email"
+		case "eq":
+			schema.Enum = []interface{}{param}
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				schema.Enum = append(schema.Enum, value)
+			}
+		case "datetime":
+			if param == "15:04" {
+				schema.Pattern = `^([01][0-9]|2[0-3]):[0-5][0-9]$`
+			} else {
+				schema.Description = "formatted as " + param
+			}
+		case "min", "gte", "gt":
+			limit(schema, t, param, true, name == "gt")
+		case "max", "lte", "lt":
+			limit(schema, t, param, false, name == "lt")
+		case "len":
+			limit(schema, t, param, true, false)
+			limit(schema, t, param, false, false)
+		}
+	}
+
+	return required
+}
+
+func limit(schema *Schema, t reflect.Type, param string, lower bool, exclusive bool) {
+	value, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		if lower {
+			schema.MinLength = intPtr(int(value))
+		} else {
+			schema.MaxLength = intPtr(int(value))
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if lower {
+			schema.MinItems = intPtr(int(value))
+		} else {
+			schema.MaxItems = intPtr(int(value))
+		}
+	default:
+		if lower {
+			schema.Minimum = &value
+			schema.ExclusiveMinimum = exclusive
+		} else {
+			schema.Maximum = &value
+			schema.ExclusiveMaximum = exclusive
+		}
+	}
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func intPtr(value int) *int {
+	return &value
+}
@@ -0,0 +1,5 @@
+// Package dto holds the request and response bodies of the API. Requests only carry the fields a
+// client may set, so ids, timestamps, versions and other server-owned fields can't be supplied, and
+// responses only carry the fields a client may see, so password hashes and tokens are never
+// serialized. The mappers convert between them and the models stored in Mongo.
+package dto
@@ -0,0 +1,72 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/models"
+)
+
+type CreateFoodRequest struct {
+	Name       *string  `json:"name" validate:"required,min=2,max=100"`
+	Price      *float64 `json:"price" validate:"required"`
+	Food_image *string  `json:"food_image" validate:"required"`
+	Menu_id    *string  `json:"menu_id" validate:"required"`
+}
+
+type UpdateFoodRequest struct {
+	Name       *string  `json:"name" validate:"omitempty,min=2,max=100"`
+	Price      *float64 `json:"price"`
+	Food_image *string  `json:"food_image"`
+	Menu_id    *string  `json:"menu_id"`
+	Version    int      `json:"version"`
+}
+
+type FoodResponse struct {
+	Food_id       string               `json:"food_id"`
+	Name          *string              `json:"name"`
+	Price         *float64             `json:"price"`
+	Food_image    *string              `json:"food_image"`
+	Menu_id       *string              `json:"menu_id"`
+	Price_history []models.PriceChange `json:"price_history"`
+	Created_at    time.Time            `json:"created_at"`
+	Updated_at    time.Time            `json:"updated_at"`
+	Deleted_at    *time.Time           `json:"deleted_at"`
+	Version       int                  `json:"version"`
+}
+
+type FoodPage struct {
+	Total_count int            `json:"total_count"`
+	Food_items  []FoodResponse `json:"food_items"`
+}
+
+func (r CreateFoodRequest) Model() models.Food {
+	return models.Food{
+		Name:       r.Name,
+		Price:      r.Price,
+		Food_image: r.Food_image,
+		Menu_id:    r.Menu_id,
+	}
+}
+
+func NewFoodResponse(food models.Food) FoodResponse {
+	return FoodResponse{
+		Food_id:       food.Food_id,
+		Name:          food.Name,
+		Price:         food.Price,
+		Food_image:    food.Food_image,
+		Menu_id:       food.Menu_id,
+		Price_history: food.Price_history,
+		Created_at:    food.Created_at,
+		Updated_at:    food.Updated_at,
+		Deleted_at:    food.Deleted_at,
+		Version:       food.Version,
+	}
+}
+
+func NewFoodResponses(foods []models.Food) []FoodResponse {
+	responses := []FoodResponse{}
+	for _, food := range foods {
+		responses = append(responses, NewFoodResponse(food))
+	}
+	return responses
+}
@@ -0,0 +1,7 @@
+package dto
+
+// ReadinessResponse tells whether the service can take traffic and why not.
+type ReadinessResponse struct {
+	Status   string `json:"status"`
+	Database string `json:"database"`
+}
@@ -0,0 +1,163 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/models"
+)
+
+// CreateInvoiceRequest invoices an order. The amounts are always computed from the order.
+type CreateInvoiceRequest struct {
+	Order_id       string  `json:"order_id" validate:"required"`
+	Payment_method *string `json:"payment_method" validate:"omitempty,eq=CARD|eq=CASH"`
+	Payment_status *string `json:"payment_status" validate:"omitempty,eq=PENDING|eq=PAID"`
+	Coupon_code    *string `json:"coupon_code"`
+}
+
+type UpdateInvoiceRequest struct {
+	Payment_method *string `json:"payment_method" validate:"omitempty,eq=CARD|eq=CASH"`
+	Payment_status *string `json:"payment_status" validate:"omitempty,eq=PENDING|eq=PAID"`
+	Version        int     `json:"version"`
+}
+
+type RefundRequest struct {
+	Reason           *string  `json:"reason" validate:"required,min=3"`
+	Order_item_ids   []string `json:"order_item_ids"`
+	Manager_email    *string  `json:"manager_email" validate:"required,email"`
+	Manager_password *string  `json:"manager_password" validate:"required"`
+	Manager_code     string   `json:"manager_code"`
+}
+
+type InvoiceResponse struct {
+	Invoice_id         string                    `json:"invoice_id"`
+	Order_id           string                    `json:"order_id"`
+	Payment_method     *string                   `json:"payment_method"`
+	Payment_status     *string                   `json:"payment_status"`
+	Payment_due_date   time.Time                 `json:"Payment_due_date"`
+	Coupon_code        *string                   `json:"coupon_code"`
+	Subtotal           float64                   `json:"subtotal"`
+	Discount_total     float64                   `json:"discount_total"`
+	Total              float64                   `json:"total"`
+	Refunded_amount    float64                   `json:"refunded_amount"`
+	Paid_at            *time.Time                `json:"paid_at"`
+	Applied_promotions []models.AppliedPromotion `json:"applied_promotions"`
+	Created_at         time.Time                 `json:"created_at"`
+	Updated_at         time.Time                 `json:"updated_at"`
+	Version            int                       `json:"version"`
+}
+
+// InvoiceViewFormat is an invoice together with the lines of its order.
+type InvoiceViewFormat struct {
+	Invoice_id         string
+	Payment_method     string
+	Order_id           string
+	Payment_status     *string
+	Payment_due        float64
+	Discount_total     float64
+	Total              float64
+	Refunded_amount    float64
+	Applied_promotions []models.AppliedPromotion
+	Table_number       int
+	Payment_due_date   time.Time
+	Order_details      []OrderLine
+	Version            int
+}
+
+type RefundResponse struct {
+	Refund_id      string    `json:"refund_id"`
+	Invoice_id     string    `json:"invoice_id"`
+	Order_id       string    `json:"order_id"`
+	Order_item_ids []string  `json:"order_item_ids"`
+	Amount         float64   `json:"amount"`
+	Payment_method string    `json:"payment_method"`
+	Reason         string    `json:"reason"`
+	Refunded_by    string    `json:"refunded_by"`
+	Approved_by    string    `json:"approved_by"`
+	Created_at     time.Time `json:"created_at"`
+}
+
+func (r CreateInvoiceRequest) Model() models.Invoice {
+	return models.Invoice{
+		Order_id:       r.Order_id,
+		Payment_method: r.Payment_method,
+		Payment_status: r.Payment_status,
+		Coupon_code:    r.Coupon_code,
+	}
+}
+
+func NewInvoiceResponse(invoice models.Invoice) InvoiceResponse {
+	return InvoiceResponse{
+		Invoice_id:         invoice.Invoice_id,
+		Order_id:           invoice.Order_id,
+		Payment_method:     invoice.Payment_method,
+		Payment_status:     invoice.Payment_status,
+		Payment_due_date:   invoice.Payment_due_date,
+		Coupon_code:        invoice.Coupon_code,
+		Subtotal:           invoice.Subtotal,
+		Discount_total:     invoice.Discount_total,
+		Total:              invoice.Total,
+		Refunded_amount:    invoice.Refunded_amount,
+		Paid_at:            invoice.Paid_at,
+		Applied_promotions: invoice.Applied_promotions,
+		Created_at:         invoice.Created_at,
+		Updated_at:         invoice.Updated_at,
+		Version:            invoice.Version,
+	}
+}
+
+func NewInvoiceResponses(invoices []models.Invoice) []InvoiceResponse {
+	responses := []InvoiceResponse{}
+	for _, invoice := range invoices {
+		responses = append(responses, NewInvoiceResponse(invoice))
+	}
+	return responses
+}
+
+// NewInvoiceViewFormat combines the invoice with its order's lines, if it has any.
+func NewInvoiceViewFormat(invoice models.Invoice, lines []OrderItemsOfOrder) InvoiceViewFormat {
+	view := InvoiceViewFormat{
+		Invoice_id:         invoice.Invoice_id,
+		Payment_method:     "null",
+		Order_id:           invoice.Order_id,
+		Payment_status:     invoice.Payment_status,
+		Discount_total:     invoice.Discount_total,
+		Total:              invoice.Total,
+		Refunded_amount:    invoice.Refunded_amount,
+		Applied_promotions: invoice.Applied_promotions,
+		Payment_due_date:   invoice.Payment_due_date,
+		Order_details:      []OrderLine{},
+		Version:            invoice.Version,
+	}
+	if invoice.Payment_method != nil {
+		view.Payment_method = *invoice.Payment_method
+	}
+	if len(lines) > 0 {
+		view.Payment_due = lines[0].Payment_due
+		view.Table_number = lines[0].Table_number
+		view.Order_details = lines[0].Order_items
+	}
+	return view
+}
+
+func NewRefundResponse(refund models.Refund) RefundResponse {
+	return RefundResponse{
+		Refund_id:      refund.Refund_id,
+		Invoice_id:     refund.Invoice_id,
+		Order_id:       refund.Order_id,
+		Order_item_ids: refund.Order_item_ids,
+		Amount:         refund.Amount,
+		Payment_method: refund.Payment_method,
+		Reason:         refund.Reason,
+		Refunded_by:    refund.Refunded_by,
+		Approved_by:    refund.Approved_by,
+		Created_at:     refund.Created_at,
+	}
+}
+
+func NewRefundResponses(refunds []models.Refund) []RefundResponse {
+	responses := []RefundResponse{}
+	for _, refund := range refunds {
+		responses = append(responses, NewRefundResponse(refund))
+	}
+	return responses
+}
@@ -0,0 +1,65 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/models"
+)
+
+type CreateMenuRequest struct {
+	Name       string     `json:"name" validate:"required"`
+	Category   string     `json:"category" validate:"required"`
+	Start_date *time.Time `json:"start_date"`
+	End_date   *time.Time `json:"end_date"`
+}
+
+type UpdateMenuRequest struct {
+	Name       string     `json:"name"`
+	Category   string     `json:"category"`
+	Start_date *time.Time `json:"start_date"`
+	End_date   *time.Time `json:"end_date"`
+	Version    int        `json:"version"`
+}
+
+type MenuResponse struct {
+	Menu_id    string     `json:"menu_id"`
+	Name       string     `json:"name"`
+	Category   string     `json:"category"`
+	Start_date *time.Time `json:"start_date"`
+	End_date   *time.Time `json:"end_date"`
+	Created_at time.Time  `json:"created_at"`
+	Updated_at time.Time  `json:"updated_at"`
+	Deleted_at *time.Time `json:"deleted_at"`
+	Version    int        `json:"version"`
+}
+
+func (r CreateMenuRequest) Model() models.Menu {
+	return models.Menu{
+		Name:       r.Name,
+		Category:   r.Category,
+		Start_Date: r.Start_date,
+		End_Date:   r.End_date,
+	}
+}
+
+func NewMenuResponse(menu models.Menu) MenuResponse {
+	return MenuResponse{
+		Menu_id:    menu.Menu_id,
+		Name:       menu.Name,
+		Category:   menu.Category,
+		Start_date: menu.Start_Date,
+		End_date:   menu.End_Date,
+		Created_at: menu.Created_at,
+		Updated_at: menu.Updated_at,
+		Deleted_at: menu.Deleted_at,
+		Version:    menu.Version,
+	}
+}
+
+func NewMenuResponses(menus []models.Menu) []MenuResponse {
+	responses := []MenuResponse{}
+	for _, menu := range menus {
+		responses = append(responses, NewMenuResponse(menu))
+	}
+	return responses
+}
@@ -0,0 +1,56 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/models"
+)
+
+type CreateOrderRequest struct {
+	Table_id   *string    `json:"table_id" validate:"required"`
+	Order_date *time.Time `json:"order_date"`
+}
+
+type UpdateOrderRequest struct {
+	Table_id *string `json:"table_id"`
+	Version  int     `json:"version"`
+}
+
+type OrderResponse struct {
+	Order_id   string     `json:"order_id"`
+	Table_id   *string    `json:"table_id"`
+	Order_date time.Time  `json:"order_date"`
+	Created_at time.Time  `json:"created_at"`
+	Updated_at time.Time  `json:"updated_at"`
+	Deleted_at *time.Time `json:"deleted_at"`
+	Version    int        `json:"version"`
+}
+
+// Model defaults the order date to now when the client leaves it out.
+func (r CreateOrderRequest) Model() models.Order {
+	order := models.Order{Table_id: r.Table_id, Order_Date: time.Now()}
+	if r.Order_date != nil {
+		order.Order_Date = *r.Order_date
+	}
+	return order
+}
+
+func NewOrderResponse(order models.Order) OrderResponse {
+	return OrderResponse{
+		Order_id:   order.Order_id,
+		Table_id:   order.Table_id,
+		Order_date: order.Order_Date,
+		Created_at: order.Created_at,
+		Updated_at: order.Updated_at,
+		Deleted_at: order.Deleted_at,
+		Version:    order.Version,
+	}
+}
+
+func NewOrderResponses(orders []models.Order) []OrderResponse {
+	responses := []OrderResponse{}
+	for _, order := range orders {
+		responses = append(responses, NewOrderResponse(order))
+	}
+	return responses
+}
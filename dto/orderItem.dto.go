@@ -0,0 +1,108 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/models"
+)
+
+// OrderItemPack places an order for a table together with its items.
+type OrderItemPack struct {
+	Table_id    *string            `json:"table_id" validate:"required"`
+	Order_items []OrderItemRequest `json:"order_items" validate:"required,min=1,dive"`
+}
+
+type OrderItemRequest struct {
+	Food_id  *string `json:"food_id" validate:"required"`
+	Quantity *string `json:"quantity" validate:"required,eq=S|eq=M|eq=L"`
+}
+
+// UpdateOrderItemRequest changes the food or size of an item. The price is always taken from the food.
+type UpdateOrderItemRequest struct {
+	Food_id  *string `json:"food_id"`
+	Quantity *string `json:"quantity" validate:"omitempty,eq=S|eq=M|eq=L"`
+	Version  int     `json:"version"`
+}
+
+type VoidRequest struct {
+	Reason           *string `json:"reason" validate:"required,min=3"`
+	Manager_email    *string `json:"manager_email" validate:"required,email"`
+	Manager_password *string `json:"manager_password" validate:"required"`
+	Manager_code     string  `json:"manager_code"`
+}
+
+type OrderItemResponse struct {
+	Order_item_id string     `json:"order_item_id"`
+	Order_id      string     `json:"order_id"`
+	Food_id       *string    `json:"food_id"`
+	Food_name     *string    `json:"food_name"`
+	Unit_price    *float64   `json:"unit_price"`
+	Quantity      *string    `json:"quantity"`
+	Status        string     `json:"status"`
+	Void_reason   string     `json:"void_reason,omitempty"`
+	Voided_by     string     `json:"voided_by,omitempty"`
+	Approved_by   string     `json:"approved_by,omitempty"`
+	Voided_at     *time.Time `json:"voided_at,omitempty"`
+	Created_at    time.Time  `json:"created_at"`
+	Updated_at    time.Time  `json:"updated_at"`
+	Version       int        `json:"version"`
+}
+
+// OrderViewFormat is the order placed by an OrderItemPack.
+type OrderViewFormat struct {
+	Order       OrderResponse
+	Order_items []OrderItemResponse
+}
+
+// OrderLine is an item of an order joined with its food and table.
+type OrderLine struct {
+	Food_name    string  `json:"food_name"`
+	Food_image   string  `json:"food_image"`
+	Order_id     string  `json:"order_id"`
+	Table_id     string  `json:"table_id"`
+	Table_number int     `json:"table_number"`
+	Price        float64 `json:"price"`
+	Amount       float64 `json:"amount"`
+	Quantity     string  `json:"quantity"`
+}
+
+type OrderItemsOfOrder struct {
+	Table_number int         `json:"table_number"`
+	Payment_due  float64     `json:"payment_due"`
+	Total_count  int         `json:"total_count"`
+	Order_items  []OrderLine `json:"order_items"`
+}
+
+func (r OrderItemRequest) Model() models.OrderItem {
+	return models.OrderItem{
+		Food_id:  r.Food_id,
+		Quantity: r.Quantity,
+	}
+}
+
+func NewOrderItemResponse(orderItem models.OrderItem) OrderItemResponse {
+	return OrderItemResponse{
+		Order_item_id: orderItem.Order_item_id,
+		Order_id:      orderItem.Order_id,
+		Food_id:       orderItem.Food_id,
+		Food_name:     orderItem.Food_name,
+		Unit_price:    orderItem.Unit_price,
+		Quantity:      orderItem.Quantity,
+		Status:        orderItem.Status,
+		Void_reason:   orderItem.Void_reason,
+		Voided_by:     orderItem.Voided_by,
+		Approved_by:   orderItem.Approved_by,
+		Voided_at:     orderItem.Voided_at,
+		Created_at:    orderItem.Created_at,
+		Updated_at:    orderItem.Updated_at,
+		Version:       orderItem.Version,
+	}
+}
+
+func NewOrderItemResponses(orderItems []models.OrderItem) []OrderItemResponse {
+	responses := []OrderItemResponse{}
+	for _, orderItem := range orderItems {
+		responses = append(responses, NewOrderItemResponse(orderItem))
+	}
+	return responses
+}
@@ -0,0 +1,156 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/models"
+)
+
+type CreatePromotionRequest struct {
+	Name         *string    `json:"name" validate:"required,min=2,max=100"`
+	Type         *string    `json:"type" validate:"required,eq=PERCENTAGE|eq=FIXED|eq=BUY_X_GET_Y"`
+	Value        *float64   `json:"value" validate:"omitempty,gte=0"`
+	Buy_quantity *int       `json:"buy_quantity" validate:"omitempty,gte=1"`
+	Get_quantity *int       `json:"get_quantity" validate:"omitempty,gte=1"`
+	Menu_id      *string    `json:"menu_id"`
+	Food_id      *string    `json:"food_id"`
+	Start_time   *string    `json:"start_time" validate:"omitempty,datetime=15:04"`
+	End_time     *string    `json:"end_time" validate:"omitempty,datetime=15:04"`
+	Start_date   *time.Time `json:"start_date"`
+	End_date     *time.Time `json:"end_date"`
+	Coupon_only  *bool      `json:"coupon_only"`
+	Active       *bool      `json:"active"`
+}
+
+// UpdatePromotionRequest can't change what a promotion applies to, only its terms.
+type UpdatePromotionRequest struct {
+	Name         *string    `json:"name" validate:"omitempty,min=2,max=100"`
+	Value        *float64   `json:"value" validate:"omitempty,gte=0"`
+	Buy_quantity *int       `json:"buy_quantity" validate:"omitempty,gte=1"`
+	Get_quantity *int       `json:"get_quantity" validate:"omitempty,gte=1"`
+	Start_time   *string    `json:"start_time" validate:"omitempty,datetime=15:04"`
+	End_time     *string    `json:"end_time" validate:"omitempty,datetime=15:04"`
+	Start_date   *time.Time `json:"start_date"`
+	End_date     *time.Time `json:"end_date"`
+	Coupon_only  *bool      `json:"coupon_only"`
+	Active       *bool      `json:"active"`
+	Version      int        `json:"version"`
+}
+
+type PromotionResponse struct {
+	Promotion_id string     `json:"promotion_id"`
+	Name         *string    `json:"name"`
+	Type         *string    `json:"type"`
+	Value        *float64   `json:"value"`
+	Buy_quantity *int       `json:"buy_quantity"`
+	Get_quantity *int       `json:"get_quantity"`
+	Menu_id      *string    `json:"menu_id"`
+	Food_id      *string    `json:"food_id"`
+	Start_time   *string    `json:"start_time"`
+	End_time     *string    `json:"end_time"`
+	Start_date   *time.Time `json:"start_date"`
+	End_date     *time.Time `json:"end_date"`
+	Coupon_only  *bool      `json:"coupon_only"`
+	Active       *bool      `json:"active"`
+	Created_at   time.Time  `json:"created_at"`
+	Updated_at   time.Time  `json:"updated_at"`
+	Version      int        `json:"version"`
+}
+
+type CreateCouponRequest struct {
+	Code         *string    `json:"code" validate:"required,min=3,max=32"`
+	Promotion_id *string    `json:"promotion_id" validate:"required"`
+	Usage_limit  *int       `json:"usage_limit" validate:"omitempty,gte=1"`
+	Expires_at   *time.Time `json:"expires_at"`
+}
+
+type CouponResponse struct {
+	Coupon_id    string     `json:"coupon_id"`
+	Code         *string    `json:"code"`
+	Promotion_id *string    `json:"promotion_id"`
+	Usage_limit  *int       `json:"usage_limit"`
+	Times_used   int        `json:"times_used"`
+	Expires_at   *time.Time `json:"expires_at"`
+	Created_at   time.Time  `json:"created_at"`
+	Updated_at   time.Time  `json:"updated_at"`
+	Version      int        `json:"version"`
+}
+
+func (r CreatePromotionRequest) Model() models.Promotion {
+	return models.Promotion{
+		Name:         r.Name,
+		Type:         r.Type,
+		Value:        r.Value,
+		Buy_quantity: r.Buy_quantity,
+		Get_quantity: r.Get_quantity,
+		Menu_id:      r.Menu_id,
+		Food_id:      r.Food_id,
+		Start_time:   r.Start_time,
+		End_time:     r.End_time,
+		Start_Date:   r.Start_date,
+		End_Date:     r.End_date,
+		Coupon_only:  r.Coupon_only,
+		Active:       r.Active,
+	}
+}
+
+func NewPromotionResponse(promotion models.Promotion) PromotionResponse {
+	return PromotionResponse{
+		Promotion_id: promotion.Promotion_id,
+		Name:         promotion.Name,
+		Type:         promotion.Type,
+		Value:        promotion.Value,
+		Buy_quantity: promotion.Buy_quantity,
+		Get_quantity: promotion.Get_quantity,
+		Menu_id:      promotion.Menu_id,
+		Food_id:      promotion.Food_id,
+		Start_time:   promotion.Start_time,
+		End_time:     promotion.End_time,
+		Start_date:   promotion.Start_Date,
+		End_date:     promotion.End_Date,
+		Coupon_only:  promotion.Coupon_only,
+		Active:       promotion.Active,
+		Created_at:   promotion.Created_at,
+		Updated_at:   promotion.Updated_at,
+		Version:      promotion.Version,
+	}
+}
+
+func NewPromotionResponses(promotions []models.Promotion) []PromotionResponse {
+	responses := []PromotionResponse{}
+	for _, promotion := range promotions {
+		responses = append(responses, NewPromotionResponse(promotion))
+	}
+	return responses
+}
+
+func (r CreateCouponRequest) Model() models.Coupon {
+	return models.Coupon{
+		Code:         r.Code,
+		Promotion_id: r.Promotion_id,
+		Usage_limit:  r.Usage_limit,
+		Expires_at:   r.Expires_at,
+	}
+}
+
+func NewCouponResponse(coupon models.Coupon) CouponResponse {
+	return CouponResponse{
+		Coupon_id:    coupon.Coupon_id,
+		Code:         coupon.Code,
+		Promotion_id: coupon.Promotion_id,
+		Usage_limit:  coupon.Usage_limit,
+		Times_used:   coupon.Times_used,
+		Expires_at:   coupon.Expires_at,
+		Created_at:   coupon.Created_at,
+		Updated_at:   coupon.Updated_at,
+		Version:      coupon.Version,
+	}
+}
+
+func NewCouponResponses(coupons []models.Coupon) []CouponResponse {
+	responses := []CouponResponse{}
+	for _, coupon := range coupons {
+		responses = append(responses, NewCouponResponse(coupon))
+	}
+	return responses
+}
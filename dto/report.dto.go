@@ -0,0 +1,34 @@
+package dto
+
+// PromotionUsage is how often a promotion applied in a period and what it cost.
+type PromotionUsage struct {
+	Promotion_id   string  `json:"promotion_id"`
+	Name           string  `json:"name"`
+	Type           string  `json:"type"`
+	Times_applied  int     `json:"times_applied"`
+	Total_discount float64 `json:"total_discount"`
+}
+
+// SalesReport sums up the paid invoices of a period, in total and per payment method.
+type SalesReport struct {
+	Totals            SalesTotals          `json:"totals"`
+	By_payment_method []PaymentMethodSales `json:"by_payment_method"`
+}
+
+type SalesTotals struct {
+	Invoices     int     `json:"invoices"`
+	Gross_sales  float64 `json:"gross_sales"`
+	Discounts    float64 `json:"discounts"`
+	Refunds      float64 `json:"refunds"`
+	Net_sales    float64 `json:"net_sales"`
+	Voided_items int     `json:"voided_items"`
+}
+
+type PaymentMethodSales struct {
+	Payment_method string  `json:"payment_method"`
+	Invoices       int     `json:"invoices"`
+	Gross_sales    float64 `json:"gross_sales"`
+	Discounts      float64 `json:"discounts"`
+	Refunds        float64 `json:"refunds"`
+	Net_sales      float64 `json:"net_sales"`
+}
@@ -0,0 +1,55 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/models"
+)
+
+type CreateTableRequest struct {
+	Number_of_guests *int `json:"number_of_guests" validate:"required"`
+	Table_number     *int `json:"table_number" validate:"required"`
+}
+
+type UpdateTableRequest struct {
+	Number_of_guests *int `json:"number_of_guests"`
+	Table_number     *int `json:"table_number"`
+	Version          int  `json:"version"`
+}
+
+type TableResponse struct {
+	Table_id         string     `json:"table_id"`
+	Number_of_guests *int       `json:"number_of_guests"`
+	Table_number     *int       `json:"table_number"`
+	Created_at       time.Time  `json:"created_at"`
+	Updated_at       time.Time  `json:"updated_at"`
+	Deleted_at       *time.Time `json:"deleted_at"`
+	Version          int        `json:"version"`
+}
+
+func (r CreateTableRequest) Model() models.Table {
+	return models.Table{
+		Number_of_guests: r.Number_of_guests,
+		Table_number:     r.Table_number,
+	}
+}
+
+func NewTableResponse(table models.Table) TableResponse {
+	return TableResponse{
+		Table_id:         table.Table_id,
+		Number_of_guests: table.Number_of_guests,
+		Table_number:     table.Table_number,
+		Created_at:       table.Created_at,
+		Updated_at:       table.Updated_at,
+		Deleted_at:       table.Deleted_at,
+		Version:          table.Version,
+	}
+}
+
+func NewTableResponses(tables []models.Table) []TableResponse {
+	responses := []TableResponse{}
+	for _, table := range tables {
+		responses = append(responses, NewTableResponse(table))
+	}
+	return responses
+}
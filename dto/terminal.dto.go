@@ -0,0 +1,95 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/models"
+)
+
+type RegisterTerminalRequest struct {
+	Name string `json:"name" validate:"required,min=2,max=100"`
+}
+
+// TerminalLoginRequest signs a user in on a terminal. The terminal proves it is registered with
+// its key, the user with their PIN.
+type TerminalLoginRequest struct {
+	Terminal_id  string `json:"terminal_id" validate:"required"`
+	Terminal_key string `json:"terminal_key" validate:"required"`
+	User_id      string `json:"user_id" validate:"required"`
+	Pin          string `json:"pin" validate:"required,numeric,min=4,max=6"`
+}
+
+// TerminalResponse leaves out the key hash and the session id.
+type TerminalResponse struct {
+	Terminal_id        string     `json:"terminal_id"`
+	Name               string     `json:"name"`
+	Registered_by      string     `json:"registered_by"`
+	User_id            string     `json:"user_id"`
+	Last_seen_at       *time.Time `json:"last_seen_at"`
+	Session_expires_at *time.Time `json:"session_expires_at"`
+	Created_at         time.Time  `json:"created_at"`
+	Updated_at         time.Time  `json:"updated_at"`
+	Revoked_at         *time.Time `json:"revoked_at"`
+	Version            int        `json:"version"`
+}
+
+// TerminalRegistrationResponse is the only response that carries the terminal's key. It is not
+// stored, so it can't be shown again.
+type TerminalRegistrationResponse struct {
+	TerminalResponse
+	Terminal_key string `json:"terminal_key"`
+}
+
+// TerminalLoginResponse carries the token of the user's session on the terminal. It has no
+// refresh token; when it expires, the user enters their PIN again.
+type TerminalLoginResponse struct {
+	UserResponse
+	Terminal_id string    `json:"terminal_id"`
+	Token       string    `json:"token"`
+	Expires_at  time.Time `json:"expires_at"`
+}
+
+func (r RegisterTerminalRequest) Model() models.Terminal {
+	return models.Terminal{
+		Name: r.Name,
+	}
+}
+
+func NewTerminalResponse(terminal models.Terminal) TerminalResponse {
+	return TerminalResponse{
+		Terminal_id:        terminal.Terminal_id,
+		Name:               terminal.Name,
+		Registered_by:      terminal.Registered_by,
+		User_id:            terminal.User_id,
+		Last_seen_at:       terminal.Last_seen_at,
+		Session_expires_at: terminal.Session_expires_at,
+		Created_at:         terminal.Created_at,
+		Updated_at:         terminal.Updated_at,
+		Revoked_at:         terminal.Revoked_at,
+		Version:            terminal.Version,
+	}
+}
+
+func NewTerminalResponses(terminals []models.Terminal) []TerminalResponse {
+	responses := []TerminalResponse{}
+	for _, terminal := range terminals {
+		responses = append(responses, NewTerminalResponse(terminal))
+	}
+	return responses
+}
+
+func NewTerminalRegistrationResponse(terminal models.Terminal, key string) TerminalRegistrationResponse {
+	return TerminalRegistrationResponse{
+		TerminalResponse: NewTerminalResponse(terminal),
+		Terminal_key:     key,
+	}
+}
+
+func NewTerminalLoginResponse(user models.User, terminalId string, token string, expiresAt time.Time) TerminalLoginResponse {
+	return TerminalLoginResponse{
+		UserResponse: NewUserResponse(user),
+		Terminal_id:  terminalId,
+		Token:        token,
+		Expires_at:   expiresAt,
+	}
+}
@@ -0,0 +1,183 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/models"
+)
+
+type SignUpRequest struct {
+	First_name string `json:"first_name" validate:"required,min=2,max=100"`
+	Last_name  string `json:"last_name" validate:"required,min=2,max=100"`
+	Password   string `json:"password" validate:"required,min=6"`
+	Email      string `json:"email" validate:"email,required"`
+	Avatar     string `json:"avatar"`
+	Phone      string `json:"phone" validate:"required"`
+	Role       string `json:"role" validate:"omitempty,eq=ADMIN|eq=MANAGER|eq=STAFF"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=6"`
+}
+
+// SetPinRequest sets the PIN the user signs in with on terminals. The password confirms it is them.
+type SetPinRequest struct {
+	Password string `json:"password" validate:"required"`
+	Pin      string `json:"pin" validate:"required,numeric,min=4,max=6"`
+}
+
+// MfaLoginRequest finishes a login that answered with an MFA challenge. Code is from the
+// authenticator app, or one of the user's recovery codes.
+type MfaLoginRequest struct {
+	Mfa_token string `json:"mfa_token" validate:"required"`
+	Code      string `json:"code" validate:"required"`
+}
+
+// MfaLoginEnrollRequest sets up MFA during a login that needs it, with the login's challenge and
+// the token mailed with it.
+type MfaLoginEnrollRequest struct {
+	Mfa_token        string `json:"mfa_token" validate:"required"`
+	Enrollment_token string `json:"enrollment_token" validate:"required"`
+}
+
+// MfaEnrollRequest starts setting up MFA. The password confirms it is them.
+type MfaEnrollRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// MfaConfirmRequest enables MFA with the first code from the new secret.
+type MfaConfirmRequest struct {
+	Code string `json:"code" validate:"required,numeric,len=6"`
+}
+
+// MfaDisableRequest turns MFA off, confirmed with the password and a code or recovery code.
+type MfaDisableRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// StatusResponse acknowledges a request that has no resource to return.
+type StatusResponse struct {
+	Status string `json:"status"`
+}
+
+// UserResponse leaves out the password and PIN hashes and the tokens.
+type UserResponse struct {
+	User_id           string     `json:"user_id"`
+	First_name        string     `json:"first_name"`
+	Last_name         string     `json:"last_name"`
+	Email             string     `json:"email"`
+	Avatar            string     `json:"avatar"`
+	Phone             string     `json:"phone"`
+	Role              string     `json:"role"`
+	Email_verified_at *time.Time `json:"email_verified_at"`
+	Has_pin           bool       `json:"has_pin"`
+	Mfa_enabled_at    *time.Time `json:"mfa_enabled_at"`
+	Created_at        time.Time  `json:"created_at"`
+	Updated_at        time.Time  `json:"updated_at"`
+	Deleted_at        *time.Time `json:"deleted_at"`
+	Version           int        `json:"version"`
+}
+
+type UserPage struct {
+	Total_count int            `json:"total_count"`
+	User_items  []UserResponse `json:"user_items"`
+}
+
+// LoginResponse is the only response that carries tokens, the ones just issued to the user. A
+// user who needs a second factor gets no tokens but Mfa, to answer on /users/login/mfa.
+type LoginResponse struct {
+	UserResponse
+	Token         string                `json:"token"`
+	Refresh_token string                `json:"refresh_token"`
+	Mfa           *MfaChallengeResponse `json:"mfa,omitempty"`
+	// Recovery_codes are returned once, by the login that enabled MFA.
+	Recovery_codes []string `json:"recovery_codes,omitempty"`
+}
+
+// MfaChallengeResponse is what a login that passed the password needs to finish.
+type MfaChallengeResponse struct {
+	Mfa_token      string    `json:"mfa_token"`
+	Mfa_expires_at time.Time `json:"mfa_expires_at"`
+	// Enrollment_required is set when the user has to set up MFA first. They were mailed a token
+	// that gets them the secret on /users/login/mfa/enroll; the code has to come from it.
+	Enrollment_required bool `json:"enrollment_required,omitempty"`
+}
+
+// MfaEnrollmentResponse is a new TOTP secret. Otpauth_uri is meant to be shown as a QR code.
+type MfaEnrollmentResponse struct {
+	Secret      string `json:"secret"`
+	Otpauth_uri string `json:"otpauth_uri"`
+}
+
+// RecoveryCodesResponse holds the recovery codes of a user who just enabled MFA. They are not
+// stored and can't be shown again.
+type RecoveryCodesResponse struct {
+	Recovery_codes []string `json:"recovery_codes"`
+}
+
+// Model leaves the password in plain text; it is hashed before the user is stored.
+func (r SignUpRequest) Model() models.User {
+	return models.User{
+		First_name: r.First_name,
+		Last_name:  r.Last_name,
+		Password:   r.Password,
+		Email:      r.Email,
+		Avatar:     r.Avatar,
+		Phone:      r.Phone,
+		Role:       r.Role,
+	}
+}
+
+func NewUserResponse(user models.User) UserResponse {
+	return UserResponse{
+		User_id:           user.User_id,
+		First_name:        user.First_name,
+		Last_name:         user.Last_name,
+		Email:             user.Email,
+		Avatar:            user.Avatar,
+		Phone:             user.Phone,
+		Role:              user.Role,
+		Email_verified_at: user.Email_verified_at,
+		Has_pin:           user.Pin != "",
+		Mfa_enabled_at:    user.Mfa_enabled_at,
+		Created_at:        user.Created_at,
+		Updated_at:        user.Updated_at,
+		Deleted_at:        user.Deleted_at,
+		Version:           user.Version,
+	}
+}
+
+func NewUserResponses(users []models.User) []UserResponse {
+	responses := []UserResponse{}
+	for _, user := range users {
+		responses = append(responses, NewUserResponse(user))
+	}
+	return responses
+}
+
+func NewLoginResponse(user models.User, token string, refreshToken string) LoginResponse {
+	return LoginResponse{
+		UserResponse:  NewUserResponse(user),
+		Token:         token,
+		Refresh_token: refreshToken,
+	}
+}
+
+func NewMfaEnrollmentResponse(secret string, uri string) MfaEnrollmentResponse {
+	return MfaEnrollmentResponse{Secret: secret, Otpauth_uri: uri}
+}
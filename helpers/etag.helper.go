@@ -0,0 +1,32 @@
+package helper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ETag formats a document version as a strong entity tag.
+func ETag(version int) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// IfMatchVersion reads the version the client expects from an If-Match header. It reports false
+// when the header is empty or "*", in which case the write is not conditional.
+func IfMatchVersion(header string) (int, bool, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, false, nil
+	}
+
+	tag := strings.TrimPrefix(header, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false, fmt.Errorf("If-Match must be a single entity tag such as \"3\"")
+	}
+
+	version, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil || version < 0 {
+		return 0, false, fmt.Errorf("If-Match does not hold a version of this resource")
+	}
+	return version, true, nil
+}
@@ -2,65 +2,104 @@ package helper
 
 import (
 	"context"
-	"fmt"
-	"log"
-	"os"
+	"errors"
 	"time"
 
 	"github.com/mayankr5/v1/restaurant-management/database"
+	"github.com/mayankr5/v1/restaurant-management/signing"
 
-	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Token uses, so a refresh token can't be sent in place of the token it refreshes.
+const (
+	accessUse  = "access"
+	refreshUse = "refresh"
+)
+
+// SignedDetails are the claims of our tokens. The registered claims carry the user id as the
+// subject, a unique id and when the token was issued and expires.
 type SignedDetails struct {
 	Email      string
 	First_name string
 	Last_name  string
-	Uid        string
-	jwt.StandardClaims
+	Token_use  string `json:"token_use"`
+	// Terminal_id and Session_id are set on tokens issued by a PIN login, which are only good
+	// while that session is the terminal's current one.
+	Terminal_id string `json:",omitempty"`
+	Session_id  string `json:",omitempty"`
+	// Generation is the user's token generation when the token was issued; resetting the
+	// password starts a new one, and the older tokens stop working.
+	Generation int `json:",omitempty"`
+	jwt.RegisteredClaims
 }
 
-var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
-
-var SECRET_KEY string = os.Getenv("SECRET_KEY")
-
-func GenerateAllTokens(email string, firstName string, lastName string, uid string) (signedToken string, signedRefreshToken string, err error) {
+// GenerateAllTokens signs a token for the user that lasts a day and a refresh token that lasts a
+// week, with keys. generation is the user's current token generation.
+func GenerateAllTokens(keys *signing.KeySet, email string, firstName string, lastName string, uid string, generation int) (signedToken string, signedRefreshToken string, err error) {
 	claims := &SignedDetails{
-		Email:      email,
-		First_name: firstName,
-		Last_name:  lastName,
-		Uid:        uid,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(24)).Unix(),
-		},
+		Email:            email,
+		First_name:       firstName,
+		Last_name:        lastName,
+		Token_use:        accessUse,
+		Generation:       generation,
+		RegisteredClaims: registeredClaims(keys, uid, 24*time.Hour),
 	}
 
 	refreshClaims := &SignedDetails{
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(168)).Unix(),
-		},
+		Token_use:        refreshUse,
+		Generation:       generation,
+		RegisteredClaims: registeredClaims(keys, uid, 168*time.Hour),
 	}
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
-	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(SECRET_KEY))
+	token, err := keys.Sign(claims)
+	if err != nil {
+		return "", "", err
+	}
 
+	refreshToken, err := keys.Sign(refreshClaims)
 	if err != nil {
-		log.Panic(err)
-		return
+		return "", "", err
 	}
 
-	return token, refreshToken, err
+	return token, refreshToken, nil
 
 }
 
-func UpdateAllTokens(signedToken string, signedRefreshToken string, userId string) {
+// GenerateTerminalToken signs a token for the user's session on a terminal that expires after ttl.
+func GenerateTerminalToken(keys *signing.KeySet, email string, firstName string, lastName string, uid string, generation int, terminalId string, sessionId string, ttl time.Duration) (signedToken string, expiresAt time.Time, err error) {
+	claims := &SignedDetails{
+		Email:            email,
+		First_name:       firstName,
+		Last_name:        lastName,
+		Token_use:        accessUse,
+		Terminal_id:      terminalId,
+		Session_id:       sessionId,
+		Generation:       generation,
+		RegisteredClaims: registeredClaims(keys, uid, ttl),
+	}
 
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	signedToken, err = keys.Sign(claims)
+	return signedToken, claims.ExpiresAt.Time, err
+}
 
+func registeredClaims(keys *signing.KeySet, uid string, ttl time.Duration) jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		Issuer:    keys.Issuer,
+		Subject:   uid,
+		Audience:  jwt.ClaimStrings{keys.Audience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ID:        primitive.NewObjectID().Hex(),
+	}
+}
+
+// UpdateAllTokens stores the tokens last issued to the user in users.
+func UpdateAllTokens(ctx context.Context, users database.Collection, signedToken string, signedRefreshToken string, userId string) error {
 	var updateObj primitive.D
 
 	updateObj = append(updateObj, bson.E{"token", signedToken})
@@ -75,7 +114,7 @@ func UpdateAllTokens(signedToken string, signedRefreshToken string, userId strin
 		Upsert: &upsert,
 	}
 
-	_, err := userCollection.UpdateOne(
+	_, err := users.UpdateOne(
 		ctx,
 		filter,
 		bson.D{
@@ -83,42 +122,22 @@ func UpdateAllTokens(signedToken string, signedRefreshToken string, userId strin
 		},
 		&opt,
 	)
-	defer cancel()
-
-	if err != nil {
-		log.Panic(err)
-		return
-	}
-	return
 
+	return err
 }
 
-func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
-
-	token, err := jwt.ParseWithClaims(
-		signedToken,
-		&SignedDetails{},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(SECRET_KEY), nil
-		},
-	)
-
-	//the token is invalid
-
-	claims, ok := token.Claims.(*SignedDetails)
-	if !ok {
-		msg = fmt.Sprintf("the token is invalid")
-		msg = err.Error()
-		return
+// ValidateToken verifies an access token with keys and returns its claims. The error says what is
+// wrong with the token and can be shown to the client.
+func ValidateToken(keys *signing.KeySet, signedToken string) (*SignedDetails, error) {
+	claims := &SignedDetails{}
+	if err := keys.Parse(signedToken, claims); err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, errors.New("token is expired")
+		}
+		return nil, errors.New("the token is invalid")
 	}
-
-	//the token is expired
-	if claims.ExpiresAt < time.Now().Local().Unix() {
-		msg = fmt.Sprint("token is expired")
-		msg = err.Error()
-		return
+	if claims.Token_use != accessUse || claims.Subject == "" {
+		return nil, errors.New("the token is invalid")
 	}
-
-	return claims, msg
-
+	return claims, nil
 }
@@ -0,0 +1,88 @@
+// Package logger is the structured logger of the service. Everything is logged as JSON lines on
+// stdout at LOG_LEVEL (debug, info, warn or error; info by default). Requests get a logger that
+// carries their request id, which handlers reach through From and helpers through FromContext.
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestIDKey is the fiber.Ctx local holding the id of the current request.
+const RequestIDKey = "request_id"
+
+const localsKey = "logger"
+
+type contextKey struct{}
+
+var current atomic.Pointer[slog.Logger]
+
+func init() {
+	current.Store(New(os.Stdout, Level(os.Getenv("LOG_LEVEL"))))
+}
+
+// New returns a JSON logger writing to w.
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+// Level parses a level name, falling back to info.
+func Level(name string) slog.Level {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// Get returns the service wide logger.
+func Get() *slog.Logger {
+	return current.Load()
+}
+
+// Set replaces the service wide logger, e.g. to capture the log in tests.
+func Set(l *slog.Logger) {
+	current.Store(l)
+}
+
+// Attach makes l the logger of the request, for handlers and for anything given the request's context.
+func Attach(c *fiber.Ctx, l *slog.Logger) {
+	c.Locals(localsKey, l)
+	c.SetUserContext(WithContext(c.UserContext(), l))
+}
+
+// From returns the logger of the request, or the service wide one outside of a request.
+func From(c *fiber.Ctx) *slog.Logger {
+	if l, ok := c.Locals(localsKey).(*slog.Logger); ok {
+		return l
+	}
+	return Get()
+}
+
+func WithContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger stored in ctx by WithContext, or the service wide one.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return Get()
+}
+
+// RequestID returns the id of the request, empty if none was assigned.
+func RequestID(c *fiber.Ctx) string {
+	id, _ := c.Locals(RequestIDKey).(string)
+	return id
+}
@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// FromEnv builds the mailer chosen by MAILER:
+//
+//   - smtp sends through SMTP_HOST and SMTP_PORT (587 by default), signing in with SMTP_USERNAME
+//     and SMTP_PASSWORD when set.
+//   - file writes the messages to MAIL_OUTBOX (outbox by default).
+//   - memory keeps them in memory, where nobody reads them.
+//
+// Messages are sent from MAIL_FROM. Without MAILER it returns nil.
+func FromEnv() (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	switch kind := os.Getenv("MAILER"); kind {
+	case "":
+		return nil, nil
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, fmt.Errorf("MAILER=smtp needs SMTP_HOST")
+		}
+		port := 587
+		if value := os.Getenv("SMTP_PORT"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("invalid SMTP_PORT %q", value)
+			}
+			port = parsed
+		}
+		return SMTP{Host: host, Port: port, Username: os.Getenv("SMTP_USERNAME"), Password: os.Getenv("SMTP_PASSWORD"), From: from}, nil
+	case "file":
+		dir := os.Getenv("MAIL_OUTBOX")
+		if dir == "" {
+			dir = "outbox"
+		}
+		return FileOutbox{Dir: dir, From: from}, nil
+	case "memory":
+		return NewOutbox(), nil
+	default:
+		return nil, fmt.Errorf("unknown MAILER %q, want smtp, file or memory", kind)
+	}
+}
@@ -0,0 +1,55 @@
+// Package mailer sends the emails of the service, such as email verification and password reset
+// links. Mailers are interchangeable: SMTP delivers for real, Outbox and FileOutbox keep the
+// messages for tests and local development.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/logger"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends messages. Send returns once the message was handed over; it does not wait for delivery.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Unconfigured drops messages with a warning, so a missing mailer never fails a request.
+type Unconfigured struct{}
+
+func (Unconfigured) Send(ctx context.Context, msg Message) error {
+	logger.FromContext(ctx).Warn("no mailer is configured, email dropped", "to", msg.To, "subject", msg.Subject)
+	return nil
+}
+
+// format renders msg as an RFC 5322 message from sender.
+func format(from string, msg Message, at time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", at.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// validAddress rejects addresses that would inject headers or SMTP commands.
+func validAddress(address string) error {
+	if address == "" || strings.ContainsAny(address, "\r\n<>") {
+		return fmt.Errorf("invalid email address %q", address)
+	}
+	return nil
+}
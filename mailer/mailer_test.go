@@ -0,0 +1,118 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSMTP accepts one message on a local port and hands the DATA section to received.
+func fakeSMTP(t *testing.T) (port int, received chan string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received = make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ready")
+
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					received <- data.String()
+					reply("250 queued")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+
+			switch command := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(command, "EHLO"):
+				reply("250 localhost")
+			case command == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case command == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, received
+}
+
+func TestSMTPDeliversTheMessage(t *testing.T) {
+	port, received := fakeSMTP(t)
+
+	m := SMTP{Host: "127.0.0.1", Port: port, From: "kitchen@example.com"}
+	err := m.Send(context.Background(), Message{To: "ada@example.com", Subject: "Reset your password", Body: "line one\nline two"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := <-received
+	for _, want := range []string{"From: kitchen@example.com\r\n", "To: ada@example.com\r\n", "Subject: Reset your password\r\n", "\r\n\r\nline one\r\nline two"} {
+		if !strings.Contains(data, want) {
+			t.Errorf("message %q does not contain %q", data, want)
+		}
+	}
+}
+
+func TestAddressesCannotInjectHeaders(t *testing.T) {
+	outbox := NewOutbox()
+
+	err := outbox.Send(context.Background(), Message{To: "ada@example.com\r\nBcc: eve@example.com", Subject: "hi"})
+	if err == nil {
+		t.Fatal("an address with a line break was accepted")
+	}
+	if len(outbox.Messages()) != 0 {
+		t.Error("the message was kept")
+	}
+}
+
+func TestFileOutboxWritesOnlyOwnerReadableFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+
+	m := FileOutbox{Dir: dir, From: "kitchen@example.com"}
+	if err := m.Send(context.Background(), Message{To: "ada@example.com", Subject: "Verify your email", Body: "token"}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("files = %v, err %v, want one .eml", files, err)
+	}
+	info, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+}
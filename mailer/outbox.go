@@ -0,0 +1,76 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Outbox keeps the messages it is given in memory. Tests read them back with Messages.
+type Outbox struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewOutbox() *Outbox {
+	return &Outbox{}
+}
+
+func (o *Outbox) Send(ctx context.Context, msg Message) error {
+	if err := validAddress(msg.To); err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.messages = append(o.messages, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first.
+func (o *Outbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Message(nil), o.messages...)
+}
+
+// To returns the messages sent to address, oldest first.
+func (o *Outbox) To(address string) []Message {
+	var sent []Message
+	for _, msg := range o.Messages() {
+		if msg.To == address {
+			sent = append(sent, msg)
+		}
+	}
+	return sent
+}
+
+// FileOutbox writes every message to its own .eml file in Dir, to be opened with any mail client.
+type FileOutbox struct {
+	Dir  string
+	From string
+}
+
+func (o FileOutbox) Send(ctx context.Context, msg Message) error {
+	if err := validAddress(msg.To); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(o.Dir, 0o700); err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+	// the messages carry tokens, so only the owner may read them
+	return os.WriteFile(filepath.Join(o.Dir, name), format(o.From, msg, now), 0o600)
+}
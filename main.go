@@ -1,41 +1,107 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/mayankr5/v1/restaurant-management/app"
 	"github.com/mayankr5/v1/restaurant-management/database"
-	"github.com/mayankr5/v1/restaurant-management/middleware"
-	"github.com/mayankr5/v1/restaurant-management/routes"
-
-	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/mongo"
+	"github.com/mayankr5/v1/restaurant-management/logger"
+	"github.com/mayankr5/v1/restaurant-management/mailer"
+	"github.com/mayankr5/v1/restaurant-management/signing"
 )
 
-var foodCollection *mongo.Collection = database.OpenCollection(database.Client, "food")
-
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			os.Exit(migrate(os.Args[2:]))
+		case "seed":
+			os.Exit(seedCommand(os.Args[2:]))
+		}
+	}
+
 	port := os.Getenv("PORT")
 
 	if port == "" {
 		port = "8000"
 	}
 
-	app := fiber.New()
+	keys, err := signing.FromEnv()
+	if err != nil {
+		logger.Get().Error("invalid signing keys", "error", err)
+		os.Exit(1)
+	}
+	switch {
+	case keys == nil:
+		logger.Get().Warn("JWT_KEYS_DIR is not set, tokens are signed with a key generated at startup and stop working on restart")
+	case keys.SigningAlgorithm() == signing.HS256:
+		logger.Get().Warn("tokens are signed with SECRET_KEY, which other services can't verify without it; move to JWT_KEYS_DIR")
+	}
 
-	app.Use(func(c *fiber.Ctx) error {
-		println("Middleware before routes")
-		return c.Next()
-	})
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	app.Use(middleware.Authentication())
+	var store database.Store
+	if os.Getenv("STORE") == "memory" {
+		logger.Get().Warn("serving from the in-process store, nothing is persisted")
+		store = database.NewMemoryStore()
+		if file := os.Getenv("SEED_FILE"); file != "" {
+			if err := seedFrom(ctx, store, file); err != nil {
+				logger.Get().Error("seeding failed", "file", file, "error", err)
+				os.Exit(1)
+			}
+		}
+	} else {
+		client, err := database.Connect(database.URI())
+		if err != nil {
+			logger.Get().Error("invalid mongodb uri", "error", err)
+			os.Exit(1)
+		}
+		store = database.NewMongoStore(client, database.Name)
 
-	routes.UserRoutes(app)
-	routes.FoodRoutes(app)
-	routes.MenuRoutes(app)
-	routes.TableRoutes(app)
-	routes.OrderRoutes(app)
-	routes.OrderItemRoutes(app)
-	routes.InvoiceRoutes(app)
+		go func() {
+			if err := database.WaitUntilReachable(ctx, store); err == nil {
+				logger.Get().Info("connected to mongodb")
+			}
+		}()
+	}
 
-	app.Listen(":" + port)
+	mail, err := mailer.FromEnv()
+	if err != nil {
+		logger.Get().Error("invalid mailer configuration", "error", err)
+		os.Exit(1)
+	}
+	if mail == nil {
+		logger.Get().Warn("MAILER is not set, verification and password reset emails are dropped")
+	}
+
+	server := app.New(app.ConfigFromEnv(), app.Deps{Store: store, Mailer: mail, Keys: keys})
+
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- server.Listen(":" + port)
+	}()
+
+	select {
+	case err := <-listenErr:
+		logger.Get().Error("server failed", "error", err)
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	logger.Get().Info("shutting down, draining in-flight requests")
+	if err := server.ShutdownWithTimeout(30 * time.Second); err != nil {
+		logger.Get().Error("shutdown did not finish cleanly", "error", err)
+	}
+
+	closeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := store.Close(closeCtx); err != nil {
+		logger.Get().Error("disconnecting from mongodb failed", "error", err)
+	}
+	logger.Get().Info("stopped")
 }
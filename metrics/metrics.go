@@ -0,0 +1,153 @@
+// Package metrics collects the service's Prometheus metrics: HTTP requests per route, Mongo
+// operations per collection and business counters. They are served in the Prometheus text format
+// by Handler.
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Registry holds every metric of the service, along with the Go runtime and process metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	mongoDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mongo_operation_duration_seconds",
+		Help:    "Mongo operation latency by collection and operation.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"collection", "operation"})
+
+	mongoErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mongo_operation_errors_total",
+		Help: "Failed Mongo operations by collection and operation. A FindOne matching nothing is not a failure.",
+	}, []string{"collection", "operation"})
+
+	ordersCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "restaurant_orders_created_total",
+		Help: "Orders placed.",
+	})
+
+	invoicesPaid = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "restaurant_invoices_paid_total",
+		Help: "Invoices marked as paid by payment method.",
+	}, []string{"payment_method"})
+
+	revenue = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "restaurant_revenue_total",
+		Help: "Total of the paid invoices by payment method, before refunds.",
+	}, []string{"payment_method"})
+
+	refunds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "restaurant_refunds_total",
+		Help: "Amount refunded by payment method.",
+	}, []string{"payment_method"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, mongoDuration, mongoErrors,
+		ordersCreated, invoicesPaid, revenue, refunds,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format, along with gauges of the app it
+// serves, see Gauge.
+func Handler(gauges ...prometheus.Collector) fiber.Handler {
+	own := prometheus.NewRegistry()
+	own.MustRegister(gauges...)
+	return adaptor.HTTPHandler(promhttp.HandlerFor(prometheus.Gatherers{Registry, own}, promhttp.HandlerOpts{}))
+}
+
+// Middleware counts and times every request by the route it matched, so that ids in paths don't
+// create a series each. Requests that match no route are counted as "unmatched".
+func Middleware() fiber.Handler {
+	var once sync.Once
+	var routes map[string]bool
+
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		if err := c.Next(); err != nil {
+			if err := c.App().Config().ErrorHandler(c, err); err != nil {
+				c.Status(http.StatusInternalServerError)
+			}
+		}
+
+		once.Do(func() {
+			routes = map[string]bool{}
+			for _, route := range c.App().GetRoutes(true) {
+				routes[route.Method+" "+route.Path] = true
+			}
+		})
+
+		// fiber reuses the method's bytes for the next request, but the label outlives this one
+		method := utils.CopyString(c.Method())
+		route := c.Route().Path
+		if !routes[method+" "+route] {
+			route = "unmatched"
+		}
+
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(c.Response().StatusCode())).Inc()
+		httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		return nil
+	}
+}
+
+// ObserveMongo records one Mongo operation that started at start and ended with err.
+func ObserveMongo(collection string, operation string, start time.Time, err error) {
+	mongoDuration.WithLabelValues(collection, operation).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		mongoErrors.WithLabelValues(collection, operation).Inc()
+	}
+}
+
+func OrderCreated() {
+	ordersCreated.Inc()
+}
+
+// InvoicePaid counts an invoice that was just marked as paid.
+func InvoicePaid(paymentMethod string, total float64) {
+	invoicesPaid.WithLabelValues(paymentLabel(paymentMethod)).Inc()
+	revenue.WithLabelValues(paymentLabel(paymentMethod)).Add(total)
+}
+
+func Refunded(paymentMethod string, amount float64) {
+	refunds.WithLabelValues(paymentLabel(paymentMethod)).Add(amount)
+}
+
+// Gauge is a gauge whose value is read from fn at every scrape.
+func Gauge(name string, help string, fn func() float64) prometheus.Collector {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help}, fn)
+}
+
+func paymentLabel(paymentMethod string) string {
+	if paymentMethod == "" {
+		return "UNKNOWN"
+	}
+	return paymentMethod
+}
@@ -0,0 +1,59 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/database"
+	"github.com/mayankr5/v1/restaurant-management/metrics"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMetricsByRoute(t *testing.T) {
+	tables := database.OpenCollection(database.NewMemoryStore(), "table")
+
+	app := fiber.New(fiber.Config{ErrorHandler: apierrors.ErrorHandler})
+	app.Use(metrics.Middleware())
+	app.Get("/metrics", metrics.Handler())
+	app.Get("/tables/:table_id", func(c *fiber.Ctx) error {
+		var table bson.M
+		err := tables.FindOne(c.Context(), bson.M{"table_id": c.Params("table_id")}).Decode(&table)
+		if err != nil {
+			return apierrors.NotFound("table was not found")
+		}
+		return c.JSON(table)
+	})
+
+	for _, path := range []string{"/tables/t1", "/tables/t2", "/nowhere/t1"} {
+		if _, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	scraped := string(body)
+
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/tables/:table_id",status="404"} 2`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/tables/:table_id"} 2`,
+		`mongo_operation_duration_seconds_count{collection="table",operation="FindOne"} 2`,
+	} {
+		if !strings.Contains(scraped, want) {
+			t.Errorf("scrape is missing %s", want)
+		}
+	}
+	if strings.Contains(scraped, `route="/tables/t1"`) || strings.Contains(scraped, `mongo_operation_errors_total{collection="table"`) {
+		t.Errorf("unexpected series in scrape:\n%s", scraped)
+	}
+}
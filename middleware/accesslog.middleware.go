@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AccessLog writes one line per request with its method, path, route, status, latency and the
+// signed in user. Errors are rendered here, rather than left to the app's ErrorHandler, so that
+// the logged status is the one the client receives.
+func AccessLog() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		if err := c.Next(); err != nil {
+			if err := c.App().Config().ErrorHandler(c, err); err != nil {
+				c.Status(http.StatusInternalServerError)
+			}
+		}
+
+		status := c.Response().StatusCode()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		uid, _ := c.Locals("uid").(string)
+		logger.From(c).LogAttrs(c.UserContext(), level, "request",
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+			slog.String("route", c.Route().Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("user_id", uid),
+			slog.String("ip", c.IP()),
+		)
+		return nil
+	}
+}
@@ -1,29 +1,69 @@
 package middleware
 
 import (
-	"fmt"
-	"net/http"
+	"errors"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/docs"
 	helper "github.com/mayankr5/v1/restaurant-management/helpers"
+	"github.com/mayankr5/v1/restaurant-management/logger"
+	"github.com/mayankr5/v1/restaurant-management/services"
+	"github.com/mayankr5/v1/restaurant-management/signing"
 )
 
-func Authentication() fiber.Handler {
+// publicRoutes can be called without a token: the operations marked Public in the docs. The
+// logins are how a token is obtained, the verification and reset confirmations are authorized by
+// the mailed token in their body, PIN logins by the terminal key and second factors by the
+// challenge token of the login. Public routes are matched on the literal path, so they can't have
+// path parameters.
+var publicRoutes = docs.PublicRoutes()
+
+// Authentication verifies the token of every request that isn't public with keys, rejects the
+// tokens of deleted users and those issued before a password reset, and keeps the sessions of
+// PIN logins alive through terminals.
+func Authentication(keys *signing.KeySet, users *services.UserService, terminals *services.TerminalService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		if publicRoutes[c.Method()+" "+c.Path()] {
+			return c.Next()
+		}
+
 		clientToken := c.Get("token")
 		if clientToken == "" {
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("No Authorization header provided")})
+			return apierrors.Unauthorized("No Authorization header provided")
+		}
+
+		claims, err := helper.ValidateToken(keys, clientToken)
+		if err != nil {
+			logger.From(c).Debug("token rejected", "reason", err)
+			return apierrors.Unauthorized(err.Error())
+		}
+
+		if err := users.CheckActive(c.UserContext(), claims.Subject, claims.Generation); err != nil {
+			var domain *services.Error
+			if errors.As(err, &domain) {
+				return apierrors.Unauthorized(domain.Message)
+			}
+			return apierrors.Internal("error occurred while checking the user", err)
 		}
 
-		claims, err := helper.ValidateToken(clientToken)
-		if err != "" {
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err})
+		// a PIN login's token only lasts as long as its session on the terminal
+		if claims.Terminal_id != "" {
+			if err := terminals.Touch(c.UserContext(), claims.Terminal_id, claims.Session_id); err != nil {
+				var domain *services.Error
+				if errors.As(err, &domain) {
+					return apierrors.Unauthorized(domain.Message)
+				}
+				return apierrors.Internal("error occurred while checking the terminal session", err)
+			}
+			c.Locals("terminal_id", claims.Terminal_id)
+			c.Locals("session_id", claims.Session_id)
 		}
 
 		c.Locals("email", claims.Email)
 		c.Locals("first_name", claims.First_name)
 		c.Locals("last_name", claims.Last_name)
-		c.Locals("uid", claims.Uid)
+		c.Locals("uid", claims.Subject)
 
 		return c.Next()
 	}
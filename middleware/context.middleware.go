@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// requestContextKey holds the request's context before any deadline, so Timeout can replace the
+// default deadline with a longer one.
+const requestContextKey = "request_context"
+
+// RequestContext gives every request a context, read with c.UserContext(), that carries the
+// request's logger, is cancelled when the client disconnects and expires after timeout. Add
+// Timeout to a route that needs a different deadline.
+func RequestContext(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		base, cancel := context.WithCancel(c.UserContext())
+		defer cancel()
+
+		stop := watchDisconnect(c.Context().Conn(), cancel)
+		defer stop()
+
+		ctx, cancelTimeout := context.WithTimeout(base, timeout)
+		defer cancelTimeout()
+
+		c.Locals(requestContextKey, base)
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
+
+// Timeout sets the deadline of the route it is added to, in place of the default one.
+func Timeout(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		base, ok := c.Locals(requestContextKey).(context.Context)
+		if !ok {
+			base = c.UserContext()
+		}
+
+		ctx, cancel := context.WithTimeout(base, timeout)
+		defer cancel()
+
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
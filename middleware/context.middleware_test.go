@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequestContextDeadlines(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: apierrors.ErrorHandler})
+	app.Use(RequestContext(50 * time.Millisecond))
+
+	wait := func(c *fiber.Ctx) error {
+		select {
+		case <-c.UserContext().Done():
+			return apierrors.Internal("query failed", c.UserContext().Err())
+		case <-time.After(200 * time.Millisecond):
+			return c.SendString("done")
+		}
+	}
+	app.Get("/default", wait)
+	app.Get("/longer", Timeout(time.Second), wait)
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/default", http.StatusGatewayTimeout},
+		{"/longer", http.StatusOK},
+	}
+	for _, tt := range tests {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.path, nil), 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s = %d, want %d", tt.path, resp.StatusCode, tt.status)
+		}
+	}
+}
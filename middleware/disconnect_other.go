@@ -0,0 +1,13 @@
+//go:build !unix
+
+package middleware
+
+import (
+	"context"
+	"net"
+)
+
+// watchDisconnect doesn't notice disconnects on this platform; requests run until their deadline.
+func watchDisconnect(conn net.Conn, cancel context.CancelFunc) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,57 @@
+//go:build unix
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+const disconnectPollInterval = 200 * time.Millisecond
+
+// watchDisconnect calls cancel once the client closes conn. The socket is peeked rather than
+// read, so a request the client pipelined behind this one is left for the server. It returns a
+// func that stops watching.
+func watchDisconnect(conn net.Conn, cancel context.CancelFunc) (stop func()) {
+	sysConn, ok := conn.(syscall.Conn)
+	if !ok {
+		return func() {}
+	}
+	raw, err := sysConn.SyscallConn()
+	if err != nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(disconnectPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if peerClosed(raw) {
+				cancel()
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func peerClosed(raw syscall.RawConn) bool {
+	closed := false
+	buf := make([]byte, 1)
+	err := raw.Read(func(fd uintptr) bool {
+		n, _, err := syscall.Recvfrom(int(fd), buf, syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		closed = (n == 0 && err == nil) || errors.Is(err, syscall.ECONNRESET)
+		// never wait for the socket to become readable
+		return true
+	})
+	return closed || errors.Is(err, net.ErrClosed)
+}
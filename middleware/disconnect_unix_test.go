@@ -0,0 +1,54 @@
+//go:build unix
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequestContextCanceledOnDisconnect(t *testing.T) {
+	started := make(chan struct{})
+	ended := make(chan error, 1)
+
+	app := fiber.New(fiber.Config{ErrorHandler: apierrors.ErrorHandler, DisableStartupMessage: true})
+	app.Use(RequestContext(15 * time.Second))
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		close(started)
+		select {
+		case <-c.UserContext().Done():
+			ended <- c.UserContext().Err()
+		case <-time.After(5 * time.Second):
+			ended <- errors.New("not canceled")
+		}
+		return nil
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.Shutdown() })
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte("GET /slow HTTP/1.1\r\nHost: localhost\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	conn.Close()
+
+	if err := <-ended; !errors.Is(err, context.Canceled) {
+		t.Errorf("handler context ended with %v, want context.Canceled", err)
+	}
+}
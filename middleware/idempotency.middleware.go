@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/database"
+	"github.com/mayankr5/v1/restaurant-management/docs"
+	"github.com/mayankr5/v1/restaurant-management/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// credentialRoutes answer with tokens, keys, secrets or codes: the operations marked Credentials
+// in the docs. Their responses are never stored, since a replay would hand them to anyone who
+// sends the same key again. Like publicRoutes, they are matched on the literal path.
+var credentialRoutes = docs.CredentialRoutes()
+
+// Idempotency replays the stored response when a mutating request is retried with the same
+// Idempotency-Key header. Keys are scoped to the signed in user and kept for ttl. Reusing a key
+// with a different request is rejected with 409. The keys are kept in records. The key of a request
+// that fails with a server error or panics is released, so the request can be retried.
+func Idempotency(ttl time.Duration, records database.Collection) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get("Idempotency-Key")
+		if key == "" || !isMutating(c.Method()) || credentialRoutes[c.Method()+" "+c.Path()] {
+			return c.Next()
+		}
+		if len(key) > 255 {
+			return apierrors.BadRequest("Idempotency-Key must be at most 255 characters")
+		}
+
+		// the key's bookkeeping has to finish even if the client goes away, or the key would stay
+		// IN_PROGRESS and every retry would be rejected
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(c.UserContext()), 10*time.Second)
+		defer cancel()
+
+		uid, _ := c.Locals("uid").(string)
+		id := uid + ":" + key
+		hash := requestHash(c)
+
+		now := time.Now()
+		record := models.IdempotencyRecord{
+			ID:           id,
+			Request_hash: hash,
+			Status:       "IN_PROGRESS",
+			Created_at:   now,
+			Expires_at:   now.Add(ttl),
+		}
+
+		_, err := records.InsertOne(ctx, record)
+		if mongo.IsDuplicateKeyError(err) {
+			// an expired key is free to be used again
+			result, deleteErr := records.DeleteOne(ctx, bson.M{"_id": id, "expires_at": bson.M{"$lte": now}})
+			if deleteErr == nil && result.DeletedCount == 1 {
+				_, err = records.InsertOne(ctx, record)
+			}
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			var stored models.IdempotencyRecord
+			if err := records.FindOne(ctx, bson.M{"_id": id}).Decode(&stored); err != nil {
+				return apierrors.Internal("error occured while looking up the idempotency key", err)
+			}
+			if stored.Request_hash != hash {
+				return apierrors.Conflict("Idempotency-Key was already used for a different request")
+			}
+			if stored.Status != "COMPLETED" {
+				return apierrors.Conflict("a request with this Idempotency-Key is still being processed")
+			}
+
+			c.Set("Idempotent-Replayed", "true")
+			if stored.Content_type != "" {
+				c.Set(fiber.HeaderContentType, stored.Content_type)
+			}
+			return c.Status(stored.Response_status).Send(stored.Response_body)
+		}
+		if err != nil {
+			return apierrors.Internal("error occured while storing the idempotency key", err)
+		}
+
+		// the key is released unless the response gets stored, also when the handler panics and
+		// Recover, which runs before this middleware, never lets c.Next return
+		stored := false
+		defer func() {
+			if !stored {
+				records.DeleteOne(ctx, bson.M{"_id": id})
+			}
+		}()
+
+		// render errors here rather than in the app's ErrorHandler so that they are stored like any other response
+		if err := c.Next(); err != nil {
+			if err := c.App().Config().ErrorHandler(c, err); err != nil {
+				return err
+			}
+		}
+
+		// server errors are not remembered so the client can retry them
+		status := c.Response().StatusCode()
+		if status >= http.StatusInternalServerError {
+			return nil
+		}
+
+		body := append([]byte(nil), c.Response().Body()...)
+		_, err = records.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+			"status":          "COMPLETED",
+			"response_status": status,
+			"content_type":    string(c.Response().Header.ContentType()),
+			"response_body":   body,
+		}})
+		stored = err == nil
+		return nil
+	}
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func requestHash(c *fiber.Ctx) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.Method()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(c.OriginalURL()))
+	hash.Write([]byte{0})
+	hash.Write(c.Body())
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/database"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestIdempotency(t *testing.T) {
+
+	calls := 0
+	app := fiber.New(fiber.Config{ErrorHandler: apierrors.ErrorHandler})
+	app.Use(Idempotency(time.Hour, database.OpenCollection(database.NewMemoryStore(), "idempotency")))
+	app.Post("/invoices", func(c *fiber.Ctx) error {
+		calls++
+		return c.Status(http.StatusOK).JSON(fiber.Map{"call": calls})
+	})
+
+	send := func(key, body string) (int, string, string) {
+		req := httptest.NewRequest(http.MethodPost, "/invoices", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(payload), resp.Header.Get("Idempotent-Replayed")
+	}
+
+	status, first, _ := send("k1", `{"order_id": "o1"}`)
+	if status != http.StatusOK || first != `{"call":1}` {
+		t.Fatalf("first request = %d %s", status, first)
+	}
+
+	status, replay, replayed := send("k1", `{"order_id": "o1"}`)
+	if status != http.StatusOK || replay != first || replayed != "true" || calls != 1 {
+		t.Errorf("retry = %d %s replayed=%q after %d calls, want the stored response", status, replay, replayed, calls)
+	}
+
+	if status, _, _ := send("k1", `{"order_id": "o2"}`); status != http.StatusConflict {
+		t.Errorf("reused key with a different body = %d, want 409", status)
+	}
+
+	if _, body, _ := send("", `{"order_id": "o1"}`); body != `{"call":2}` {
+		t.Errorf("request without a key = %s, want it to run", body)
+	}
+}
+
+func TestIdempotencyReleasesTheKeyOfAPanic(t *testing.T) {
+
+	calls := 0
+	app := fiber.New(fiber.Config{ErrorHandler: apierrors.ErrorHandler})
+	app.Use(Recover())
+	app.Use(Idempotency(time.Hour, database.OpenCollection(database.NewMemoryStore(), "idempotency")))
+	app.Post("/invoices", func(c *fiber.Ctx) error {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		return c.JSON(fiber.Map{"call": calls})
+	})
+
+	send := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/invoices", bytes.NewBufferString(`{}`))
+		req.Header.Set("Idempotency-Key", "k1")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	if status := send(); status != http.StatusInternalServerError {
+		t.Fatalf("panicking request = %d, want 500", status)
+	}
+	if status := send(); status != http.StatusOK || calls != 2 {
+		t.Errorf("retry after a panic = %d after %d calls, want it to run again", status, calls)
+	}
+}
+
+func TestIdempotencyNeverStoresCredentials(t *testing.T) {
+
+	calls := 0
+	records := database.OpenCollection(database.NewMemoryStore(), "idempotency")
+	app := fiber.New(fiber.Config{ErrorHandler: apierrors.ErrorHandler})
+	app.Use(Idempotency(time.Hour, records))
+	app.Post("/users/login", func(c *fiber.Ctx) error {
+		calls++
+		return c.JSON(fiber.Map{"token": calls})
+	})
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/users/login", bytes.NewBufferString(`{}`))
+		req.Header.Set("Idempotency-Key", "k1")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Header.Get("Idempotent-Replayed") != "" {
+			t.Fatalf("login %d was replayed", i+1)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("logins ran %d times, want 2", calls)
+	}
+	if count, _ := records.CountDocuments(context.Background(), bson.M{}); count != 0 {
+		t.Errorf("%d idempotency records stored for logins, want none", count)
+	}
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/mayankr5/v1/restaurant-management/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+)
+
+// Recover turns a panicking handler into a 500 and logs the panic with its stack trace.
+func Recover() fiber.Handler {
+	return recover.New(recover.Config{
+		EnableStackTrace: true,
+		StackTraceHandler: func(c *fiber.Ctx, e interface{}) {
+			logger.From(c).Error("panic", "error", fmt.Sprint(e), "stack", string(debug.Stack()))
+		},
+	})
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/mayankr5/v1/restaurant-management/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestID tags every request with an id, taken from the X-Request-ID header when the client or a
+// proxy sent a usable one and generated otherwise. The id is echoed in the response header, added
+// to every log line of the request and included in error responses.
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(fiber.HeaderXRequestID)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		c.Locals(logger.RequestIDKey, id)
+		c.Set(fiber.HeaderXRequestID, id)
+		logger.Attach(c, logger.Get().With(logger.RequestIDKey, id))
+
+		return c.Next()
+	}
+}
+
+// validRequestID accepts up to 128 printable ASCII characters, so ids can't inject into logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
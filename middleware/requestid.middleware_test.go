@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mayankr5/v1/restaurant-management/apierrors"
+	"github.com/mayankr5/v1/restaurant-management/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequestIDAndAccessLog(t *testing.T) {
+	var logged bytes.Buffer
+	previous := logger.Get()
+	logger.Set(logger.New(&logged, logger.Level("debug")))
+	t.Cleanup(func() { logger.Set(previous) })
+
+	app := fiber.New(fiber.Config{ErrorHandler: apierrors.ErrorHandler})
+	app.Use(RequestID())
+	app.Use(AccessLog())
+	app.Get("/tables/:table_id", func(c *fiber.Ctx) error {
+		c.Locals("uid", "u1")
+		return apierrors.NotFound("table was not found")
+	})
+
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"propagated", "abc-123", true},
+		{"generated", "", false},
+		{"unprintable", "bad\nid", false},
+		{"too long", strings.Repeat("x", 129), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logged.Reset()
+
+			req := httptest.NewRequest(http.MethodGet, "/tables/t1", nil)
+			if tt.incoming != "" {
+				req.Header.Set(fiber.HeaderXRequestID, tt.incoming)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			id := resp.Header.Get(fiber.HeaderXRequestID)
+			if tt.keep && id != tt.incoming {
+				t.Errorf("X-Request-ID = %q, want %q", id, tt.incoming)
+			}
+			if !tt.keep && (id == "" || id == tt.incoming) {
+				t.Errorf("X-Request-ID = %q, want a generated id", id)
+			}
+
+			var body apierrors.Error
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Request_id != id || body.Code != apierrors.CodeNotFound {
+				t.Errorf("error body %+v, want request_id %q", body, id)
+			}
+
+			var line map[string]interface{}
+			if err := json.Unmarshal(logged.Bytes(), &line); err != nil {
+				t.Fatalf("access log %q: %v", logged.String(), err)
+			}
+			want := map[string]interface{}{
+				"msg": "request", "request_id": id, "method": "GET", "path": "/tables/t1",
+				"route": "/tables/:table_id", "status": float64(404), "user_id": "u1",
+			}
+			for key, value := range want {
+				if line[key] != value {
+					t.Errorf("access log %s = %v, want %v", key, line[key], value)
+				}
+			}
+		})
+	}
+}
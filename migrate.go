@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/database"
+	"github.com/mayankr5/v1/restaurant-management/logger"
+	"github.com/mayankr5/v1/restaurant-management/migrations"
+)
+
+const migrateUsage = `usage: restaurant-management migrate [up|status]
+
+  up      apply the pending migrations (the default)
+  status  list every migration and when it was applied
+`
+
+// migrate runs the migrate subcommand against MONGODB_URI and returns the exit code.
+func migrate(args []string) int {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	if len(args) > 1 || (command != "up" && command != "status") {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	client, err := database.Connect(database.URI())
+	if err != nil {
+		logger.Get().Error("invalid mongodb uri", "error", err)
+		return 1
+	}
+	defer client.Disconnect(context.Background())
+
+	pingCtx, cancelPing := context.WithTimeout(ctx, 10*time.Second)
+	defer cancelPing()
+	if err := client.Ping(pingCtx, nil); err != nil {
+		logger.Get().Error("mongodb is not reachable", "error", err)
+		return 1
+	}
+
+	db := client.Database(database.Name)
+
+	if command == "status" {
+		applied, err := migrations.Applied(ctx, db)
+		if err != nil {
+			logger.Get().Error("reading applied migrations failed", "error", err)
+			return 1
+		}
+		for _, migration := range migrations.All {
+			state := "pending"
+			if record, ok := applied[migration.Version]; ok {
+				state = "applied " + record.Applied_at.Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-28s  %s\n", migration.Version, state, migration.Description)
+		}
+		return 0
+	}
+
+	applied := 0
+	err = migrations.Up(ctx, db, func(migration migrations.Migration) {
+		applied++
+		logger.Get().Info("migration applied", "version", migration.Version, "description", migration.Description)
+	})
+	if err != nil {
+		logger.Get().Error("migration failed", "error", err)
+		return 1
+	}
+	logger.Get().Info("database is up to date", "applied", applied)
+	return 0
+}
@@ -0,0 +1,76 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// versioned lists the collections whose documents carry an optimistic concurrency version.
+var versioned = []string{"food", "menu", "table", "order", "orderItem", "invoice", "user", "promotion", "coupon"}
+
+// backfillDefaults fills in fields that documents written by older releases lack.
+func backfillDefaults(ctx context.Context, db *mongo.Database) error {
+	for _, name := range versioned {
+		_, err := db.Collection(name).UpdateMany(ctx,
+			bson.M{"version": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"version": 1}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := db.Collection("orderItem").UpdateMany(ctx,
+		bson.M{"status": bson.M{"$in": []interface{}{nil, ""}}},
+		bson.M{"$set": bson.M{"status": "ACTIVE"}},
+	)
+	if err != nil {
+		return err
+	}
+
+	// the current price becomes the first history entry, effective since the food was created
+	_, err = db.Collection("food").UpdateMany(ctx,
+		bson.M{"price_history": bson.M{"$in": []interface{}{nil, bson.A{}}}, "price": bson.M{"$ne": nil}},
+		mongo.Pipeline{{{"$set", bson.D{{"price_history", bson.A{bson.D{
+			{"price", "$price"},
+			{"effective_from", "$created_at"},
+			{"effective_to", nil},
+			{"changed_by", ""},
+		}}}}}}},
+	)
+	return err
+}
+
+// backfillPaidAt dates the payment of invoices paid before it was recorded at their creation,
+// the closest there is, and indexes the payment dates.
+func backfillPaidAt(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("invoice").UpdateMany(ctx,
+		bson.M{"payment_status": bson.M{"$nin": bson.A{nil, "PENDING"}}, "paid_at": nil},
+		mongo.Pipeline{{{"$set", bson.D{{"paid_at", "$created_at"}}}}},
+	)
+	if err != nil {
+		return err
+	}
+	return createIndexes(paidInvoiceIndexes)(ctx, db)
+}
+
+// archivable lists the collections besides users whose documents are soft deleted.
+var archivable = []string{"table", "food", "menu", "order"}
+
+// backfillDeletedAt marks the documents written before soft deletes as not deleted. Lists only
+// match a null deleted_at, and the unique index on table numbers leaves out tables without one.
+func backfillDeletedAt(ctx context.Context, db *mongo.Database) error {
+	for _, name := range archivable {
+		_, err := db.Collection(name).UpdateMany(ctx,
+			bson.M{"deleted_at": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"deleted_at": nil}},
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// live limits a unique index to documents that are not soft deleted, so a deleted table's
+// number can be given to a new one.
+var live = bson.M{"deleted_at": bson.M{"$type": "null"}}
+
+// The index lists below belong to the migration that creates them and are as fixed as the
+// migration itself: a released list is never edited, new indexes go in a new migration. The
+// unique ones back the uniqueness checks of the services, which can't prevent duplicates from
+// concurrent requests on their own.
+
+// initialIndexes are the lookup, unique and TTL indexes of migration 2.
+var initialIndexes = map[string][]mongo.IndexModel{
+	"food": {
+		{Keys: bson.D{{"food_id", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"menu_id", 1}}},
+	},
+	"menu": {
+		{Keys: bson.D{{"menu_id", 1}}, Options: options.Index().SetUnique(true)},
+	},
+	"table": {
+		{Keys: bson.D{{"table_id", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"table_number", 1}}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(live)},
+	},
+	"order": {
+		{Keys: bson.D{{"order_id", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"table_id", 1}}},
+	},
+	"orderItem": {
+		{Keys: bson.D{{"order_item_id", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"order_id", 1}}},
+		{Keys: bson.D{{"food_id", 1}}},
+	},
+	"invoice": {
+		{Keys: bson.D{{"invoice_id", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"order_id", 1}}},
+		{Keys: bson.D{{"payment_status", 1}, {"created_at", 1}}},
+	},
+	"refund": {
+		{Keys: bson.D{{"refund_id", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"invoice_id", 1}}},
+	},
+	"user": {
+		{Keys: bson.D{{"user_id", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"email", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"phone", 1}}, Options: options.Index().SetUnique(true)},
+	},
+	"promotion": {
+		{Keys: bson.D{{"promotion_id", 1}}, Options: options.Index().SetUnique(true)},
+	},
+	"coupon": {
+		{Keys: bson.D{{"coupon_id", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"code", 1}}, Options: options.Index().SetUnique(true)},
+	},
+	"audit": {
+		{Keys: bson.D{{"entity", 1}, {"entity_id", 1}, {"created_at", -1}}},
+		{Keys: bson.D{{"created_at", -1}}},
+	},
+	"idempotency": {
+		// Mongo removes keys once expires_at has passed
+		{Keys: bson.D{{"expires_at", 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+}
+
+// tokenIndexes are the email verification and password reset token indexes of migration 4.
+var tokenIndexes = map[string][]mongo.IndexModel{
+	"userToken": {
+		{Keys: bson.D{{"token_hash", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"user_id", 1}, {"purpose", 1}}},
+		// expired tokens can't be used, so Mongo may as well remove them
+		{Keys: bson.D{{"expires_at", 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+}
+
+// loginAttemptIndexes are the failed login counter indexes of migration 5.
+var loginAttemptIndexes = map[string][]mongo.IndexModel{
+	"loginAttempt": {
+		{Keys: bson.D{{"key", 1}}, Options: options.Index().SetUnique(true)},
+		// failures are forgotten once the window, or the lock, has passed
+		{Keys: bson.D{{"expires_at", 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+}
+
+// terminalIndexes are the POS terminal indexes of migration 6.
+var terminalIndexes = map[string][]mongo.IndexModel{
+	"terminal": {
+		{Keys: bson.D{{"terminal_id", 1}}, Options: options.Index().SetUnique(true)},
+	},
+}
+
+// liveUserIndexes replace the unique email and phone indexes of migration 2 in migration 7, so
+// that a deleted user's email and phone can be signed up again.
+var liveUserIndexes = map[string][]mongo.IndexModel{
+	"user": {
+		{Keys: bson.D{{"email", 1}}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(live)},
+		{Keys: bson.D{{"phone", 1}}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(live)},
+	},
+}
+
+// paidInvoiceIndexes serve the sales report, which picks invoices by when they were paid, in
+// migration 8.
+var paidInvoiceIndexes = map[string][]mongo.IndexModel{
+	"invoice": {
+		{Keys: bson.D{{"payment_status", 1}, {"paid_at", 1}}},
+	},
+}
+
+// createIndexes returns a migration that creates the missing indexes of the list. Existing ones
+// with the same keys and options are left alone.
+func createIndexes(indexes map[string][]mongo.IndexModel) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		for name, models := range indexes {
+			if _, err := db.Collection(name).Indexes().CreateMany(ctx, models); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// makeUserUniquesLive swaps the unique email and phone indexes for ones that leave out deleted
+// users. Users written before soft deletes have no deleted_at, which the partial indexes would
+// leave out too, so it is set first. An index is dropped before its replacement is created,
+// since Mongo won't keep two indexes on the same key that differ only in their filter.
+func makeUserUniquesLive(ctx context.Context, db *mongo.Database) error {
+	users := db.Collection("user")
+
+	_, err := users.UpdateMany(ctx, bson.M{"deleted_at": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"deleted_at": nil}})
+	if err != nil {
+		return err
+	}
+
+	cursor, err := users.Indexes().List(ctx)
+	if err != nil {
+		return err
+	}
+	var indexes []bson.M
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		name, _ := index["name"].(string)
+		// a rerun finds the replacements already in place
+		if _, partial := index["partialFilterExpression"]; partial || (name != "email_1" && name != "phone_1") {
+			continue
+		}
+		if _, err := users.Indexes().DropOne(ctx, name); err != nil {
+			return fmt.Errorf("dropping %s: %w", name, err)
+		}
+	}
+
+	return createIndexes(liveUserIndexes)(ctx, db)
+}
@@ -0,0 +1,104 @@
+// Package migrations versions the changes the Mongo database needs beyond what is created on the
+// fly: indexes, schema validators and backfills of existing documents. Each migration runs once
+// and is recorded in the migrations collection when it succeeds.
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type Migration struct {
+	Version     int
+	Description string
+	// Up must be safe to run again, since two instances may migrate at the same time and a
+	// migration that failed halfway is retried from the start.
+	Up func(ctx context.Context, db *mongo.Database) error
+}
+
+// All lists the migrations in the order they are applied. Add new ones at the end; never change
+// or renumber one that has been released.
+var All = []Migration{
+	{Version: 1, Description: "backfill versions, order item statuses and food price histories", Up: backfillDefaults},
+	{Version: 2, Description: "create lookup, unique and TTL indexes", Up: createIndexes(initialIndexes)},
+	{Version: 3, Description: "add JSON schema validators", Up: addValidators},
+	{Version: 4, Description: "index email verification and password reset tokens", Up: createIndexes(tokenIndexes)},
+	{Version: 5, Description: "index failed login counters", Up: createIndexes(loginAttemptIndexes)},
+	{Version: 6, Description: "index POS terminals", Up: createIndexes(terminalIndexes)},
+	{Version: 7, Description: "leave deleted users out of the unique email and phone indexes", Up: makeUserUniquesLive},
+	{Version: 8, Description: "date the payment of paid invoices and index it", Up: backfillPaidAt},
+	{Version: 9, Description: "mark tables, foods, menus and orders written before soft deletes as not deleted", Up: backfillDeletedAt},
+}
+
+// Record is stored in the migrations collection for every applied migration.
+type Record struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	Applied_at  time.Time `bson:"applied_at"`
+}
+
+const collectionName = "migrations"
+
+// Applied returns the recorded migrations by version.
+func Applied(ctx context.Context, db *mongo.Database) (map[int]Record, error) {
+	cursor, err := db.Collection(collectionName).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []Record
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := map[int]Record{}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// Pending returns the migrations that have not been applied yet, in order.
+func Pending(ctx context.Context, db *mongo.Database) ([]Migration, error) {
+	applied, err := Applied(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range All {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies the pending migrations in order and stops at the first one that fails. done is
+// called after each migration is recorded.
+func Up(ctx context.Context, db *mongo.Database, done func(Migration)) error {
+	pending, err := Pending(ctx, db)
+	if err != nil {
+		return fmt.Errorf("reading applied migrations: %w", err)
+	}
+
+	for _, migration := range pending {
+		if err := migration.Up(ctx, db); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+
+		record := Record{Version: migration.Version, Description: migration.Description, Applied_at: time.Now()}
+		_, err := db.Collection(collectionName).InsertOne(ctx, record)
+		// another instance finished the same migration first
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("recording migration %d: %w", migration.Version, err)
+		}
+		done(migration)
+	}
+	return nil
+}
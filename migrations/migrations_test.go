@@ -0,0 +1,66 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestMigrationsAreOrdered(t *testing.T) {
+	for i, migration := range All {
+		if migration.Version != i+1 {
+			t.Errorf("migration %d has version %d, versions must count up from 1", i, migration.Version)
+		}
+		if migration.Description == "" || migration.Up == nil {
+			t.Errorf("migration %d needs a description and an Up func", migration.Version)
+		}
+	}
+}
+
+// currentIndexes replays the index lists of the migrations in order; a later index on the same
+// keys replaces the earlier one.
+func currentIndexes() map[string]map[string]mongo.IndexModel {
+	current := map[string]map[string]mongo.IndexModel{}
+	for _, list := range []map[string][]mongo.IndexModel{initialIndexes, tokenIndexes, loginAttemptIndexes, terminalIndexes, liveUserIndexes, paidInvoiceIndexes} {
+		for name, indexes := range list {
+			if current[name] == nil {
+				current[name] = map[string]mongo.IndexModel{}
+			}
+			for _, index := range indexes {
+				var keys []string
+				for _, key := range index.Keys.(bson.D) {
+					keys = append(keys, key.Key)
+				}
+				current[name][strings.Join(keys, ",")] = index
+			}
+		}
+	}
+	return current
+}
+
+func TestUniqueIndexes(t *testing.T) {
+	current := currentIndexes()
+
+	for _, want := range []string{"user.email", "user.phone", "user.user_id", "table.table_number", "food.food_id", "menu.menu_id", "order.order_id", "coupon.code", "userToken.token_hash", "loginAttempt.key", "terminal.terminal_id"} {
+		name, key, _ := strings.Cut(want, ".")
+		index, ok := current[name][key]
+		if !ok || index.Options == nil || index.Options.Unique == nil || !*index.Options.Unique {
+			t.Errorf("%s has no unique index", want)
+		}
+	}
+
+	// deleted users and tables give up their email, phone and number
+	for _, want := range []string{"user.email", "user.phone", "table.table_number"} {
+		name, key, _ := strings.Cut(want, ".")
+		if options := current[name][key].Options; options == nil || options.PartialFilterExpression == nil {
+			t.Errorf("the unique index on %s should leave out deleted documents", want)
+		}
+	}
+
+	ttl := current["idempotency"]["expires_at"].Options
+	if ttl == nil || ttl.ExpireAfterSeconds == nil || *ttl.ExpireAfterSeconds != 0 {
+		t.Errorf("idempotency keys need a TTL index on expires_at")
+	}
+}
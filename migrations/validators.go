@@ -0,0 +1,85 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	str         = bson.M{"bsonType": "string"}
+	nullableStr = bson.M{"bsonType": bson.A{"string", "null"}}
+	number      = bson.M{"bsonType": "number", "minimum": 0}
+	integer     = bson.M{"bsonType": bson.A{"int", "long"}}
+	date        = bson.M{"bsonType": "date"}
+	nullable    = bson.M{"bsonType": bson.A{"date", "null"}}
+)
+
+func enum(values ...string) bson.M {
+	return bson.M{"bsonType": "string", "enum": values}
+}
+
+func schema(required []string, properties bson.M) bson.M {
+	return bson.M{"$jsonSchema": bson.M{"bsonType": "object", "required": required, "properties": properties}}
+}
+
+// Validators lists the $jsonSchema validator of each collection. They only check what every
+// handler writes; the request validation in the handlers stays the first line of defence.
+var Validators = map[string]bson.M{
+	"food": schema([]string{"food_id", "name", "price", "menu_id"}, bson.M{
+		"food_id": str, "name": str, "price": number, "menu_id": str, "food_image": nullableStr,
+		"version": integer, "deleted_at": nullable,
+	}),
+	"menu": schema([]string{"menu_id", "name", "category"}, bson.M{
+		"menu_id": str, "name": str, "category": str, "start_date": nullable, "end_date": nullable,
+		"version": integer, "deleted_at": nullable,
+	}),
+	"table": schema([]string{"table_id", "table_number", "number_of_guests"}, bson.M{
+		"table_id": str, "table_number": integer, "number_of_guests": integer,
+		"version": integer, "deleted_at": nullable,
+	}),
+	"order": schema([]string{"order_id", "order_date"}, bson.M{
+		"order_id": str, "order_date": date, "table_id": nullableStr,
+		"version": integer, "deleted_at": nullable,
+	}),
+	"orderItem": schema([]string{"order_item_id", "order_id", "food_id", "quantity", "status"}, bson.M{
+		"order_item_id": str, "order_id": str, "food_id": str, "quantity": enum("S", "M", "L"),
+		"status": enum("ACTIVE", "VOIDED", "REFUNDED"), "unit_price": bson.M{"bsonType": bson.A{"number", "null"}, "minimum": 0}, "version": integer,
+	}),
+	"invoice": schema([]string{"invoice_id", "order_id", "payment_status", "total"}, bson.M{
+		"invoice_id": str, "order_id": str, "total": number, "refunded_amount": number,
+		"payment_status": enum("PENDING", "PAID", "PARTIALLY_REFUNDED", "REFUNDED"),
+		"payment_method": bson.M{"enum": bson.A{"CARD", "CASH", "", nil}},
+		"version":        integer,
+	}),
+	"user": schema([]string{"user_id", "email", "phone", "password"}, bson.M{
+		"user_id": str, "email": str, "phone": str, "password": str,
+		"role": enum("ADMIN", "MANAGER", "STAFF"), "version": integer, "deleted_at": nullable,
+	}),
+}
+
+// addValidators creates the collections that don't exist yet and sets their validators.
+// Documents that already break a validator can still be updated, but new ones are rejected.
+func addValidators(ctx context.Context, db *mongo.Database) error {
+	for name, validator := range Validators {
+		var commandErr mongo.CommandError
+		err := db.CreateCollection(ctx, name)
+		if err != nil && !(errors.As(err, &commandErr) && commandErr.Name == "NamespaceExists") {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		err = db.RunCommand(ctx, bson.D{
+			{"collMod", name},
+			{"validator", validator},
+			{"validationLevel", "moderate"},
+			{"validationAction", "error"},
+		}).Err()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
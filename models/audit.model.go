@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Audit struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Entity     string             `json:"entity"`
+	Entity_id  string             `json:"entity_id"`
+	Action     string             `json:"action"`
+	User_id    string             `json:"user_id"`
+	Before     bson.M             `json:"before"`
+	After      bson.M             `json:"after"`
+	Changes    []AuditChange      `json:"changes"`
+	Created_at time.Time          `json:"created_at"`
+	Audit_id   string             `json:"audit_id"`
+}
+
+type AuditChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
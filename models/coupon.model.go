@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Coupon struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Code         *string            `json:"code" validate:"required,min=3,max=32"`
+	Promotion_id *string            `json:"promotion_id" validate:"required"`
+	Usage_limit  *int               `json:"usage_limit" validate:"omitempty,gte=1"`
+	Times_used   int                `json:"times_used"`
+	Expires_at   *time.Time         `json:"expires_at"`
+	Created_at   time.Time          `json:"created_at"`
+	Updated_at   time.Time          `json:"updated_at"`
+	Version      int                `json:"version"`
+	Coupon_id    string             `json:"coupon_id"`
+}
@@ -7,12 +7,22 @@ import (
 )
 
 type Food struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Name       *string            `json:"name" validate:"required,min=2,max=100"`
-	Price      *float64           `json:"price" validate:"required"`
-	Food_image *string            `json:"food_image" validate:"required"`
-	Created_at time.Time          `json:"created_at"`
-	Updated_at time.Time          `json:"updated_at"`
-	Food_id    string             `json:"food_id"`
-	Menu_id    *string            `json:"menu_id" validate:"required"`
+	ID            primitive.ObjectID `bson:"_id"`
+	Name          *string            `json:"name" validate:"required,min=2,max=100"`
+	Price         *float64           `json:"price" validate:"required"`
+	Food_image    *string            `json:"food_image" validate:"required"`
+	Created_at    time.Time          `json:"created_at"`
+	Updated_at    time.Time          `json:"updated_at"`
+	Version       int                `json:"version"`
+	Deleted_at    *time.Time         `json:"deleted_at"`
+	Food_id       string             `json:"food_id"`
+	Menu_id       *string            `json:"menu_id" validate:"required"`
+	Price_history []PriceChange      `json:"price_history"`
+}
+
+type PriceChange struct {
+	Price          float64    `json:"price"`
+	Effective_from time.Time  `json:"effective_from"`
+	Effective_to   *time.Time `json:"effective_to"`
+	Changed_by     string     `json:"changed_by"`
 }
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type IdempotencyRecord struct {
+	ID              string    `bson:"_id" json:"key"`
+	Request_hash    string    `json:"request_hash"`
+	Status          string    `json:"status" validate:"eq=IN_PROGRESS|eq=COMPLETED"`
+	Response_status int       `json:"response_status"`
+	Content_type    string    `json:"content_type"`
+	Response_body   []byte    `json:"response_body"`
+	Created_at      time.Time `json:"created_at"`
+	Expires_at      time.Time `json:"expires_at"`
+}
@@ -7,12 +7,20 @@ import (
 )
 
 type Invoice struct {
-	ID               primitive.ObjectID `bson:"_id"`
-	Invoice_id       string             `json:"invoice_id"`
-	Order_id         string             `json:"order_id"`
-	Payment_method   *string            `json:"payment_method" validate:"eq=CARD|eq=CASH|eq="`
-	Payment_status   *string            `json:"payment_status" validate:"required,eq=PENDING|eq=PAID"`
-	Payment_due_date time.Time          `json:"Payment_due_date"`
-	Created_at       time.Time          `json:"created_at"`
-	Updated_at       time.Time          `json:"updated_at"`
+	ID                 primitive.ObjectID `bson:"_id"`
+	Invoice_id         string             `json:"invoice_id"`
+	Order_id           string             `json:"order_id"`
+	Payment_method     *string            `json:"payment_method" validate:"eq=CARD|eq=CASH|eq="`
+	Payment_status     *string            `json:"payment_status" validate:"required,eq=PENDING|eq=PAID|eq=PARTIALLY_REFUNDED|eq=REFUNDED"`
+	Payment_due_date   time.Time          `json:"Payment_due_date"`
+	Coupon_code        *string            `json:"coupon_code"`
+	Subtotal           float64            `json:"subtotal"`
+	Discount_total     float64            `json:"discount_total"`
+	Total              float64            `json:"total"`
+	Refunded_amount    float64            `json:"refunded_amount"`
+	Paid_at            *time.Time         `json:"paid_at"`
+	Applied_promotions []AppliedPromotion `json:"applied_promotions"`
+	Created_at         time.Time          `json:"created_at"`
+	Updated_at         time.Time          `json:"updated_at"`
+	Version            int                `json:"version"`
 }
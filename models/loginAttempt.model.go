@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginAttempt counts the recent failed logins for an email or from an address, or the wrong
+// PINs or second factors of a user. Key is "email:", "ip:", "pin:" or "mfa:" followed by the
+// lower-cased email, the address or the user id.
+type LoginAttempt struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Key             string             `json:"key"`
+	Failures        int                `json:"failures"`
+	Last_failure_at time.Time          `json:"last_failure_at"`
+	// Retry_at is when the next attempt is allowed after a run of failures.
+	Retry_at     *time.Time `json:"retry_at"`
+	Locked_until *time.Time `json:"locked_until"`
+	Expires_at   time.Time  `json:"expires_at"`
+}
@@ -14,5 +14,7 @@ type Menu struct {
 	End_Date   *time.Time         `json:"end_date"`
 	Created_at time.Time          `json:"created_at"`
 	Updated_at time.Time          `json:"updated_at"`
+	Version    int                `json:"version"`
+	Deleted_at *time.Time         `json:"deleted_at"`
 	Menu_id    string             `json:"food_id"`
 }
@@ -12,5 +12,6 @@ type Note struct {
 	Title      string             `json:"title"`
 	Created_at time.Time          `json:"created_at"`
 	Updated_at time.Time          `json:"updated_at"`
+	Version    int                `json:"version"`
 	Note_id    string             `json:"note_id"`
 }
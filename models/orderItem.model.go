@@ -9,10 +9,17 @@ import (
 type OrderItem struct {
 	ID            primitive.ObjectID `bson:"_id"`
 	Quantity      *string            `json:"quantity" validate:"required,eq=S|eq=M|eq=L"`
-	Unit_price    *float64           `json:"unit_price" validate:"required"`
+	Unit_price    *float64           `json:"unit_price"`
+	Food_name     *string            `json:"food_name"`
 	Created_at    time.Time          `json:"created_at"`
 	Updated_at    time.Time          `json:"updated_at"`
+	Version       int                `json:"version"`
 	Food_id       *string            `json:"food_id" validate:"required"`
 	Order_item_id string             `json:"order_item_id"`
 	Order_id      string             `json:"order_id" validate:"required"`
+	Status        string             `json:"status"`
+	Void_reason   string             `json:"void_reason,omitempty"`
+	Voided_by     string             `json:"voided_by,omitempty"`
+	Approved_by   string             `json:"approved_by,omitempty"`
+	Voided_at     *time.Time         `json:"voided_at,omitempty"`
 }
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Promotion struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Name         *string            `json:"name" validate:"required,min=2,max=100"`
+	Type         *string            `json:"type" validate:"required,eq=PERCENTAGE|eq=FIXED|eq=BUY_X_GET_Y"`
+	Value        *float64           `json:"value" validate:"omitempty,gte=0"`
+	Buy_quantity *int               `json:"buy_quantity" validate:"omitempty,gte=1"`
+	Get_quantity *int               `json:"get_quantity" validate:"omitempty,gte=1"`
+	Menu_id      *string            `json:"menu_id"`
+	Food_id      *string            `json:"food_id"`
+	Start_time   *string            `json:"start_time" validate:"omitempty,datetime=15:04"`
+	End_time     *string            `json:"end_time" validate:"omitempty,datetime=15:04"`
+	Start_Date   *time.Time         `json:"start_date"`
+	End_Date     *time.Time         `json:"end_date"`
+	Coupon_only  *bool              `json:"coupon_only"`
+	Active       *bool              `json:"active"`
+	Created_at   time.Time          `json:"created_at"`
+	Updated_at   time.Time          `json:"updated_at"`
+	Version      int                `json:"version"`
+	Promotion_id string             `json:"promotion_id"`
+}
+
+type AppliedPromotion struct {
+	Promotion_id string  `json:"promotion_id"`
+	Name         string  `json:"name"`
+	Type         string  `json:"type"`
+	Coupon_code  string  `json:"coupon_code,omitempty"`
+	Discount     float64 `json:"discount"`
+}
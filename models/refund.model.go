@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Refund struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Invoice_id     string             `json:"invoice_id"`
+	Order_id       string             `json:"order_id"`
+	Order_item_ids []string           `json:"order_item_ids"`
+	Amount         float64            `json:"amount"`
+	Payment_method string             `json:"payment_method"`
+	Reason         string             `json:"reason"`
+	Refunded_by    string             `json:"refunded_by"`
+	Approved_by    string             `json:"approved_by"`
+	Created_at     time.Time          `json:"created_at"`
+	Refund_id      string             `json:"refund_id"`
+}
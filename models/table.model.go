@@ -12,5 +12,7 @@ type Table struct {
 	Table_number     *int               `json:"table_number" validate:"required"`
 	Created_at       time.Time          `json:"created_at"`
 	Updated_at       time.Time          `json:"updated_at"`
+	Version          int                `json:"version"`
+	Deleted_at       *time.Time         `json:"deleted_at"`
 	Table_id         string             `json:"table_id"`
 }
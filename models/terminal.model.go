@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Terminal is a shared POS device staff sign in on with their PIN. It authenticates with a key
+// handed out once at registration, of which only the SHA-256 hash is stored. One user is signed in
+// at a time; Session_id names their session, which ends at Session_expires_at unless they keep
+// using it.
+type Terminal struct {
+	ID                 primitive.ObjectID `bson:"_id"`
+	Name               string             `json:"name" validate:"required,min=2,max=100"`
+	Key_hash           string             `json:"key_hash"`
+	Registered_by      string             `json:"registered_by"`
+	Session_id         string             `json:"session_id"`
+	User_id            string             `json:"user_id"`
+	Last_seen_at       *time.Time         `json:"last_seen_at"`
+	Session_expires_at *time.Time         `json:"session_expires_at"`
+	Created_at         time.Time          `json:"created_at"`
+	Updated_at         time.Time          `json:"updated_at"`
+	Revoked_at         *time.Time         `json:"revoked_at"`
+	Version            int                `json:"version"`
+	Terminal_id        string             `json:"terminal_id"`
+}
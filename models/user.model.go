@@ -7,16 +7,26 @@ import (
 )
 
 type User struct {
-	ID            primitive.ObjectID `bson:"_id"`
-	First_name    string             `json:"first_name" validate:"required,min=2,max=100"`
-	Last_name     string             `json:"last_name" validate:"required,min=2,max=100"`
-	Password      string             `json:"Password" validate:"required,min=6"`
-	Email         string             `json:"email" validate:"email,required"`
-	Avatar        string             `json:"avatar"`
-	Phone         string             `json:"phone" validate:"required"`
-	Token         string             `json:"token"`
-	Refresh_Token string             `json:"refresh_token"`
-	Created_at    time.Time          `json:"created_at"`
-	Updated_at    time.Time          `json:"updated_at"`
-	User_id       string             `json:"user_id"`
+	ID                 primitive.ObjectID `bson:"_id"`
+	First_name         string             `json:"first_name" validate:"required,min=2,max=100"`
+	Last_name          string             `json:"last_name" validate:"required,min=2,max=100"`
+	Password           string             `json:"Password" validate:"required,min=6"`
+	Pin                string             `json:"pin"`
+	Email              string             `json:"email" validate:"email,required"`
+	Avatar             string             `json:"avatar"`
+	Phone              string             `json:"phone" validate:"required"`
+	Role               string             `json:"role" validate:"omitempty,eq=ADMIN|eq=MANAGER|eq=STAFF"`
+	Token              string             `json:"token"`
+	Refresh_Token      string             `json:"refresh_token"`
+	Token_generation   int                `json:"token_generation"`
+	Email_verified_at  *time.Time         `json:"email_verified_at"`
+	Mfa_secret         string             `json:"mfa_secret"`
+	Mfa_enabled_at     *time.Time         `json:"mfa_enabled_at"`
+	Mfa_last_step      int64              `json:"mfa_last_step"`
+	Mfa_recovery_codes []string           `json:"mfa_recovery_codes"`
+	Created_at         time.Time          `json:"created_at"`
+	Updated_at         time.Time          `json:"updated_at"`
+	Version            int                `json:"version"`
+	Deleted_at         *time.Time         `json:"deleted_at"`
+	User_id            string             `json:"user_id"`
 }
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserToken is a single-use token handed to a user: mailed to verify their email or reset their
+// password, or returned by a login that still needs the second factor. Only the SHA-256 hash of the
+// token is stored.
+type UserToken struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Token_id   string             `json:"token_id"`
+	User_id    string             `json:"user_id"`
+	Purpose    string             `json:"purpose" validate:"eq=VERIFY_EMAIL|eq=RESET_PASSWORD|eq=MFA_LOGIN"`
+	Token_hash string             `json:"token_hash"`
+	Expires_at time.Time          `json:"expires_at"`
+	Used_at    *time.Time         `json:"used_at"`
+	Created_at time.Time          `json:"created_at"`
+}
@@ -0,0 +1,58 @@
+// Package repositories opens the collections the services keep their documents in, all on one
+// store.
+package repositories
+
+import (
+	"context"
+
+	"github.com/mayankr5/v1/restaurant-management/database"
+)
+
+// Repositories are the collections of a store, one per kind of document.
+type Repositories struct {
+	Store database.Store
+
+	Audits        database.Collection
+	Coupons       database.Collection
+	Foods         database.Collection
+	Idempotency   database.Collection
+	Invoices      database.Collection
+	LoginAttempts database.Collection
+	Menus         database.Collection
+	Orders        database.Collection
+	OrderItems    database.Collection
+	Promotions    database.Collection
+	Refunds       database.Collection
+	Tables        database.Collection
+	Terminals     database.Collection
+	Users         database.Collection
+	UserTokens    database.Collection
+}
+
+// New opens the collections of store. Opening them connects to nothing.
+func New(store database.Store) *Repositories {
+	return &Repositories{
+		Store: store,
+
+		Audits:        database.OpenCollection(store, "audit"),
+		Coupons:       database.OpenCollection(store, "coupon"),
+		Foods:         database.OpenCollection(store, "food"),
+		Idempotency:   database.OpenCollection(store, "idempotency"),
+		Invoices:      database.OpenCollection(store, "invoice"),
+		LoginAttempts: database.OpenCollection(store, "loginAttempt"),
+		Menus:         database.OpenCollection(store, "menu"),
+		Orders:        database.OpenCollection(store, "order"),
+		OrderItems:    database.OpenCollection(store, "orderItem"),
+		Promotions:    database.OpenCollection(store, "promotion"),
+		Refunds:       database.OpenCollection(store, "refund"),
+		Tables:        database.OpenCollection(store, "table"),
+		Terminals:     database.OpenCollection(store, "terminal"),
+		Users:         database.OpenCollection(store, "user"),
+		UserTokens:    database.OpenCollection(store, "userToken"),
+	}
+}
+
+// WithTransaction runs fn in a transaction of the store. fn must use the context it is given.
+func (r *Repositories) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.Store.WithTransaction(ctx, fn)
+}
@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"github.com/mayankr5/v1/restaurant-management/controllers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func AuditRoutes(app *fiber.App, audits *controllers.AuditController) {
+	app.Get("/audit", audits.GetAudits)
+}
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/mayankr5/v1/restaurant-management/controllers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func CouponRoutes(app *fiber.App, promotions *controllers.PromotionController) {
+	app.Get("/coupons", promotions.GetCoupons)
+	app.Get("/coupons/:coupon_id", promotions.GetCoupon)
+	app.Post("/coupons", promotions.CreateCoupon)
+}
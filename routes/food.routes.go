@@ -6,9 +6,12 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func FoodRoutes(app *fiber.App) {
-	app.Get("/foods", controllers.GetFoods)
-	app.Get("/foods/:food_id", controllers.GetFood)
-	app.Post("/foods", controllers.CreateFood)
-	app.Patch("/foods/:food_id", controllers.UpdateFood)
+func FoodRoutes(app *fiber.App, foods *controllers.FoodController) {
+	app.Get("/foods", foods.GetFoods)
+	app.Get("/foods/:food_id", foods.GetFood)
+	app.Get("/foods/:food_id/prices", foods.GetFoodPrices)
+	app.Post("/foods", foods.CreateFood)
+	app.Patch("/foods/:food_id", foods.UpdateFood)
+	app.Delete("/foods/:food_id", foods.DeleteFood)
+	app.Post("/foods/:food_id/restore", foods.RestoreFood)
 }
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/mayankr5/v1/restaurant-management/controllers"
+	"github.com/mayankr5/v1/restaurant-management/database"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HealthRoutes serves the liveness and readiness probes. Register it before the authentication
+// middleware so probes don't need a token. Readiness is whether store answers.
+func HealthRoutes(app *fiber.App, store database.Store) {
+	health := controllers.NewHealthController(store)
+	app.Get("/healthz", health.Healthz)
+	app.Get("/readyz", health.Readyz)
+}
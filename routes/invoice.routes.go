@@ -6,9 +6,11 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func InvoiceRoutes(app *fiber.App) {
-	app.Get("/invoices", controllers.GetInvoices)
-	app.Get("/invoices/:invoice_id", controllers.GetInvoice)
-	app.Post("/invoices", controllers.CreateInvoice)
-	app.Patch("/invoices/:invoice_id", controllers.UpdateInvoice)
+func InvoiceRoutes(app *fiber.App, invoices *controllers.InvoiceController) {
+	app.Get("/invoices", invoices.GetInvoices)
+	app.Get("/invoices/:invoice_id", invoices.GetInvoice)
+	app.Post("/invoices", invoices.CreateInvoice)
+	app.Patch("/invoices/:invoice_id", invoices.UpdateInvoice)
+	app.Post("/invoices/:invoice_id/refund", invoices.RefundInvoice)
+	app.Get("/invoices/:invoice_id/refunds", invoices.GetInvoiceRefunds)
 }
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/mayankr5/v1/restaurant-management/controllers"
+	"github.com/mayankr5/v1/restaurant-management/signing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// KeyRoutes publishes the keys tokens are verified with, for other services checking our tokens.
+// Register it before the authentication middleware.
+func KeyRoutes(app *fiber.App, keys *signing.KeySet) {
+	app.Get("/.well-known/jwks.json", controllers.NewKeyController(keys).GetJWKS)
+}
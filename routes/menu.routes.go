@@ -6,9 +6,11 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func MenuRoutes(app *fiber.App) {
-	app.Get("/menus", controllers.GetMenus)
-	app.Get("/menus/:menu_id", controllers.GetMenu)
-	app.Post("/menus", controllers.CreateMenu)
-	app.Patch("/menus/:menu_id", controllers.UpdateMenu)
+func MenuRoutes(app *fiber.App, menus *controllers.MenuController) {
+	app.Get("/menus", menus.GetMenus)
+	app.Get("/menus/:menu_id", menus.GetMenu)
+	app.Post("/menus", menus.CreateMenu)
+	app.Patch("/menus/:menu_id", menus.UpdateMenu)
+	app.Delete("/menus/:menu_id", menus.DeleteMenu)
+	app.Post("/menus/:menu_id/restore", menus.RestoreMenu)
 }
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/logger"
+	"github.com/mayankr5/v1/restaurant-management/metrics"
+	"github.com/mayankr5/v1/restaurant-management/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MetricsRoutes serves the Prometheus metrics, with the number of open orders counted by orders.
+// Register it before the authentication middleware so the scraper doesn't need a token.
+func MetricsRoutes(app *fiber.App, orders *services.OrderService) {
+	openOrders := metrics.Gauge("restaurant_open_orders", "Orders that have not been paid yet.", func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		count, err := orders.CountOpen(ctx)
+		if err != nil {
+			logger.Get().Warn("counting open orders failed", "error", err)
+			return math.NaN()
+		}
+		return float64(count)
+	})
+
+	app.Get("/metrics", metrics.Handler(openOrders))
+}
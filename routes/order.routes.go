@@ -6,9 +6,11 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func OrderRoutes(app *fiber.App) {
-	app.Get("/orders", controllers.GetOrders)
-	app.Get("/orders/:order_id", controllers.GetOrder)
-	app.Post("/orders", controllers.CreateOrder)
-	app.Patch("/orders/:order_id", controllers.UpdateOrder)
+func OrderRoutes(app *fiber.App, orders *controllers.OrderController) {
+	app.Get("/orders", orders.GetOrders)
+	app.Get("/orders/:order_id", orders.GetOrder)
+	app.Post("/orders", orders.CreateOrder)
+	app.Patch("/orders/:order_id", orders.UpdateOrder)
+	app.Delete("/orders/:order_id", orders.DeleteOrder)
+	app.Post("/orders/:order_id/restore", orders.RestoreOrder)
 }
@@ -6,10 +6,11 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func OrderItemRoutes(app *fiber.App) {
-	app.Get("/orderItems", controllers.GetOrderItems)
-	app.Get("/orderItems/:orderItem_id", controllers.GetOrderItem)
-	app.Get("/orderItems-order/:order_id", controllers.GetOrderItemsByOrder)
-	app.Post("/orderItems", controllers.CreateOrderItem)
-	app.Patch("/orderItems/:orderItem_id", controllers.UpdateOrderItem)
+func OrderItemRoutes(app *fiber.App, orders *controllers.OrderController) {
+	app.Get("/orderItems", orders.GetOrderItems)
+	app.Get("/orderItems/:order_item_id", orders.GetOrderItem)
+	app.Get("/orderItems-order/:order_id", orders.GetOrderItemsByOrder)
+	app.Post("/orderItems", orders.CreateOrderItem)
+	app.Patch("/orderItems/:order_item_id", orders.UpdateOrderItem)
+	app.Post("/orderItems/:order_item_id/void", orders.VoidOrderItem)
 }
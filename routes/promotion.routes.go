@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/mayankr5/v1/restaurant-management/controllers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func PromotionRoutes(app *fiber.App, promotions *controllers.PromotionController, reports *controllers.ReportController) {
+	app.Get("/promotions", promotions.GetPromotions)
+	app.Get("/promotions/report", reports.GetPromotionReport)
+	app.Get("/promotions/:promotion_id", promotions.GetPromotion)
+	app.Post("/promotions", promotions.CreatePromotion)
+	app.Patch("/promotions/:promotion_id", promotions.UpdatePromotion)
+}
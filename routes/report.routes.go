@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/controllers"
+	"github.com/mayankr5/v1/restaurant-management/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func ReportRoutes(app *fiber.App, reports *controllers.ReportController) {
+	// reports aggregate every invoice of the period, which takes longer than the default deadline allows
+	app.Get("/reports/sales", middleware.Timeout(time.Minute), reports.GetSalesReport)
+}
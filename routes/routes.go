@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"github.com/mayankr5/v1/restaurant-management/controllers"
+	"github.com/mayankr5/v1/restaurant-management/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Register adds every API route to app, served by svc.
+func Register(app *fiber.App, svc *services.Services) {
+	orders := controllers.NewOrderController(svc.Orders)
+	promotions := controllers.NewPromotionController(svc.Promotions)
+	reports := controllers.NewReportController(svc.Reports)
+
+	UserRoutes(app, controllers.NewUserController(svc.Users))
+	FoodRoutes(app, controllers.NewFoodController(svc.Foods))
+	MenuRoutes(app, controllers.NewMenuController(svc.Menus))
+	TableRoutes(app, controllers.NewTableController(svc.Tables))
+	OrderRoutes(app, orders)
+	OrderItemRoutes(app, orders)
+	InvoiceRoutes(app, controllers.NewInvoiceController(svc.Invoices))
+	PromotionRoutes(app, promotions, reports)
+	CouponRoutes(app, promotions)
+	ReportRoutes(app, reports)
+	AuditRoutes(app, controllers.NewAuditController(svc.Audits))
+	TerminalRoutes(app, controllers.NewTerminalController(svc.Terminals))
+}
@@ -6,9 +6,11 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func TableRoutes(app *fiber.App) {
-	app.Get("/tables", controllers.GetTables)
-	app.Get("/tables/:table_id", controllers.GetTable)
-	app.Post("/tables", controllers.CreateTable)
-	app.Patch("/tables/:table_id", controllers.UpdateTable)
+func TableRoutes(app *fiber.App, tables *controllers.TableController) {
+	app.Get("/tables", tables.GetTables)
+	app.Get("/tables/:table_id", tables.GetTable)
+	app.Post("/tables", tables.CreateTable)
+	app.Patch("/tables/:table_id", tables.UpdateTable)
+	app.Delete("/tables/:table_id", tables.DeleteTable)
+	app.Post("/tables/:table_id/restore", tables.RestoreTable)
 }
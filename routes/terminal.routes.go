@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/mayankr5/v1/restaurant-management/controllers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TerminalRoutes(app *fiber.App, terminals *controllers.TerminalController) {
+	app.Get("/terminals", terminals.GetTerminals)
+	app.Post("/terminals", terminals.RegisterTerminal)
+	app.Post("/terminals/login", terminals.TerminalLogin)
+	app.Post("/terminals/logout", terminals.TerminalLogout)
+	app.Delete("/terminals/:terminal_id", terminals.RevokeTerminal)
+}
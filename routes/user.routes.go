@@ -6,9 +6,22 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func UserRoutes(app *fiber.App) {
-	app.Get("/users", controllers.GetUsers)
-	app.Get("/users/:user_id", controllers.GetUser)
-	app.Post("/users/signup", controllers.SignUp)
-	app.Post("/users/login", controllers.Login)
+func UserRoutes(app *fiber.App, users *controllers.UserController) {
+	app.Get("/users", users.GetUsers)
+	app.Get("/users/:user_id", users.GetUser)
+	app.Post("/users/signup", users.SignUp)
+	app.Post("/users/login", users.Login)
+	app.Post("/users/login/mfa", users.LoginMfa)
+	app.Post("/users/login/mfa/enroll", users.EnrollAtLogin)
+	app.Post("/users/verification", users.RequestVerification)
+	app.Post("/users/verification/confirm", users.VerifyEmail)
+	app.Post("/users/password-reset", users.RequestPasswordReset)
+	app.Post("/users/password-reset/confirm", users.ResetPassword)
+	app.Post("/users/pin", users.SetPin)
+	app.Post("/users/mfa", users.EnrollMfa)
+	app.Post("/users/mfa/confirm", users.ConfirmMfa)
+	app.Delete("/users/mfa", users.DisableMfa)
+	app.Post("/users/:user_id/unlock", users.UnlockUser)
+	app.Delete("/users/:user_id", users.DeleteUser)
+	app.Post("/users/:user_id/restore", users.RestoreUser)
 }
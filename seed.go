@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/database"
+	"github.com/mayankr5/v1/restaurant-management/logger"
+	"github.com/mayankr5/v1/restaurant-management/seed"
+)
+
+const defaultFixture = "fixtures/demo.yaml"
+
+// seedCommand runs the seed subcommand against MONGODB_URI and returns the exit code.
+func seedCommand(args []string) int {
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "usage: restaurant-management seed [fixture file, %s by default]\n", defaultFixture)
+		return 2
+	}
+	file := defaultFixture
+	if len(args) == 1 {
+		file = args[0]
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	client, err := database.Connect(database.URI())
+	if err != nil {
+		logger.Get().Error("invalid mongodb uri", "error", err)
+		return 1
+	}
+	store := database.NewMongoStore(client, database.Name)
+	defer store.Close(context.Background())
+
+	pingCtx, cancelPing := context.WithTimeout(ctx, 10*time.Second)
+	defer cancelPing()
+	if err := store.Ping(pingCtx); err != nil {
+		logger.Get().Error("mongodb is not reachable", "error", err)
+		return 1
+	}
+
+	if err := seedFrom(ctx, store, file); err != nil {
+		logger.Get().Error("seeding failed", "file", file, "error", err)
+		return 1
+	}
+	return 0
+}
+
+// seedFrom loads the fixture into store and logs what was added.
+func seedFrom(ctx context.Context, store database.Store, file string) error {
+	fixture, err := seed.Load(file)
+	if err != nil {
+		return err
+	}
+
+	summary, err := seed.Apply(ctx, store, fixture)
+	if err != nil {
+		return err
+	}
+
+	collections := make([]string, 0, len(summary))
+	for name := range summary {
+		collections = append(collections, name)
+	}
+	sort.Strings(collections)
+	for _, name := range collections {
+		logger.Get().Info("seeded", "file", file, "collection", name, "created", summary[name].Created, "existing", summary[name].Existing)
+	}
+	return nil
+}
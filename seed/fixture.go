@@ -0,0 +1,166 @@
+package seed
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fixture is a restaurant described in a JSON or YAML file. Every record has an id of its own
+// choosing, which becomes its menu_id, food_id and so on, and which other records refer to.
+type Fixture struct {
+	Menus  []Menu  `json:"menus" yaml:"menus"`
+	Foods  []Food  `json:"foods" yaml:"foods"`
+	Tables []Table `json:"tables" yaml:"tables"`
+	Users  []User  `json:"users" yaml:"users"`
+	Orders []Order `json:"orders" yaml:"orders"`
+}
+
+type Menu struct {
+	Id       string `json:"id" yaml:"id"`
+	Name     string `json:"name" yaml:"name"`
+	Category string `json:"category" yaml:"category"`
+}
+
+type Food struct {
+	Id    string  `json:"id" yaml:"id"`
+	Name  string  `json:"name" yaml:"name"`
+	Price float64 `json:"price" yaml:"price"`
+	Image string  `json:"image" yaml:"image"`
+	Menu  string  `json:"menu" yaml:"menu"`
+}
+
+type Table struct {
+	Id     string `json:"id" yaml:"id"`
+	Number int    `json:"number" yaml:"number"`
+	Guests int    `json:"guests" yaml:"guests"`
+}
+
+type User struct {
+	Id         string `json:"id" yaml:"id"`
+	First_name string `json:"first_name" yaml:"first_name"`
+	Last_name  string `json:"last_name" yaml:"last_name"`
+	Email      string `json:"email" yaml:"email"`
+	Phone      string `json:"phone" yaml:"phone"`
+	Password   string `json:"password" yaml:"password"`
+	Role       string `json:"role" yaml:"role"`
+}
+
+// Order is a past order. Its items are priced at the food's fixture price.
+type Order struct {
+	Id      string      `json:"id" yaml:"id"`
+	Table   string      `json:"table" yaml:"table"`
+	Date    time.Time   `json:"date" yaml:"date"`
+	Items   []OrderItem `json:"items" yaml:"items"`
+	Invoice *Invoice    `json:"invoice" yaml:"invoice"`
+}
+
+type OrderItem struct {
+	Food     string `json:"food" yaml:"food"`
+	Quantity string `json:"quantity" yaml:"quantity"`
+}
+
+// Invoice bills the order's items, without promotions. Its id is the order's id.
+type Invoice struct {
+	Status string `json:"status" yaml:"status"`
+	Method string `json:"method" yaml:"method"`
+}
+
+// Load reads a fixture, as YAML when the file ends in .yaml or .yml and as JSON otherwise.
+func Load(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fixture Fixture
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &fixture)
+	default:
+		err = json.Unmarshal(data, &fixture)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := fixture.Check(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+// Check makes sure every id is set and unique and every reference resolves.
+func (f *Fixture) Check() error {
+	menus, err := ids("menu", len(f.Menus), func(i int) string { return f.Menus[i].Id })
+	if err != nil {
+		return err
+	}
+	foods, err := ids("food", len(f.Foods), func(i int) string { return f.Foods[i].Id })
+	if err != nil {
+		return err
+	}
+	tables, err := ids("table", len(f.Tables), func(i int) string { return f.Tables[i].Id })
+	if err != nil {
+		return err
+	}
+	if _, err := ids("user", len(f.Users), func(i int) string { return f.Users[i].Id }); err != nil {
+		return err
+	}
+	if _, err := ids("order", len(f.Orders), func(i int) string { return f.Orders[i].Id }); err != nil {
+		return err
+	}
+
+	for _, food := range f.Foods {
+		if !menus[food.Menu] {
+			return fmt.Errorf("food %s: unknown menu %q", food.Id, food.Menu)
+		}
+	}
+	for _, user := range f.Users {
+		if user.Email == "" || user.Password == "" {
+			return fmt.Errorf("user %s: email and password are required", user.Id)
+		}
+	}
+	for _, order := range f.Orders {
+		if !tables[order.Table] {
+			return fmt.Errorf("order %s: unknown table %q", order.Id, order.Table)
+		}
+		if len(order.Items) == 0 {
+			return fmt.Errorf("order %s: has no items", order.Id)
+		}
+		for _, item := range order.Items {
+			if !foods[item.Food] {
+				return fmt.Errorf("order %s: unknown food %q", order.Id, item.Food)
+			}
+			if item.Quantity != "S" && item.Quantity != "M" && item.Quantity != "L" {
+				return fmt.Errorf("order %s: quantity must be S, M or L, not %q", order.Id, item.Quantity)
+			}
+		}
+		if invoice := order.Invoice; invoice != nil {
+			if invoice.Status != "PENDING" && invoice.Status != "PAID" {
+				return fmt.Errorf("order %s: invoice status must be PENDING or PAID, not %q", order.Id, invoice.Status)
+			}
+			if invoice.Method != "" && invoice.Method != "CARD" && invoice.Method != "CASH" {
+				return fmt.Errorf("order %s: payment method must be CARD or CASH, not %q", order.Id, invoice.Method)
+			}
+		}
+	}
+	return nil
+}
+
+func ids(kind string, n int, id func(i int) string) (map[string]bool, error) {
+	seen := map[string]bool{}
+	for i := 0; i < n; i++ {
+		if id(i) == "" {
+			return nil, fmt.Errorf("%s #%d has no id", kind, i+1)
+		}
+		if seen[id(i)] {
+			return nil, fmt.Errorf("%s %s is listed twice", kind, id(i))
+		}
+		seen[id(i)] = true
+	}
+	return seen, nil
+}
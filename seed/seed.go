@@ -0,0 +1,199 @@
+// Package seed loads a demo restaurant from a fixture file into a store, so that a
+// development environment has menus, foods, tables, staff and past orders to work with.
+package seed
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/database"
+	"github.com/mayankr5/v1/restaurant-management/models"
+	"github.com/mayankr5/v1/restaurant-management/services"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Count tells how many documents of a collection were created and how many already existed.
+type Count struct {
+	Created  int
+	Existing int
+}
+
+// Summary holds a Count per collection.
+type Summary map[string]*Count
+
+// Apply writes the fixture to store. A document whose id already exists is left as
+// it is, so applying a fixture again only adds what is new in it.
+func Apply(ctx context.Context, store database.Store, fixture *Fixture) (Summary, error) {
+	summary := Summary{}
+	now := time.Now()
+
+	for _, menu := range fixture.Menus {
+		menu := menu
+		err := summary.insert(ctx, store, "menu", "menu_id", menu.Id, func() (interface{}, error) {
+			return models.Menu{
+				ID: primitive.NewObjectID(), Menu_id: menu.Id, Name: menu.Name, Category: menu.Category,
+				Created_at: now, Updated_at: now, Version: 1,
+			}, nil
+		})
+		if err != nil {
+			return summary, err
+		}
+	}
+
+	prices := map[string]float64{}
+	names := map[string]string{}
+	for _, food := range fixture.Foods {
+		food := food
+		prices[food.Id] = round(food.Price)
+		names[food.Id] = food.Name
+		err := summary.insert(ctx, store, "food", "food_id", food.Id, func() (interface{}, error) {
+			price := round(food.Price)
+			return models.Food{
+				ID: primitive.NewObjectID(), Food_id: food.Id, Name: &food.Name, Price: &price,
+				Food_image: &food.Image, Menu_id: &food.Menu,
+				Price_history: []models.PriceChange{{Price: price, Effective_from: now}},
+				Created_at:    now, Updated_at: now, Version: 1,
+			}, nil
+		})
+		if err != nil {
+			return summary, err
+		}
+	}
+
+	for _, table := range fixture.Tables {
+		table := table
+		err := summary.insert(ctx, store, "table", "table_id", table.Id, func() (interface{}, error) {
+			return models.Table{
+				ID: primitive.NewObjectID(), Table_id: table.Id, Table_number: &table.Number,
+				Number_of_guests: &table.Guests, Created_at: now, Updated_at: now, Version: 1,
+			}, nil
+		})
+		if err != nil {
+			return summary, err
+		}
+	}
+
+	for _, user := range fixture.Users {
+		user := user
+		err := summary.insert(ctx, store, "user", "user_id", user.Id, func() (interface{}, error) {
+			password, err := services.HashPassword(user.Password)
+			if err != nil {
+				return nil, err
+			}
+			role := user.Role
+			if role == "" {
+				role = "STAFF"
+			}
+			return models.User{
+				ID: primitive.NewObjectID(), User_id: user.Id, First_name: user.First_name, Last_name: user.Last_name,
+				Email: user.Email, Phone: user.Phone, Password: password, Role: role,
+				Created_at: now, Updated_at: now, Version: 1,
+				// fixture addresses can't receive mail, so they count as verified
+				Email_verified_at: &now,
+			}, nil
+		})
+		if err != nil {
+			return summary, err
+		}
+	}
+
+	for _, order := range fixture.Orders {
+		if err := summary.order(ctx, store, order, prices, names); err != nil {
+			return summary, err
+		}
+	}
+	return summary, nil
+}
+
+// order writes a past order with its items and invoice, all dated at the order's date.
+func (s Summary) order(ctx context.Context, store database.Store, order Order, prices map[string]float64, names map[string]string) error {
+	at := order.Date
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	err := s.insert(ctx, store, "order", "order_id", order.Id, func() (interface{}, error) {
+		return models.Order{
+			ID: primitive.NewObjectID(), Order_id: order.Id, Table_id: &order.Table, Order_Date: at,
+			Created_at: at, Updated_at: at, Version: 1,
+		}, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var subtotal float64
+	for i, item := range order.Items {
+		item := item
+		price := prices[item.Food]
+		name := names[item.Food]
+		subtotal += price
+
+		err := s.insert(ctx, store, "orderItem", "order_item_id", fmt.Sprintf("%s-%d", order.Id, i+1), func() (interface{}, error) {
+			return models.OrderItem{
+				ID: primitive.NewObjectID(), Order_item_id: fmt.Sprintf("%s-%d", order.Id, i+1), Order_id: order.Id,
+				Food_id: &item.Food, Food_name: &name, Quantity: &item.Quantity, Unit_price: &price, Status: "ACTIVE",
+				Created_at: at, Updated_at: at, Version: 1,
+			}, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if order.Invoice == nil {
+		return nil
+	}
+	return s.insert(ctx, store, "invoice", "invoice_id", order.Id, func() (interface{}, error) {
+		invoice := models.Invoice{
+			ID: primitive.NewObjectID(), Invoice_id: order.Id, Order_id: order.Id,
+			Payment_status: &order.Invoice.Status, Payment_due_date: at.AddDate(0, 0, 1),
+			Subtotal: round(subtotal), Total: round(subtotal), Applied_promotions: []models.AppliedPromotion{},
+			Created_at: at, Updated_at: at, Version: 1,
+		}
+		if order.Invoice.Method != "" {
+			invoice.Payment_method = &order.Invoice.Method
+		}
+		if order.Invoice.Status == "PAID" {
+			invoice.Paid_at = &at
+		}
+		return invoice, nil
+	})
+}
+
+// insert writes the document built by build to the collection of store, unless one with the id
+// exists already.
+func (s Summary) insert(ctx context.Context, store database.Store, collection string, idField string, id string, build func() (interface{}, error)) error {
+	count, ok := s[collection]
+	if !ok {
+		count = &Count{}
+		s[collection] = count
+	}
+
+	existing, err := database.OpenCollection(store, collection).CountDocuments(ctx, bson.M{idField: id})
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", collection, id, err)
+	}
+	if existing > 0 {
+		count.Existing++
+		return nil
+	}
+
+	document, err := build()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", collection, id, err)
+	}
+	if _, err := database.OpenCollection(store, collection).InsertOne(ctx, document); err != nil {
+		return fmt.Errorf("%s %s: %w", collection, id, err)
+	}
+	count.Created++
+	return nil
+}
+
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,72 @@
+package seed
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mayankr5/v1/restaurant-management/database"
+	"github.com/mayankr5/v1/restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDemoFixtureSeedsOnce(t *testing.T) {
+	store := database.NewMemoryStore()
+
+	fixture, err := Load("../fixtures/demo.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	first, err := Apply(ctx, store, fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first["food"].Created != len(fixture.Foods) || first["user"].Created != len(fixture.Users) {
+		t.Errorf("first run created %d foods and %d users, want %d and %d",
+			first["food"].Created, first["user"].Created, len(fixture.Foods), len(fixture.Users))
+	}
+
+	second, err := Apply(ctx, store, fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, count := range second {
+		if count.Created != 0 || count.Existing != first[name].Created {
+			t.Errorf("second run on %s = %+v, want everything existing", name, *count)
+		}
+	}
+
+	var invoice models.Invoice
+	if err := database.OpenCollection(store, "invoice").FindOne(ctx, bson.M{"invoice_id": "demo-order-1"}).Decode(&invoice); err != nil {
+		t.Fatal(err)
+	}
+	if invoice.Total != 46 || *invoice.Payment_status != "PAID" || *invoice.Payment_method != "CARD" {
+		t.Errorf("invoice = %v %s %s, want 46 PAID CARD", invoice.Total, *invoice.Payment_status, *invoice.Payment_method)
+	}
+}
+
+func TestCheckRejectsBrokenReferences(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture Fixture
+		want    string
+	}{
+		{"unknown menu", Fixture{Foods: []Food{{Id: "soup", Menu: "lunch"}}}, `unknown menu "lunch"`},
+		{"duplicate id", Fixture{Tables: []Table{{Id: "t1"}, {Id: "t1"}}}, "listed twice"},
+		{"unknown food", Fixture{
+			Tables: []Table{{Id: "t1"}},
+			Orders: []Order{{Id: "o1", Table: "t1", Items: []OrderItem{{Food: "soup", Quantity: "M"}}}},
+		}, `unknown food "soup"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.fixture.Check()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Check() = %v, want an error about %s", err, tt.want)
+			}
+		})
+	}
+}
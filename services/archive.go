@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Page selects Limit items of a list, starting at Offset.
+type Page struct {
+	Offset int
+	Limit  int
+}
+
+// listFilter excludes soft-deleted documents unless includeDeleted is set.
+func listFilter(includeDeleted bool) bson.M {
+	if includeDeleted {
+		return bson.M{}
+	}
+	return bson.M{"deleted_at": nil}
+}
+
+// archive flags the document whose idField is id as deleted instead of removing it, so that
+// orders and invoices referencing it keep resolving. Only managers may delete.
+func (s *base) archive(ctx context.Context, uid string, collection database.Collection, entity string, idField string, id string) (*mongo.UpdateResult, error) {
+	if err := s.requireManager(ctx, uid, "deleting the "+entity); err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{idField: id}
+
+	before := Snapshot(ctx, collection, filter)
+	if before == nil {
+		return nil, errorf(NotFound, "%s was not found", entity)
+	}
+
+	now := time.Now()
+	result, err := collection.UpdateOne(
+		ctx,
+		bson.M{idField: id, "deleted_at": nil},
+		bson.D{
+			{"$set", bson.D{{"deleted_at", now}, {"updated_at", now}}},
+			{"$inc", bson.D{{"version", 1}}},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, errorf(Conflict, "%s is already deleted", entity)
+	}
+
+	s.recordAudit(ctx, uid, entity, id, "DELETE", before, Snapshot(ctx, collection, filter))
+	return result, nil
+}
+
+// unarchive undoes archive, for managers too. It is a Conflict when a document that isn't
+// deleted has taken the document's unique fields meanwhile.
+func (s *base) unarchive(ctx context.Context, uid string, collection database.Collection, entity string, idField string, id string) (*mongo.UpdateResult, error) {
+	if err := s.requireManager(ctx, uid, "restoring the "+entity); err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{idField: id}
+
+	before := Snapshot(ctx, collection, filter)
+	if before == nil {
+		return nil, errorf(NotFound, "%s was not found", entity)
+	}
+
+	result, err := collection.UpdateOne(
+		ctx,
+		bson.M{idField: id, "deleted_at": bson.M{"$ne": nil}},
+		bson.D{
+			{"$set", bson.D{{"deleted_at", nil}, {"updated_at", time.Now()}}},
+			{"$inc", bson.D{{"version", 1}}},
+		},
+	)
+	if err != nil {
+		return nil, writeFailed(err, entity+" conflicts with one that is not deleted")
+	}
+	if result.MatchedCount == 0 {
+		return nil, errorf(Conflict, "%s is not deleted", entity)
+	}
+
+	s.recordAudit(ctx, uid, entity, id, "RESTORE", before, Snapshot(ctx, collection, filter))
+	return result, nil
+}
+
+// pagePipeline counts the documents matching filter and slices page out of them into field.
+func pagePipeline(filter bson.M, page Page, field string) mongo.Pipeline {
+	matchStage := bson.D{{"$match", filter}}
+	groupStage := bson.D{{"$group", bson.D{{"_id", bson.D{{"_id", "null"}}}, {"total_count", bson.D{{"$sum", 1}}}, {"data", bson.D{{"$push", "$$ROOT"}}}}}}
+	projectStage := bson.D{
+		{"$project", bson.D{
+			{"_id", 0},
+			{"total_count", 1},
+			{field, bson.D{{"$slice", []interface{}{"$data", page.Offset, page.Limit}}}},
+		}}}
+	return mongo.Pipeline{matchStage, groupStage, projectStage}
+}
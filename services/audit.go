@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/database"
+	"github.com/mayankr5/v1/restaurant-management/logger"
+	"github.com/mayankr5/v1/restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// auditHiddenFields are never copied into the audit log.
+var auditHiddenFields = []string{"password", "pin", "token", "refresh_token", "key_hash", "mfa_secret", "mfa_recovery_codes"}
+
+// recordAudit writes an audit entry for a mutation. before is nil for creates and after is nil
+// for deletes; both may be model structs or documents. Failures are logged, never returned,
+// so that auditing can't break the mutation it describes.
+func (s *base) recordAudit(ctx context.Context, uid string, entity string, entityId string, action string, before interface{}, after interface{}) {
+	// the change is already made, so the record is written even if the client has gone away meanwhile
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	var audit models.Audit
+
+	audit.ID = primitive.NewObjectID()
+	audit.Audit_id = audit.ID.Hex()
+	audit.Entity = entity
+	audit.Entity_id = entityId
+	audit.Action = action
+	audit.User_id = uid
+	audit.Before = auditDocument(before)
+	audit.After = auditDocument(after)
+	audit.Changes = auditChanges(audit.Before, audit.After)
+	audit.Created_at = time.Now()
+
+	if _, err := s.repos.Audits.InsertOne(ctx, audit); err != nil {
+		logger.FromContext(ctx).Error("audit record failed", "entity", entity, "entity_id", entityId, "action", action, "error", err)
+	}
+}
+
+// AuditService reads the audit trail.
+type AuditService struct {
+	*base
+}
+
+// AuditQuery selects audit entries; fields left empty match every entry.
+type AuditQuery struct {
+	Entity    string
+	Entity_id string
+	User_id   string
+	Action    string
+	Period    Period
+}
+
+// List returns the page of the entries matching query, newest first. uid must be a MANAGER or
+// ADMIN: entries hold the documents before and after each change.
+func (s *AuditService) List(ctx context.Context, uid string, query AuditQuery, page Page) ([]models.Audit, error) {
+	if err := s.requireManager(ctx, uid, "reading the audit log"); err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{}
+	for key, value := range map[string]string{"entity": query.Entity, "entity_id": query.Entity_id, "user_id": query.User_id, "action": query.Action} {
+		if value != "" {
+			filter[key] = value
+		}
+	}
+	if created := query.Period.filter(); created != nil {
+		filter["created_at"] = created
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{"created_at", -1}}).
+		SetSkip(int64(page.Offset)).
+		SetLimit(int64(page.Limit))
+
+	result, err := s.repos.Audits.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close(ctx)
+
+	allAudits := []models.Audit{}
+	if err := result.All(ctx, &allAudits); err != nil {
+		return nil, err
+	}
+	return allAudits, nil
+}
+
+// Snapshot loads the current state of a document so it can be recorded as the before or after of a mutation.
+func Snapshot(ctx context.Context, collection database.Collection, filter interface{}) bson.M {
+	var document bson.M
+	if err := collection.FindOne(ctx, filter).Decode(&document); err != nil {
+		return nil
+	}
+	return document
+}
+
+func auditDocument(value interface{}) bson.M {
+	if value == nil || reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil() {
+		return nil
+	}
+
+	document, ok := value.(bson.M)
+	if !ok {
+		data, err := bson.Marshal(value)
+		if err != nil {
+			return nil
+		}
+		if err := bson.Unmarshal(data, &document); err != nil {
+			return nil
+		}
+	}
+
+	for _, field := range auditHiddenFields {
+		delete(document, field)
+	}
+	return document
+}
+
+func auditChanges(before bson.M, after bson.M) []models.AuditChange {
+	if before == nil || after == nil {
+		return nil
+	}
+
+	fields := map[string]bool{}
+	for field := range before {
+		fields[field] = true
+	}
+	for field := range after {
+		fields[field] = true
+	}
+	delete(fields, "_id")
+
+	changes := []models.AuditChange{}
+	for field := range fields {
+		if !reflect.DeepEqual(before[field], after[field]) {
+			changes = append(changes, models.AuditChange{Field: field, Before: before[field], After: after[field]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/models"
+)
+
+// promotionLine is a single unit of food on an order, as seen by the promotion engine.
+type promotionLine struct {
+	Order_item_id string
+	Food_id       string
+	Menu_id       string
+	Price         float64
+}
+
+// applyPromotions evaluates the promotions against the order lines at the given time and returns
+// the promotions that applied together with the total discount, which never exceeds the subtotal.
+// Coupon-only promotions are applied only when the coupon references them. Happy hours are read
+// on the clock of loc.
+func applyPromotions(lines []promotionLine, promotions []models.Promotion, coupon *models.Coupon, at time.Time, loc *time.Location) ([]models.AppliedPromotion, float64) {
+	var subtotal float64
+	for _, line := range lines {
+		subtotal += line.Price
+	}
+
+	applied := []models.AppliedPromotion{}
+	var total float64
+
+	for _, promotion := range promotions {
+		viaCoupon := coupon != nil && coupon.Promotion_id != nil && *coupon.Promotion_id == promotion.Promotion_id
+		if promotion.Coupon_only != nil && *promotion.Coupon_only && !viaCoupon {
+			continue
+		}
+		if !promotionIsActive(promotion, at, loc) {
+			continue
+		}
+
+		discount := promotionDiscount(promotion, eligibleLines(promotion, lines))
+		discount = math.Min(discount, subtotal-total)
+		discount = math.Round(discount*100) / 100
+		if discount <= 0 {
+			continue
+		}
+		total += discount
+
+		appliedPromotion := models.AppliedPromotion{
+			Promotion_id: promotion.Promotion_id,
+			Name:         *promotion.Name,
+			Type:         *promotion.Type,
+			Discount:     discount,
+		}
+		if viaCoupon {
+			appliedPromotion.Coupon_code = *coupon.Code
+		}
+		applied = append(applied, appliedPromotion)
+	}
+
+	return applied, math.Round(total*100) / 100
+}
+
+// promotionIsActive reports whether the promotion is enabled, within its date range and,
+// for happy hour promotions, inside its daily time window on the clock of loc.
+func promotionIsActive(promotion models.Promotion, at time.Time, loc *time.Location) bool {
+	if promotion.Active != nil && !*promotion.Active {
+		return false
+	}
+	if promotion.Start_Date != nil && at.Before(*promotion.Start_Date) {
+		return false
+	}
+	if promotion.End_Date != nil && at.After(*promotion.End_Date) {
+		return false
+	}
+	if promotion.Start_time == nil || promotion.End_time == nil {
+		return true
+	}
+
+	start, err := minuteOfDay(*promotion.Start_time)
+	if err != nil {
+		return false
+	}
+	end, err := minuteOfDay(*promotion.End_time)
+	if err != nil {
+		return false
+	}
+
+	// times come back from the store in UTC, the window is local wall-clock time
+	local := at.In(loc)
+	now := local.Hour()*60 + local.Minute()
+	if start <= end {
+		return now >= start && now < end
+	}
+	// the window wraps around midnight, e.g. 22:00-02:00
+	return now >= start || now < end
+}
+
+func eligibleLines(promotion models.Promotion, lines []promotionLine) []promotionLine {
+	var eligible []promotionLine
+	for _, line := range lines {
+		if promotion.Food_id != nil && *promotion.Food_id != "" && *promotion.Food_id != line.Food_id {
+			continue
+		}
+		if promotion.Menu_id != nil && *promotion.Menu_id != "" && *promotion.Menu_id != line.Menu_id {
+			continue
+		}
+		eligible = append(eligible, line)
+	}
+	return eligible
+}
+
+func promotionDiscount(promotion models.Promotion, lines []promotionLine) float64 {
+	if len(lines) == 0 {
+		return 0
+	}
+
+	var base float64
+	for _, line := range lines {
+		base += line.Price
+	}
+
+	value := 0.0
+	if promotion.Value != nil {
+		value = *promotion.Value
+	}
+
+	switch *promotion.Type {
+	case "PERCENTAGE":
+		return base * math.Min(value, 100) / 100
+	case "FIXED":
+		return math.Min(value, base)
+	case "BUY_X_GET_Y":
+		buy, get := 1, 1
+		if promotion.Buy_quantity != nil {
+			buy = *promotion.Buy_quantity
+		}
+		if promotion.Get_quantity != nil {
+			get = *promotion.Get_quantity
+		}
+
+		// the cheapest units of every complete buy+get group are free
+		prices := make([]float64, 0, len(lines))
+		for _, line := range lines {
+			prices = append(prices, line.Price)
+		}
+		sort.Float64s(prices)
+
+		free := len(prices) / (buy + get) * get
+		var discount float64
+		for _, price := range prices[:free] {
+			discount += price
+		}
+		return discount
+	}
+	return 0
+}
+
+func minuteOfDay(clock string) (int, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, err
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Kind classifies an Error, so each transport can report it in its own way.
+type Kind int
+
+const (
+	// Invalid means the request breaks a business rule, e.g. it refers to a table that doesn't exist.
+	Invalid Kind = iota + 1
+	// NotFound means the resource the operation is about doesn't exist.
+	NotFound
+	// Conflict means the resource isn't in a state that allows the operation.
+	Conflict
+	// Unauthorized means the caller's credentials are wrong.
+	Unauthorized
+	// Forbidden means the credentials are right but don't allow the operation.
+	Forbidden
+	// Stale means the resource changed since the version the caller read.
+	Stale
+	// Throttled means the caller has to wait RetryAfter before trying again.
+	Throttled
+)
+
+// Error is a business rule the operation ran into. Its message can be shown to users.
+type Error struct {
+	Kind    Kind
+	Message string
+	// RetryAfter is set on Throttled errors.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is matches the Err sentinels by kind, so errors.Is(err, ErrNotFound) holds for every NotFound error.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Kind == e.Kind
+}
+
+var (
+	ErrInvalid      = &Error{Kind: Invalid, Message: "invalid"}
+	ErrNotFound     = &Error{Kind: NotFound, Message: "not found"}
+	ErrConflict     = &Error{Kind: Conflict, Message: "conflict"}
+	ErrUnauthorized = &Error{Kind: Unauthorized, Message: "unauthorized"}
+	ErrForbidden    = &Error{Kind: Forbidden, Message: "forbidden"}
+	ErrStale        = &Error{Kind: Stale, Message: "stale"}
+	ErrThrottled    = &Error{Kind: Throttled, Message: "throttled"}
+)
+
+func errorf(kind Kind, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// lookupFailed reports a FindOne that matched nothing as entity not being found, and passes any
+// other failure on.
+func lookupFailed(err error, entity string) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return errorf(NotFound, "%s was not found", entity)
+	}
+	return err
+}
+
+// writeFailed reports a write rejected by a unique index as a conflict, and passes any other
+// failure on. The unique indexes are created by the migrate command.
+func writeFailed(err error, conflict string) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return errorf(Conflict, "%s", conflict)
+	}
+	return err
+}
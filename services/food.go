@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	"github.com/mayankr5/v1/restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// FoodService adds foods to menus and keeps the history of their prices.
+type FoodService struct {
+	*base
+}
+
+// Create adds the food to its menu, with its price rounded to cents as the first price in its history.
+func (s *FoodService) Create(ctx context.Context, uid string, food models.Food) (models.Food, error) {
+	var menu models.Menu
+
+	err := s.repos.Menus.FindOne(ctx, bson.M{"menu_id": food.Menu_id, "deleted_at": nil}).Decode(&menu)
+	if err != nil {
+		return food, errorf(Invalid, "menu was not found")
+	}
+
+	food.Created_at = time.Now()
+	food.Updated_at = time.Now()
+	food.ID = primitive.NewObjectID()
+	food.Version = 1
+	food.Food_id = food.ID.Hex()
+	food.Deleted_at = nil
+	price := toFixed(*food.Price, 2)
+	food.Price = &price
+	food.Price_history = []models.PriceChange{{Price: price, Effective_from: food.Created_at, Changed_by: uid}}
+
+	if _, err := s.repos.Foods.InsertOne(ctx, food); err != nil {
+		return food, err
+	}
+	s.recordAudit(ctx, uid, "food", food.Food_id, "CREATE", nil, food)
+	return food, nil
+}
+
+// Update changes the food. A new price is added to its history; orders already placed keep the
+// price they were placed at.
+func (s *FoodService) Update(ctx context.Context, uid string, foodId string, expect Precondition, change dto.UpdateFoodRequest) (*mongo.UpdateResult, int, error) {
+	var updateObj primitive.D
+
+	if change.Name != nil {
+		updateObj = append(updateObj, bson.E{"name", change.Name})
+	}
+
+	if change.Price != nil {
+		var existing models.Food
+		price := toFixed(*change.Price, 2)
+
+		err := s.repos.Foods.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&existing)
+		if err == nil && (existing.Price == nil || *existing.Price != price) {
+			history := priceHistoryWith(existing, price, time.Now(), uid)
+			updateObj = append(updateObj, bson.E{"price_history", history})
+		}
+		updateObj = append(updateObj, bson.E{"price", price})
+	}
+
+	if change.Food_image != nil {
+		updateObj = append(updateObj, bson.E{"food_image", change.Food_image})
+	}
+
+	if change.Menu_id != nil {
+		var menu models.Menu
+		err := s.repos.Menus.FindOne(ctx, bson.M{"menu_id": change.Menu_id, "deleted_at": nil}).Decode(&menu)
+		if err != nil {
+			return nil, 0, errorf(Invalid, "menu was not found")
+		}
+		updateObj = append(updateObj, bson.E{"menu_id", change.Menu_id})
+	}
+
+	updateObj = append(updateObj, bson.E{"updated_at", time.Now()})
+
+	return s.updateVersioned(ctx, uid, s.repos.Foods, "food", "food_id", foodId, expect, updateObj)
+}
+
+// List returns the number of foods and the page of them, without the deleted ones unless
+// includeDeleted is set.
+func (s *FoodService) List(ctx context.Context, page Page, includeDeleted bool) (int, []models.Food, error) {
+	result, err := s.repos.Foods.Aggregate(ctx, pagePipeline(listFilter(includeDeleted), page, "food_items"))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer result.Close(ctx)
+
+	var pages []struct {
+		Total_count int
+		Food_items  []models.Food
+	}
+	if err := result.All(ctx, &pages); err != nil {
+		return 0, nil, err
+	}
+	if len(pages) == 0 {
+		return 0, []models.Food{}, nil
+	}
+	return pages[0].Total_count, pages[0].Food_items, nil
+}
+
+func (s *FoodService) Get(ctx context.Context, foodId string) (models.Food, error) {
+	var food models.Food
+
+	err := s.repos.Foods.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food)
+	return food, lookupFailed(err, "food")
+}
+
+// Prices returns the food's price history, oldest first.
+func (s *FoodService) Prices(ctx context.Context, foodId string) ([]models.PriceChange, error) {
+	food, err := s.Get(ctx, foodId)
+	if err != nil {
+		return nil, err
+	}
+	if food.Price_history == nil {
+		return []models.PriceChange{}, nil
+	}
+	return food.Price_history, nil
+}
+
+// Delete soft-deletes the food; orders keep the name and price they were placed with.
+func (s *FoodService) Delete(ctx context.Context, uid string, foodId string) (*mongo.UpdateResult, error) {
+	return s.archive(ctx, uid, s.repos.Foods, "food", "food_id", foodId)
+}
+
+func (s *FoodService) Restore(ctx context.Context, uid string, foodId string) (*mongo.UpdateResult, error) {
+	return s.unarchive(ctx, uid, s.repos.Foods, "food", "food_id", foodId)
+}
@@ -0,0 +1,421 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	"github.com/mayankr5/v1/restaurant-management/logger"
+	"github.com/mayankr5/v1/restaurant-management/metrics"
+	"github.com/mayankr5/v1/restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// InvoiceService bills orders, takes payments and refunds them.
+type InvoiceService struct {
+	*base
+	users      *UserService
+	promotions *PromotionService
+}
+
+func (s *InvoiceService) List(ctx context.Context) ([]models.Invoice, error) {
+	result, err := s.repos.Invoices.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close(ctx)
+
+	var allInvoices []models.Invoice
+	if err := result.All(ctx, &allInvoices); err != nil {
+		return nil, err
+	}
+	return allInvoices, nil
+}
+
+// Get returns the invoice together with the lines of its order.
+func (s *InvoiceService) Get(ctx context.Context, invoiceId string) (dto.InvoiceViewFormat, error) {
+	var invoice models.Invoice
+
+	err := s.repos.Invoices.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice)
+	if err != nil {
+		return dto.InvoiceViewFormat{}, lookupFailed(err, "invoice")
+	}
+
+	allOrderItems, err := s.itemsByOrder(ctx, invoice.Order_id)
+	if err != nil {
+		return dto.InvoiceViewFormat{}, fmt.Errorf("listing the invoice lines: %w", err)
+	}
+	return dto.NewInvoiceViewFormat(invoice, allOrderItems), nil
+}
+
+// Create bills the order. The active promotions, and the one behind the coupon if any, are
+// applied; a coupon that doesn't apply to the order is Invalid.
+func (s *InvoiceService) Create(ctx context.Context, uid string, invoice models.Invoice) (models.Invoice, error) {
+	var order models.Order
+
+	err := s.repos.Orders.FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order)
+	if err != nil {
+		return invoice, errorf(Invalid, "order was not found")
+	}
+	status := "PENDING"
+	if invoice.Payment_status == nil {
+		invoice.Payment_status = &status
+	}
+
+	var coupon *models.Coupon
+	if invoice.Coupon_code != nil && *invoice.Coupon_code != "" {
+		coupon, err = s.promotions.findCoupon(ctx, *invoice.Coupon_code)
+		if err != nil {
+			return invoice, err
+		}
+		invoice.Coupon_code = coupon.Code
+	} else {
+		invoice.Coupon_code = nil
+	}
+
+	subtotal, applied, discount, err := s.priceOrder(ctx, order, coupon)
+	if err != nil {
+		return invoice, fmt.Errorf("pricing the order: %w", err)
+	}
+
+	couponApplied := false
+	for _, promotion := range applied {
+		if promotion.Coupon_code != "" {
+			couponApplied = true
+		}
+	}
+	if coupon != nil && !couponApplied {
+		return invoice, errorf(Invalid, "coupon does not apply to this order")
+	}
+
+	invoice.Subtotal = subtotal
+	invoice.Discount_total = discount
+	invoice.Total = toFixed(subtotal-discount, 2)
+	invoice.Applied_promotions = applied
+
+	invoice.Payment_due_date = time.Now().AddDate(0, 0, 1)
+	invoice.Created_at = time.Now()
+	invoice.Updated_at = time.Now()
+	invoice.Paid_at = nil
+	if *invoice.Payment_status == "PAID" {
+		invoice.Paid_at = &invoice.Created_at
+	}
+	invoice.ID = primitive.NewObjectID()
+	invoice.Version = 1
+	invoice.Invoice_id = invoice.ID.Hex()
+
+	if coupon != nil {
+		redeemed, err := s.promotions.redeemCoupon(ctx, *coupon, uid)
+		if err != nil {
+			return invoice, fmt.Errorf("redeeming the coupon: %w", err)
+		}
+		if !redeemed {
+			return invoice, errorf(Invalid, "coupon usage limit has been reached")
+		}
+	}
+
+	if _, err := s.repos.Invoices.InsertOne(ctx, invoice); err != nil {
+		if coupon != nil {
+			s.promotions.releaseCoupon(ctx, *coupon, uid)
+		}
+		return invoice, err
+	}
+	s.recordAudit(ctx, uid, "invoice", invoice.Invoice_id, "CREATE", nil, invoice)
+	if *invoice.Payment_status == "PAID" {
+		metrics.InvoicePaid(stringValue(invoice.Payment_method), invoice.Total)
+	}
+	return invoice, nil
+}
+
+// Update records the payment method and whether the invoice is paid. Paid invoices are only
+// reversed by a refund, which goes back to the method they were paid with, so the method is fixed
+// once the invoice is paid.
+func (s *InvoiceService) Update(ctx context.Context, uid string, invoiceId string, expect Precondition, change dto.UpdateInvoiceRequest) (*mongo.UpdateResult, int, error) {
+	if change.Payment_status != nil && *change.Payment_status != "PENDING" && *change.Payment_status != "PAID" {
+		return nil, 0, errorf(Invalid, "invoices are refunded through the refund endpoint")
+	}
+
+	var existing models.Invoice
+	if change.Payment_status != nil || change.Payment_method != nil {
+		err := s.repos.Invoices.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&existing)
+		if err != nil {
+			return nil, 0, lookupFailed(err, "invoice")
+		}
+	}
+	settled := existing.Payment_status != nil && *existing.Payment_status != "PENDING"
+	if change.Payment_status != nil && settled && *existing.Payment_status != *change.Payment_status {
+		return nil, 0, errorf(Conflict, "a paid invoice can only be reversed with a refund")
+	}
+	if change.Payment_method != nil && settled && stringValue(existing.Payment_method) != *change.Payment_method {
+		return nil, 0, errorf(Conflict, "the payment method of a paid invoice can't be changed")
+	}
+	paying := change.Payment_status != nil && *change.Payment_status == "PAID" && !settled
+
+	var updateObj primitive.D
+
+	if change.Payment_method != nil {
+		updateObj = append(updateObj, bson.E{"payment_method", change.Payment_method})
+	}
+
+	if change.Payment_status != nil {
+		updateObj = append(updateObj, bson.E{"payment_status", change.Payment_status})
+	}
+
+	now := time.Now()
+	// the sales report counts an invoice in the period it was paid in
+	if paying {
+		updateObj = append(updateObj, bson.E{"paid_at", now})
+	}
+
+	updateObj = append(updateObj, bson.E{"updated_at", now})
+
+	result, version, err := s.updateVersioned(ctx, uid, s.repos.Invoices, "invoice", "invoice_id", invoiceId, expect, updateObj)
+	if err != nil {
+		return result, version, err
+	}
+	if paying {
+		method := existing.Payment_method
+		if change.Payment_method != nil {
+			method = change.Payment_method
+		}
+		metrics.InvoicePaid(stringValue(method), existing.Total)
+	}
+	return result, version, nil
+}
+
+// Refund pays back the given items of a paid invoice, or all that is left of it when no items
+// are given, with a manager's approval. Lines are refunded at their share of the invoice total,
+// so discounts are refunded proportionally.
+func (s *InvoiceService) Refund(ctx context.Context, uid string, invoiceId string, reason string, orderItemIds []string, approval Approval) (models.Refund, error) {
+	var refund models.Refund
+	var invoice models.Invoice
+
+	err := s.repos.Invoices.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice)
+	if err != nil {
+		return refund, lookupFailed(err, "invoice")
+	}
+
+	if invoice.Payment_status == nil || (*invoice.Payment_status != "PAID" && *invoice.Payment_status != "PARTIALLY_REFUNDED") {
+		return refund, errorf(Conflict, "only paid invoices can be refunded")
+	}
+
+	if invoice.Payment_method == nil || *invoice.Payment_method == "" {
+		return refund, errorf(Conflict, "invoice has no payment method to refund to")
+	}
+
+	manager, err := s.users.AuthorizeManager(ctx, approval)
+	if err != nil {
+		return refund, err
+	}
+
+	lines, err := s.orderPromotionLines(ctx, invoice.Order_id)
+	if err != nil {
+		return refund, fmt.Errorf("listing the invoice lines: %w", err)
+	}
+
+	result, err := s.repos.OrderItems.Find(ctx, bson.M{"order_id": invoice.Order_id, "status": bson.M{"$nin": bson.A{"VOIDED", "REFUNDED"}}})
+	if err != nil {
+		return refund, fmt.Errorf("listing the invoice lines: %w", err)
+	}
+	defer result.Close(ctx)
+
+	var refundable []models.OrderItem
+	if err := result.All(ctx, &refundable); err != nil {
+		return refund, fmt.Errorf("listing the invoice lines: %w", err)
+	}
+
+	refundableIds := map[string]bool{}
+	for _, orderItem := range refundable {
+		refundableIds[orderItem.Order_item_id] = true
+	}
+
+	remaining := toFixed(invoice.Total-invoice.Refunded_amount, 2)
+	amount := remaining
+
+	if len(orderItemIds) == 0 {
+		for id := range refundableIds {
+			orderItemIds = append(orderItemIds, id)
+		}
+	} else {
+		ratio := 0.0
+		if invoice.Subtotal > 0 {
+			ratio = invoice.Total / invoice.Subtotal
+		}
+
+		// an id given twice is refunded once
+		requested := map[string]bool{}
+		unique := []string{}
+		for _, id := range orderItemIds {
+			if !refundableIds[id] {
+				return refund, errorf(Conflict, "order item %s is not refundable", id)
+			}
+			if !requested[id] {
+				requested[id] = true
+				unique = append(unique, id)
+			}
+		}
+		orderItemIds = unique
+
+		var linesTotal float64
+		for _, line := range lines {
+			if requested[line.Order_item_id] {
+				linesTotal += line.Price
+			}
+		}
+		amount = toFixed(math.Min(linesTotal*ratio, remaining), 2)
+	}
+
+	if amount <= 0 {
+		return refund, errorf(Conflict, "nothing left to refund on this invoice")
+	}
+
+	status := "PARTIALLY_REFUNDED"
+	if amount >= remaining || len(orderItemIds) == len(refundableIds) {
+		status = "REFUNDED"
+	}
+
+	refund.ID = primitive.NewObjectID()
+	refund.Refund_id = refund.ID.Hex()
+	refund.Invoice_id = invoice.Invoice_id
+	refund.Order_id = invoice.Order_id
+	refund.Order_item_ids = orderItemIds
+	refund.Amount = amount
+	refund.Payment_method = *invoice.Payment_method
+	refund.Reason = reason
+	refund.Refunded_by = uid
+	refund.Approved_by = manager.User_id
+	refund.Created_at = time.Now()
+
+	// the invoice, its items and the refund record change together or not at all
+	err = s.repos.WithTransaction(ctx, func(ctx context.Context) error {
+		updateResult, err := s.repos.Invoices.UpdateOne(
+			ctx,
+			bson.M{"invoice_id": invoiceId, "refunded_amount": invoice.Refunded_amount},
+			bson.D{
+				{"$inc", bson.D{{"refunded_amount", amount}, {"version", 1}}},
+				{"$set", bson.D{{"payment_status", status}, {"updated_at", refund.Created_at}}},
+			},
+		)
+		if err != nil {
+			return err
+		}
+		if updateResult.MatchedCount == 0 {
+			return errorf(Conflict, "invoice was refunded concurrently, retry the refund")
+		}
+
+		itemsResult, err := s.repos.OrderItems.UpdateMany(
+			ctx,
+			bson.M{"order_item_id": bson.M{"$in": orderItemIds}, "status": bson.M{"$nin": bson.A{"VOIDED", "REFUNDED"}}},
+			bson.D{
+				{"$set", bson.D{{"status", "REFUNDED"}, {"updated_at", refund.Created_at}}},
+				{"$inc", bson.D{{"version", 1}}},
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("marking the order items as refunded: %w", err)
+		}
+		if itemsResult.MatchedCount != int64(len(orderItemIds)) {
+			return errorf(Conflict, "order items were voided or refunded concurrently, retry the refund")
+		}
+
+		if _, err := s.repos.Refunds.InsertOne(ctx, refund); err != nil {
+			return fmt.Errorf("recording the refund: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Refund{}, err
+	}
+
+	s.recordAudit(ctx, uid, "invoice", invoiceId, "REFUND", invoice, Snapshot(ctx, s.repos.Invoices, bson.M{"invoice_id": invoiceId}))
+	for _, id := range orderItemIds {
+		s.recordAudit(ctx, uid, "orderItem", id, "REFUND", nil, Snapshot(ctx, s.repos.OrderItems, bson.M{"order_item_id": id}))
+	}
+	logger.FromContext(ctx).Info("invoice refunded", "invoice_id", invoiceId, "refund_id", refund.Refund_id, "amount", amount, "approved_by", manager.User_id)
+	s.recordAudit(ctx, uid, "refund", refund.Refund_id, "CREATE", nil, refund)
+	metrics.Refunded(refund.Payment_method, amount)
+	return refund, nil
+}
+
+// Refunds returns the refunds of the invoice.
+func (s *InvoiceService) Refunds(ctx context.Context, invoiceId string) ([]models.Refund, error) {
+	result, err := s.repos.Refunds.Find(ctx, bson.M{"invoice_id": invoiceId})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close(ctx)
+
+	var allRefunds []models.Refund
+	if err := result.All(ctx, &allRefunds); err != nil {
+		return nil, err
+	}
+	return allRefunds, nil
+}
+
+// Reprice recomputes the totals of the order's pending invoices, e.g. after an item was voided.
+func (s *InvoiceService) Reprice(ctx context.Context, uid string, orderId string) error {
+	var order models.Order
+	if err := s.repos.Orders.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		return lookupFailed(err, "order")
+	}
+
+	result, err := s.repos.Invoices.Find(ctx, bson.M{"order_id": orderId, "payment_status": "PENDING"})
+	if err != nil {
+		return err
+	}
+	defer result.Close(ctx)
+
+	var invoices []models.Invoice
+	if err := result.All(ctx, &invoices); err != nil {
+		return err
+	}
+
+	for _, invoice := range invoices {
+		var coupon *models.Coupon
+		if invoice.Coupon_code != nil {
+			var found models.Coupon
+			if err := s.repos.Coupons.FindOne(ctx, bson.M{"code": *invoice.Coupon_code}).Decode(&found); err == nil {
+				coupon = &found
+			}
+		}
+
+		subtotal, applied, discount, err := s.priceOrder(ctx, order, coupon)
+		if err != nil {
+			return err
+		}
+
+		filter := bson.M{"invoice_id": invoice.Invoice_id}
+
+		_, err = s.repos.Invoices.UpdateOne(
+			ctx,
+			filter,
+			bson.D{
+				{"$set", bson.D{
+					{"subtotal", subtotal},
+					{"discount_total", discount},
+					{"total", toFixed(subtotal-discount, 2)},
+					{"applied_promotions", applied},
+					{"updated_at", time.Now()},
+				}},
+				{"$inc", bson.D{{"version", 1}}},
+			},
+		)
+		if err != nil {
+			return err
+		}
+		s.recordAudit(ctx, uid, "invoice", invoice.Invoice_id, "UPDATE", invoice, Snapshot(ctx, s.repos.Invoices, filter))
+	}
+	return nil
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
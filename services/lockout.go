@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/logger"
+	"github.com/mayankr5/v1/restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LoginPolicy is how logins are secured. Failures are counted per email, whether or not it has an
+// account, and per client address; failed PIN logins and second factors per user.
+type LoginPolicy struct {
+	// BackoffAfter is the number of failures for an email after which each further attempt has to wait.
+	BackoffAfter int
+	// Backoff is the first wait, doubled with every further failure up to Lockout.
+	Backoff time.Duration
+	// LockoutAfter is the number of failures that lock an email out until Lockout has passed or a
+	// manager unlocks it.
+	LockoutAfter int
+	Lockout      time.Duration
+	// IPLockoutAfter is the number of failures from one address, over all emails, that lock the
+	// address out for Lockout.
+	IPLockoutAfter int
+	// Window is how long failures are remembered after the last one.
+	Window time.Duration
+	// MfaRoles are the roles whose users need a second factor to log in, and can't turn it off.
+	// Users of other roles may enable it themselves.
+	MfaRoles []string
+}
+
+// DefaultLoginPolicy is the policy used for settings that aren't given.
+func DefaultLoginPolicy() LoginPolicy {
+	return LoginPolicy{
+		BackoffAfter:   3,
+		Backoff:        time.Second,
+		LockoutAfter:   10,
+		Lockout:        15 * time.Minute,
+		IPLockoutAfter: 100,
+		Window:         time.Hour,
+	}
+}
+
+// withDefaults returns the policy with the settings it leaves out taken from DefaultLoginPolicy.
+func (p LoginPolicy) withDefaults() LoginPolicy {
+	defaults := DefaultLoginPolicy()
+	if p.BackoffAfter <= 0 {
+		p.BackoffAfter = defaults.BackoffAfter
+	}
+	if p.Backoff <= 0 {
+		p.Backoff = defaults.Backoff
+	}
+	if p.LockoutAfter <= 0 {
+		p.LockoutAfter = defaults.LockoutAfter
+	}
+	if p.Lockout <= 0 {
+		p.Lockout = defaults.Lockout
+	}
+	if p.IPLockoutAfter <= 0 {
+		p.IPLockoutAfter = defaults.IPLockoutAfter
+	}
+	if p.Window <= 0 {
+		p.Window = defaults.Window
+	}
+	return p
+}
+
+// requiresMfa tells whether users with the role need a second factor.
+func (p LoginPolicy) requiresMfa(role string) bool {
+	for _, required := range p.MfaRoles {
+		if strings.EqualFold(required, role) {
+			return true
+		}
+	}
+	return false
+}
+
+func emailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// mfaKey counts the wrong second factors of a user.
+func mfaKey(userId string) string {
+	return "mfa:" + userId
+}
+
+// pinKey counts the failed PIN logins of a user, apart from its email logins.
+func pinKey(userId string) string {
+	return "pin:" + userId
+}
+
+// loginKeys are the keys failures of a login are counted under. Logins that don't come from a
+// client address, e.g. from tests, are only counted per email.
+func loginKeys(email string, ip string) []string {
+	keys := []string{emailKey(email)}
+	if ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+	return keys
+}
+
+// resetKeys are the keys password reset requests are counted under: per email and per client
+// address like logins, but apart from them, so asking for resets doesn't lock anyone out.
+func resetKeys(email string, ip string) []string {
+	keys := loginKeys(email, ip)
+	for i := range keys {
+		keys[i] += "/reset"
+	}
+	return keys
+}
+
+// checkThrottle returns a Throttled error while any of the keys has to wait. The message is the
+// same for every key, so it doesn't tell whether an email has an account.
+func (s *base) checkThrottle(ctx context.Context, keys ...string) error {
+	now := time.Now()
+	for _, key := range keys {
+		attempt, err := s.loadAttempt(ctx, key, now)
+		if err != nil {
+			return err
+		}
+
+		var until time.Time
+		if attempt.Retry_at != nil {
+			until = *attempt.Retry_at
+		}
+		if attempt.Locked_until != nil && attempt.Locked_until.After(until) {
+			until = *attempt.Locked_until
+		}
+		if now.Before(until) {
+			return &Error{Kind: Throttled, Message: "too many failed logins, try again later", RetryAfter: until.Sub(now)}
+		}
+	}
+	return nil
+}
+
+// loadAttempt returns the failures counted under key, or none when they are older than the window.
+func (s *base) loadAttempt(ctx context.Context, key string, now time.Time) (models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+
+	err := s.repos.LoginAttempts.FindOne(ctx, bson.M{"key": key}).Decode(&attempt)
+	if errors.Is(err, mongo.ErrNoDocuments) || err == nil && !now.Before(attempt.Expires_at) {
+		return models.LoginAttempt{Key: key}, nil
+	}
+	return attempt, err
+}
+
+// recordFailure counts a failed login under key and sets the wait or lock it leads to. locked
+// tells whether this failure locked the key.
+func (s *base) recordFailure(ctx context.Context, policy LoginPolicy, key string) (attempt models.LoginAttempt, locked bool, err error) {
+	now := time.Now()
+
+	// Mongo removes expired records only once a minute
+	if _, err := s.repos.LoginAttempts.DeleteOne(ctx, bson.M{"key": key, "expires_at": bson.M{"$lte": now}}); err != nil {
+		return attempt, false, err
+	}
+
+	_, err = s.repos.LoginAttempts.UpdateOne(
+		ctx,
+		bson.M{"key": key},
+		bson.D{
+			{"$inc", bson.D{{"failures", 1}}},
+			{"$set", bson.D{{"last_failure_at", now}, {"expires_at", now.Add(policy.Window)}}},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return attempt, false, err
+	}
+	if err := s.repos.LoginAttempts.FindOne(ctx, bson.M{"key": key}).Decode(&attempt); err != nil {
+		return attempt, false, err
+	}
+
+	lockAfter := policy.LockoutAfter
+	if strings.HasPrefix(key, "ip:") {
+		lockAfter = policy.IPLockoutAfter
+	}
+
+	update := bson.D{}
+	switch {
+	case attempt.Failures >= lockAfter:
+		until := now.Add(policy.Lockout)
+		attempt.Locked_until = &until
+		locked = true
+		update = append(update, bson.E{"locked_until", until})
+		// the lock outlives the window when the window is the shorter of the two
+		if until.After(attempt.Expires_at) {
+			update = append(update, bson.E{"expires_at", until})
+		}
+	case attempt.Failures >= policy.BackoffAfter && !strings.HasPrefix(key, "ip:"):
+		wait := policy.Backoff
+		for i := policy.BackoffAfter; i < attempt.Failures && wait < policy.Lockout; i++ {
+			wait *= 2
+		}
+		if wait > policy.Lockout {
+			wait = policy.Lockout
+		}
+		retryAt := now.Add(wait)
+		attempt.Retry_at = &retryAt
+		update = append(update, bson.E{"retry_at", retryAt})
+	}
+
+	if len(update) > 0 {
+		if _, err := s.repos.LoginAttempts.UpdateOne(ctx, bson.M{"key": key}, bson.D{{"$set", update}}); err != nil {
+			return attempt, locked, err
+		}
+	}
+	return attempt, locked, nil
+}
+
+// loginFailed counts a failed login under each of the keys, and writes it as action and any lock
+// it leads to into the audit log, together with event. userId is empty when the login named no
+// known user. err, the reason the login failed, is returned as it is; counting failures can't
+// change the answer.
+func (s *base) loginFailed(ctx context.Context, action string, userId string, event bson.M, keys []string, err error) error {
+	policy := s.policy
+
+	for i, key := range keys {
+		attempt, locked, recordErr := s.recordFailure(ctx, policy, key)
+		if recordErr != nil {
+			logger.FromContext(ctx).Error("counting a failed login failed", "key", key, "error", recordErr)
+			continue
+		}
+		if i == 0 {
+			event["failures"] = attempt.Failures
+		}
+		if !locked {
+			continue
+		}
+
+		lock := bson.M{"key": key, "failures": attempt.Failures, "locked_until": attempt.Locked_until}
+		if ip, ok := strings.CutPrefix(key, "ip:"); ok {
+			logger.FromContext(ctx).Warn("address locked", "failures", attempt.Failures)
+			s.recordAudit(ctx, "", "ip", ip, "IP_LOCKED", nil, lock)
+		} else {
+			logger.FromContext(ctx).Warn("account locked", "user_id", userId, "key", key, "failures", attempt.Failures)
+			s.recordAudit(ctx, "", "user", userId, "ACCOUNT_LOCKED", nil, lock)
+		}
+	}
+	s.recordAudit(ctx, "", "user", userId, action, nil, event)
+	return err
+}
+
+// clearFailures forgets the failed logins counted under key, after it was used to sign in or the
+// user's password was reset. Failures from the address are never cleared this way, so one working
+// account doesn't unlock it.
+func (s *base) clearFailures(ctx context.Context, key string) {
+	if _, err := s.repos.LoginAttempts.DeleteOne(ctx, bson.M{"key": key}); err != nil {
+		logger.FromContext(ctx).Error("clearing failed logins failed", "key", key, "error", err)
+	}
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	"github.com/mayankr5/v1/restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MenuService creates and changes menus.
+type MenuService struct {
+	*base
+}
+
+func (s *MenuService) Create(ctx context.Context, uid string, menu models.Menu) (models.Menu, error) {
+	menu.Created_at = time.Now()
+	menu.Updated_at = time.Now()
+	menu.ID = primitive.NewObjectID()
+	menu.Version = 1
+	menu.Menu_id = menu.ID.Hex()
+	menu.Deleted_at = nil
+
+	if _, err := s.repos.Menus.InsertOne(ctx, menu); err != nil {
+		return menu, err
+	}
+	s.recordAudit(ctx, uid, "menu", menu.Menu_id, "CREATE", nil, menu)
+	return menu, nil
+}
+
+// Update renames or recategorizes the menu. Its dates only change together, and must lie ahead
+// in the right order.
+func (s *MenuService) Update(ctx context.Context, uid string, menuId string, expect Precondition, change dto.UpdateMenuRequest) (*mongo.UpdateResult, int, error) {
+	var updateObj primitive.D
+
+	if change.Start_date != nil && change.End_date != nil {
+		if !inTimeSpan(*change.Start_date, *change.End_date, time.Now()) {
+			return nil, 0, errorf(Invalid, "kindly retype the time")
+		}
+
+		updateObj = append(updateObj, bson.E{"start_date", change.Start_date})
+		updateObj = append(updateObj, bson.E{"end_date", change.End_date})
+	}
+
+	if change.Name != "" {
+		updateObj = append(updateObj, bson.E{"name", change.Name})
+	}
+	if change.Category != "" {
+		updateObj = append(updateObj, bson.E{"category", change.Category})
+	}
+
+	updateObj = append(updateObj, bson.E{"updated_at", time.Now()})
+
+	return s.updateVersioned(ctx, uid, s.repos.Menus, "menu", "menu_id", menuId, expect, updateObj)
+}
+
+func inTimeSpan(start, end, check time.Time) bool {
+	return start.After(check) && end.After(start)
+}
+
+// List returns the menus, without the deleted ones unless includeDeleted is set.
+func (s *MenuService) List(ctx context.Context, includeDeleted bool) ([]models.Menu, error) {
+	result, err := s.repos.Menus.Find(ctx, listFilter(includeDeleted))
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close(ctx)
+
+	var allMenus []models.Menu
+	if err := result.All(ctx, &allMenus); err != nil {
+		return nil, err
+	}
+	return allMenus, nil
+}
+
+func (s *MenuService) Get(ctx context.Context, menuId string) (models.Menu, error) {
+	var menu models.Menu
+
+	err := s.repos.Menus.FindOne(ctx, bson.M{"menu_id": menuId}).Decode(&menu)
+	return menu, lookupFailed(err, "menu")
+}
+
+// Delete soft-deletes the menu. Its foods stay, but no new ones can be added to it.
+func (s *MenuService) Delete(ctx context.Context, uid string, menuId string) (*mongo.UpdateResult, error) {
+	return s.archive(ctx, uid, s.repos.Menus, "menu", "menu_id", menuId)
+}
+
+func (s *MenuService) Restore(ctx context.Context, uid string, menuId string) (*mongo.UpdateResult, error) {
+	return s.unarchive(ctx, uid, s.repos.Menus, "menu", "menu_id", menuId)
+}
@@ -0,0 +1,415 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/logger"
+	"github.com/mayankr5/v1/restaurant-management/mailer"
+	"github.com/mayankr5/v1/restaurant-management/models"
+	"github.com/mayankr5/v1/restaurant-management/totp"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	// MfaIssuer is the name the accounts are listed under in authenticator apps.
+	MfaIssuer = "Restaurant"
+	// MfaChallengeTTL is how long a login that passed the password has to send the second factor.
+	MfaChallengeTTL = 5 * time.Minute
+)
+
+// recoveryCodeCount is how many recovery codes a user gets when enabling MFA.
+const recoveryCodeCount = 10
+
+// LoginResult is a signed in user, with their tokens on User, or the Challenge of a login that
+// still needs the second factor.
+type LoginResult struct {
+	User      models.User
+	Challenge *MfaChallenge
+	// Recovery_codes are set on the login that completed an MFA enrollment, the only time they are shown.
+	Recovery_codes []string
+}
+
+// MfaChallenge is what LoginMfa needs besides the code.
+type MfaChallenge struct {
+	Token      string
+	Expires_at time.Time
+	// Enrollment_required is set when the user's role needs MFA and they haven't set it up yet. A
+	// token to set it up was mailed to them; EnrollAtLogin gives them the secret the code has to
+	// come from.
+	Enrollment_required bool
+}
+
+// MfaEnrollment is a TOTP secret waiting for its first code.
+type MfaEnrollment struct {
+	Secret string
+	// Uri is the otpauth:// URI to show as a QR code.
+	Uri string
+}
+
+// needsMfa tells whether the user has to pass a second factor to log in or approve.
+func (s *base) needsMfa(ctx context.Context, user models.User) bool {
+	return user.Mfa_enabled_at != nil || s.policy.requiresMfa(user.Role)
+}
+
+// challenge issues the token a login that passed the password answers with the second factor. A
+// user who has to use MFA but hasn't enabled it is mailed a token to enroll with, so the password
+// alone doesn't get anyone the secret.
+func (s *UserService) challenge(ctx context.Context, user models.User) (*MfaChallenge, error) {
+	var challenge MfaChallenge
+
+	var err error
+	challenge.Token, challenge.Expires_at, err = s.issueToken(ctx, user.User_id, mfaLogin, MfaChallengeTTL)
+	if err != nil {
+		return nil, fmt.Errorf("issuing the MFA challenge: %w", err)
+	}
+
+	if user.Mfa_enabled_at == nil {
+		if err := s.sendEnrollment(ctx, user); err != nil {
+			return nil, err
+		}
+		challenge.Enrollment_required = true
+	}
+	return &challenge, nil
+}
+
+// sendEnrollment mails the user a token to set up MFA with at login. A secret that wasn't
+// confirmed is dropped, so only one fetched with the token can enable MFA.
+func (s *UserService) sendEnrollment(ctx context.Context, user models.User) error {
+	if user.Mfa_secret != "" {
+		_, err := s.repos.Users.UpdateOne(
+			ctx,
+			bson.M{"user_id": user.User_id},
+			bson.D{
+				{"$set", bson.D{{"mfa_secret", ""}, {"updated_at", time.Now()}}},
+				{"$inc", bson.D{{"version", 1}}},
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("dropping the unconfirmed secret: %w", err)
+		}
+	}
+
+	token, expires, err := s.issueToken(ctx, user.User_id, mfaEnroll, MfaChallengeTTL)
+	if err != nil {
+		return fmt.Errorf("issuing the MFA enrollment token: %w", err)
+	}
+
+	// the user can log in again for another mail, so a failed one doesn't fail the login
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Set up two-factor authentication",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"your account needs a second factor from an authenticator app. To set it up, send this\n"+
+			"token with the mfa_token of your login to POST /users/login/mfa/enroll:\n\n"+
+			"%s\n\n"+
+			"It can be used once, until %s. If you didn't just log in, someone knows your\n"+
+			"password: reset it with POST /users/password-reset.\n",
+			user.First_name, token, expires.UTC().Format(time.RFC1123)),
+	})
+	if err != nil {
+		logger.FromContext(ctx).Error("MFA enrollment email was not sent", "user_id", user.User_id, "error", err)
+	}
+	return nil
+}
+
+// EnrollAtLogin gives the user of a login that has to set up MFA a new secret. It takes the
+// login's challenge and the token mailed with it, so it needs both the password and the mailbox.
+// LoginMfa with a code from the secret enables MFA and finishes the login.
+func (s *UserService) EnrollAtLogin(ctx context.Context, mfaToken string, enrollmentToken string) (MfaEnrollment, error) {
+	expired := errorf(Unauthorized, "the login has expired, log in with your password again")
+
+	challenge, err := s.findToken(ctx, mfaToken, mfaLogin)
+	if err != nil {
+		return MfaEnrollment{}, expired
+	}
+	enrollment, err := s.findToken(ctx, enrollmentToken, mfaEnroll)
+	if err != nil || enrollment.User_id != challenge.User_id {
+		return MfaEnrollment{}, errorf(Invalid, "token is invalid or has expired")
+	}
+
+	var user models.User
+	if err := s.repos.Users.FindOne(ctx, bson.M{"user_id": challenge.User_id, "deleted_at": nil}).Decode(&user); err != nil {
+		return MfaEnrollment{}, expired
+	}
+	if user.Mfa_enabled_at != nil {
+		return MfaEnrollment{}, errorf(Conflict, "MFA is already enabled")
+	}
+
+	if _, err := s.redeemToken(ctx, enrollmentToken, mfaEnroll); err != nil {
+		return MfaEnrollment{}, err
+	}
+	if user, err = s.startEnrollment(ctx, user); err != nil {
+		return MfaEnrollment{}, err
+	}
+	return enrollmentOf(user), nil
+}
+
+// LoginMfa finishes a login with the second factor: a code from the user's authenticator app or,
+// once MFA is enabled, one of their recovery codes. A login that enrolls the user, with the secret
+// of EnrollAtLogin, enables MFA and returns their recovery codes. Wrong codes are throttled per user, see LoginPolicy.
+func (s *UserService) LoginMfa(ctx context.Context, mfaToken string, code string, ip string) (LoginResult, error) {
+	var result LoginResult
+	expired := errorf(Unauthorized, "the login has expired, log in with your password again")
+
+	record, err := s.findToken(ctx, mfaToken, mfaLogin)
+	if err != nil {
+		return result, expired
+	}
+
+	var user models.User
+	if err := s.repos.Users.FindOne(ctx, bson.M{"user_id": record.User_id, "deleted_at": nil}).Decode(&user); err != nil {
+		return result, expired
+	}
+
+	enrolling := user.Mfa_enabled_at == nil
+	if enrolling && user.Mfa_secret == "" {
+		return result, errorf(Conflict, "MFA has to be set up first with the token mailed to you")
+	}
+	if err := s.checkSecondFactor(ctx, user, code, !enrolling, bson.M{"ip": ip}); err != nil {
+		return result, err
+	}
+
+	// of two logins answering the same challenge, only one gets tokens
+	if _, err := s.redeemToken(ctx, mfaToken, mfaLogin); err != nil {
+		return result, expired
+	}
+
+	if enrolling {
+		if result.Recovery_codes, err = s.enableMfa(ctx, user); err != nil {
+			return result, err
+		}
+	}
+	result.User, err = s.signIn(ctx, user)
+	return result, err
+}
+
+// EnrollMfa starts setting up MFA for the user with a new secret, after checking their password.
+// ConfirmMfa enables it with the first code.
+func (s *UserService) EnrollMfa(ctx context.Context, uid string, password string) (MfaEnrollment, error) {
+	var user models.User
+
+	if err := s.repos.Users.FindOne(ctx, bson.M{"user_id": uid, "deleted_at": nil}).Decode(&user); err != nil {
+		return MfaEnrollment{}, lookupFailed(err, "user")
+	}
+	if passwordIsValid, msg := VerifyPassword(password, user.Password); !passwordIsValid {
+		return MfaEnrollment{}, errorf(Forbidden, "%s", msg)
+	}
+	if user.Mfa_enabled_at != nil {
+		return MfaEnrollment{}, errorf(Conflict, "MFA is already enabled")
+	}
+
+	user, err := s.startEnrollment(ctx, user)
+	if err != nil {
+		return MfaEnrollment{}, err
+	}
+	return enrollmentOf(user), nil
+}
+
+// ConfirmMfa enables MFA for the user with the first code from the secret of EnrollMfa, and returns
+// their recovery codes. They are not stored and can't be shown again.
+func (s *UserService) ConfirmMfa(ctx context.Context, uid string, code string) ([]string, error) {
+	var user models.User
+
+	if err := s.repos.Users.FindOne(ctx, bson.M{"user_id": uid, "deleted_at": nil}).Decode(&user); err != nil {
+		return nil, lookupFailed(err, "user")
+	}
+	if user.Mfa_enabled_at != nil {
+		return nil, errorf(Conflict, "MFA is already enabled")
+	}
+	if user.Mfa_secret == "" {
+		return nil, errorf(Conflict, "MFA enrollment has not been started")
+	}
+
+	if err := s.checkSecondFactor(ctx, user, code, false, bson.M{}); err != nil {
+		return nil, err
+	}
+	return s.enableMfa(ctx, user)
+}
+
+// DisableMfa turns MFA off for the user, after checking their password and second factor. Users of
+// a role that needs MFA can't.
+func (s *UserService) DisableMfa(ctx context.Context, uid string, password string, code string) error {
+	var user models.User
+
+	filter := bson.M{"user_id": uid, "deleted_at": nil}
+	if err := s.repos.Users.FindOne(ctx, filter).Decode(&user); err != nil {
+		return lookupFailed(err, "user")
+	}
+	if s.policy.requiresMfa(user.Role) {
+		return errorf(Forbidden, "MFA is required for the %s role", user.Role)
+	}
+	if user.Mfa_enabled_at == nil {
+		return errorf(Conflict, "MFA is not enabled")
+	}
+	if passwordIsValid, msg := VerifyPassword(password, user.Password); !passwordIsValid {
+		return errorf(Forbidden, "%s", msg)
+	}
+	if err := s.checkSecondFactor(ctx, user, code, true, bson.M{}); err != nil {
+		return err
+	}
+
+	_, err := s.repos.Users.UpdateOne(
+		ctx,
+		filter,
+		bson.D{
+			{"$set", bson.D{{"mfa_secret", ""}, {"mfa_enabled_at", nil}, {"mfa_last_step", 0}, {"mfa_recovery_codes", nil}, {"updated_at", time.Now()}}},
+			{"$inc", bson.D{{"version", 1}}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	logger.FromContext(ctx).Info("MFA disabled", "user_id", uid)
+	s.recordAudit(ctx, uid, "user", uid, "MFA_DISABLE", user, Snapshot(ctx, s.repos.Users, filter))
+	return nil
+}
+
+// startEnrollment gives the user a new secret, replacing any that wasn't confirmed.
+func (s *UserService) startEnrollment(ctx context.Context, user models.User) (models.User, error) {
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return user, err
+	}
+
+	filter := bson.M{"user_id": user.User_id}
+	_, err = s.repos.Users.UpdateOne(
+		ctx,
+		filter,
+		bson.D{
+			{"$set", bson.D{{"mfa_secret", secret}, {"mfa_last_step", 0}, {"updated_at", time.Now()}}},
+			{"$inc", bson.D{{"version", 1}}},
+		},
+	)
+	if err != nil {
+		return user, err
+	}
+	s.recordAudit(ctx, user.User_id, "user", user.User_id, "MFA_ENROLL", user, Snapshot(ctx, s.repos.Users, filter))
+
+	user.Mfa_secret = secret
+	return user, nil
+}
+
+// enableMfa marks the user's secret confirmed and gives them new recovery codes, which are returned.
+func (s *UserService) enableMfa(ctx context.Context, user models.User) ([]string, error) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"user_id": user.User_id}
+	now := time.Now()
+	_, err = s.repos.Users.UpdateOne(
+		ctx,
+		filter,
+		bson.D{
+			{"$set", bson.D{{"mfa_enabled_at", now}, {"mfa_recovery_codes", hashes}, {"updated_at", now}}},
+			{"$inc", bson.D{{"version", 1}}},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	logger.FromContext(ctx).Info("MFA enabled", "user_id", user.User_id)
+	s.recordAudit(ctx, user.User_id, "user", user.User_id, "MFA_ENABLE", user, Snapshot(ctx, s.repos.Users, filter))
+	return codes, nil
+}
+
+// checkSecondFactor checks a code from the user's authenticator app, or with allowRecovery one of
+// their recovery codes, which is used up. A code is accepted once, so a code seen over someone's
+// shoulder can't be replayed. Failures are counted under mfaKey and audited as MFA_FAILED with event.
+func (s *base) checkSecondFactor(ctx context.Context, user models.User, code string, allowRecovery bool, event bson.M) error {
+	key := mfaKey(user.User_id)
+	if err := s.checkThrottle(ctx, key); err != nil {
+		return err
+	}
+
+	code = strings.ReplaceAll(code, " ", "")
+	ok, err := s.acceptCode(ctx, user, code)
+	if err == nil && !ok && allowRecovery {
+		ok, err = s.useRecoveryCode(ctx, user, code)
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		logger.FromContext(ctx).Warn("second factor failed", "user_id", user.User_id)
+		return s.loginFailed(ctx, "MFA_FAILED", user.User_id, event, []string{key}, errorf(Unauthorized, "the code is incorrect"))
+	}
+	s.clearFailures(ctx, key)
+	return nil
+}
+
+// acceptCode checks a TOTP code, allowing one step of clock skew, and records its step so it
+// can't be used again.
+func (s *base) acceptCode(ctx context.Context, user models.User, code string) (bool, error) {
+	if user.Mfa_secret == "" {
+		return false, nil
+	}
+	step, ok := totp.Validate(user.Mfa_secret, code, time.Now(), 1)
+	if !ok {
+		return false, nil
+	}
+
+	// only moving forward matches, so of two requests with the same code one fails
+	result, err := s.repos.Users.UpdateOne(
+		ctx,
+		bson.M{"user_id": user.User_id, "mfa_last_step": bson.M{"$lt": step}},
+		bson.D{{"$set", bson.D{{"mfa_last_step", step}}}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// useRecoveryCode uses up one of the user's recovery codes.
+func (s *base) useRecoveryCode(ctx context.Context, user models.User, code string) (bool, error) {
+	if user.Mfa_enabled_at == nil {
+		return false, nil
+	}
+	hash := hashToken(normalizeRecoveryCode(code))
+
+	result, err := s.repos.Users.UpdateOne(
+		ctx,
+		bson.M{"user_id": user.User_id, "mfa_recovery_codes": hash},
+		bson.D{{"$pull", bson.D{{"mfa_recovery_codes", hash}}}},
+	)
+	if err != nil {
+		return false, err
+	}
+	if result.ModifiedCount == 0 {
+		return false, nil
+	}
+	logger.FromContext(ctx).Warn("recovery code used", "user_id", user.User_id, "left", len(user.Mfa_recovery_codes)-1)
+	s.recordAudit(ctx, user.User_id, "user", user.User_id, "MFA_RECOVERY_CODE_USED", nil, bson.M{"left": len(user.Mfa_recovery_codes) - 1})
+	return true, nil
+}
+
+// newRecoveryCodes returns recoveryCodeCount codes like "k3j9x-q2m7d" and the hashes to store.
+func newRecoveryCodes() (codes []string, hashes []string, err error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := 0; i < recoveryCodeCount; i++ {
+		random := make([]byte, 7)
+		if _, err := rand.Read(random); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(random)[:10])
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(code, "-", ""))
+}
+
+func enrollmentOf(user models.User) MfaEnrollment {
+	return MfaEnrollment{Secret: user.Mfa_secret, Uri: totp.URI(user.Mfa_secret, MfaIssuer, user.Email)}
+}
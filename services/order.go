@@ -0,0 +1,409 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	"github.com/mayankr5/v1/restaurant-management/logger"
+	"github.com/mayankr5/v1/restaurant-management/metrics"
+	"github.com/mayankr5/v1/restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// OrderService places orders and changes and voids their items.
+type OrderService struct {
+	*base
+	users    *UserService
+	invoices *InvoiceService
+}
+
+// Create opens an order without items, at a table if it names one.
+func (s *OrderService) Create(ctx context.Context, uid string, order models.Order) (models.Order, error) {
+	if order.Table_id != nil {
+		var table models.Table
+		err := s.repos.Tables.FindOne(ctx, bson.M{"table_id": order.Table_id, "deleted_at": nil}).Decode(&table)
+		if err != nil {
+			return order, errorf(Invalid, "table was not found")
+		}
+	}
+
+	order.Created_at = time.Now()
+	order.Updated_at = time.Now()
+	order.ID = primitive.NewObjectID()
+	order.Version = 1
+	order.Order_id = order.ID.Hex()
+	order.Deleted_at = nil
+
+	if _, err := s.repos.Orders.InsertOne(ctx, order); err != nil {
+		return order, err
+	}
+	s.recordAudit(ctx, uid, "order", order.Order_id, "CREATE", nil, order)
+	metrics.OrderCreated()
+	return order, nil
+}
+
+// Update moves the order to another table.
+func (s *OrderService) Update(ctx context.Context, uid string, orderId string, expect Precondition, change dto.UpdateOrderRequest) (*mongo.UpdateResult, int, error) {
+	var updateObj primitive.D
+
+	if change.Table_id != nil {
+		var table models.Table
+		err := s.repos.Tables.FindOne(ctx, bson.M{"table_id": change.Table_id, "deleted_at": nil}).Decode(&table)
+		if err != nil {
+			return nil, 0, errorf(Invalid, "table was not found")
+		}
+		updateObj = append(updateObj, bson.E{"table_id", change.Table_id})
+	}
+
+	updateObj = append(updateObj, bson.E{"updated_at", time.Now()})
+
+	return s.updateVersioned(ctx, uid, s.repos.Orders, "order", "order_id", orderId, expect, updateObj)
+}
+
+// List returns the orders, without the deleted ones unless includeDeleted is set.
+func (s *OrderService) List(ctx context.Context, includeDeleted bool) ([]models.Order, error) {
+	result, err := s.repos.Orders.Find(ctx, listFilter(includeDeleted))
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close(ctx)
+
+	var allOrders []models.Order
+	if err := result.All(ctx, &allOrders); err != nil {
+		return nil, err
+	}
+	return allOrders, nil
+}
+
+func (s *OrderService) Get(ctx context.Context, orderId string) (models.Order, error) {
+	var order models.Order
+
+	err := s.repos.Orders.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order)
+	return order, lookupFailed(err, "order")
+}
+
+// Delete soft-deletes the order; its items and invoices stay as they are.
+func (s *OrderService) Delete(ctx context.Context, uid string, orderId string) (*mongo.UpdateResult, error) {
+	return s.archive(ctx, uid, s.repos.Orders, "order", "order_id", orderId)
+}
+
+func (s *OrderService) Restore(ctx context.Context, uid string, orderId string) (*mongo.UpdateResult, error) {
+	return s.unarchive(ctx, uid, s.repos.Orders, "order", "order_id", orderId)
+}
+
+// Place opens an order at the table with the given items, all or nothing. Every item gets the
+// name and price its food has now, so later menu changes don't alter the bill.
+func (s *OrderService) Place(ctx context.Context, uid string, tableId string, items []dto.OrderItemRequest) (models.Order, []models.OrderItem, error) {
+	var order models.Order
+	var table models.Table
+
+	err := s.repos.Tables.FindOne(ctx, bson.M{"table_id": tableId, "deleted_at": nil}).Decode(&table)
+	if err != nil {
+		return order, nil, errorf(Invalid, "table %s was not found", tableId)
+	}
+
+	// the order id is assigned up front, but nothing is written until every item checks out
+	order.ID = primitive.NewObjectID()
+	order.Order_id = order.ID.Hex()
+	order.Order_Date, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	order.Table_id = &tableId
+
+	foodIds := []string{}
+	for _, item := range items {
+		foodIds = append(foodIds, *item.Food_id)
+	}
+
+	result, err := s.repos.Foods.Find(ctx, bson.M{"food_id": bson.M{"$in": foodIds}, "deleted_at": nil})
+	if err != nil {
+		return order, nil, fmt.Errorf("looking up the ordered food: %w", err)
+	}
+	defer result.Close(ctx)
+
+	var foods []models.Food
+	if err := result.All(ctx, &foods); err != nil {
+		return order, nil, fmt.Errorf("looking up the ordered food: %w", err)
+	}
+
+	foodsById := map[string]models.Food{}
+	for _, food := range foods {
+		foodsById[food.Food_id] = food
+	}
+
+	orderItems := []models.OrderItem{}
+	orderItemsToBeInserted := []interface{}{}
+
+	for _, request := range items {
+		food, ok := foodsById[*request.Food_id]
+		if !ok {
+			return order, nil, errorf(Invalid, "food %s was not found", *request.Food_id)
+		}
+
+		orderItem := request.Model()
+		orderItem.Order_id = order.Order_id
+		orderItem.ID = primitive.NewObjectID()
+		orderItem.Version = 1
+		orderItem.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		orderItem.Order_item_id = orderItem.ID.Hex()
+		orderItem.Status = "ACTIVE"
+
+		var num = toFixed(*food.Price, 2)
+		orderItem.Unit_price = &num
+		orderItem.Food_name = food.Name
+
+		orderItems = append(orderItems, orderItem)
+		orderItemsToBeInserted = append(orderItemsToBeInserted, orderItem)
+	}
+
+	err = s.repos.WithTransaction(ctx, func(ctx context.Context) error {
+		order.Created_at = time.Now()
+		order.Updated_at = time.Now()
+		order.Version = 1
+		if _, err := s.repos.Orders.InsertOne(ctx, order); err != nil {
+			return err
+		}
+		_, err := s.repos.OrderItems.InsertMany(ctx, orderItemsToBeInserted)
+		return err
+	})
+	if err != nil {
+		return order, nil, err
+	}
+	metrics.OrderCreated()
+
+	s.recordAudit(ctx, uid, "order", order.Order_id, "CREATE", nil, order)
+	for _, orderItem := range orderItems {
+		s.recordAudit(ctx, uid, "orderItem", orderItem.Order_item_id, "CREATE", nil, orderItem)
+	}
+	return order, orderItems, nil
+}
+
+// Items returns every order item, voided and refunded ones included.
+func (s *OrderService) Items(ctx context.Context) ([]models.OrderItem, error) {
+	result, err := s.repos.OrderItems.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close(ctx)
+
+	var allOrderItems []models.OrderItem
+	if err := result.All(ctx, &allOrderItems); err != nil {
+		return nil, err
+	}
+	return allOrderItems, nil
+}
+
+func (s *OrderService) Item(ctx context.Context, orderItemId string) (models.OrderItem, error) {
+	var orderItem models.OrderItem
+
+	err := s.repos.OrderItems.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&orderItem)
+	return orderItem, lookupFailed(err, "order item")
+}
+
+// ItemsByOrder groups the order's items that aren't voided with their table and what is due for them.
+func (s *OrderService) ItemsByOrder(ctx context.Context, id string) ([]dto.OrderItemsOfOrder, error) {
+	return s.itemsByOrder(ctx, id)
+}
+
+func (s *base) itemsByOrder(ctx context.Context, id string) ([]dto.OrderItemsOfOrder, error) {
+	matchStage := bson.D{{"$match", bson.D{{"order_id", id}, {"status", bson.D{{"$ne", "VOIDED"}}}}}}
+	lookupStage := bson.D{{"$lookup", bson.D{{"from", "food"}, {"localField", "food_id"}, {"foreignField", "food_id"}, {"as", "food"}}}}
+	unwindStage := bson.D{{"$unwind", bson.D{{"path", "$food"}, {"preserveNullAndEmptyArrays", true}}}}
+
+	lookupOrderStage := bson.D{{"$lookup", bson.D{{"from", "order"}, {"localField", "order_id"}, {"foreignField", "order_id"}, {"as", "order"}}}}
+	unwindOrderStage := bson.D{{"$unwind", bson.D{{"path", "$order"}, {"preserveNullAndEmptyArrays", true}}}}
+
+	lookupTableStage := bson.D{{"$lookup", bson.D{{"from", "table"}, {"localField", "order.table_id"}, {"foreignField", "table_id"}, {"as", "table"}}}}
+	unwindTableStage := bson.D{{"$unwind", bson.D{{"path", "$table"}, {"preserveNullAndEmptyArrays", true}}}}
+
+	// items snapshot their price when ordered; items from before snapshotting fall back to the food's price
+	snapshotPrice := bson.D{{"$cond", bson.A{bson.D{{"$ifNull", bson.A{"$food_name", false}}}, "$unit_price", "$food.price"}}}
+
+	projectStage := bson.D{
+		{"$project", bson.D{
+			{"_id", 0},
+			{"amount", snapshotPrice},
+			{"total_count", 1},
+			{"food_name", bson.D{{"$ifNull", bson.A{"$food_name", "$food.name"}}}},
+			{"food_image", "$food.food_image"},
+			{"table_number", "$table.table_number"},
+			{"table_id", "$table.table_id"},
+			{"order_id", "$order.order_id"},
+			{"price", snapshotPrice},
+			{"quantity", 1},
+		}}}
+
+	groupStage := bson.D{{"$group", bson.D{{"_id", bson.D{{"order_id", "$order_id"}, {"table_id", "$table_id"}, {"table_number", "$table_number"}}}, {"payment_due", bson.D{{"$sum", "$amount"}}}, {"total_count", bson.D{{"$sum", 1}}}, {"order_items", bson.D{{"$push", "$$ROOT"}}}}}}
+
+	projectStage2 := bson.D{
+		{"$project", bson.D{
+
+			{"_id", 0},
+			{"payment_due", 1},
+			{"total_count", 1},
+			{"table_number", "$_id.table_number"},
+			{"order_items", 1},
+		}}}
+
+	result, err := s.repos.OrderItems.Aggregate(ctx, mongo.Pipeline{
+		matchStage,
+		lookupStage,
+		unwindStage,
+		lookupOrderStage,
+		unwindOrderStage,
+		lookupTableStage,
+		unwindTableStage,
+		projectStage,
+		groupStage,
+		projectStage2})
+
+	if err != nil {
+		return nil, err
+	}
+
+	OrderItems := []dto.OrderItemsOfOrder{}
+	if err := result.All(ctx, &OrderItems); err != nil {
+		return nil, err
+	}
+
+	return OrderItems, nil
+}
+
+// UpdateItem changes the item's quantity or food, and reprices the order's pending invoices. A
+// new food is snapshotted just like when the item was ordered. Only active items of orders that
+// haven't been paid can change; a settled bill keeps the prices it was paid at.
+func (s *OrderService) UpdateItem(ctx context.Context, uid string, orderItemId string, expect Precondition, change dto.UpdateOrderItemRequest) (*mongo.UpdateResult, int, error) {
+	var orderItem models.OrderItem
+
+	err := s.repos.OrderItems.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&orderItem)
+	if err != nil {
+		return nil, 0, lookupFailed(err, "order item")
+	}
+	if orderItem.Status != "ACTIVE" {
+		return nil, 0, errorf(Conflict, "order item is %s and can't be changed", strings.ToLower(orderItem.Status))
+	}
+
+	paid, err := s.repos.Invoices.CountDocuments(ctx, bson.M{"order_id": orderItem.Order_id, "payment_status": bson.M{"$ne": "PENDING"}})
+	if err != nil {
+		return nil, 0, fmt.Errorf("checking the order's invoices: %w", err)
+	}
+	if paid > 0 {
+		return nil, 0, errorf(Conflict, "order has been paid, its items can't be changed")
+	}
+
+	var updateObj primitive.D
+
+	if change.Quantity != nil {
+		updateObj = append(updateObj, bson.E{"quantity", *change.Quantity})
+	}
+
+	if change.Food_id != nil {
+		var food models.Food
+		err := s.repos.Foods.FindOne(ctx, bson.M{"food_id": *change.Food_id, "deleted_at": nil}).Decode(&food)
+		if err != nil {
+			return nil, 0, errorf(Invalid, "food %s was not found", *change.Food_id)
+		}
+
+		updateObj = append(updateObj, bson.E{"food_id", *change.Food_id})
+		updateObj = append(updateObj, bson.E{"food_name", food.Name})
+		updateObj = append(updateObj, bson.E{"unit_price", toFixed(*food.Price, 2)})
+	}
+
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	updateObj = append(updateObj, bson.E{"updated_at", updatedAt})
+
+	result, version, err := s.updateVersioned(ctx, uid, s.repos.OrderItems, "orderItem", "order_item_id", orderItemId, expect, updateObj)
+	if err != nil {
+		return result, version, err
+	}
+	if err := s.invoices.Reprice(ctx, uid, orderItem.Order_id); err != nil {
+		return nil, 0, fmt.Errorf("updating the invoice totals: %w", err)
+	}
+	return result, version, nil
+}
+
+// VoidItem takes an item off an unpaid order with a manager's approval, and reprices the
+// order's pending invoices. Items of paid orders are refunded instead.
+func (s *OrderService) VoidItem(ctx context.Context, uid string, orderItemId string, reason string, approval Approval) (*mongo.UpdateResult, error) {
+	var orderItem models.OrderItem
+
+	err := s.repos.OrderItems.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&orderItem)
+	if err != nil {
+		return nil, lookupFailed(err, "order item")
+	}
+
+	if orderItem.Status == "VOIDED" || orderItem.Status == "REFUNDED" {
+		return nil, errorf(Conflict, "order item is already %s", strings.ToLower(orderItem.Status))
+	}
+
+	paid, err := s.repos.Invoices.CountDocuments(ctx, bson.M{"order_id": orderItem.Order_id, "payment_status": bson.M{"$ne": "PENDING"}})
+	if err != nil {
+		return nil, fmt.Errorf("checking the order's invoices: %w", err)
+	}
+	if paid > 0 {
+		return nil, errorf(Conflict, "order has been paid, refund the invoice instead")
+	}
+
+	manager, err := s.users.AuthorizeManager(ctx, approval)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	before := Snapshot(ctx, s.repos.OrderItems, bson.M{"order_item_id": orderItemId})
+
+	result, err := s.repos.OrderItems.UpdateOne(
+		ctx,
+		bson.M{"order_item_id": orderItemId, "status": bson.M{"$nin": bson.A{"VOIDED", "REFUNDED"}}},
+		bson.D{
+			{"$set", bson.D{
+				{"status", "VOIDED"},
+				{"void_reason", reason},
+				{"voided_by", uid},
+				{"approved_by", manager.User_id},
+				{"voided_at", now},
+				{"updated_at", now},
+			}},
+			{"$inc", bson.D{{"version", 1}}},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, errorf(Conflict, "order item is already voided")
+	}
+	logger.FromContext(ctx).Info("order item voided", "order_item_id", orderItemId, "approved_by", manager.User_id)
+	s.recordAudit(ctx, uid, "orderItem", orderItemId, "VOID", before, Snapshot(ctx, s.repos.OrderItems, bson.M{"order_item_id": orderItemId}))
+
+	if err := s.invoices.Reprice(ctx, uid, orderItem.Order_id); err != nil {
+		return nil, fmt.Errorf("updating the invoice totals: %w", err)
+	}
+	return result, nil
+}
+
+// CountOpen counts the orders that are not deleted and have no paid or refunded invoice yet.
+func (s *OrderService) CountOpen(ctx context.Context) (int64, error) {
+	settled, err := s.repos.Invoices.Find(ctx, bson.M{"payment_status": bson.M{"$in": []string{"PAID", "PARTIALLY_REFUNDED", "REFUNDED"}}})
+	if err != nil {
+		return 0, err
+	}
+	defer settled.Close(ctx)
+
+	var invoices []models.Invoice
+	if err := settled.All(ctx, &invoices); err != nil {
+		return 0, err
+	}
+
+	orderIds := []string{}
+	for _, invoice := range invoices {
+		orderIds = append(orderIds, invoice.Order_id)
+	}
+
+	return s.repos.Orders.CountDocuments(ctx, bson.M{"deleted_at": nil, "order_id": bson.M{"$nin": orderIds}})
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Round rounds an amount of money to cents, halves away from zero.
+func Round(amount float64) float64 {
+	return toFixed(amount, 2)
+}
+
+func round(num float64) int {
+	return int(num + math.Copysign(0.5, num))
+}
+
+func toFixed(num float64, precision int) float64 {
+	output := math.Pow(10, float64(precision))
+	res := float64(round(num*output)) / output
+	return res
+}
+
+// priceHistoryWith closes the food's current price and opens a new one effective from now.
+// Foods created before price history was kept get their current price recorded first.
+func priceHistoryWith(food models.Food, price float64, now time.Time, uid string) []models.PriceChange {
+	history := food.Price_history
+	if len(history) == 0 && food.Price != nil {
+		history = append(history, models.PriceChange{Price: *food.Price, Effective_from: food.Created_at})
+	}
+
+	for i := range history {
+		if history[i].Effective_to == nil {
+			history[i].Effective_to = &now
+		}
+	}
+	return append(history, models.PriceChange{Price: price, Effective_from: now, Changed_by: uid})
+}
+
+// priceAt returns the price the food had at the given time, falling back to the current price.
+func priceAt(food models.Food, at time.Time) float64 {
+	for _, change := range food.Price_history {
+		if !at.Before(change.Effective_from) && (change.Effective_to == nil || at.Before(*change.Effective_to)) {
+			return change.Price
+		}
+	}
+	if food.Price == nil {
+		return 0
+	}
+	return *food.Price
+}
+
+// priceOrder evaluates every active promotion, plus the one behind the coupon if any,
+// against the order's items and returns the subtotal, the applied promotions and the discount.
+// Promotions are checked at the time the order was placed, so repricing an invoice later
+// applies the same happy hours.
+func (s *base) priceOrder(ctx context.Context, order models.Order, coupon *models.Coupon) (float64, []models.AppliedPromotion, float64, error) {
+	lines, err := s.orderPromotionLines(ctx, order.Order_id)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+
+	var subtotal float64
+	for _, line := range lines {
+		subtotal += line.Price
+	}
+
+	result, err := s.repos.Promotions.Find(ctx, bson.M{"active": bson.M{"$ne": false}})
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	defer result.Close(ctx)
+
+	var promotions []models.Promotion
+	if err := result.All(ctx, &promotions); err != nil {
+		return 0, nil, 0, err
+	}
+
+	applied, discount := applyPromotions(lines, promotions, coupon, orderTime(order), s.location)
+	return toFixed(subtotal, 2), applied, discount, nil
+}
+
+// orderTime is when the order was placed. Orders written before it was recorded fall back to
+// when they were created.
+func orderTime(order models.Order) time.Time {
+	switch {
+	case !order.Order_Date.IsZero():
+		return order.Order_Date
+	case !order.Created_at.IsZero():
+		return order.Created_at
+	}
+	return time.Now()
+}
+
+// orderPromotionLines prices the order's items that are neither voided nor refunded.
+func (s *base) orderPromotionLines(ctx context.Context, orderId string) ([]promotionLine, error) {
+	result, err := s.repos.OrderItems.Find(ctx, bson.M{"order_id": orderId, "status": bson.M{"$nin": bson.A{"VOIDED", "REFUNDED"}}})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close(ctx)
+
+	var orderItems []models.OrderItem
+	if err := result.All(ctx, &orderItems); err != nil {
+		return nil, err
+	}
+
+	foodIds := []string{}
+	for _, orderItem := range orderItems {
+		if orderItem.Food_id != nil {
+			foodIds = append(foodIds, *orderItem.Food_id)
+		}
+	}
+
+	foodResult, err := s.repos.Foods.Find(ctx, bson.M{"food_id": bson.M{"$in": foodIds}})
+	if err != nil {
+		return nil, err
+	}
+	defer foodResult.Close(ctx)
+
+	var foods []models.Food
+	if err := foodResult.All(ctx, &foods); err != nil {
+		return nil, err
+	}
+
+	foodsById := map[string]models.Food{}
+	for _, food := range foods {
+		foodsById[food.Food_id] = food
+	}
+
+	lines := []promotionLine{}
+	for _, orderItem := range orderItems {
+		if orderItem.Food_id == nil {
+			continue
+		}
+		food, ok := foodsById[*orderItem.Food_id]
+		snapshot := orderItem.Food_name != nil && orderItem.Unit_price != nil
+		if !ok && !snapshot {
+			continue
+		}
+
+		line := promotionLine{Order_item_id: orderItem.Order_item_id, Food_id: *orderItem.Food_id}
+		if snapshot {
+			line.Price = *orderItem.Unit_price
+		} else {
+			line.Price = priceAt(food, orderItem.Created_at)
+		}
+		if food.Menu_id != nil {
+			line.Menu_id = *food.Menu_id
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	"github.com/mayankr5/v1/restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PromotionService creates promotions and the coupons that unlock them, and redeems coupons.
+type PromotionService struct {
+	*base
+}
+
+func (s *PromotionService) List(ctx context.Context) ([]models.Promotion, error) {
+	result, err := s.repos.Promotions.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close(ctx)
+
+	var allPromotions []models.Promotion
+	if err := result.All(ctx, &allPromotions); err != nil {
+		return nil, err
+	}
+	return allPromotions, nil
+}
+
+func (s *PromotionService) Get(ctx context.Context, promotionId string) (models.Promotion, error) {
+	var promotion models.Promotion
+
+	err := s.repos.Promotions.FindOne(ctx, bson.M{"promotion_id": promotionId}).Decode(&promotion)
+	return promotion, lookupFailed(err, "promotion")
+}
+
+// Create checks the promotion's terms and what it applies to, and stores it active unless it
+// says otherwise.
+func (s *PromotionService) Create(ctx context.Context, uid string, promotion models.Promotion) (models.Promotion, error) {
+	if err := checkPromotion(promotion); err != nil {
+		return promotion, err
+	}
+
+	if promotion.Menu_id != nil && *promotion.Menu_id != "" {
+		var menu models.Menu
+		if err := s.repos.Menus.FindOne(ctx, bson.M{"menu_id": promotion.Menu_id}).Decode(&menu); err != nil {
+			return promotion, errorf(Invalid, "menu was not found")
+		}
+	}
+
+	if promotion.Food_id != nil && *promotion.Food_id != "" {
+		var food models.Food
+		if err := s.repos.Foods.FindOne(ctx, bson.M{"food_id": promotion.Food_id}).Decode(&food); err != nil {
+			return promotion, errorf(Invalid, "food was not found")
+		}
+	}
+
+	if promotion.Active == nil {
+		active := true
+		promotion.Active = &active
+	}
+
+	promotion.Created_at = time.Now()
+	promotion.Updated_at = time.Now()
+	promotion.ID = primitive.NewObjectID()
+	promotion.Version = 1
+	promotion.Promotion_id = promotion.ID.Hex()
+
+	if _, err := s.repos.Promotions.InsertOne(ctx, promotion); err != nil {
+		return promotion, err
+	}
+	s.recordAudit(ctx, uid, "promotion", promotion.Promotion_id, "CREATE", nil, promotion)
+	return promotion, nil
+}
+
+// Update changes the promotion's terms. What it applies to can't change. The terms are checked
+// as they will be after the change.
+func (s *PromotionService) Update(ctx context.Context, uid string, promotionId string, expect Precondition, change dto.UpdatePromotionRequest) (*mongo.UpdateResult, int, error) {
+	var existing models.Promotion
+
+	err := s.repos.Promotions.FindOne(ctx, bson.M{"promotion_id": promotionId}).Decode(&existing)
+	if err != nil {
+		return nil, 0, lookupFailed(err, "promotion")
+	}
+
+	var updateObj primitive.D
+
+	if change.Name != nil {
+		updateObj = append(updateObj, bson.E{"name", change.Name})
+		existing.Name = change.Name
+	}
+
+	if change.Value != nil {
+		updateObj = append(updateObj, bson.E{"value", change.Value})
+		existing.Value = change.Value
+	}
+
+	if change.Buy_quantity != nil {
+		updateObj = append(updateObj, bson.E{"buy_quantity", change.Buy_quantity})
+		existing.Buy_quantity = change.Buy_quantity
+	}
+
+	if change.Get_quantity != nil {
+		updateObj = append(updateObj, bson.E{"get_quantity", change.Get_quantity})
+		existing.Get_quantity = change.Get_quantity
+	}
+
+	if change.Start_time != nil && change.End_time != nil {
+		updateObj = append(updateObj, bson.E{"start_time", change.Start_time})
+		updateObj = append(updateObj, bson.E{"end_time", change.End_time})
+		existing.Start_time = change.Start_time
+		existing.End_time = change.End_time
+	}
+
+	if change.Start_date != nil {
+		updateObj = append(updateObj, bson.E{"start_date", change.Start_date})
+		existing.Start_Date = change.Start_date
+	}
+
+	if change.End_date != nil {
+		updateObj = append(updateObj, bson.E{"end_date", change.End_date})
+		existing.End_Date = change.End_date
+	}
+
+	if change.Coupon_only != nil {
+		updateObj = append(updateObj, bson.E{"coupon_only", change.Coupon_only})
+	}
+
+	if change.Active != nil {
+		updateObj = append(updateObj, bson.E{"active", change.Active})
+	}
+
+	if err := checkPromotion(existing); err != nil {
+		return nil, 0, err
+	}
+
+	updateObj = append(updateObj, bson.E{"updated_at", time.Now()})
+
+	return s.updateVersioned(ctx, uid, s.repos.Promotions, "promotion", "promotion_id", promotionId, expect, updateObj)
+}
+
+func (s *PromotionService) Coupons(ctx context.Context) ([]models.Coupon, error) {
+	result, err := s.repos.Coupons.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close(ctx)
+
+	var allCoupons []models.Coupon
+	if err := result.All(ctx, &allCoupons); err != nil {
+		return nil, err
+	}
+	return allCoupons, nil
+}
+
+func (s *PromotionService) Coupon(ctx context.Context, couponId string) (models.Coupon, error) {
+	var coupon models.Coupon
+
+	err := s.repos.Coupons.FindOne(ctx, bson.M{"coupon_id": couponId}).Decode(&coupon)
+	return coupon, lookupFailed(err, "coupon")
+}
+
+// CreateCoupon issues a coupon for an existing promotion. Codes are unique regardless of case
+// and surrounding spaces.
+func (s *PromotionService) CreateCoupon(ctx context.Context, uid string, coupon models.Coupon) (models.Coupon, error) {
+	var promotion models.Promotion
+
+	err := s.repos.Promotions.FindOne(ctx, bson.M{"promotion_id": coupon.Promotion_id}).Decode(&promotion)
+	if err != nil {
+		return coupon, errorf(Invalid, "promotion was not found")
+	}
+
+	code := normalizeCouponCode(*coupon.Code)
+	coupon.Code = &code
+
+	count, err := s.repos.Coupons.CountDocuments(ctx, bson.M{"code": code})
+	if err != nil {
+		return coupon, err
+	}
+	if count > 0 {
+		return coupon, errorf(Conflict, "this coupon code already exists")
+	}
+
+	coupon.Times_used = 0
+	coupon.Created_at = time.Now()
+	coupon.Updated_at = time.Now()
+	coupon.ID = primitive.NewObjectID()
+	coupon.Version = 1
+	coupon.Coupon_id = coupon.ID.Hex()
+
+	if _, err := s.repos.Coupons.InsertOne(ctx, coupon); err != nil {
+		return coupon, writeFailed(err, "this coupon code already exists")
+	}
+	s.recordAudit(ctx, uid, "coupon", coupon.Coupon_id, "CREATE", nil, coupon)
+	return coupon, nil
+}
+
+// findCoupon looks up a coupon that can still be redeemed; anything else is Invalid.
+func (s *PromotionService) findCoupon(ctx context.Context, code string) (*models.Coupon, error) {
+	var coupon models.Coupon
+
+	err := s.repos.Coupons.FindOne(ctx, bson.M{"code": normalizeCouponCode(code)}).Decode(&coupon)
+	if err != nil {
+		return nil, errorf(Invalid, "coupon was not found")
+	}
+	if coupon.Expires_at != nil && coupon.Expires_at.Before(time.Now()) {
+		return nil, errorf(Invalid, "coupon has expired")
+	}
+	if coupon.Usage_limit != nil && coupon.Times_used >= *coupon.Usage_limit {
+		return nil, errorf(Invalid, "coupon usage limit has been reached")
+	}
+	return &coupon, nil
+}
+
+// redeemCoupon counts one use of the coupon, failing when its usage limit has been reached.
+func (s *PromotionService) redeemCoupon(ctx context.Context, coupon models.Coupon, uid string) (bool, error) {
+	filter := bson.M{"coupon_id": coupon.Coupon_id}
+	if coupon.Usage_limit != nil {
+		filter["times_used"] = bson.M{"$lt": *coupon.Usage_limit}
+	}
+
+	result, err := s.repos.Coupons.UpdateOne(
+		ctx,
+		filter,
+		bson.D{
+			{"$inc", bson.D{{"times_used", 1}, {"version", 1}}},
+			{"$set", bson.D{{"updated_at", time.Now()}}},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	if result.MatchedCount == 1 {
+		s.recordAudit(ctx, uid, "coupon", coupon.Coupon_id, "UPDATE", coupon, Snapshot(ctx, s.repos.Coupons, bson.M{"coupon_id": coupon.Coupon_id}))
+	}
+	return result.MatchedCount == 1, nil
+}
+
+func (s *PromotionService) releaseCoupon(ctx context.Context, coupon models.Coupon, uid string) error {
+	before := Snapshot(ctx, s.repos.Coupons, bson.M{"coupon_id": coupon.Coupon_id})
+
+	_, err := s.repos.Coupons.UpdateOne(
+		ctx,
+		bson.M{"coupon_id": coupon.Coupon_id},
+		bson.D{
+			{"$inc", bson.D{{"times_used", -1}, {"version", 1}}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	s.recordAudit(ctx, uid, "coupon", coupon.Coupon_id, "UPDATE", before, Snapshot(ctx, s.repos.Coupons, bson.M{"coupon_id": coupon.Coupon_id}))
+	return nil
+}
+
+func checkPromotion(promotion models.Promotion) error {
+	switch *promotion.Type {
+	case "PERCENTAGE":
+		if promotion.Value == nil || *promotion.Value > 100 {
+			return errorf(Invalid, "percentage promotions need a value between 0 and 100")
+		}
+	case "FIXED":
+		if promotion.Value == nil {
+			return errorf(Invalid, "fixed promotions need a value")
+		}
+	case "BUY_X_GET_Y":
+		if promotion.Buy_quantity == nil || promotion.Get_quantity == nil {
+			return errorf(Invalid, "buy x get y promotions need buy_quantity and get_quantity")
+		}
+	}
+
+	if (promotion.Start_time == nil) != (promotion.End_time == nil) {
+		return errorf(Invalid, "happy hour promotions need both start_time and end_time")
+	}
+	if promotion.Start_Date != nil && promotion.End_Date != nil && !promotion.End_Date.After(*promotion.Start_Date) {
+		return errorf(Invalid, "end_date must be after start_date")
+	}
+	return nil
+}
+
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/dto"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Period is a span of time; a nil bound leaves it open on that side.
+type Period struct {
+	From *time.Time
+	To   *time.Time
+}
+
+// filter matches the times within the period, or is nil when the period is open on both sides.
+func (p Period) filter() bson.M {
+	if p.From == nil && p.To == nil {
+		return nil
+	}
+	filter := bson.M{}
+	if p.From != nil {
+		filter["$gte"] = *p.From
+	}
+	if p.To != nil {
+		filter["$lte"] = *p.To
+	}
+	return filter
+}
+
+// ReportService sums up sales and promotions.
+type ReportService struct {
+	*base
+}
+
+// Sales sums up the invoices paid in the period per payment method, net of discounts and of
+// the refunds made in the period, and counts the items voided in it. Invoices count when they
+// were paid, not when they were created. Only managers may see it.
+func (s *ReportService) Sales(ctx context.Context, uid string, period Period) (dto.SalesReport, error) {
+	var report dto.SalesReport
+
+	if err := s.requireManager(ctx, uid, "the sales report"); err != nil {
+		return report, err
+	}
+
+	match := bson.D{{"payment_status", bson.D{{"$in", bson.A{"PAID", "PARTIALLY_REFUNDED", "REFUNDED"}}}}}
+	if paid := period.filter(); paid != nil {
+		match = append(match, bson.E{"paid_at", paid})
+	}
+
+	matchStage := bson.D{{"$match", match}}
+	groupStage := bson.D{{"$group", bson.D{
+		{"_id", "$payment_method"},
+		{"invoices", bson.D{{"$sum", 1}}},
+		{"gross_sales", bson.D{{"$sum", "$subtotal"}}},
+		{"discounts", bson.D{{"$sum", "$discount_total"}}},
+		{"net_sales", bson.D{{"$sum", "$total"}}},
+	}}}
+	projectStage := bson.D{{"$project", bson.D{
+		{"_id", 0},
+		{"payment_method", "$_id"},
+		{"invoices", 1},
+		{"gross_sales", 1},
+		{"discounts", 1},
+		{"net_sales", 1},
+	}}}
+
+	result, err := s.repos.Invoices.Aggregate(ctx, mongo.Pipeline{
+		matchStage, groupStage, projectStage})
+	if err != nil {
+		return report, err
+	}
+	defer result.Close(ctx)
+
+	report.By_payment_method = []dto.PaymentMethodSales{}
+	if err := result.All(ctx, &report.By_payment_method); err != nil {
+		return report, err
+	}
+
+	// a refund counts in the period it was made in, whenever the invoice was paid
+	refundMatch := bson.D{}
+	if created := period.filter(); created != nil {
+		refundMatch = append(refundMatch, bson.E{"created_at", created})
+	}
+	refundResult, err := s.repos.Refunds.Aggregate(ctx, mongo.Pipeline{
+		bson.D{{"$match", refundMatch}},
+		bson.D{{"$group", bson.D{{"_id", "$payment_method"}, {"refunds", bson.D{{"$sum", "$amount"}}}}}},
+		bson.D{{"$project", bson.D{{"_id", 0}, {"payment_method", "$_id"}, {"refunds", 1}}}},
+	})
+	if err != nil {
+		return report, err
+	}
+	defer refundResult.Close(ctx)
+
+	var refunds []dto.PaymentMethodSales
+	if err := refundResult.All(ctx, &refunds); err != nil {
+		return report, err
+	}
+
+	rows := map[string]int{}
+	for i, row := range report.By_payment_method {
+		rows[row.Payment_method] = i
+	}
+	for _, refund := range refunds {
+		i, ok := rows[refund.Payment_method]
+		if !ok {
+			i = len(report.By_payment_method)
+			rows[refund.Payment_method] = i
+			report.By_payment_method = append(report.By_payment_method, dto.PaymentMethodSales{Payment_method: refund.Payment_method})
+		}
+		row := &report.By_payment_method[i]
+		row.Refunds = Round(row.Refunds + refund.Refunds)
+		row.Net_sales = Round(row.Net_sales - refund.Refunds)
+	}
+
+	totals := &report.Totals
+	for _, row := range report.By_payment_method {
+		totals.Invoices += row.Invoices
+		totals.Gross_sales = Round(totals.Gross_sales + row.Gross_sales)
+		totals.Discounts = Round(totals.Discounts + row.Discounts)
+		totals.Refunds = Round(totals.Refunds + row.Refunds)
+		totals.Net_sales = Round(totals.Net_sales + row.Net_sales)
+	}
+
+	voidFilter := bson.M{"status": "VOIDED"}
+	if voided := period.filter(); voided != nil {
+		voidFilter["voided_at"] = voided
+	}
+	voidedItems, err := s.repos.OrderItems.CountDocuments(ctx, voidFilter)
+	if err != nil {
+		return report, err
+	}
+	totals.Voided_items = int(voidedItems)
+
+	return report, nil
+}
+
+// Promotions reports how often each promotion applied to the invoices of the period, and the
+// discount it gave in total.
+func (s *ReportService) Promotions(ctx context.Context, period Period) ([]dto.PromotionUsage, error) {
+	match := bson.D{}
+	if created := period.filter(); created != nil {
+		match = append(match, bson.E{"created_at", created})
+	}
+
+	matchStage := bson.D{{"$match", match}}
+	unwindStage := bson.D{{"$unwind", "$applied_promotions"}}
+	groupStage := bson.D{{"$group", bson.D{
+		{"_id", "$applied_promotions.promotion_id"},
+		{"name", bson.D{{"$first", "$applied_promotions.name"}}},
+		{"type", bson.D{{"$first", "$applied_promotions.type"}}},
+		{"times_applied", bson.D{{"$sum", 1}}},
+		{"total_discount", bson.D{{"$sum", "$applied_promotions.discount"}}},
+	}}}
+	projectStage := bson.D{{"$project", bson.D{
+		{"_id", 0},
+		{"promotion_id", "$_id"},
+		{"name", 1},
+		{"type", 1},
+		{"times_applied", 1},
+		{"total_discount", 1},
+	}}}
+
+	result, err := s.repos.Invoices.Aggregate(ctx, mongo.Pipeline{
+		matchStage, unwindStage, groupStage, projectStage})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close(ctx)
+
+	report := []dto.PromotionUsage{}
+	if err := result.All(ctx, &report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
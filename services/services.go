@@ -0,0 +1,81 @@
+// Package services holds the business rules of the restaurant: pricing, placing and voiding
+// orders, invoicing and refunds, menus, promotions, users and POS terminals. Methods take plain
+// Go values and return models or a *Error, so HTTP handlers, commands and background jobs can
+// share them.
+//
+// Services hold no connection of their own; they keep their documents in the repositories they
+// are built with, see New.
+package services
+
+import (
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/mailer"
+	"github.com/mayankr5/v1/restaurant-management/repositories"
+	"github.com/mayankr5/v1/restaurant-management/signing"
+)
+
+// Deps are what the services depend on.
+type Deps struct {
+	Repos *repositories.Repositories
+	// Mailer sends the verification and password reset emails. When nil, they are dropped with a
+	// warning.
+	Mailer mailer.Mailer
+	// Keys sign the tokens issued at login.
+	Keys *signing.KeySet
+	// Login secures logins; settings it leaves out are taken from DefaultLoginPolicy.
+	Login LoginPolicy
+	// Location is the time zone of the restaurant, whose clock happy hours follow. When nil,
+	// the server's local time zone is used.
+	Location *time.Location
+}
+
+// base is what every service shares.
+type base struct {
+	repos    *repositories.Repositories
+	mailer   mailer.Mailer
+	keys     *signing.KeySet
+	policy   LoginPolicy
+	location *time.Location
+}
+
+// Services are all the services, wired to each other.
+type Services struct {
+	Audits     *AuditService
+	Foods      *FoodService
+	Invoices   *InvoiceService
+	Menus      *MenuService
+	Orders     *OrderService
+	Promotions *PromotionService
+	Reports    *ReportService
+	Tables     *TableService
+	Terminals  *TerminalService
+	Users      *UserService
+}
+
+// New builds the services on deps.
+func New(deps Deps) *Services {
+	b := &base{repos: deps.Repos, mailer: deps.Mailer, keys: deps.Keys, policy: deps.Login.withDefaults(), location: deps.Location}
+	if b.mailer == nil {
+		b.mailer = mailer.Unconfigured{}
+	}
+	if b.location == nil {
+		b.location = time.Local
+	}
+
+	users := &UserService{base: b}
+	promotions := &PromotionService{base: b}
+	invoices := &InvoiceService{base: b, users: users, promotions: promotions}
+	return &Services{
+		Audits:     &AuditService{base: b},
+		Foods:      &FoodService{base: b},
+		Invoices:   invoices,
+		Menus:      &MenuService{base: b},
+		Orders:     &OrderService{base: b, users: users, invoices: invoices},
+		Promotions: promotions,
+		Reports:    &ReportService{base: b},
+		Tables:     &TableService{base: b},
+		Terminals:  &TerminalService{base: b},
+		Users:      users,
+	}
+}
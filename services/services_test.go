@@ -0,0 +1,904 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/database"
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	"github.com/mayankr5/v1/restaurant-management/mailer"
+	"github.com/mayankr5/v1/restaurant-management/models"
+	"github.com/mayankr5/v1/restaurant-management/repositories"
+	"github.com/mayankr5/v1/restaurant-management/signing"
+	"github.com/mayankr5/v1/restaurant-management/totp"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func setupServicesTest(t *testing.T, policy LoginPolicy) (context.Context, *Services, *repositories.Repositories) {
+	t.Helper()
+
+	keys, err := signing.Generate("test", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	repos := repositories.New(database.NewMemoryStore())
+	repos.Tables.InsertOne(ctx, bson.M{"table_id": "t1", "table_number": 4, "number_of_guests": 2})
+	repos.Foods.InsertOne(ctx, bson.M{"food_id": "f1", "name": "Soup", "price": 4.5, "menu_id": "m1"})
+	repos.Foods.InsertOne(ctx, bson.M{"food_id": "f2", "name": "Bread", "price": 2.0, "menu_id": "m1"})
+
+	for _, user := range []struct{ email, role string }{{"staff@example.com", "STAFF"}, {"manager@example.com", "MANAGER"}, {"admin@example.com", "ADMIN"}} {
+		hash, err := bcrypt.GenerateFromPassword([]byte("secret-pass"), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		repos.Users.InsertOne(ctx, models.User{ID: primitive.NewObjectID(), User_id: user.role, Email: user.email, Password: string(hash), Role: user.role})
+	}
+	return ctx, New(Deps{Repos: repos, Keys: keys, Login: policy}), repos
+}
+
+func orderItems(foodIds ...string) []dto.OrderItemRequest {
+	items := []dto.OrderItemRequest{}
+	for i := range foodIds {
+		quantity := "M"
+		items = append(items, dto.OrderItemRequest{Food_id: &foodIds[i], Quantity: &quantity})
+	}
+	return items
+}
+
+func TestPlaceRejectsUnknownReferences(t *testing.T) {
+	ctx, svc, repos := setupServicesTest(t, LoginPolicy{})
+
+	if _, _, err := svc.Orders.Place(ctx, "u1", "missing", orderItems("f1")); !errors.Is(err, ErrInvalid) {
+		t.Errorf("unknown table: err = %v, want Invalid", err)
+	}
+	if _, _, err := svc.Orders.Place(ctx, "u1", "t1", orderItems("f1", "missing")); !errors.Is(err, ErrInvalid) {
+		t.Errorf("unknown food: err = %v, want Invalid", err)
+	}
+
+	count, _ := repos.Orders.CountDocuments(ctx, bson.M{})
+	if count != 0 {
+		t.Errorf("%d orders were written, want none", count)
+	}
+}
+
+func TestPlaceSnapshotsPricesAndVoidRepricesTheInvoice(t *testing.T) {
+	ctx, svc, repos := setupServicesTest(t, LoginPolicy{})
+
+	order, items, err := svc.Orders.Place(ctx, "u1", "t1", orderItems("f1", "f2", "f2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repos.Foods.UpdateOne(ctx, bson.M{"food_id": "f1"}, bson.M{"$set": bson.M{"price": 9.0}})
+
+	grouped, err := svc.Orders.ItemsByOrder(ctx, order.Order_id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grouped) != 1 || grouped[0].Payment_due != 8.5 {
+		t.Fatalf("items by order = %+v, want 8.5 due", grouped)
+	}
+
+	invoice, err := svc.Invoices.Create(ctx, "u1", models.Invoice{Order_id: order.Order_id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	staff := Approval{Email: "staff@example.com", Password: "secret-pass"}
+	if _, err := svc.Orders.VoidItem(ctx, "u1", items[1].Order_item_id, "dropped", staff); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("staff approval: err = %v, want Forbidden", err)
+	}
+
+	manager := Approval{Email: "manager@example.com", Password: "secret-pass"}
+	if _, err := svc.Orders.VoidItem(ctx, "u1", items[1].Order_item_id, "dropped", manager); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Orders.VoidItem(ctx, "u1", items[1].Order_item_id, "dropped", manager); !errors.Is(err, ErrConflict) {
+		t.Errorf("second void: err = %v, want Conflict", err)
+	}
+
+	view, err := svc.Invoices.Get(ctx, invoice.Invoice_id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.Total != 6.5 {
+		t.Errorf("total after the void = %v, want 6.5", view.Total)
+	}
+}
+
+func TestHappyHoursFollowTheRestaurantClock(t *testing.T) {
+	start, end := "17:00", "19:00"
+	happyHour := models.Promotion{Start_time: &start, End_time: &end}
+	// 15:30 UTC, as an order date comes back from the store
+	at := time.Date(2026, 3, 6, 15, 30, 0, 0, time.UTC)
+
+	if promotionIsActive(happyHour, at, time.UTC) {
+		t.Error("happy hour is active at 15:30 UTC")
+	}
+	if !promotionIsActive(happyHour, at, time.FixedZone("UTC+2", 2*60*60)) {
+		t.Error("happy hour isn't active at 17:30 in a restaurant two hours ahead of UTC")
+	}
+
+	if svc := New(Deps{}); svc.Orders.location != time.Local {
+		t.Errorf("default location = %v, want the local time zone", svc.Orders.location)
+	}
+}
+
+func TestPromotionIsActive(t *testing.T) {
+	yes, no := true, false
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	clock := func(value string) *string { return &value }
+	at := func(hour, minute int) time.Time { return time.Date(2026, 3, 6, hour, minute, 0, 0, time.UTC) }
+
+	for _, test := range []struct {
+		name      string
+		promotion models.Promotion
+		at        time.Time
+		want      bool
+	}{
+		{"no terms", models.Promotion{}, at(12, 0), true},
+		{"disabled", models.Promotion{Active: &no}, at(12, 0), false},
+		{"enabled", models.Promotion{Active: &yes}, at(12, 0), true},
+		{"before the start date", models.Promotion{Start_Date: &until}, at(12, 0), false},
+		{"after the end date", models.Promotion{End_Date: &from}, at(12, 0), false},
+		{"between the dates", models.Promotion{Start_Date: &from, End_Date: &until}, at(12, 0), true},
+		{"inside the window", models.Promotion{Start_time: clock("17:00"), End_time: clock("19:00")}, at(17, 0), true},
+		{"at the end of the window", models.Promotion{Start_time: clock("17:00"), End_time: clock("19:00")}, at(19, 0), false},
+		{"before the window", models.Promotion{Start_time: clock("17:00"), End_time: clock("19:00")}, at(16, 59), false},
+		{"late in a window past midnight", models.Promotion{Start_time: clock("22:00"), End_time: clock("02:00")}, at(23, 30), true},
+		{"early in a window past midnight", models.Promotion{Start_time: clock("22:00"), End_time: clock("02:00")}, at(1, 59), true},
+		{"after a window past midnight", models.Promotion{Start_time: clock("22:00"), End_time: clock("02:00")}, at(2, 0), false},
+		{"before a window past midnight", models.Promotion{Start_time: clock("22:00"), End_time: clock("02:00")}, at(21, 0), false},
+		{"unreadable window", models.Promotion{Start_time: clock("5pm"), End_time: clock("19:00")}, at(17, 30), false},
+	} {
+		if got := promotionIsActive(test.promotion, test.at, time.UTC); got != test.want {
+			t.Errorf("%s: active = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPromotionDiscount(t *testing.T) {
+	percentage, fixed, buyGet := "PERCENTAGE", "FIXED", "BUY_X_GET_Y"
+	number := func(value float64) *float64 { return &value }
+	count := func(value int) *int { return &value }
+	lines := func(prices ...float64) []promotionLine {
+		var lines []promotionLine
+		for _, price := range prices {
+			lines = append(lines, promotionLine{Price: price})
+		}
+		return lines
+	}
+
+	for _, test := range []struct {
+		name      string
+		promotion models.Promotion
+		lines     []promotionLine
+		want      float64
+	}{
+		{"percentage", models.Promotion{Type: &percentage, Value: number(10)}, lines(4.5, 2), 0.65},
+		{"percentage over 100", models.Promotion{Type: &percentage, Value: number(150)}, lines(4.5, 2), 6.5},
+		{"fixed", models.Promotion{Type: &fixed, Value: number(3)}, lines(4.5, 2), 3},
+		{"fixed over the price", models.Promotion{Type: &fixed, Value: number(10)}, lines(4.5, 2), 6.5},
+		{"buy one get one by default", models.Promotion{Type: &buyGet}, lines(4.5, 2, 3), 2},
+		{"buy two get one", models.Promotion{Type: &buyGet, Buy_quantity: count(2), Get_quantity: count(1)}, lines(4.5, 2, 3), 2},
+		{"buy two get one, one group complete", models.Promotion{Type: &buyGet, Buy_quantity: count(2), Get_quantity: count(1)}, lines(4.5, 2, 3, 1), 1},
+		{"buy two get one, no group complete", models.Promotion{Type: &buyGet, Buy_quantity: count(2), Get_quantity: count(1)}, lines(4.5, 2), 0},
+		{"no lines", models.Promotion{Type: &fixed, Value: number(3)}, nil, 0},
+	} {
+		if got := promotionDiscount(test.promotion, test.lines); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: discount = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestApplyPromotions(t *testing.T) {
+	percentage, fixed := "PERCENTAGE", "FIXED"
+	yes := true
+	text := func(value string) *string { return &value }
+	number := func(value float64) *float64 { return &value }
+	promotion := func(id string, kind *string, value float64) models.Promotion {
+		return models.Promotion{Promotion_id: id, Name: text(id), Type: kind, Value: number(value)}
+	}
+	lines := []promotionLine{
+		{Order_item_id: "i1", Food_id: "f1", Menu_id: "m1", Price: 4.5},
+		{Order_item_id: "i2", Food_id: "f2", Menu_id: "m1", Price: 2},
+		{Order_item_id: "i3", Food_id: "f3", Menu_id: "m2", Price: 6},
+	}
+	at := time.Date(2026, 3, 6, 12, 0, 0, 0, time.UTC)
+
+	soupOff := promotion("soup", &fixed, 1)
+	soupOff.Food_id = text("f1")
+	breadless := promotion("breadless", &fixed, 1)
+	breadless.Food_id = text("f9")
+	starters := promotion("starters", &percentage, 50)
+	starters.Menu_id = text("m1")
+	couponOnly := promotion("coupon", &fixed, 2)
+	couponOnly.Coupon_only = &yes
+	coupon := &models.Coupon{Code: text("SAVE2"), Promotion_id: text("coupon")}
+
+	for _, test := range []struct {
+		name       string
+		promotions []models.Promotion
+		coupon     *models.Coupon
+		want       []string
+		discount   float64
+	}{
+		{"scoped to a food", []models.Promotion{soupOff}, nil, []string{"soup"}, 1},
+		{"scoped to a menu", []models.Promotion{starters}, nil, []string{"starters"}, 3.25},
+		{"scoped to a food nobody ordered", []models.Promotion{breadless}, nil, nil, 0},
+		{"coupon-only without the coupon", []models.Promotion{couponOnly}, nil, nil, 0},
+		{"coupon-only with its coupon", []models.Promotion{couponOnly}, coupon, []string{"coupon"}, 2},
+		{"coupon for another promotion", []models.Promotion{couponOnly}, &models.Coupon{Code: text("OTHER"), Promotion_id: text("soup")}, nil, 0},
+		{"stacked", []models.Promotion{soupOff, starters, couponOnly}, coupon, []string{"soup", "starters", "coupon"}, 6.25},
+		{"never more than the subtotal", []models.Promotion{promotion("half", &percentage, 50), promotion("ten", &fixed, 10)}, nil, []string{"half", "ten"}, 12.5},
+	} {
+		applied, discount := applyPromotions(lines, test.promotions, test.coupon, at, time.UTC)
+
+		var got []string
+		for _, promotion := range applied {
+			got = append(got, promotion.Promotion_id)
+			if promotion.Promotion_id == "coupon" && promotion.Coupon_code != "SAVE2" {
+				t.Errorf("%s: coupon code = %q, want SAVE2", test.name, promotion.Coupon_code)
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(test.want, ",") || discount != test.discount {
+			t.Errorf("%s: applied %v for %v, want %v for %v", test.name, got, discount, test.want, test.discount)
+		}
+	}
+}
+
+func TestCouponsStopApplyingAtTheirUsageLimit(t *testing.T) {
+	ctx, svc, _ := setupServicesTest(t, LoginPolicy{})
+
+	name, fixed, yes, code, limit := "Two off", "FIXED", true, "save2", 1
+	value := 2.0
+	promotion, err := svc.Promotions.Create(ctx, "MANAGER", models.Promotion{Name: &name, Type: &fixed, Value: &value, Coupon_only: &yes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Promotions.CreateCoupon(ctx, "MANAGER", models.Coupon{Code: &code, Promotion_id: &promotion.Promotion_id, Usage_limit: &limit}); err != nil {
+		t.Fatal(err)
+	}
+
+	invoiceWithCoupon := func() (models.Invoice, error) {
+		order, _, err := svc.Orders.Place(ctx, "u1", "t1", orderItems("f1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return svc.Invoices.Create(ctx, "u1", models.Invoice{Order_id: order.Order_id, Coupon_code: &code})
+	}
+
+	invoice, err := invoiceWithCoupon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.Total != 2.5 || *invoice.Coupon_code != "SAVE2" {
+		t.Errorf("invoice with the coupon = %v with %q, want 2.5 with SAVE2", invoice.Total, *invoice.Coupon_code)
+	}
+	if _, err := invoiceWithCoupon(); !errors.Is(err, ErrInvalid) {
+		t.Errorf("coupon used past its limit: err = %v, want Invalid", err)
+	}
+}
+
+func TestAuditRecordsWhatChangedWithoutSecrets(t *testing.T) {
+	ctx, svc, repos := setupServicesTest(t, LoginPolicy{})
+
+	name := "Tomato soup"
+	if _, _, err := svc.Foods.Update(ctx, "MANAGER", "f1", Precondition{}, dto.UpdateFoodRequest{Name: &name}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.Audits.List(ctx, "STAFF", AuditQuery{}, Page{Limit: 10}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("audit log read by staff: err = %v, want Forbidden", err)
+	}
+	entries, err := svc.Audits.List(ctx, "MANAGER", AuditQuery{Entity: "food", Entity_id: "f1", Action: "UPDATE"}, Page{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("%d audit entries for the update, want 1", len(entries))
+	}
+	entry := entries[0]
+	if entry.User_id != "MANAGER" || entry.Before["name"] != "Soup" || entry.After["name"] != name {
+		t.Errorf("audit entry = %+v, want the manager renaming Soup", entry)
+	}
+	var fields []string
+	for _, change := range entry.Changes {
+		fields = append(fields, change.Field)
+		if change.Field == "name" && (change.Before != "Soup" || change.After != name) {
+			t.Errorf("name change = %v to %v, want Soup to %s", change.Before, change.After, name)
+		}
+	}
+	if strings.Join(fields, ",") != "name,updated_at,version" {
+		t.Errorf("changed fields = %v, want name, updated_at and version", fields)
+	}
+
+	var user models.User
+	repos.Users.FindOne(ctx, bson.M{"user_id": "STAFF"}).Decode(&user)
+	if document := auditDocument(user); document["password"] != nil || document["email"] != user.Email {
+		t.Errorf("audited user = %v, want it without the password", document)
+	}
+	if changes := auditChanges(nil, auditDocument(user)); changes != nil {
+		t.Errorf("changes of a create = %v, want none", changes)
+	}
+}
+
+func TestUpdateVersionedReportsMissingAndStaleDocuments(t *testing.T) {
+	ctx, svc, _ := setupServicesTest(t, LoginPolicy{})
+
+	name := "Broth"
+	change := dto.UpdateFoodRequest{Name: &name}
+
+	if _, _, err := svc.Foods.Update(ctx, "u1", "missing", Precondition{}, change); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown food: err = %v, want NotFound", err)
+	}
+
+	_, version, err := svc.Foods.Update(ctx, "u1", "f1", Expect(0), change)
+	if err != nil || version != 1 {
+		t.Fatalf("first update: version %d, err %v", version, err)
+	}
+
+	_, version, err = svc.Foods.Update(ctx, "u1", "f1", Expect(0), change)
+	if !errors.Is(err, ErrStale) || version != 1 {
+		t.Errorf("stale update: version %d, err %v, want Stale at version 1", version, err)
+	}
+
+	if _, err := svc.Foods.Delete(ctx, "MANAGER", "f1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := svc.Foods.Update(ctx, "u1", "f1", Precondition{}, change); !errors.Is(err, ErrNotFound) {
+		t.Errorf("update of a deleted food: err = %v, want NotFound", err)
+	}
+}
+
+func TestTokensAreHashedSingleUseAndExpire(t *testing.T) {
+	ctx, svc, repos := setupServicesTest(t, LoginPolicy{})
+
+	token, _, err := svc.Users.issueToken(ctx, "STAFF", resetPassword, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := repos.UserTokens.CountDocuments(ctx, bson.M{"token_hash": token}); count != 0 {
+		t.Error("the token is stored in plain text")
+	}
+
+	if _, err := svc.Users.redeemToken(ctx, token, verifyEmail); !errors.Is(err, ErrInvalid) {
+		t.Errorf("redeemed for another purpose: err = %v, want Invalid", err)
+	}
+	if _, err := svc.Users.redeemToken(ctx, token, resetPassword); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Users.redeemToken(ctx, token, resetPassword); !errors.Is(err, ErrInvalid) {
+		t.Errorf("redeemed twice: err = %v, want Invalid", err)
+	}
+
+	expired, _, err := svc.Users.issueToken(ctx, "STAFF", resetPassword, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Users.redeemToken(ctx, expired, resetPassword); !errors.Is(err, ErrInvalid) {
+		t.Errorf("redeemed after expiry: err = %v, want Invalid", err)
+	}
+}
+
+func TestUnknownEmailsAndWrongPasswordsFailAlike(t *testing.T) {
+	ctx, svc, _ := setupServicesTest(t, LoginPolicy{})
+
+	var unknown, wrong *Error
+	_, err := svc.Users.Login(ctx, "nobody@example.com", "secret-pass", "")
+	if !errors.As(err, &unknown) {
+		t.Fatalf("unknown email: err = %v", err)
+	}
+	_, err = svc.Users.Login(ctx, "staff@example.com", "wrong-pass", "")
+	if !errors.As(err, &wrong) {
+		t.Fatalf("wrong password: err = %v", err)
+	}
+	if *unknown != *wrong || unknown.Kind != Unauthorized {
+		t.Errorf("unknown email = %+v, wrong password = %+v, want the same Unauthorized error", unknown, wrong)
+	}
+}
+
+func TestFailedLoginsBackOffThenLockUntilAManagerUnlocks(t *testing.T) {
+	ctx, svc, repos := setupServicesTest(t, LoginPolicy{BackoffAfter: 2, Backoff: time.Second, LockoutAfter: 4, Lockout: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Users.Login(ctx, "staff@example.com", "wrong", "10.0.0.1"); !errors.Is(err, ErrUnauthorized) {
+			t.Fatalf("failure %d: err = %v, want Unauthorized", i+1, err)
+		}
+	}
+
+	// the right password doesn't help while the email has to wait
+	_, err := svc.Users.Login(ctx, "STAFF@example.com", "secret-pass", "10.0.0.2")
+	var throttled *Error
+	if !errors.As(err, &throttled) || throttled.Kind != Throttled || throttled.RetryAfter <= 0 || throttled.RetryAfter > time.Second {
+		t.Fatalf("login while backing off: err = %v, want Throttled for up to a second", err)
+	}
+
+	// the waits double until the failure that locks the account
+	attempt, locked, err := svc.Users.recordFailure(ctx, svc.Users.policy, emailKey("staff@example.com"))
+	if err != nil || locked || attempt.Retry_at == nil || time.Until(*attempt.Retry_at) <= time.Second {
+		t.Fatalf("third failure = %+v, locked %v, err %v, want a wait of two seconds", attempt, locked, err)
+	}
+	event := bson.M{"email": "staff@example.com", "ip": "10.0.0.1"}
+	if err := svc.Users.loginFailed(ctx, "LOGIN_FAILED", "STAFF", event, loginKeys("staff@example.com", "10.0.0.1"), ErrUnauthorized); err != ErrUnauthorized {
+		t.Fatalf("loginFailed changed the error to %v", err)
+	}
+	if count, _ := repos.Audits.CountDocuments(ctx, bson.M{"entity_id": "STAFF", "action": "ACCOUNT_LOCKED"}); count != 1 {
+		t.Fatalf("%d ACCOUNT_LOCKED audit records, want 1", count)
+	}
+
+	if err := svc.Users.Unlock(ctx, "STAFF", "STAFF"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("unlock by staff: err = %v, want Forbidden", err)
+	}
+	if err := svc.Users.Unlock(ctx, "MANAGER", "STAFF"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Users.Login(ctx, "staff@example.com", "secret-pass", "10.0.0.1"); err != nil {
+		t.Errorf("login after unlocking: %v", err)
+	}
+}
+
+func TestTerminalSessionsEndWhenIdleOrWhenAnotherUserSignsIn(t *testing.T) {
+	ctx, svc, repos := setupServicesTest(t, LoginPolicy{})
+
+	terminal, key, err := svc.Terminals.Register(ctx, "MANAGER", models.Terminal{Name: "Front till"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, uid := range []string{"STAFF", "MANAGER"} {
+		if err := svc.Users.SetPin(ctx, uid, "secret-pass", "2468"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	session := func(uid string) string {
+		t.Helper()
+		if _, err := svc.Terminals.Login(ctx, terminal.Terminal_id, key, uid, "2468"); err != nil {
+			t.Fatal(err)
+		}
+		var current models.Terminal
+		repos.Terminals.FindOne(ctx, bson.M{"terminal_id": terminal.Terminal_id}).Decode(&current)
+		return current.Session_id
+	}
+
+	if _, err := svc.Terminals.Login(ctx, terminal.Terminal_id, "wrong-key", "STAFF", "2468"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("wrong terminal key: err = %v, want Unauthorized", err)
+	}
+
+	staff := session("STAFF")
+	if err := svc.Terminals.Touch(ctx, terminal.Terminal_id, staff); err != nil {
+		t.Fatalf("fresh session: %v", err)
+	}
+
+	repos.Terminals.UpdateOne(ctx, bson.M{"terminal_id": terminal.Terminal_id}, bson.D{{"$set", bson.D{{"session_expires_at", time.Now().Add(-time.Second)}}}})
+	if err := svc.Terminals.Touch(ctx, terminal.Terminal_id, staff); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("idle session: err = %v, want Unauthorized", err)
+	}
+
+	staff = session("STAFF")
+	manager := session("MANAGER")
+	if err := svc.Terminals.Touch(ctx, terminal.Terminal_id, staff); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("session after switching users: err = %v, want Unauthorized", err)
+	}
+	if err := svc.Terminals.Touch(ctx, terminal.Terminal_id, manager); err != nil {
+		t.Errorf("switched-to session: %v", err)
+	}
+
+	now := time.Now()
+	repos.Users.UpdateOne(ctx, bson.M{"user_id": "STAFF"}, bson.D{{"$set", bson.D{{"mfa_enabled_at", now}}}})
+	if _, err := svc.Terminals.Login(ctx, terminal.Terminal_id, key, "STAFF", "2468"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("PIN login of a user with MFA: err = %v, want Forbidden", err)
+	}
+	if _, err := svc.Terminals.Login(ctx, terminal.Terminal_id, key, "STAFF", "0000"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("wrong PIN of a user with MFA: err = %v, want Forbidden before the PIN is checked", err)
+	}
+	mfaRequired := New(Deps{Repos: repos, Keys: svc.Users.keys, Login: LoginPolicy{MfaRoles: []string{"MANAGER"}}})
+	if _, err := mfaRequired.Terminals.Login(ctx, terminal.Terminal_id, key, "MANAGER", "2468"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("PIN login of a role that needs MFA: err = %v, want Forbidden", err)
+	}
+}
+
+func TestMfaLoginsNeedAFreshCodeOrAnUnusedRecoveryCode(t *testing.T) {
+	ctx, svc, repos := setupServicesTest(t, LoginPolicy{})
+	outbox := mailer.NewOutbox()
+	svc = New(Deps{Repos: repos, Keys: svc.Users.keys, Mailer: outbox, Login: LoginPolicy{MfaRoles: []string{"MANAGER"}}})
+	code := func(secret string, ahead int64) string {
+		t.Helper()
+		code, err := totp.Code(secret, totp.Step(time.Now())+ahead)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	// a manager who never set MFA up is mailed a token to enroll with on their first login; a
+	// secret handed out before and never confirmed stops working
+	stale := "JBSWY3DPEHPK3PXP"
+	repos.Users.UpdateOne(ctx, bson.M{"user_id": "MANAGER"}, bson.D{{"$set", bson.D{{"mfa_secret", stale}}}})
+	result, err := svc.Users.Login(ctx, "manager@example.com", "secret-pass", "")
+	if err != nil || result.Challenge == nil || !result.Challenge.Enrollment_required || result.User.Token != "" {
+		t.Fatalf("login = %+v, %v, want an enrollment challenge and no tokens", result, err)
+	}
+	challenge := result.Challenge.Token
+	if _, err := svc.Users.LoginMfa(ctx, challenge, code(stale, 0), ""); !errors.Is(err, ErrConflict) {
+		t.Errorf("code of the unconfirmed secret: err = %v, want Conflict", err)
+	}
+	if _, err := svc.Users.EnrollAtLogin(ctx, challenge, "not-a-token"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("enrolling without the mailed token: err = %v, want Invalid", err)
+	}
+	sent := outbox.To("manager@example.com")
+	if len(sent) != 1 {
+		t.Fatalf("%d emails sent to the manager, want the enrollment token", len(sent))
+	}
+	enrollmentToken := mailedToken.FindString(sent[0].Body)
+	if _, err := svc.Users.EnrollAtLogin(ctx, "not-a-challenge", enrollmentToken); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("enrolling without the login: err = %v, want Unauthorized", err)
+	}
+	enrollment, err := svc.Users.EnrollAtLogin(ctx, challenge, enrollmentToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Users.EnrollAtLogin(ctx, challenge, enrollmentToken); !errors.Is(err, ErrInvalid) {
+		t.Errorf("reused enrollment token: err = %v, want Invalid", err)
+	}
+	secret := enrollment.Secret
+	if _, err := svc.Users.LoginMfa(ctx, challenge, "000000", ""); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("wrong code: err = %v, want Unauthorized", err)
+	}
+	result, err = svc.Users.LoginMfa(ctx, challenge, code(secret, 0), "")
+	if err != nil || result.User.Token == "" || len(result.Recovery_codes) != recoveryCodeCount {
+		t.Fatalf("enrolling login = %+v, %v, want tokens and recovery codes", result, err)
+	}
+	recoveryCodes := result.Recovery_codes
+
+	// the challenge and the code are both used up
+	if _, err := svc.Users.LoginMfa(ctx, challenge, code(secret, 1), ""); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("reused challenge: err = %v, want Unauthorized", err)
+	}
+	result, _ = svc.Users.Login(ctx, "manager@example.com", "secret-pass", "")
+	if result.Challenge.Enrollment_required || len(outbox.To("manager@example.com")) != 1 {
+		t.Errorf("challenge after enabling MFA asks for an enrollment")
+	}
+	if _, err := svc.Users.LoginMfa(ctx, result.Challenge.Token, code(secret, 0), ""); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("replayed code: err = %v, want Unauthorized", err)
+	}
+	if _, err := svc.Users.LoginMfa(ctx, result.Challenge.Token, strings.ToUpper(recoveryCodes[0]), ""); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	result, _ = svc.Users.Login(ctx, "manager@example.com", "secret-pass", "")
+	if _, err := svc.Users.LoginMfa(ctx, result.Challenge.Token, recoveryCodes[0], ""); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("used recovery code: err = %v, want Unauthorized", err)
+	}
+
+	// approvals need the code too, and the role can't turn MFA off
+	approval := Approval{Email: "manager@example.com", Password: "secret-pass"}
+	if _, err := svc.Users.AuthorizeManager(ctx, approval); !errors.Is(err, ErrForbidden) {
+		t.Errorf("approval without a code: err = %v, want Forbidden", err)
+	}
+	approval.Code = recoveryCodes[1]
+	if _, err := svc.Users.AuthorizeManager(ctx, approval); err != nil {
+		t.Errorf("approval with a recovery code: %v", err)
+	}
+	if err := svc.Users.DisableMfa(ctx, "MANAGER", "secret-pass", recoveryCodes[2]); !errors.Is(err, ErrForbidden) {
+		t.Errorf("disabling required MFA: err = %v, want Forbidden", err)
+	}
+
+	// staff aren't asked
+	if result, err := svc.Users.Login(ctx, "staff@example.com", "secret-pass", ""); err != nil || result.Challenge != nil || result.User.Token == "" {
+		t.Errorf("staff login = %+v, %v, want tokens", result, err)
+	}
+}
+
+func TestDeleteAndRestoreTable(t *testing.T) {
+	ctx, svc, _ := setupServicesTest(t, LoginPolicy{})
+
+	if _, err := svc.Tables.Delete(ctx, "STAFF", "t1"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("delete by staff: err = %v, want Forbidden", err)
+	}
+
+	if _, err := svc.Tables.Delete(ctx, "MANAGER", "t1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Tables.Delete(ctx, "MANAGER", "t1"); !errors.Is(err, ErrConflict) {
+		t.Errorf("second delete: err = %v, want Conflict", err)
+	}
+	if tables, _ := svc.Tables.List(ctx, false); len(tables) != 0 {
+		t.Errorf("listed %d tables after the delete, want none", len(tables))
+	}
+	if tables, _ := svc.Tables.List(ctx, true); len(tables) != 1 {
+		t.Errorf("listed %d tables including deleted ones, want 1", len(tables))
+	}
+
+	if _, err := svc.Tables.Restore(ctx, "MANAGER", "t1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Tables.Restore(ctx, "MANAGER", "t1"); !errors.Is(err, ErrConflict) {
+		t.Errorf("second restore: err = %v, want Conflict", err)
+	}
+	if _, err := svc.Tables.Delete(ctx, "MANAGER", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown table: err = %v, want NotFound", err)
+	}
+}
+
+func TestSignUpGivesRolesOnlyWhenAnAdminAsks(t *testing.T) {
+	ctx, svc, repos := setupServicesTest(t, LoginPolicy{})
+	newUser := func(n string, role string) models.User {
+		return models.User{First_name: "New", Last_name: "User", Email: n + "@example.com", Phone: n, Password: "secret-pass", Role: role}
+	}
+
+	user, err := svc.Users.SignUp(ctx, "STAFF", newUser("plain", ""))
+	if err != nil || user.Role != "STAFF" {
+		t.Fatalf("sign up without a role = %q, %v, want STAFF", user.Role, err)
+	}
+	var stored models.User
+	repos.Users.FindOne(ctx, bson.M{"user_id": user.User_id}).Decode(&stored)
+	if stored.Token != "" || stored.Refresh_Token != "" {
+		t.Error("signing up issued tokens, want them issued only at login")
+	}
+	for _, uid := range []string{"", "STAFF", "MANAGER"} {
+		if _, err := svc.Users.SignUp(ctx, uid, newUser("by-"+uid, "ADMIN")); !errors.Is(err, ErrForbidden) {
+			t.Errorf("ADMIN signed up by %q: err = %v, want Forbidden", uid, err)
+		}
+	}
+	if user, err := svc.Users.SignUp(ctx, "ADMIN", newUser("by-admin", "MANAGER")); err != nil || user.Role != "MANAGER" {
+		t.Errorf("MANAGER signed up by an admin = %q, %v", user.Role, err)
+	}
+
+	if _, err := svc.Users.SignUp(ctx, "STAFF", newUser("plain", "")); !errors.Is(err, ErrConflict) {
+		t.Errorf("taken email: err = %v, want Conflict", err)
+	}
+	if _, err := svc.Users.Delete(ctx, "MANAGER", user.User_id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Users.SignUp(ctx, "STAFF", newUser("plain", "")); err != nil {
+		t.Errorf("email of a deleted user: err = %v, want it free again", err)
+	}
+}
+
+func TestRefundCountsEachItemOnceAndLeavesRefundedItemsOutOfPricing(t *testing.T) {
+	ctx, svc, _ := setupServicesTest(t, LoginPolicy{})
+
+	order, items, err := svc.Orders.Place(ctx, "u1", "t1", orderItems("f1", "f2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	paid, card := "PAID", "CARD"
+	invoice, err := svc.Invoices.Create(ctx, "u1", models.Invoice{Order_id: order.Order_id, Payment_status: &paid, Payment_method: &card})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager := Approval{Email: "manager@example.com", Password: "secret-pass"}
+	soup := items[0].Order_item_id
+	refund, err := svc.Invoices.Refund(ctx, "u1", invoice.Invoice_id, "cold", []string{soup, soup}, manager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refund.Amount != 4.5 || len(refund.Order_item_ids) != 1 {
+		t.Errorf("refund of the soup given twice = %v for %v, want 4.5 for one item", refund.Amount, refund.Order_item_ids)
+	}
+
+	lines, err := svc.Invoices.orderPromotionLines(ctx, order.Order_id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 || lines[0].Order_item_id != items[1].Order_item_id {
+		t.Errorf("priced lines after the refund = %+v, want only the bread", lines)
+	}
+}
+
+func TestUpdateItemRepricesPendingInvoicesAndLeavesSettledItemsAlone(t *testing.T) {
+	ctx, svc, _ := setupServicesTest(t, LoginPolicy{})
+
+	order, items, err := svc.Orders.Place(ctx, "u1", "t1", orderItems("f1", "f2", "f2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	invoice, err := svc.Invoices.Create(ctx, "u1", models.Invoice{Order_id: order.Order_id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	soup := "f1"
+	if _, _, err := svc.Orders.UpdateItem(ctx, "u1", items[1].Order_item_id, Precondition{}, dto.UpdateOrderItemRequest{Food_id: &soup}); err != nil {
+		t.Fatal(err)
+	}
+	view, err := svc.Invoices.Get(ctx, invoice.Invoice_id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.Total != 11 {
+		t.Errorf("total after swapping the bread for soup = %v, want 11", view.Total)
+	}
+
+	manager := Approval{Email: "manager@example.com", Password: "secret-pass"}
+	if _, err := svc.Orders.VoidItem(ctx, "u1", items[2].Order_item_id, "dropped", manager); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := svc.Orders.UpdateItem(ctx, "u1", items[2].Order_item_id, Precondition{}, dto.UpdateOrderItemRequest{Food_id: &soup}); !errors.Is(err, ErrConflict) {
+		t.Errorf("updating a voided item: err = %v, want Conflict", err)
+	}
+
+	paid := "PAID"
+	if _, _, err := svc.Invoices.Update(ctx, "u1", invoice.Invoice_id, Precondition{}, dto.UpdateInvoiceRequest{Payment_status: &paid}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := svc.Orders.UpdateItem(ctx, "u1", items[0].Order_item_id, Precondition{}, dto.UpdateOrderItemRequest{Food_id: &soup}); !errors.Is(err, ErrConflict) {
+		t.Errorf("updating an item of a paid order: err = %v, want Conflict", err)
+	}
+}
+
+func TestPaidInvoicesKeepThePaymentMethodTheyAreRefundedTo(t *testing.T) {
+	ctx, svc, repos := setupServicesTest(t, LoginPolicy{})
+
+	order, _, err := svc.Orders.Place(ctx, "u1", "t1", orderItems("f1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	invoice, err := svc.Invoices.Create(ctx, "u1", models.Invoice{Order_id: order.Order_id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paid, cash, card := "PAID", "CASH", "CARD"
+	if _, _, err := svc.Invoices.Update(ctx, "u1", invoice.Invoice_id, Precondition{}, dto.UpdateInvoiceRequest{Payment_method: &card}); err != nil {
+		t.Fatalf("changing the method of a pending invoice: %v", err)
+	}
+	if _, _, err := svc.Invoices.Update(ctx, "u1", invoice.Invoice_id, Precondition{}, dto.UpdateInvoiceRequest{Payment_method: &cash, Payment_status: &paid}); err != nil {
+		t.Fatal(err)
+	}
+	var stored models.Invoice
+	repos.Invoices.FindOne(ctx, bson.M{"invoice_id": invoice.Invoice_id}).Decode(&stored)
+	if stored.Paid_at == nil {
+		t.Error("paying the invoice didn't record when")
+	}
+	if _, _, err := svc.Invoices.Update(ctx, "u1", invoice.Invoice_id, Precondition{}, dto.UpdateInvoiceRequest{Payment_method: &card}); !errors.Is(err, ErrConflict) {
+		t.Errorf("changing the method of a paid invoice: err = %v, want Conflict", err)
+	}
+
+	refund, err := svc.Invoices.Refund(ctx, "u1", invoice.Invoice_id, "cold", nil, Approval{Email: "manager@example.com", Password: "secret-pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refund.Payment_method != cash {
+		t.Errorf("refunded to %q, want the CASH the invoice was paid with", refund.Payment_method)
+	}
+}
+
+func TestSalesReportCountsPaymentsAndRefundsWhenTheyWereMade(t *testing.T) {
+	ctx, svc, repos := setupServicesTest(t, LoginPolicy{})
+
+	january := time.Date(2026, time.January, 15, 12, 0, 0, 0, time.UTC)
+	february := time.Date(2026, time.February, 15, 12, 0, 0, 0, time.UTC)
+	endOfJanuary := time.Date(2026, time.January, 30, 22, 0, 0, 0, time.UTC)
+	secondOfFebruary := time.Date(2026, time.February, 2, 12, 0, 0, 0, time.UTC)
+	refunded, paid, card, cash := "PARTIALLY_REFUNDED", "PAID", "CARD", "CASH"
+	repos.Invoices.InsertOne(ctx, models.Invoice{ID: primitive.NewObjectID(), Invoice_id: "i1", Payment_status: &refunded, Payment_method: &card,
+		Subtotal: 20, Total: 20, Refunded_amount: 5, Created_at: january, Paid_at: &january})
+	// billed in january, paid in february
+	repos.Invoices.InsertOne(ctx, models.Invoice{ID: primitive.NewObjectID(), Invoice_id: "i2", Payment_status: &paid, Payment_method: &cash,
+		Subtotal: 10, Total: 10, Created_at: endOfJanuary, Paid_at: &secondOfFebruary})
+	repos.Refunds.InsertOne(ctx, models.Refund{ID: primitive.NewObjectID(), Refund_id: "r1", Invoice_id: "i1", Amount: 5, Payment_method: card, Created_at: february})
+
+	month := func(at time.Time) Period {
+		start := time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, time.UTC)
+		end := start.AddDate(0, 1, 0)
+		return Period{From: &start, To: &end}
+	}
+
+	if _, err := svc.Reports.Sales(ctx, "STAFF", month(january)); !errors.Is(err, ErrForbidden) {
+		t.Errorf("report for staff: err = %v, want Forbidden", err)
+	}
+
+	report, err := svc.Reports.Sales(ctx, "MANAGER", month(january))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Totals.Invoices != 1 || report.Totals.Refunds != 0 || report.Totals.Net_sales != 20 {
+		t.Errorf("january = %+v, want the invoice paid then without its later refund", report.Totals)
+	}
+
+	report, err = svc.Reports.Sales(ctx, "MANAGER", month(february))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Totals.Invoices != 1 || report.Totals.Refunds != 5 || report.Totals.Net_sales != 5 {
+		t.Errorf("february = %+v, want the invoice paid then and the refund", report.Totals)
+	}
+}
+
+// mailedToken finds the token in an email.
+var mailedToken = regexp.MustCompile(`(?m)^[A-Za-z0-9_-]{43}$`)
+
+// failingMailer can't send anything.
+type failingMailer struct{}
+
+func (failingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	return errors.New("mail server unreachable")
+}
+
+func TestPasswordResetsAnswerTheSameAreThrottledAndSignOutOldTokens(t *testing.T) {
+	ctx, _, repos := setupServicesTest(t, LoginPolicy{})
+	keys, err := signing.Generate("test", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := New(Deps{Repos: repos, Keys: keys, Mailer: failingMailer{}, Login: LoginPolicy{BackoffAfter: 3, Backoff: time.Minute}})
+
+	for _, email := range []string{"staff@example.com", "nobody@example.com"} {
+		if err := svc.Users.RequestPasswordReset(ctx, email, "10.0.0.1"); err != nil {
+			t.Errorf("reset for %s: err = %v, want the same answer whether or not it was mailed", email, err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		svc.Users.RequestPasswordReset(ctx, "nobody@example.com", "10.0.0.2")
+	}
+	if err := svc.Users.RequestPasswordReset(ctx, "nobody@example.com", "10.0.0.3"); !errors.Is(err, ErrThrottled) {
+		t.Errorf("fourth reset for an email: err = %v, want Throttled", err)
+	}
+
+	verification, _, err := svc.Users.issueToken(ctx, "STAFF", verifyEmail, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reset, _, err := svc.Users.issueToken(ctx, "STAFF", resetPassword, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Users.ResetPassword(ctx, reset, "brand-new-pass"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.Users.CheckActive(ctx, "STAFF", 0); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("token from before the reset: err = %v, want Unauthorized", err)
+	}
+	if _, err := svc.Users.VerifyEmail(ctx, verification); !errors.Is(err, ErrInvalid) {
+		t.Errorf("verification mailed before the reset: err = %v, want Invalid", err)
+	}
+	login, err := svc.Users.Login(ctx, "staff@example.com", "brand-new-pass", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Users.CheckActive(ctx, "STAFF", login.User.Token_generation); err != nil {
+		t.Errorf("token from after the reset: err = %v", err)
+	}
+}
+
+func TestManagerApprovalsAreDeniedAlikeAndThrottledLikeLogins(t *testing.T) {
+	ctx, svc, _ := setupServicesTest(t, LoginPolicy{BackoffAfter: 3, Backoff: time.Minute})
+
+	var denials []string
+	for _, approval := range []Approval{
+		{Email: "nobody@example.com", Password: "secret-pass"},
+		{Email: "staff@example.com", Password: "secret-pass"},
+		{Email: "manager@example.com", Password: "wrong-pass", IP: "10.0.0.1"},
+	} {
+		_, err := svc.Users.AuthorizeManager(ctx, approval)
+		if !errors.Is(err, ErrForbidden) {
+			t.Fatalf("approval by %s: err = %v, want Forbidden", approval.Email, err)
+		}
+		denials = append(denials, err.Error())
+	}
+	if denials[0] != denials[1] || denials[1] != denials[2] {
+		t.Errorf("denials differ: %q", denials)
+	}
+
+	for i := 0; i < 2; i++ {
+		svc.Users.AuthorizeManager(ctx, Approval{Email: "manager@example.com", Password: "wrong-pass"})
+	}
+	if _, err := svc.Users.AuthorizeManager(ctx, Approval{Email: "manager@example.com", Password: "secret-pass"}); !errors.Is(err, ErrThrottled) {
+		t.Errorf("approval after three wrong passwords: err = %v, want Throttled", err)
+	}
+	if _, err := svc.Users.Login(ctx, "manager@example.com", "secret-pass", ""); !errors.Is(err, ErrThrottled) {
+		t.Errorf("login after three wrong approvals: err = %v, want Throttled", err)
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/dto"
+	"github.com/mayankr5/v1/restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TableService keeps the tables orders are placed at.
+type TableService struct {
+	*base
+}
+
+// List returns the tables, without the deleted ones unless includeDeleted is set.
+func (s *TableService) List(ctx context.Context, includeDeleted bool) ([]models.Table, error) {
+	result, err := s.repos.Tables.Find(ctx, listFilter(includeDeleted))
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close(ctx)
+
+	var allTables []models.Table
+	if err := result.All(ctx, &allTables); err != nil {
+		return nil, err
+	}
+	return allTables, nil
+}
+
+func (s *TableService) Get(ctx context.Context, tableId string) (models.Table, error) {
+	var table models.Table
+
+	err := s.repos.Tables.FindOne(ctx, bson.M{"table_id": tableId}).Decode(&table)
+	return table, lookupFailed(err, "table")
+}
+
+// Create adds the table. Table numbers are unique.
+func (s *TableService) Create(ctx context.Context, uid string, table models.Table) (models.Table, error) {
+	table.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	table.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	table.ID = primitive.NewObjectID()
+	table.Version = 1
+	table.Table_id = table.ID.Hex()
+	table.Deleted_at = nil
+
+	if _, err := s.repos.Tables.InsertOne(ctx, table); err != nil {
+		return table, writeFailed(err, "a table with this number already exists")
+	}
+	s.recordAudit(ctx, uid, "table", table.Table_id, "CREATE", nil, table)
+	return table, nil
+}
+
+// Update renumbers the table or changes how many guests it seats.
+func (s *TableService) Update(ctx context.Context, uid string, tableId string, expect Precondition, change dto.UpdateTableRequest) (*mongo.UpdateResult, int, error) {
+	var updateObj primitive.D
+
+	if change.Number_of_guests != nil {
+		updateObj = append(updateObj, bson.E{"number_of_guests", change.Number_of_guests})
+	}
+
+	if change.Table_number != nil {
+		updateObj = append(updateObj, bson.E{"table_number", change.Table_number})
+	}
+
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	updateObj = append(updateObj, bson.E{"updated_at", updatedAt})
+
+	return s.updateVersioned(ctx, uid, s.repos.Tables, "table", "table_id", tableId, expect, updateObj)
+}
+
+// Delete soft-deletes the table; orders placed at it keep resolving.
+func (s *TableService) Delete(ctx context.Context, uid string, tableId string) (*mongo.UpdateResult, error) {
+	return s.archive(ctx, uid, s.repos.Tables, "table", "table_id", tableId)
+}
+
+func (s *TableService) Restore(ctx context.Context, uid string, tableId string) (*mongo.UpdateResult, error) {
+	return s.unarchive(ctx, uid, s.repos.Tables, "table", "table_id", tableId)
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"time"
+
+	helper "github.com/mayankr5/v1/restaurant-management/helpers"
+	"github.com/mayankr5/v1/restaurant-management/logger"
+	"github.com/mayankr5/v1/restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// TerminalTokenTTL is how long the token of a PIN login lasts, however busy the user is.
+	TerminalTokenTTL = time.Hour
+	// TerminalIdleTimeout ends a session on a terminal that hasn't been used for that long.
+	TerminalIdleTimeout = 5 * time.Minute
+)
+
+// TerminalService registers shared POS terminals and signs staff in on them with their PIN.
+type TerminalService struct {
+	*base
+}
+
+// TerminalLogin is a user's session on a terminal.
+type TerminalLogin struct {
+	User       models.User
+	Token      string
+	Expires_at time.Time
+}
+
+func (s *TerminalService) List(ctx context.Context) ([]models.Terminal, error) {
+	result, err := s.repos.Terminals.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close(ctx)
+
+	var allTerminals []models.Terminal
+	if err := result.All(ctx, &allTerminals); err != nil {
+		return nil, err
+	}
+	return allTerminals, nil
+}
+
+// Register adds a terminal and returns it with its key, which the terminal sends on every PIN
+// login. The key is not stored and can't be shown again. uid must be a MANAGER or ADMIN.
+func (s *TerminalService) Register(ctx context.Context, uid string, terminal models.Terminal) (models.Terminal, string, error) {
+	if err := s.requireManager(ctx, uid, "registering a terminal"); err != nil {
+		return terminal, "", err
+	}
+
+	key, err := randomToken()
+	if err != nil {
+		return terminal, "", err
+	}
+
+	terminal.ID = primitive.NewObjectID()
+	terminal.Terminal_id = terminal.ID.Hex()
+	terminal.Key_hash = hashToken(key)
+	terminal.Registered_by = uid
+	terminal.Created_at = time.Now()
+	terminal.Updated_at = terminal.Created_at
+	terminal.Version = 1
+
+	if _, err := s.repos.Terminals.InsertOne(ctx, terminal); err != nil {
+		return terminal, "", err
+	}
+	s.recordAudit(ctx, uid, "terminal", terminal.Terminal_id, "CREATE", nil, terminal)
+	return terminal, key, nil
+}
+
+// Revoke retires the terminal. Its key stops working and whoever is signed in on it is signed out.
+// uid must be a MANAGER or ADMIN.
+func (s *TerminalService) Revoke(ctx context.Context, uid string, terminalId string) error {
+	if err := s.requireManager(ctx, uid, "revoking a terminal"); err != nil {
+		return err
+	}
+
+	filter := bson.M{"terminal_id": terminalId, "revoked_at": nil}
+	before := Snapshot(ctx, s.repos.Terminals, filter)
+	if before == nil {
+		return errorf(NotFound, "terminal was not found")
+	}
+
+	now := time.Now()
+	_, err := s.repos.Terminals.UpdateOne(
+		ctx,
+		filter,
+		bson.D{
+			{"$set", bson.D{{"revoked_at", now}, {"updated_at", now}, {"session_id", ""}, {"user_id", ""}, {"session_expires_at", nil}}},
+			{"$inc", bson.D{{"version", 1}}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	s.recordAudit(ctx, uid, "terminal", terminalId, "REVOKE", before, Snapshot(ctx, s.repos.Terminals, bson.M{"terminal_id": terminalId}))
+	return nil
+}
+
+// Login signs the user in on the terminal with their PIN, signing out whoever was signed in on it
+// before. Wrong PINs are throttled per user like failed logins, see LoginPolicy. Users who need MFA
+// are Forbidden to sign in with a PIN.
+func (s *TerminalService) Login(ctx context.Context, terminalId string, key string, userId string, pin string) (TerminalLogin, error) {
+	var login TerminalLogin
+	var terminal models.Terminal
+
+	// an unknown and a revoked terminal or a wrong key look the same
+	err := s.repos.Terminals.FindOne(ctx, bson.M{"terminal_id": terminalId, "revoked_at": nil}).Decode(&terminal)
+	if err != nil || subtle.ConstantTimeCompare([]byte(hashToken(key)), []byte(terminal.Key_hash)) != 1 {
+		logger.FromContext(ctx).Warn("terminal login failed", "reason", "unknown terminal or wrong key", "terminal_id", terminalId)
+		return login, errorf(Unauthorized, "terminal is not registered")
+	}
+
+	if err := s.checkThrottle(ctx, pinKey(userId)); err != nil {
+		logger.FromContext(ctx).Warn("terminal login throttled", "terminal_id", terminalId, "user_id", userId)
+		return login, err
+	}
+
+	user := &login.User
+	err = s.repos.Users.FindOne(ctx, bson.M{"user_id": userId, "deleted_at": nil}).Decode(user)
+
+	// a PIN and the terminal's key don't make up for the code of a user who needs a second factor,
+	// so their PIN isn't even checked and guessing it doesn't clear their failed logins
+	if err == nil && s.needsMfa(ctx, *user) {
+		logger.FromContext(ctx).Warn("terminal login refused", "reason", "user needs MFA", "terminal_id", terminalId, "user_id", userId)
+		return login, errorf(Forbidden, "users with MFA can't sign in with a PIN, log in with the password and code")
+	}
+
+	if err != nil || user.Pin == "" || bcrypt.CompareHashAndPassword([]byte(user.Pin), []byte(pin)) != nil {
+		logger.FromContext(ctx).Warn("terminal login failed", "reason", "wrong PIN", "terminal_id", terminalId, "user_id", userId)
+		event := bson.M{"terminal_id": terminalId}
+		return login, s.loginFailed(ctx, "PIN_LOGIN_FAILED", userId, event, []string{pinKey(userId)}, errorf(Unauthorized, "user or PIN is incorrect"))
+	}
+	s.clearFailures(ctx, pinKey(userId))
+
+	now := time.Now()
+	idle := now.Add(TerminalIdleTimeout)
+	sessionId := primitive.NewObjectID().Hex()
+	_, err = s.repos.Terminals.UpdateOne(
+		ctx,
+		bson.M{"terminal_id": terminalId},
+		bson.D{
+			{"$set", bson.D{{"session_id", sessionId}, {"user_id", userId}, {"last_seen_at", now}, {"session_expires_at", idle}, {"updated_at", now}}},
+			{"$inc", bson.D{{"version", 1}}},
+		},
+	)
+	if err != nil {
+		return login, err
+	}
+
+	login.Token, login.Expires_at, err = helper.GenerateTerminalToken(s.keys, user.Email, user.First_name, user.Last_name, user.User_id, user.Token_generation, terminalId, sessionId, TerminalTokenTTL)
+	if err != nil {
+		return login, fmt.Errorf("signing the token: %w", err)
+	}
+	logger.FromContext(ctx).Info("terminal login succeeded", "terminal_id", terminalId, "user_id", userId, "previous_user_id", terminal.User_id)
+	s.recordAudit(ctx, userId, "terminal", terminalId, "SIGN_IN", terminal, Snapshot(ctx, s.repos.Terminals, bson.M{"terminal_id": terminalId}))
+	return login, nil
+}
+
+// Logout ends the user's session on the terminal, so the next user can sign in.
+func (s *TerminalService) Logout(ctx context.Context, uid string, terminalId string, sessionId string) error {
+	if terminalId == "" {
+		return errorf(Invalid, "only sessions on a terminal can be signed out")
+	}
+
+	filter := bson.M{"terminal_id": terminalId, "session_id": sessionId}
+	before := Snapshot(ctx, s.repos.Terminals, filter)
+	now := time.Now()
+	result, err := s.repos.Terminals.UpdateOne(
+		ctx,
+		filter,
+		bson.D{
+			{"$set", bson.D{{"session_id", ""}, {"user_id", ""}, {"session_expires_at", nil}, {"updated_at", now}}},
+			{"$inc", bson.D{{"version", 1}}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errorf(Conflict, "the session has already ended")
+	}
+	s.recordAudit(ctx, uid, "terminal", terminalId, "SIGN_OUT", before, Snapshot(ctx, s.repos.Terminals, bson.M{"terminal_id": terminalId}))
+	return nil
+}
+
+// Touch checks that the session is still the terminal's current one and hasn't been idle for
+// longer than TerminalIdleTimeout, and keeps it alive for another TerminalIdleTimeout. The
+// authentication middleware calls it for every request made with a terminal token.
+func (s *TerminalService) Touch(ctx context.Context, terminalId string, sessionId string) error {
+	now := time.Now()
+	result, err := s.repos.Terminals.UpdateOne(
+		ctx,
+		bson.M{"terminal_id": terminalId, "session_id": sessionId, "revoked_at": nil, "session_expires_at": bson.M{"$gt": now}},
+		bson.D{{"$set", bson.D{{"last_seen_at", now}, {"session_expires_at", now.Add(TerminalIdleTimeout)}}}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errorf(Unauthorized, "the session on this terminal has ended, sign in with your PIN again")
+	}
+	return nil
+}
+
+// requireManager is Forbidden unless uid is a MANAGER or ADMIN; action says what needs one.
+func (s *base) requireManager(ctx context.Context, uid string, action string) error {
+	var caller models.User
+	if err := s.repos.Users.FindOne(ctx, bson.M{"user_id": uid, "deleted_at": nil}).Decode(&caller); err != nil || !isManager(caller) {
+		return errorf(Forbidden, "%s requires a manager", action)
+	}
+	return nil
+}
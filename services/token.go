@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/mayankr5/v1/restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	verifyEmail   = "VERIFY_EMAIL"
+	resetPassword = "RESET_PASSWORD"
+	mfaLogin      = "MFA_LOGIN"
+	mfaEnroll     = "MFA_ENROLL"
+)
+
+var (
+	// VerificationTTL is how long an email verification token can be used.
+	VerificationTTL = 48 * time.Hour
+	// PasswordResetTTL is how long a password reset token can be used.
+	PasswordResetTTL = time.Hour
+)
+
+// issueToken creates a token for purpose that expires after ttl, and revokes the user's earlier
+// tokens for the same purpose so only the latest mail works. The token itself is returned once
+// and never stored.
+func (s *base) issueToken(ctx context.Context, userId string, purpose string, ttl time.Duration) (string, time.Time, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	_, err = s.repos.UserTokens.UpdateMany(
+		ctx,
+		bson.M{"user_id": userId, "purpose": purpose, "used_at": nil},
+		bson.D{{"$set", bson.D{{"used_at", now}}}},
+	)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("revoking earlier tokens: %w", err)
+	}
+
+	record := models.UserToken{
+		ID:         primitive.NewObjectID(),
+		User_id:    userId,
+		Purpose:    purpose,
+		Token_hash: hashToken(token),
+		Expires_at: now.Add(ttl),
+		Created_at: now,
+	}
+	record.Token_id = record.ID.Hex()
+
+	if _, err := s.repos.UserTokens.InsertOne(ctx, record); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, record.Expires_at, nil
+}
+
+// redeemToken uses up the token if it was issued for purpose and hasn't expired or been used.
+// Every way a token can be wrong is the same Invalid error, so tokens can't be probed.
+func (s *base) redeemToken(ctx context.Context, token string, purpose string) (models.UserToken, error) {
+	invalid := errorf(Invalid, "token is invalid or has expired")
+
+	record, err := s.findToken(ctx, token, purpose)
+	if err != nil {
+		return record, err
+	}
+
+	// only the first of two concurrent redemptions matches
+	result, err := s.repos.UserTokens.UpdateOne(
+		ctx,
+		bson.M{"token_id": record.Token_id, "used_at": nil},
+		bson.D{{"$set", bson.D{{"used_at", time.Now()}}}},
+	)
+	if err != nil {
+		return record, err
+	}
+	if result.MatchedCount == 0 {
+		return record, invalid
+	}
+	return record, nil
+}
+
+// findToken returns the token if it was issued for purpose and hasn't expired or been used,
+// without using it up.
+func (s *base) findToken(ctx context.Context, token string, purpose string) (models.UserToken, error) {
+	var record models.UserToken
+	invalid := errorf(Invalid, "token is invalid or has expired")
+
+	err := s.repos.UserTokens.FindOne(ctx, bson.M{"token_hash": hashToken(token), "purpose": purpose}).Decode(&record)
+	if err != nil {
+		return record, invalid
+	}
+	if record.Used_at != nil || !time.Now().Before(record.Expires_at) {
+		return record, invalid
+	}
+	return record, nil
+}
+
+// randomToken returns 32 random bytes, URL-safe encoded.
+func randomToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// hashToken is what is stored of a token. Tokens are random, so an unsalted hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
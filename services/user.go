@@ -0,0 +1,519 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	helper "github.com/mayankr5/v1/restaurant-management/helpers"
+	"github.com/mayankr5/v1/restaurant-management/logger"
+	"github.com/mayankr5/v1/restaurant-management/mailer"
+	"github.com/mayankr5/v1/restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserService signs users up and in, and checks the managers approving voids and refunds.
+type UserService struct {
+	*base
+}
+
+// SignUp creates the user with a hashed password and no tokens; they sign in with Login. New
+// users are STAFF; only an admin, as uid, may give them another role. The email and phone number must not belong to a user who isn't
+// deleted.
+func (s *UserService) SignUp(ctx context.Context, uid string, user models.User) (models.User, error) {
+	if user.Role == "" {
+		user.Role = "STAFF"
+	}
+	if user.Role != "STAFF" {
+		var caller models.User
+		err := s.repos.Users.FindOne(ctx, bson.M{"user_id": uid, "deleted_at": nil}).Decode(&caller)
+		if err != nil || caller.Role != "ADMIN" {
+			return user, errorf(Forbidden, "only an admin can sign up a user with the role %s", user.Role)
+		}
+	}
+
+	count, err := s.repos.Users.CountDocuments(ctx, bson.M{"email": user.Email, "deleted_at": nil})
+	if err != nil {
+		return user, fmt.Errorf("checking for the email: %w", err)
+	}
+
+	if count == 0 {
+		count, err = s.repos.Users.CountDocuments(ctx, bson.M{"phone": user.Phone, "deleted_at": nil})
+		if err != nil {
+			return user, fmt.Errorf("checking for the phone number: %w", err)
+		}
+	}
+
+	if count > 0 {
+		return user, errorf(Conflict, "this email or phone number already exists")
+	}
+
+	password, err := HashPassword(user.Password)
+	if err != nil {
+		return user, fmt.Errorf("hashing the password: %w", err)
+	}
+	user.Password = password
+
+	user.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	user.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	user.ID = primitive.NewObjectID()
+	user.Version = 1
+	user.User_id = user.ID.Hex()
+	user.Deleted_at = nil
+
+	if _, err := s.repos.Users.InsertOne(ctx, user); err != nil {
+		// the checks above race with concurrent sign ups, the unique indexes don't
+		return user, writeFailed(err, "this email or phone number already exists")
+	}
+	s.recordAudit(ctx, uid, "user", user.User_id, "CREATE", nil, user)
+
+	// the user can ask for another mail, so a failed one doesn't undo the sign up
+	if err := s.sendVerification(ctx, user); err != nil {
+		logger.FromContext(ctx).Warn("verification email was not sent", "user_id", user.User_id, "error", err)
+	}
+	return user, nil
+}
+
+// CheckActive is Unauthorized unless the user exists, isn't deleted and their tokens are still of
+// generation, the one the token was issued in. The authentication middleware calls it for every
+// request, so deleting a user or resetting their password signs them out at once.
+func (s *UserService) CheckActive(ctx context.Context, userId string, generation int) error {
+	var user models.User
+
+	err := s.repos.Users.FindOne(ctx, bson.M{"user_id": userId, "deleted_at": nil}).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return errorf(Unauthorized, "this account has been deleted")
+	}
+	if err != nil {
+		return err
+	}
+	if generation != user.Token_generation {
+		return errorf(Unauthorized, "this token was revoked, log in again")
+	}
+	return nil
+}
+
+// List returns the number of users and the page of them, without the deleted ones unless
+// includeDeleted is set.
+func (s *UserService) List(ctx context.Context, page Page, includeDeleted bool) (int, []models.User, error) {
+	result, err := s.repos.Users.Aggregate(ctx, pagePipeline(listFilter(includeDeleted), page, "user_items"))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer result.Close(ctx)
+
+	var pages []struct {
+		Total_count int
+		User_items  []models.User
+	}
+	if err := result.All(ctx, &pages); err != nil {
+		return 0, nil, err
+	}
+	if len(pages) == 0 {
+		return 0, []models.User{}, nil
+	}
+	return pages[0].Total_count, pages[0].User_items, nil
+}
+
+func (s *UserService) Get(ctx context.Context, userId string) (models.User, error) {
+	var user models.User
+
+	err := s.repos.Users.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
+	return user, lookupFailed(err, "user")
+}
+
+// Delete soft-deletes the user, who can't sign in anymore.
+func (s *UserService) Delete(ctx context.Context, uid string, userId string) (*mongo.UpdateResult, error) {
+	return s.archive(ctx, uid, s.repos.Users, "user", "user_id", userId)
+}
+
+// Restore undoes Delete. It is a Conflict when another user has taken the email or phone number meanwhile.
+func (s *UserService) Restore(ctx context.Context, uid string, userId string) (*mongo.UpdateResult, error) {
+	return s.unarchive(ctx, uid, s.repos.Users, "user", "user_id", userId)
+}
+
+// RequestVerification mails the user a new email verification token. Earlier ones stop working.
+func (s *UserService) RequestVerification(ctx context.Context, userId string) error {
+	var user models.User
+
+	err := s.repos.Users.FindOne(ctx, bson.M{"user_id": userId, "deleted_at": nil}).Decode(&user)
+	if err != nil {
+		return lookupFailed(err, "user")
+	}
+	if user.Email_verified_at != nil {
+		return errorf(Conflict, "email is already verified")
+	}
+	return s.sendVerification(ctx, user)
+}
+
+// VerifyEmail uses up an email verification token and marks its user's email as verified.
+func (s *UserService) VerifyEmail(ctx context.Context, token string) (models.User, error) {
+	var user models.User
+
+	record, err := s.redeemToken(ctx, token, verifyEmail)
+	if err != nil {
+		return user, err
+	}
+
+	filter := bson.M{"user_id": record.User_id, "deleted_at": nil}
+	before := Snapshot(ctx, s.repos.Users, filter)
+	if before == nil {
+		return user, errorf(NotFound, "user was not found")
+	}
+
+	now := time.Now()
+	_, err = s.repos.Users.UpdateOne(
+		ctx,
+		filter,
+		bson.D{
+			{"$set", bson.D{{"email_verified_at", now}, {"updated_at", now}}},
+			{"$inc", bson.D{{"version", 1}}},
+		},
+	)
+	if err != nil {
+		return user, err
+	}
+
+	if err := s.repos.Users.FindOne(ctx, filter).Decode(&user); err != nil {
+		return user, err
+	}
+	s.recordAudit(ctx, user.User_id, "user", user.User_id, "VERIFY_EMAIL", before, user)
+	return user, nil
+}
+
+// RequestPasswordReset mails a password reset token to the user with the email. ip is the
+// client's address, or empty when there is none. Requests are throttled per email and per address
+// like failed logins. The answer is the same whether or not the email has an account and whether
+// or not the mail could be sent, so callers can't find out which addresses have an account.
+func (s *UserService) RequestPasswordReset(ctx context.Context, email string, ip string) error {
+	keys := resetKeys(email, ip)
+	if err := s.checkThrottle(ctx, keys...); err != nil {
+		var throttled *Error
+		if errors.As(err, &throttled) {
+			return &Error{Kind: Throttled, Message: "too many password reset requests, try again later", RetryAfter: throttled.RetryAfter}
+		}
+		return err
+	}
+	for _, key := range keys {
+		if _, _, err := s.recordFailure(ctx, s.policy, key); err != nil {
+			logger.FromContext(ctx).Error("counting a password reset request failed", "key", key, "error", err)
+		}
+	}
+
+	var user models.User
+
+	err := s.repos.Users.FindOne(ctx, bson.M{"email": email, "deleted_at": nil}).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			logger.FromContext(ctx).Info("password reset requested for an unknown email")
+			return nil
+		}
+		return err
+	}
+
+	token, expires, err := s.issueToken(ctx, user.User_id, resetPassword, PasswordResetTTL)
+	if err != nil {
+		logger.FromContext(ctx).Error("issuing the reset token failed", "user_id", user.User_id, "error", err)
+		return nil
+	}
+	logger.FromContext(ctx).Info("password reset requested", "user_id", user.User_id)
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"someone asked to reset the password of your account. To choose a new one, send this\n"+
+			"token with your new password to POST /users/password-reset/confirm:\n\n"+
+			"%s\n\n"+
+			"It can be used once, until %s. If you didn't ask for a reset, ignore this email;\n"+
+			"your password stays as it is.\n",
+			user.First_name, token, expires.UTC().Format(time.RFC1123)),
+	})
+	if err != nil {
+		logger.FromContext(ctx).Error("password reset email was not sent", "user_id", user.User_id, "error", err)
+	}
+	return nil
+}
+
+// ResetPassword uses up a password reset token and sets its user's password. Receiving the token
+// proves the user owns the address, so the email counts as verified from then on. The user's
+// tokens and their other mailed tokens are revoked, so whoever knew the old password is signed out.
+func (s *UserService) ResetPassword(ctx context.Context, token string, password string) error {
+	record, err := s.redeemToken(ctx, token, resetPassword)
+	if err != nil {
+		return err
+	}
+
+	var user models.User
+	filter := bson.M{"user_id": record.User_id, "deleted_at": nil}
+	if err := s.repos.Users.FindOne(ctx, filter).Decode(&user); err != nil {
+		return lookupFailed(err, "user")
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("hashing the password: %w", err)
+	}
+
+	now := time.Now()
+	update := bson.D{{"password", hash}, {"token", ""}, {"refresh_token", ""}, {"updated_at", now}}
+	if user.Email_verified_at == nil {
+		update = append(update, bson.E{"email_verified_at", now})
+	}
+
+	_, err = s.repos.Users.UpdateOne(
+		ctx,
+		filter,
+		bson.D{
+			{"$set", update},
+			// the tokens issued so far stop working
+			{"$inc", bson.D{{"version", 1}, {"token_generation", 1}}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if _, err := s.repos.UserTokens.UpdateMany(
+		ctx,
+		bson.M{"user_id": user.User_id, "used_at": nil},
+		bson.D{{"$set", bson.D{{"used_at", now}}}},
+	); err != nil {
+		logger.FromContext(ctx).Error("revoking the mailed tokens failed", "user_id", user.User_id, "error", err)
+	}
+	// whoever reset the password can read the user's mail, so a lockout no longer protects anything
+	s.clearFailures(ctx, emailKey(user.Email))
+	logger.FromContext(ctx).Info("password reset", "user_id", user.User_id)
+	s.recordAudit(ctx, user.User_id, "user", user.User_id, "PASSWORD_RESET", user, Snapshot(ctx, s.repos.Users, filter))
+	return nil
+}
+
+// sendVerification mails the user a new email verification token.
+func (s *UserService) sendVerification(ctx context.Context, user models.User) error {
+	token, expires, err := s.issueToken(ctx, user.User_id, verifyEmail, VerificationTTL)
+	if err != nil {
+		return fmt.Errorf("issuing the verification token: %w", err)
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"an account was created for you. To confirm this is your email address, send this\n"+
+			"token to POST /users/verification/confirm:\n\n"+
+			"%s\n\n"+
+			"It can be used once, until %s.\n",
+			user.First_name, token, expires.UTC().Format(time.RFC1123)),
+	})
+}
+
+// Login checks the credentials and signs the user in, with a new pair of tokens on the user. ip
+// is the client's address, or empty when there is none. Failed logins are throttled per email and
+// per address, see LoginPolicy; while either has to wait, Login fails with Throttled without
+// checking the password. A user who needs MFA gets a Challenge instead of tokens, which LoginMfa
+// answers with the second factor.
+func (s *UserService) Login(ctx context.Context, email string, password string, ip string) (LoginResult, error) {
+	var result LoginResult
+	user := &result.User
+
+	if err := s.checkThrottle(ctx, loginKeys(email, ip)...); err != nil {
+		logger.FromContext(ctx).Warn("login throttled", "error", err)
+		return result, err
+	}
+
+	// an unknown email and a wrong password answer alike, so logins can't tell which emails have
+	// an account
+	incorrect := errorf(Unauthorized, "login or password is incorrect")
+
+	err := s.repos.Users.FindOne(ctx, bson.M{"email": email, "deleted_at": nil}).Decode(user)
+	if err != nil {
+		// checking a password takes as long as for a known email
+		VerifyPassword(password, unknownUserHash)
+		logger.FromContext(ctx).Warn("login failed", "reason", "unknown email")
+		return result, s.loginFailed(ctx, "LOGIN_FAILED", "", bson.M{"email": email, "ip": ip}, loginKeys(email, ip), incorrect)
+	}
+
+	if passwordIsValid, _ := VerifyPassword(password, user.Password); !passwordIsValid {
+		logger.FromContext(ctx).Warn("login failed", "reason", "wrong password", "user_id", user.User_id)
+		return result, s.loginFailed(ctx, "LOGIN_FAILED", user.User_id, bson.M{"email": email, "ip": ip}, loginKeys(email, ip), incorrect)
+	}
+	s.clearFailures(ctx, emailKey(email))
+
+	if s.needsMfa(ctx, *user) {
+		result.Challenge, err = s.challenge(ctx, *user)
+		if err == nil {
+			logger.FromContext(ctx).Info("login waits for the second factor", "user_id", user.User_id)
+		}
+		return result, err
+	}
+
+	result.User, err = s.signIn(ctx, *user)
+	return result, err
+}
+
+// signIn issues the user a new pair of tokens, returned on the user. It is only called once every
+// factor the user needs has been checked.
+func (s *UserService) signIn(ctx context.Context, user models.User) (models.User, error) {
+	token, refreshToken, err := helper.GenerateAllTokens(s.keys, user.Email, user.First_name, user.Last_name, user.User_id, user.Token_generation)
+	if err != nil {
+		return user, fmt.Errorf("signing the tokens: %w", err)
+	}
+	if err := helper.UpdateAllTokens(ctx, s.repos.Users, token, refreshToken, user.User_id); err != nil {
+		return user, fmt.Errorf("storing the tokens: %w", err)
+	}
+	logger.FromContext(ctx).Info("login succeeded", "user_id", user.User_id)
+	s.recordAudit(ctx, user.User_id, "user", user.User_id, "LOGIN", user, Snapshot(ctx, s.repos.Users, bson.M{"user_id": user.User_id}))
+
+	user.Token = token
+	user.Refresh_Token = refreshToken
+	return user, nil
+}
+
+// Unlock lifts the lockout of the user's account and forgets its failed logins, PINs and codes. uid must be a
+// MANAGER or ADMIN. Locked out addresses are not affected; they unlock when their lock expires.
+func (s *UserService) Unlock(ctx context.Context, uid string, userId string) error {
+	if err := s.requireManager(ctx, uid, "unlocking an account"); err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := s.repos.Users.FindOne(ctx, bson.M{"user_id": userId, "deleted_at": nil}).Decode(&user); err != nil {
+		return lookupFailed(err, "user")
+	}
+
+	filter := bson.M{"key": emailKey(user.Email)}
+	before := Snapshot(ctx, s.repos.LoginAttempts, filter)
+	if _, err := s.repos.LoginAttempts.DeleteOne(ctx, filter); err != nil {
+		return err
+	}
+	s.clearFailures(ctx, pinKey(user.User_id))
+	s.clearFailures(ctx, mfaKey(user.User_id))
+	logger.FromContext(ctx).Info("account unlocked", "user_id", user.User_id)
+	s.recordAudit(ctx, uid, "user", user.User_id, "ACCOUNT_UNLOCKED", before, nil)
+	return nil
+}
+
+// SetPin sets the PIN the user signs in with on terminals, after checking their password. PINs
+// are checked on every switch of users, so they are hashed at bcrypt's default cost rather than
+// the password's.
+func (s *UserService) SetPin(ctx context.Context, uid string, password string, pin string) error {
+	var user models.User
+
+	filter := bson.M{"user_id": uid, "deleted_at": nil}
+	if err := s.repos.Users.FindOne(ctx, filter).Decode(&user); err != nil {
+		return lookupFailed(err, "user")
+	}
+	if passwordIsValid, msg := VerifyPassword(password, user.Password); !passwordIsValid {
+		return errorf(Forbidden, "%s", msg)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("hashing the PIN: %w", err)
+	}
+
+	_, err = s.repos.Users.UpdateOne(
+		ctx,
+		filter,
+		bson.D{
+			{"$set", bson.D{{"pin", string(hash)}, {"updated_at", time.Now()}}},
+			{"$inc", bson.D{{"version", 1}}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	s.clearFailures(ctx, pinKey(uid))
+	s.recordAudit(ctx, uid, "user", uid, "SET_PIN", user, Snapshot(ctx, s.repos.Users, filter))
+	return nil
+}
+
+// Approval is the sign-off of a manager on a void or refund. Code is the manager's second factor,
+// needed when they use MFA. IP is the client's address, or empty when there is none.
+type Approval struct {
+	Email    string
+	Password string
+	Code     string
+	IP       string
+}
+
+// AuthorizeManager checks the credentials of the manager approving a void or refund. Wrong
+// passwords are throttled per email and per address like failed logins, see LoginPolicy. Anything
+// short of a MANAGER or ADMIN with the right password, and code if they use MFA, is the same
+// Forbidden error, so approvals can't be used to find out which emails belong to managers.
+func (s *UserService) AuthorizeManager(ctx context.Context, approval Approval) (models.User, error) {
+	var manager models.User
+	denied := errorf(Forbidden, "manager approval was denied")
+	keys := loginKeys(approval.Email, approval.IP)
+	event := bson.M{"email": approval.Email, "ip": approval.IP, "approval": true}
+
+	if err := s.checkThrottle(ctx, keys...); err != nil {
+		logger.FromContext(ctx).Warn("approval throttled", "error", err)
+		return manager, err
+	}
+
+	err := s.repos.Users.FindOne(ctx, bson.M{"email": approval.Email, "deleted_at": nil}).Decode(&manager)
+	if err != nil {
+		logger.FromContext(ctx).Warn("approval denied", "reason", "unknown email")
+		return manager, s.loginFailed(ctx, "APPROVAL_FAILED", "", event, keys, denied)
+	}
+
+	if passwordIsValid, _ := VerifyPassword(approval.Password, manager.Password); !passwordIsValid {
+		logger.FromContext(ctx).Warn("approval denied", "reason", "wrong password", "user_id", manager.User_id)
+		return manager, s.loginFailed(ctx, "APPROVAL_FAILED", manager.User_id, event, keys, denied)
+	}
+	s.clearFailures(ctx, emailKey(approval.Email))
+
+	if !isManager(manager) {
+		logger.FromContext(ctx).Warn("approval denied", "reason", "not a manager", "user_id", manager.User_id)
+		return manager, denied
+	}
+
+	if s.needsMfa(ctx, manager) {
+		if manager.Mfa_enabled_at == nil {
+			logger.FromContext(ctx).Warn("approval denied", "reason", "MFA not enabled", "user_id", manager.User_id)
+			return manager, denied
+		}
+		if err := s.checkSecondFactor(ctx, manager, approval.Code, true, event); err != nil {
+			// a wrong code is counted like a wrong password; waiting is still Throttled
+			if errors.Is(err, ErrUnauthorized) {
+				return manager, denied
+			}
+			return manager, err
+		}
+	}
+	return manager, nil
+}
+
+func isManager(user models.User) bool {
+	return user.Role == "MANAGER" || user.Role == "ADMIN"
+}
+
+// unknownUserHash is what a login with an unknown email checks the password against. It has the
+// cost of HashPassword, so it takes as long as checking the password of a real user.
+const unknownUserHash = "$2a$14$WD6vlWH41Qekz3Z75x4QEuL.DsIuSiEspa0pgfqukEAscQzlxTwEy"
+
+func HashPassword(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes), nil
+}
+
+func VerifyPassword(userPassword string, providedPassword string) (bool, string) {
+	err := bcrypt.CompareHashAndPassword([]byte(providedPassword), []byte(userPassword))
+	check := true
+	msg := ""
+
+	if err != nil {
+		msg = fmt.Sprintf("login or password is incorrect")
+		check = false
+	}
+	return check, msg
+}
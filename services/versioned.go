@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+
+	"github.com/mayankr5/v1/restaurant-management/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Precondition is the version a write expects its document to be at. The zero value expects
+// nothing, so the write always goes through.
+type Precondition struct {
+	Version int
+	Set     bool
+}
+
+// Expect makes a precondition for version.
+func Expect(version int) Precondition {
+	return Precondition{Version: version, Set: true}
+}
+
+// updateVersioned $sets update on the document whose idField is id, bumps its version and audits
+// the change as uid. The update only goes through while the document meets expect; otherwise the
+// error is Stale. Updates never create documents, so an unknown id is NotFound, and neither
+// touch deleted ones, which are NotFound too until restored. The returned version is the
+// document's after the call, also when it fails as Stale.
+func (s *base) updateVersioned(ctx context.Context, uid string, collection database.Collection, entity string, idField string, id string, expect Precondition, update primitive.D) (*mongo.UpdateResult, int, error) {
+	filter := bson.M{idField: id, "deleted_at": nil}
+
+	before := Snapshot(ctx, collection, filter)
+	if before == nil {
+		return nil, 0, errorf(NotFound, "%s was not found", entity)
+	}
+
+	guarded := bson.M{idField: id, "deleted_at": nil}
+	if expect.Set {
+		guarded["version"] = versionFilter(expect.Version)
+	}
+
+	result, err := collection.UpdateOne(
+		ctx,
+		guarded,
+		bson.D{
+			{"$set", update},
+			{"$inc", bson.D{{"version", 1}}},
+		},
+	)
+	if err != nil {
+		return nil, 0, writeFailed(err, entity+" conflicts with an existing one")
+	}
+
+	after := Snapshot(ctx, collection, filter)
+	if after == nil {
+		return nil, 0, errorf(NotFound, "%s was not found", entity)
+	}
+	version := documentVersion(after)
+
+	if result.MatchedCount == 0 {
+		return nil, version, errorf(Stale, "%s was changed by someone else, fetch it again and retry", entity)
+	}
+
+	s.recordAudit(ctx, uid, entity, id, "UPDATE", before, after)
+	return result, version, nil
+}
+
+// versionFilter matches documents at version; documents written before versioning count as version 0.
+func versionFilter(version int) interface{} {
+	if version == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return version
+}
+
+func documentVersion(document bson.M) int {
+	switch v := document["version"].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
@@ -0,0 +1,89 @@
+package signing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultIssuer is the issuer and audience of tokens when JWT_ISSUER and JWT_AUDIENCE aren't set.
+const DefaultIssuer = "restaurant-management"
+
+// FromEnv builds the key set from the environment:
+//
+//   - JWT_KEYS_DIR is a directory of PEM files, one key per file, named after the key's id
+//     (e.g. 2026-10.pem). Private keys sign and verify; public keys only verify, for the
+//     previous key while its tokens run out.
+//   - JWT_SIGNING_KEY is the id of the key that signs, the last one in name order by default.
+//   - JWT_ISSUER and JWT_AUDIENCE name this service in the tokens.
+//   - JWT_LEEWAY is the allowed clock skew, a Go duration.
+//
+// Without JWT_KEYS_DIR, tokens are signed with HS256 and SECRET_KEY when it is set. FromEnv
+// returns nil when neither is set.
+func FromEnv() (*KeySet, error) {
+	issuer := envOr("JWT_ISSUER", DefaultIssuer)
+	audience := envOr("JWT_AUDIENCE", DefaultIssuer)
+
+	var keys *KeySet
+	var err error
+	switch dir, secret := os.Getenv("JWT_KEYS_DIR"), os.Getenv("SECRET_KEY"); {
+	case dir != "":
+		keys, err = loadDir(dir, issuer, audience, os.Getenv("JWT_SIGNING_KEY"))
+	case secret != "":
+		keys, err = NewKeySet(issuer, audience, "secret", SecretKey("secret", []byte(secret)))
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if value := os.Getenv("JWT_LEEWAY"); value != "" {
+		leeway, err := time.ParseDuration(value)
+		if err != nil || leeway < 0 {
+			return nil, fmt.Errorf("JWT_LEEWAY %q is not a duration", value)
+		}
+		keys.Leeway = leeway
+	}
+	return keys, nil
+}
+
+// loadDir reads every .pem file in dir as a key named after the file.
+func loadDir(dir string, issuer string, audience string, signingId string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no .pem keys in %s", dir)
+	}
+	sort.Strings(paths)
+
+	var keys []Key
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseKey(strings.TrimSuffix(filepath.Base(path), ".pem"), data)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if signingId == "" {
+		signingId = keys[len(keys)-1].ID
+	}
+	return NewKeySet(issuer, audience, signingId, keys...)
+}
+
+func envOr(name string, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
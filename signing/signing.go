@@ -0,0 +1,236 @@
+// Package signing holds the keys tokens are signed and verified with. A KeySet signs with one key
+// and verifies with every key it holds, so a key can be rotated out without signing anyone out:
+// the next key is added first, then made the signing key, and the old one is removed once the
+// tokens it signed have expired. The public keys are published as a JWKS for other services.
+package signing
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+	// HS256 signs with a shared secret. It is only meant for deployments that still sign with
+	// SECRET_KEY; its key is never published.
+	HS256 = "HS256"
+)
+
+// DefaultLeeway is how far the clocks of the servers signing and verifying tokens may drift apart.
+const DefaultLeeway = 30 * time.Second
+
+// Key is a key tokens are signed or verified with. Keys parsed from a public key only verify.
+type Key struct {
+	ID        string
+	Algorithm string
+	private   crypto.PrivateKey
+	public    crypto.PublicKey
+}
+
+// ParseKey reads a PEM encoded RSA or Ed25519 key: a PKCS #8 private key, which can sign, or a
+// PKIX public key, which can only verify.
+func ParseKey(id string, data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, fmt.Errorf("key %s: no PEM data", id)
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("key %s: %w", id, err)
+		}
+		switch private := private.(type) {
+		case *rsa.PrivateKey:
+			return Key{ID: id, Algorithm: RS256, private: private, public: &private.PublicKey}, nil
+		case ed25519.PrivateKey:
+			return Key{ID: id, Algorithm: EdDSA, private: private, public: private.Public()}, nil
+		}
+	case "PUBLIC KEY":
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("key %s: %w", id, err)
+		}
+		switch public := public.(type) {
+		case *rsa.PublicKey:
+			return Key{ID: id, Algorithm: RS256, public: public}, nil
+		case ed25519.PublicKey:
+			return Key{ID: id, Algorithm: EdDSA, public: public}, nil
+		}
+	}
+	return Key{}, fmt.Errorf("key %s: %s is not an RSA or Ed25519 key", id, block.Type)
+}
+
+// SecretKey is an HS256 key with a shared secret.
+func SecretKey(id string, secret []byte) Key {
+	return Key{ID: id, Algorithm: HS256, private: secret, public: secret}
+}
+
+// GenerateKey returns a new Ed25519 key.
+func GenerateKey(id string) (Key, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{ID: id, Algorithm: EdDSA, private: private, public: public}, nil
+}
+
+// canSign tells whether the key has its private part.
+func (k Key) canSign() bool {
+	return k.private != nil
+}
+
+func (k Key) method() jwt.SigningMethod {
+	switch k.Algorithm {
+	case RS256:
+		return jwt.SigningMethodRS256
+	case EdDSA:
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodHS256
+}
+
+// KeySet signs tokens with its signing key and verifies them with any of its keys. Tokens carry
+// Issuer and Audience, which verification checks.
+type KeySet struct {
+	Issuer   string
+	Audience string
+	// Leeway is the clock skew allowed when checking when a token was issued and expires.
+	Leeway  time.Duration
+	signing Key
+	keys    map[string]Key
+}
+
+// NewKeySet returns a key set signing with the key named signingId, which has to be one of keys
+// and have its private part.
+func NewKeySet(issuer string, audience string, signingId string, keys ...Key) (*KeySet, error) {
+	set := &KeySet{Issuer: issuer, Audience: audience, Leeway: DefaultLeeway, keys: map[string]Key{}}
+	for _, key := range keys {
+		if _, ok := set.keys[key.ID]; ok {
+			return nil, fmt.Errorf("key %s is given twice", key.ID)
+		}
+		set.keys[key.ID] = key
+	}
+
+	signing, ok := set.keys[signingId]
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is not in the key set", signingId)
+	}
+	if !signing.canSign() {
+		return nil, fmt.Errorf("signing key %s has no private key", signingId)
+	}
+	set.signing = signing
+	return set, nil
+}
+
+// Generate returns a key set with a new Ed25519 key, for tests and for servers without
+// configured keys. Its tokens stop working when the process ends.
+func Generate(issuer string, audience string) (*KeySet, error) {
+	key, err := GenerateKey("generated")
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(issuer, audience, key.ID, key)
+}
+
+// SigningAlgorithm is the algorithm new tokens are signed with.
+func (s *KeySet) SigningAlgorithm() string {
+	return s.signing.Algorithm
+}
+
+// Sign signs claims with the signing key, naming it in the kid header.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.method(), claims)
+	token.Header["kid"] = s.signing.ID
+	return token.SignedString(s.signing.private)
+}
+
+// Parse verifies token into claims. The token has to name one of the keys in its kid header
+// and be signed with that key's algorithm, be issued by Issuer for Audience, and have an expiry.
+func (s *KeySet) Parse(token string, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(
+		token,
+		claims,
+		func(token *jwt.Token) (interface{}, error) {
+			id, _ := token.Header["kid"].(string)
+			key, ok := s.keys[id]
+			if !ok {
+				return nil, fmt.Errorf("unknown key %q", id)
+			}
+			// a token can't pick how it is checked, e.g. HS256 with a public RSA key as the secret
+			if token.Method.Alg() != key.Algorithm {
+				return nil, fmt.Errorf("key %s is for %s, not %s", id, key.Algorithm, token.Method.Alg())
+			}
+			return key.public, nil
+		},
+		jwt.WithValidMethods(s.algorithms()),
+		jwt.WithIssuer(s.Issuer),
+		jwt.WithAudience(s.Audience),
+		jwt.WithLeeway(s.Leeway),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
+	return err
+}
+
+func (s *KeySet) algorithms() []string {
+	var algorithms []string
+	seen := map[string]bool{}
+	for _, key := range s.keys {
+		if !seen[key.Algorithm] {
+			seen[key.Algorithm] = true
+			algorithms = append(algorithms, key.Algorithm)
+		}
+	}
+	return algorithms
+}
+
+// JSONWebKey is a public key as published in a JWKS (RFC 7517).
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// N and E are the modulus and exponent of RSA keys.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Crv and X are the curve and public key of Ed25519 keys.
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JSONWebKeySet is the document served at /.well-known/jwks.json.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public keys of the set, ordered by id. Secret keys are left out.
+func (s *KeySet) JWKS() JSONWebKeySet {
+	jwks := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range s.keys {
+		encode := base64.RawURLEncoding.EncodeToString
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JSONWebKey{Kty: "RSA", Kid: key.ID, Alg: key.Algorithm, Use: "sig",
+				N: encode(public.N.Bytes()), E: encode(big.NewInt(int64(public.E)).Bytes())})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JSONWebKey{Kty: "OKP", Kid: key.ID, Alg: key.Algorithm, Use: "sig",
+				Crv: "Ed25519", X: encode(public)})
+		}
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].Kid < jwks.Keys[j].Kid })
+	return jwks
+}
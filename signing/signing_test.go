@@ -0,0 +1,153 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func pemKey(t *testing.T, key interface{}, public bool) []byte {
+	t.Helper()
+	var der []byte
+	var err error
+	kind := "PRIVATE KEY"
+	if public {
+		kind = "PUBLIC KEY"
+		der, err = x509.MarshalPKIXPublicKey(key)
+	} else {
+		der, err = x509.MarshalPKCS8PrivateKey(key)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der})
+}
+
+func claims(issuer string, audience string, expiresIn time.Duration) jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		Issuer:    issuer,
+		Subject:   "user",
+		Audience:  jwt.ClaimStrings{audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+	}
+}
+
+func TestRotatedKeysVerifyUntilTheyAreRemoved(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := ParseKey("2026-09", pemKey(t, rsaKey, false))
+	if err != nil || old.Algorithm != RS256 {
+		t.Fatalf("RSA key = %+v, %v", old, err)
+	}
+	oldPublic, err := ParseKey("2026-09", pemKey(t, &rsaKey.PublicKey, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	next, err := ParseKey("2026-10", pemKey(t, edKey, false))
+	if err != nil || next.Algorithm != EdDSA {
+		t.Fatalf("Ed25519 key = %+v, %v", next, err)
+	}
+
+	before, err := NewKeySet("iss", "aud", "2026-09", old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := before.Sign(claims("iss", "aud", time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewKeySet("iss", "aud", "2026-09", oldPublic, next); err == nil {
+		t.Error("a public key can't sign")
+	}
+	during, err := NewKeySet("iss", "aud", "2026-10", oldPublic, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := during.Parse(token, &jwt.RegisteredClaims{}); err != nil {
+		t.Errorf("token of the previous key during the overlap: %v", err)
+	}
+	if jwks := during.JWKS(); len(jwks.Keys) != 2 || jwks.Keys[0].Kty != "RSA" || jwks.Keys[1].Crv != "Ed25519" {
+		t.Errorf("jwks = %+v, want both keys", jwks)
+	}
+
+	after, _ := NewKeySet("iss", "aud", "2026-10", next)
+	if err := after.Parse(token, &jwt.RegisteredClaims{}); err == nil {
+		t.Error("token of a removed key was accepted")
+	}
+}
+
+func TestParseRejectsForeignAlgorithmsIssuersAndExpiredTokens(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	key, _ := ParseKey("rsa", pemKey(t, rsaKey, false))
+	keys, _ := NewKeySet("iss", "aud", "rsa", key)
+
+	// HS256 keyed with the public RSA key, which anyone can fetch from the JWKS
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims("iss", "aud", time.Hour))
+	forged.Header["kid"] = "rsa"
+	signed, _ := forged.SignedString(pemKey(t, &rsaKey.PublicKey, true))
+	if err := keys.Parse(signed, &jwt.RegisteredClaims{}); err == nil {
+		t.Error("HS256 token keyed with the public key was accepted")
+	}
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, claims("iss", "aud", time.Hour))
+	unsigned.Header["kid"] = "rsa"
+	signed, _ = unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err := keys.Parse(signed, &jwt.RegisteredClaims{}); err == nil {
+		t.Error("unsigned token was accepted")
+	}
+
+	for name, c := range map[string]jwt.RegisteredClaims{
+		"another issuer":   claims("other", "aud", time.Hour),
+		"another audience": claims("iss", "other", time.Hour),
+		"expired":          claims("iss", "aud", -time.Minute),
+	} {
+		signed, _ := keys.Sign(c)
+		if err := keys.Parse(signed, &jwt.RegisteredClaims{}); err == nil {
+			t.Errorf("%s: token was accepted", name)
+		}
+	}
+
+	// a token that expired within the leeway still works
+	signed, _ = keys.Sign(claims("iss", "aud", -10*time.Second))
+	if err := keys.Parse(signed, &jwt.RegisteredClaims{}); err != nil {
+		t.Errorf("token within the leeway: %v", err)
+	}
+}
+
+func TestFromEnvSignsWithTheLastKeyByDefault(t *testing.T) {
+	dir := t.TempDir()
+	for _, id := range []string{"2026-09", "2026-10"} {
+		_, private, _ := ed25519.GenerateKey(rand.Reader)
+		if err := os.WriteFile(filepath.Join(dir, id+".pem"), pemKey(t, private, false), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("JWT_KEYS_DIR", dir)
+	t.Setenv("JWT_LEEWAY", "5s")
+
+	keys, err := FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.signing.ID != "2026-10" || keys.Leeway != 5*time.Second || keys.Issuer != DefaultIssuer {
+		t.Errorf("keys sign with %s, leeway %v, issuer %s", keys.signing.ID, keys.Leeway, keys.Issuer)
+	}
+
+	t.Setenv("JWT_SIGNING_KEY", "2026-09")
+	if keys, err := FromEnv(); err != nil || keys.signing.ID != "2026-09" {
+		t.Errorf("JWT_SIGNING_KEY: %v", err)
+	}
+}
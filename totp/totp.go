@@ -0,0 +1,92 @@
+// Package totp implements the time-based one-time passwords of RFC 6238 the way authenticator
+// apps use them: HMAC-SHA1, 6 digits and 30 second steps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Digits is the length of a code.
+	Digits = 6
+	// Period is how long a code is valid.
+	Period = 30 * time.Second
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random 160 bit secret, base32 encoded as authenticator apps expect it.
+func NewSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(secret), nil
+}
+
+// URI is the otpauth:// provisioning URI of the secret. Shown as a QR code, it lets an
+// authenticator app add the account under issuer.
+func URI(secret string, issuer string, account string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(int(Period.Seconds())))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Step is the number of the time step at falls in.
+func Step(at time.Time) int64 {
+	return at.Unix() / int64(Period.Seconds())
+}
+
+// Code is the code of the secret for the time step.
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", fmt.Errorf("totp: decoding the secret: %w", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%1000000), nil
+}
+
+// Validate checks the code against the steps from skew before to skew after the one at falls in,
+// to allow for clocks that are a little off. It returns the step that matched, so callers can
+// refuse a code that was already used.
+func Validate(secret string, code string, at time.Time, skew int) (int64, bool) {
+	if len(code) != Digits {
+		return 0, false
+	}
+	current := Step(at)
+	for offset := -int64(skew); offset <= int64(skew); offset++ {
+		want, err := Code(secret, current+offset)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return current + offset, true
+		}
+	}
+	return 0, false
+}
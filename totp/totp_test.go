@@ -0,0 +1,65 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the test vectors in RFC 6238 appendix B.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodesMatchTheRFCVectors(t *testing.T) {
+	// the RFC lists 8 digit codes; the 6 digit ones are their last six digits
+	for unix, want := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		got, err := Code(rfcSecret, Step(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("code at %d = %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestValidateAllowsOneStepOfSkew(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	previous, _ := Code(rfcSecret, Step(at)-1)
+	stale, _ := Code(rfcSecret, Step(at)-2)
+
+	if step, ok := Validate(rfcSecret, previous, at, 1); !ok || step != Step(at)-1 {
+		t.Errorf("previous step: step %d, ok %v, want %d", step, ok, Step(at)-1)
+	}
+	if _, ok := Validate(rfcSecret, stale, at, 1); ok {
+		t.Error("a code from two steps ago was accepted")
+	}
+	if _, ok := Validate(rfcSecret, "12345", at, 1); ok {
+		t.Error("a short code was accepted")
+	}
+}
+
+func TestURIProvisionsTheSecret(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := url.Parse(URI(secret, "Trattoria", "ada@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" || parsed.Path != "/Trattoria:ada@example.com" {
+		t.Errorf("uri = %s, want otpauth://totp/Trattoria:ada@example.com", parsed)
+	}
+	if got := parsed.Query().Get("secret"); got != secret {
+		t.Errorf("secret = %q, want %q", got, secret)
+	}
+}